@@ -1,16 +1,20 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/bytedance/sonic"
+	"github.com/freekieb7/gopenehr/internal/attachment"
 	"github.com/freekieb7/gopenehr/internal/audit"
 	"github.com/freekieb7/gopenehr/internal/cli"
 	"github.com/freekieb7/gopenehr/internal/config"
@@ -24,6 +28,7 @@ import (
 	"github.com/freekieb7/gopenehr/internal/webhook"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/compress"
+	"github.com/google/uuid"
 	_ "go.uber.org/automaxprocs/maxprocs"
 )
 
@@ -44,6 +49,8 @@ func run(ctx context.Context, args []string) error {
 		fmt.Println("  serve          - Start the web server")
 		fmt.Println("  migrate [cmd]  - Run database migrations (up/down)")
 		fmt.Println("  seed [count]   - Seed the database with test data (default count: 1000)")
+		fmt.Println("  export <ehr_id> [json|zip] [file] - Export an EHR as a gopenehr export document (default: json to stdout)")
+		fmt.Println("  import <file>  - Import an EHR from a gopenehr export document or zip archive")
 		fmt.Println("  healthcheck    - Check if the server is healthy")
 		fmt.Println("  version        - Show version information")
 		return nil
@@ -56,6 +63,10 @@ func run(ctx context.Context, args []string) error {
 		return runMigrate(ctx, args[1:])
 	case "seed":
 		return runSeed(ctx, args[1:])
+	case "export":
+		return runExport(ctx, args[1:])
+	case "import":
+		return runImport(ctx, args[1:])
 	case "healthcheck":
 		return runHealthcheck(ctx)
 	case "version":
@@ -115,12 +126,18 @@ func runServer(ctx context.Context) error {
 		// Enable SO_REUSEPORT for better load balancing across CPU cores
 		EnableSplittingOnParsers: true,
 
+		// Stream request bodies larger than the body limit, so attachment uploads are not held in memory
+		StreamRequestBody: true,
+
 		JSONEncoder: sonic.Marshal,
 		JSONDecoder: sonic.Unmarshal,
 	})
 
-	// Compression middleware
+	// Compression middleware, attachment downloads are streamed as is so range requests keep working
 	srv.Use(compress.New(compress.Config{
+		Next: func(c *fiber.Ctx) bool {
+			return strings.Contains(c.Path(), "/attachment/")
+		},
 		Level: compress.LevelBestSpeed,
 	}))
 
@@ -136,6 +153,21 @@ func runServer(ctx context.Context) error {
 		Timeout: 10 * time.Second,
 	})
 
+	// Attachment store
+	var attachmentStore attachment.Store
+	switch settings.AttachmentStore {
+	case config.AttachmentStoreS3:
+		s3Store, err := attachment.NewS3Store(settings.AttachmentS3.Endpoint, settings.AttachmentS3.Region, settings.AttachmentS3.Bucket, settings.AttachmentS3.AccessKey, settings.AttachmentS3.SecretKey, &http.Client{
+			Timeout: 5 * time.Minute,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create S3 attachment store: %w", err)
+		}
+		attachmentStore = s3Store
+	default:
+		attachmentStore = attachment.NewFileStore(settings.AttachmentDir)
+	}
+
 	// Services
 	healthChecker := health.NewChecker(settings.Version, db)
 	auditService := audit.NewService(tel.Logger, db)
@@ -144,6 +176,18 @@ func runServer(ctx context.Context) error {
 	openEHRService := openehr.NewService(tel.Logger, db)
 	tenantService := tenant.NewService(db)
 
+	// Standing query evaluator
+	standingQueryEvaluator := openehr.NewStandingQueryEvaluator(tel, openEHRService, webhookSink)
+
+	// Cohort refresher
+	cohortRefresher := openehr.NewCohortRefresher(tel.Logger, openEHRService)
+
+	// Report scheduler
+	reportScheduler := openehr.NewReportScheduler(tel.Logger, openEHRService, webhookService, settings.ReportOutputDir)
+
+	// EHR purger
+	ehrPurger := openehr.NewEHRPurger(tel.Logger, openEHRService, auditSink, webhookSink, attachmentStore, settings.EHRDeletionGracePeriod)
+
 	// Routes
 	healthHandler := health.NewHandler(tel.Logger, healthChecker)
 	healthHandler.RegisterRoutes(srv)
@@ -154,7 +198,7 @@ func runServer(ctx context.Context) error {
 	webhookHandler := webhook.NewHandler(settings, tel, auditSink, oauthService, webhookService)
 	webhookHandler.RegisterRoutes(srv)
 
-	openEHRHandler := openehr.NewHandler(settings, tel, openEHRService, auditService, webhookService, auditSink, webhookSink, oauthService)
+	openEHRHandler := openehr.NewHandler(settings, tel, openEHRService, auditService, webhookService, auditSink, webhookSink, oauthService, standingQueryEvaluator, attachmentStore)
 	openEHRHandler.RegisterRoutes(srv)
 
 	tenantsHandler := tenant.NewHandler(settings, tel, tenantService, oauthService, auditSink)
@@ -180,6 +224,9 @@ func runServer(ctx context.Context) error {
 	// Start webhook sink
 	webhookSink.Start(ctx)
 
+	// Start standing query evaluator
+	standingQueryEvaluator.Start(ctx)
+
 	// Start server
 	go func() {
 		tel.Logger.InfoContext(ctx, "Starting server", "port", settings.Port)
@@ -197,6 +244,33 @@ func runServer(ctx context.Context) error {
 		}
 	}()
 
+	// Start cohort refresher
+	go func() {
+		tel.Logger.InfoContext(ctx, "Starting cohort refresher")
+		err := cohortRefresher.Start(ctx)
+		if err != nil {
+			tel.Logger.ErrorContext(ctx, "Cohort refresher error", "error", err)
+		}
+	}()
+
+	// Start report scheduler
+	go func() {
+		tel.Logger.InfoContext(ctx, "Starting report scheduler")
+		err := reportScheduler.Start(ctx)
+		if err != nil {
+			tel.Logger.ErrorContext(ctx, "Report scheduler error", "error", err)
+		}
+	}()
+
+	// Start EHR purger
+	go func() {
+		tel.Logger.InfoContext(ctx, "Starting EHR purger")
+		err := ehrPurger.Start(ctx)
+		if err != nil {
+			tel.Logger.ErrorContext(ctx, "EHR purger error", "error", err)
+		}
+	}()
+
 	// Wait for termination signal or server error
 	select {
 	case sig := <-stopChan:
@@ -316,3 +390,111 @@ func runSeed(ctx context.Context, args []string) error {
 	seeder.Seed(count)
 	return nil
 }
+
+func runExport(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: gopenehr export <ehr_id> [json|zip] [file]")
+	}
+
+	ehrID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid EHR ID: %w", err)
+	}
+
+	format := openehr.EHRExtractFormatJSON
+	if len(args) >= 2 {
+		format = args[1]
+	}
+	if format != openehr.EHRExtractFormatJSON && format != openehr.EHRExtractFormatZip {
+		return fmt.Errorf("invalid format: %s", format)
+	}
+
+	// Load config
+	settings := config.NewSettings()
+	if err := settings.Load(); err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Init database
+	db := database.New()
+	if err := db.Connect(ctx, settings.DatabaseURL); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	// Log to stderr, the extract itself may be written to stdout
+	logger := &telemetry.Logger{Logger: slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))}
+
+	out := os.Stdout
+	if len(args) >= 3 {
+		out, err = os.Create(args[2])
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer func() {
+			if err := out.Close(); err != nil {
+				logger.ErrorContext(ctx, "Failed to close output file", "error", err)
+			}
+		}()
+	}
+
+	w := bufio.NewWriter(out)
+	var writer openehr.EHRExtractWriter = openehr.NewEHRExtractJSONWriter(w)
+	if format == openehr.EHRExtractFormatZip {
+		writer = openehr.NewEHRExtractZipWriter(w)
+	}
+
+	service := openehr.NewService(logger, db)
+	if err := service.ExportEHR(ctx, ehrID, writer); err != nil {
+		return fmt.Errorf("failed to export EHR: %w", err)
+	}
+
+	return w.Flush()
+}
+
+func runImport(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: gopenehr import <file>")
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read extract: %w", err)
+	}
+
+	// Zip archives start with a local file header
+	format := openehr.EHRExtractFormatJSON
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		format = openehr.EHRExtractFormatZip
+	}
+
+	items, err := openehr.ReadEHRExtract(data, format)
+	if err != nil {
+		return fmt.Errorf("failed to read extract: %w", err)
+	}
+
+	// Load config
+	settings := config.NewSettings()
+	if err := settings.Load(); err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Init database
+	db := database.New()
+	if err := db.Connect(ctx, settings.DatabaseURL); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	logger := &telemetry.Logger{Logger: slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))}
+
+	// Imported outside of any tenant, like the seeded EHRs
+	service := openehr.NewService(logger, db)
+	result, err := service.ImportEHR(ctx, uuid.Nil, items)
+	if err != nil {
+		return fmt.Errorf("failed to import EHR: %w", err)
+	}
+
+	logger.InfoContext(ctx, "EHR imported", "ehr_id", result.EHRID, "versioned_objects", result.VersionedObjectCount, "versions", result.VersionCount, "contributions", result.ContributionCount, "tags", result.TagCount)
+	return nil
+}
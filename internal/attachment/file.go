@@ -0,0 +1,114 @@
+package attachment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var _ Store = (*FileStore)(nil)
+
+// FileStore keeps attachment content on the local filesystem, spread over sub-directories named after the first two characters of the hash
+type FileStore struct {
+	Dir string
+}
+
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{
+		Dir: dir,
+	}
+}
+
+func (s *FileStore) path(hash string) string {
+	return filepath.Join(s.Dir, hash[:2], hash)
+}
+
+func (s *FileStore) Put(ctx context.Context, hash string, content io.Reader, size int64) error {
+	if !ValidHash(hash) {
+		return fmt.Errorf("invalid attachment hash: %q", hash)
+	}
+
+	path := s.path(hash)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create attachment directory: %w", err)
+	}
+
+	// Write next to the final file and rename, so a partially written file is never served
+	tmp, err := os.CreateTemp(filepath.Dir(path), hash+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create attachment file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	written, err := io.Copy(tmp, content)
+	if err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write attachment file: %w", err)
+	}
+	if written != size {
+		_ = tmp.Close()
+		return fmt.Errorf("attachment size mismatch: expected %d bytes, got %d", size, written)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to sync attachment file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close attachment file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to move attachment file into place: %w", err)
+	}
+
+	return nil
+}
+
+func (s *FileStore) Get(ctx context.Context, hash string, offset, length int64) (io.ReadCloser, error) {
+	if !ValidHash(hash) {
+		return nil, ErrNotFound
+	}
+
+	f, err := os.Open(s.path(hash))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to open attachment file: %w", err)
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to seek attachment file: %w", err)
+	}
+
+	if length < 0 {
+		return f, nil
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(f, length), f}, nil
+}
+
+func (s *FileStore) Delete(ctx context.Context, hash string) error {
+	if !ValidHash(hash) {
+		return nil
+	}
+
+	if err := os.Remove(s.path(hash)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete attachment file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package attachment
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("attachment content not found")
+
+// Store keeps attachment content addressed by the hex encoded SHA-256 hash of the content,
+// so the same content uploaded twice is only stored once
+type Store interface {
+	// Put stores the content under its hash, putting content that is already stored is a no-op
+	Put(ctx context.Context, hash string, content io.Reader, size int64) error
+	// Get opens the content from offset on, reading at most length bytes, a negative length reads until the end
+	Get(ctx context.Context, hash string, offset, length int64) (io.ReadCloser, error)
+	// Delete removes the content, deleting content that is not stored is a no-op
+	Delete(ctx context.Context, hash string) error
+}
+
+// ValidHash reports if the hash is a lowercase hex encoded SHA-256 hash, which is safe to use as file name or object key
+func ValidHash(hash string) bool {
+	if len(hash) != 64 {
+		return false
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return false
+	}
+	for _, r := range hash {
+		if r >= 'A' && r <= 'F' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,157 @@
+package attachment
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func hashOf(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+	content := []byte("0123456789abcdefghij")
+	hash := hashOf(content)
+
+	if _, err := store.Get(ctx, hash, 0, -1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound before put, got %v", err)
+	}
+
+	if err := store.Put(ctx, hash, bytes.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	// Putting the same content again is a no-op
+	if err := store.Put(ctx, hash, bytes.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("second put failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		offset   int64
+		length   int64
+		expected string
+	}{
+		{"whole content", 0, -1, "0123456789abcdefghij"},
+		{"range", 5, 4, "5678"},
+		{"open ended range", 15, -1, "fghij"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := store.Get(ctx, hash, tt.offset, tt.length)
+			if err != nil {
+				t.Fatalf("get failed: %v", err)
+			}
+			defer r.Close()
+
+			data, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, data)
+			}
+		})
+	}
+
+	if err := store.Delete(ctx, hash); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := store.Get(ctx, hash, 0, -1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+	if err := store.Delete(ctx, hash); err != nil {
+		t.Fatalf("delete of missing content failed: %v", err)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	testStore(t, NewFileStore(t.TempDir()))
+}
+
+func TestFileStoreRejectsSizeMismatch(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	content := []byte("content")
+
+	err := store.Put(context.Background(), hashOf(content), bytes.NewReader(content), int64(len(content))+1)
+	if err == nil {
+		t.Fatal("expected size mismatch error")
+	}
+	if _, err := store.Get(context.Background(), hashOf(content), 0, -1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected no content after failed put, got %v", err)
+	}
+}
+
+// fakeS3 serves objects of a single bucket from memory, honouring single byte ranges
+func fakeS3(t *testing.T, bucket string) *httptest.Server {
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") {
+			t.Errorf("missing signature on %s %s", r.Method, r.URL.Path)
+		}
+
+		key, ok := strings.CutPrefix(r.URL.Path, "/"+bucket+"/")
+		if !ok {
+			http.Error(w, "NoSuchBucket", http.StatusNotFound)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.Method {
+		case http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			if r.Header.Get("x-amz-content-sha256") != hashOf(data) {
+				http.Error(w, "XAmzContentSHA256Mismatch", http.StatusBadRequest)
+				return
+			}
+			objects[key] = data
+		case http.MethodGet:
+			data, ok := objects[key]
+			if !ok {
+				http.Error(w, "NoSuchKey", http.StatusNotFound)
+				return
+			}
+			http.ServeContent(w, r, key, time.Time{}, bytes.NewReader(data))
+		case http.MethodDelete:
+			delete(objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+}
+
+func TestS3Store(t *testing.T) {
+	server := fakeS3(t, "attachments")
+	defer server.Close()
+
+	store, err := NewS3Store(server.URL, "", "attachments", "access", "secret", server.Client())
+	if err != nil {
+		t.Fatalf("failed to create S3 store: %v", err)
+	}
+	testStore(t, store)
+}
+
+func TestValidHash(t *testing.T) {
+	if !ValidHash(hashOf([]byte("content"))) {
+		t.Error("expected SHA-256 hex hash to be valid")
+	}
+	for _, hash := range []string{"", "../etc/passwd", strings.ToUpper(hashOf([]byte("content"))), hashOf([]byte("content"))[:63]} {
+		if ValidHash(hash) {
+			t.Errorf("expected %q to be invalid", hash)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -27,6 +28,22 @@ const (
 	Production  Environment = "production"
 )
 
+type AttachmentStoreType string
+
+const (
+	AttachmentStoreFilesystem AttachmentStoreType = "filesystem"
+	AttachmentStoreS3         AttachmentStoreType = "s3"
+)
+
+func (t AttachmentStoreType) IsValid() bool {
+	switch t {
+	case AttachmentStoreFilesystem, AttachmentStoreS3:
+		return true
+	default:
+		return false
+	}
+}
+
 func (e Environment) IsValid() bool {
 	switch e {
 	case Development, Production:
@@ -49,6 +66,22 @@ type Settings struct {
 	OtelInsecure        bool
 	KafkaBrokers        []string
 	CapEHRs             int
+	ReportOutputDir     string
+	// EHRDeletionGracePeriod is how long a deleted EHR can be restored before it is purged
+	EHRDeletionGracePeriod time.Duration
+	AttachmentStore        AttachmentStoreType
+	AttachmentDir          string
+	// AttachmentMaxSize is the largest attachment in bytes that can be uploaded
+	AttachmentMaxSize int64
+	AttachmentS3      AttachmentS3Settings
+}
+
+type AttachmentS3Settings struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
 }
 
 func NewSettings() *Settings {
@@ -131,6 +164,56 @@ func (s *Settings) Load() error {
 		return err
 	}
 	s.CapEHRs = int(capEHRs)
+
+	reportOutputDir, err := getEnvString("REPORT_OUTPUT_DIR", "reports", false)
+	if err != nil {
+		return err
+	}
+	s.ReportOutputDir = reportOutputDir
+
+	ehrDeletionGracePeriod, err := getEnvDuration("EHR_DELETION_GRACE_PERIOD", 30*24*time.Hour, false)
+	if err != nil {
+		return err
+	}
+	s.EHRDeletionGracePeriod = ehrDeletionGracePeriod
+
+	attachmentStore, err := getEnvString("ATTACHMENT_STORE", string(AttachmentStoreFilesystem), false)
+	if err != nil {
+		return err
+	}
+	s.AttachmentStore = AttachmentStoreType(attachmentStore)
+	if !s.AttachmentStore.IsValid() {
+		return fmt.Errorf("environment variable ATTACHMENT_STORE has invalid value: %s", attachmentStore)
+	}
+
+	attachmentDir, err := getEnvString("ATTACHMENT_DIR", "attachments", false)
+	if err != nil {
+		return err
+	}
+	s.AttachmentDir = attachmentDir
+
+	attachmentMaxSize, err := getEnvUint64("ATTACHMENT_MAX_SIZE", 512<<20, false)
+	if err != nil {
+		return err
+	}
+	s.AttachmentMaxSize = int64(attachmentMaxSize)
+
+	s3Required := s.AttachmentStore == AttachmentStoreS3
+	if s.AttachmentS3.Endpoint, err = getEnvString("ATTACHMENT_S3_ENDPOINT", "", s3Required); err != nil {
+		return err
+	}
+	if s.AttachmentS3.Region, err = getEnvString("ATTACHMENT_S3_REGION", "us-east-1", false); err != nil {
+		return err
+	}
+	if s.AttachmentS3.Bucket, err = getEnvString("ATTACHMENT_S3_BUCKET", "", s3Required); err != nil {
+		return err
+	}
+	if s.AttachmentS3.AccessKey, err = getEnvString("ATTACHMENT_S3_ACCESS_KEY", "", s3Required); err != nil {
+		return err
+	}
+	if s.AttachmentS3.SecretKey, err = getEnvString("ATTACHMENT_S3_SECRET_KEY", "", s3Required); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -179,6 +262,21 @@ func getEnvUint64(key string, defaultValue uint64, required bool) (uint64, error
 	return value, nil
 }
 
+func getEnvDuration(key string, defaultValue time.Duration, required bool) (time.Duration, error) {
+	valueStr, exists := os.LookupEnv(key)
+	if !exists {
+		if required {
+			return 0, fmt.Errorf("environment variable %s is required", key)
+		}
+		return defaultValue, nil
+	}
+	value, err := time.ParseDuration(valueStr)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("environment variable %s has invalid value: %s", key, valueStr)
+	}
+	return value, nil
+}
+
 func getEnvLogLevel(key string, defaultValue slog.Level, required bool) (slog.Level, error) {
 	levelStr, exists := os.LookupEnv(key)
 	if !exists {
@@ -26,6 +26,22 @@ func New() *Database {
 		&migration.SetupAudit{},
 		&migration.SetupWebhook{},
 		&migration.SetupTenant{},
+		&migration.SetupAQLFunctions{},
+		&migration.SetupStandingQuery{},
+		&migration.SetupCohort{},
+		&migration.SetupReport{},
+		&migration.UpdateQuery{},
+		&migration.SetupRole{},
+		&migration.SetupEHRAccessPolicy{},
+		&migration.SetupEHRStatusFlags{},
+		&migration.SetupPartyTags{},
+		&migration.SetupTagSearch{},
+		&migration.SetupVersionLifecycle{},
+		&migration.SetupEHRSearch{},
+		&migration.SetupEHRMerge{},
+		&migration.SetupEHRDeletion{},
+		&migration.SetupAttachment{},
+		&migration.SetupQueryEHRAccess{},
 	}
 	slices.SortFunc(migrations, func(migration1, migration2 migration.Migration) int {
 		if migration1.Version() < migration2.Version() {
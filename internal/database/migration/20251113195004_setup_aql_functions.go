@@ -0,0 +1,69 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var _ Migration = (*SetupAQLFunctions)(nil)
+
+type SetupAQLFunctions struct{}
+
+func (m *SetupAQLFunctions) Version() uint64 {
+	return 20251113195004
+}
+
+func (m *SetupAQLFunctions) Name() string {
+	return "Setup AQL Functions"
+}
+
+func (m *SetupAQLFunctions) Up(ctx context.Context, tx pgx.Tx) error {
+	// Converts an ISO 8601 (partial) date or date/time to seconds since epoch, so values compare in time order.
+	// Partial values are normalized to the start of the period they denote, e.g. "2023-05" becomes 2023-05-01T00:00:00.
+	_, err := tx.Exec(ctx, `
+		CREATE FUNCTION openehr.iso8601_epoch(value TEXT) RETURNS NUMERIC
+		LANGUAGE sql STABLE PARALLEL SAFE AS $$
+			SELECT EXTRACT(EPOCH FROM CASE
+				WHEN value ~ '^\d{4}$' THEN (value || '-01-01')::timestamptz
+				WHEN value ~ '^\d{4}-\d{2}$' THEN (value || '-01')::timestamptz
+				WHEN value ~ '^\d{4}-\d{2}-\d{2}T\d{2}(Z|[+-]\d{2}(:?\d{2})?)?$' THEN regexp_replace(value, '^(\d{4}-\d{2}-\d{2}T\d{2})', '\1:00')::timestamptz
+				WHEN value ~ '^\d{4}-\d{2}-\d{2}([T ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[+-]\d{2}(:?\d{2})?)?)?$' THEN value::timestamptz
+			END)
+		$$;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create iso8601_epoch function: %w", err)
+	}
+
+	// Converts an ISO 8601 duration to seconds, so that for example P1W and P7D compare as equal.
+	_, err = tx.Exec(ctx, `
+		CREATE FUNCTION openehr.iso8601_duration_seconds(value TEXT) RETURNS NUMERIC
+		LANGUAGE sql STABLE PARALLEL SAFE AS $$
+			SELECT CASE
+				WHEN value ~ '^-?P(\d+(\.\d+)?[YMWD])*(T(\d+(\.\d+)?[HMS])+)?$' AND value !~ '^-?PT?$'
+				THEN (CASE WHEN left(value, 1) = '-' THEN -1 ELSE 1 END) * EXTRACT(EPOCH FROM ltrim(value, '-')::interval)
+			END
+		$$;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create iso8601_duration_seconds function: %w", err)
+	}
+
+	return nil
+}
+
+func (m *SetupAQLFunctions) Down(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `DROP FUNCTION IF EXISTS openehr.iso8601_duration_seconds(TEXT);`)
+	if err != nil {
+		return fmt.Errorf("failed to drop iso8601_duration_seconds function: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `DROP FUNCTION IF EXISTS openehr.iso8601_epoch(TEXT);`)
+	if err != nil {
+		return fmt.Errorf("failed to drop iso8601_epoch function: %w", err)
+	}
+
+	return nil
+}
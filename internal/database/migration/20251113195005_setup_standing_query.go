@@ -0,0 +1,47 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var _ Migration = (*SetupStandingQuery)(nil)
+
+type SetupStandingQuery struct{}
+
+func (m *SetupStandingQuery) Version() uint64 {
+	return 20251113195005
+}
+
+func (m *SetupStandingQuery) Name() string {
+	return "Setup Standing Query Table"
+}
+
+func (m *SetupStandingQuery) Up(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `
+		CREATE TABLE openehr.tbl_standing_query (
+			id UUID PRIMARY KEY DEFAULT uuidv4(),
+			query_name TEXT NOT NULL,
+			query_version TEXT,
+			parameters JSONB NOT NULL DEFAULT '{}'::jsonb,
+			is_active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create tbl_standing_query table: %w", err)
+	}
+
+	return nil
+}
+
+func (m *SetupStandingQuery) Down(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `DROP TABLE IF EXISTS openehr.tbl_standing_query;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop tbl_standing_query table: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var _ Migration = (*SetupCohort)(nil)
+
+type SetupCohort struct{}
+
+func (m *SetupCohort) Version() uint64 {
+	return 20251113195006
+}
+
+func (m *SetupCohort) Name() string {
+	return "Setup Cohort Tables"
+}
+
+func (m *SetupCohort) Up(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `
+		CREATE TABLE openehr.tbl_cohort (
+			name TEXT PRIMARY KEY,
+			description TEXT NOT NULL DEFAULT '',
+			query TEXT NOT NULL,
+			parameters JSONB NOT NULL DEFAULT '{}'::jsonb,
+			refresh_interval_seconds BIGINT,
+			current_version INT NOT NULL DEFAULT 0,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create tbl_cohort table: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		CREATE TABLE openehr.tbl_cohort_version (
+			cohort_name TEXT NOT NULL REFERENCES openehr.tbl_cohort(name) ON DELETE CASCADE,
+			version INT NOT NULL,
+			member_count INT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (cohort_name, version)
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create tbl_cohort_version table: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		CREATE TABLE openehr.tbl_cohort_member (
+			cohort_name TEXT NOT NULL,
+			version INT NOT NULL,
+			ehr_id UUID NOT NULL REFERENCES openehr.tbl_ehr(id) ON DELETE CASCADE,
+			PRIMARY KEY (cohort_name, version, ehr_id),
+			FOREIGN KEY (cohort_name, version) REFERENCES openehr.tbl_cohort_version(cohort_name, version) ON DELETE CASCADE
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create tbl_cohort_member table: %w", err)
+	}
+
+	return nil
+}
+
+func (m *SetupCohort) Down(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `DROP TABLE IF EXISTS openehr.tbl_cohort_member;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop tbl_cohort_member table: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `DROP TABLE IF EXISTS openehr.tbl_cohort_version;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop tbl_cohort_version table: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `DROP TABLE IF EXISTS openehr.tbl_cohort;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop tbl_cohort table: %w", err)
+	}
+
+	return nil
+}
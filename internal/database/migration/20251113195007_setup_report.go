@@ -0,0 +1,84 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var _ Migration = (*SetupReport)(nil)
+
+type SetupReport struct{}
+
+func (m *SetupReport) Version() uint64 {
+	return 20251113195007
+}
+
+func (m *SetupReport) Name() string {
+	return "Setup Report Tables"
+}
+
+func (m *SetupReport) Up(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `
+		CREATE TABLE openehr.tbl_report (
+			id UUID PRIMARY KEY DEFAULT uuidv4(),
+			name TEXT NOT NULL UNIQUE,
+			query_name TEXT NOT NULL,
+			query_version TEXT,
+			parameters JSONB NOT NULL DEFAULT '{}'::jsonb,
+			schedule TEXT NOT NULL,  -- cron expression, e.g. '0 6 1 * *'
+			format TEXT NOT NULL,    -- 'csv' or 'ndjson'
+			delivery TEXT NOT NULL,  -- 'file' or 'webhook'
+			webhook_subscription_id UUID REFERENCES webhook.tbl_subscription(id) ON DELETE SET NULL,
+			is_active BOOLEAN NOT NULL DEFAULT TRUE,
+			next_run_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create tbl_report table: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `CREATE INDEX idx_report_next_run_at ON openehr.tbl_report (next_run_at) WHERE is_active;`)
+	if err != nil {
+		return fmt.Errorf("failed to create idx_report_next_run_at index: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		CREATE TABLE openehr.tbl_report_run (
+			id UUID PRIMARY KEY DEFAULT uuidv4(),
+			report_id UUID NOT NULL REFERENCES openehr.tbl_report(id) ON DELETE CASCADE,
+			status TEXT NOT NULL,  -- 'running', 'succeeded' or 'failed'
+			row_count INT NOT NULL DEFAULT 0,
+			error TEXT,
+			output_path TEXT,
+			started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			finished_at TIMESTAMPTZ
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create tbl_report_run table: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `CREATE INDEX idx_report_run_report_id ON openehr.tbl_report_run (report_id, started_at DESC);`)
+	if err != nil {
+		return fmt.Errorf("failed to create idx_report_run_report_id index: %w", err)
+	}
+
+	return nil
+}
+
+func (m *SetupReport) Down(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `DROP TABLE IF EXISTS openehr.tbl_report_run;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop tbl_report_run table: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `DROP TABLE IF EXISTS openehr.tbl_report;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop tbl_report table: %w", err)
+	}
+
+	return nil
+}
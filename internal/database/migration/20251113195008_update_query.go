@@ -0,0 +1,45 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var _ Migration = (*UpdateQuery)(nil)
+
+type UpdateQuery struct{}
+
+func (m *UpdateQuery) Version() uint64 {
+	return 20251113195008
+}
+
+func (m *UpdateQuery) Name() string {
+	return "Update Query Table"
+}
+
+func (m *UpdateQuery) Up(ctx context.Context, tx pgx.Tx) error {
+	// Stored query versions are SemVer strings, e.g. '1.0.0', existing integer versions become their major version
+	_, err := tx.Exec(ctx, `ALTER TABLE openehr.tbl_query ALTER COLUMN version TYPE TEXT USING version::text || '.0.0';`)
+	if err != nil {
+		return fmt.Errorf("failed to alter tbl_query version column: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `ALTER TABLE openehr.tbl_query ADD COLUMN parameters JSONB NOT NULL DEFAULT '[]'::jsonb;`)
+	if err != nil {
+		return fmt.Errorf("failed to add tbl_query parameters column: %w", err)
+	}
+
+	return nil
+}
+
+func (m *UpdateQuery) Down(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `ALTER TABLE openehr.tbl_query DROP COLUMN IF EXISTS parameters;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop tbl_query parameters column: %w", err)
+	}
+
+	// The version column is left as TEXT, SemVer versions cannot be converted back to INT without collisions
+	return nil
+}
@@ -0,0 +1,73 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var _ Migration = (*SetupRole)(nil)
+
+type SetupRole struct{}
+
+func (m *SetupRole) Version() uint64 {
+	return 20251113195009
+}
+
+func (m *SetupRole) Name() string {
+	return "Setup Role Tables"
+}
+
+func (m *SetupRole) Up(ctx context.Context, tx pgx.Tx) error {
+	// Roles are versioned like the other parties, the role service already reads and writes these tables
+	_, err := tx.Exec(ctx, `
+		CREATE TABLE openehr.tbl_role (
+			id TEXT PRIMARY KEY,
+			version_int INT NOT NULL,
+			versioned_party_id UUID NOT NULL REFERENCES openehr.tbl_versioned_object(id) ON DELETE CASCADE,
+			contribution_id UUID NOT NULL REFERENCES openehr.tbl_contribution(id) ON DELETE CASCADE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create tbl_role table: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		CREATE TABLE openehr.tbl_role_data (
+			id TEXT PRIMARY KEY,
+			data JSONB NOT NULL,
+			version_data JSONB NOT NULL
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create tbl_role_data table: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `ALTER TABLE openehr.tbl_role_data ALTER COLUMN data SET COMPRESSION lz4;`)
+	if err != nil {
+		return fmt.Errorf("failed to set compression on tbl_role_data.data column: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `ALTER TABLE openehr.tbl_role_data ALTER COLUMN version_data SET COMPRESSION lz4;`)
+	if err != nil {
+		return fmt.Errorf("failed to set compression on tbl_role_data.version_data column: %w", err)
+	}
+
+	return nil
+}
+
+func (m *SetupRole) Down(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `DROP TABLE IF EXISTS openehr.tbl_role_data;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop tbl_role_data table: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `DROP TABLE IF EXISTS openehr.tbl_role;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop tbl_role table: %w", err)
+	}
+
+	return nil
+}
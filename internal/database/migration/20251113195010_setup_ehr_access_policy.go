@@ -0,0 +1,68 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var _ Migration = (*SetupEHRAccessPolicy)(nil)
+
+type SetupEHRAccessPolicy struct{}
+
+func (m *SetupEHRAccessPolicy) Version() uint64 {
+	return 20251113195010
+}
+
+func (m *SetupEHRAccessPolicy) Name() string {
+	return "Setup EHR Access Policy"
+}
+
+func (m *SetupEHRAccessPolicy) Up(ctx context.Context, tx pgx.Tx) error {
+	// The policy is evaluated for every EHR a query touches, so the latest EHR_ACCESS must be cheap to find
+	_, err := tx.Exec(ctx, `CREATE INDEX idx_ehr_access_ehr_id_version ON openehr.tbl_ehr_access (ehr_id, version_int DESC);`)
+	if err != nil {
+		return fmt.Errorf("failed to create idx_ehr_access_ehr_id_version index: %w", err)
+	}
+
+	// Decides if a caller may read an EHR, using the settings of its latest EHR_ACCESS.
+	// An EHR without allowed subjects and roles, or without EHR_ACCESS at all, is readable by everyone.
+	_, err = tx.Exec(ctx, `
+		CREATE FUNCTION openehr.ehr_access_allowed(target_ehr_id UUID, subject TEXT, roles TEXT[]) RETURNS BOOLEAN
+		LANGUAGE sql STABLE PARALLEL SAFE AS $$
+			SELECT COALESCE((
+				SELECT (
+					COALESCE(ead.data->'settings'->'allowed_subjects', '[]'::jsonb) = '[]'::jsonb
+					AND COALESCE(ead.data->'settings'->'allowed_roles', '[]'::jsonb) = '[]'::jsonb
+				)
+				OR COALESCE(ead.data->'settings'->'allowed_subjects' ? subject, FALSE)
+				OR COALESCE(ead.data->'settings'->'allowed_roles' ?| roles, FALSE)
+				FROM openehr.tbl_ehr_access ea
+				JOIN openehr.tbl_ehr_access_data ead ON ead.id = ea.id
+				WHERE ea.ehr_id = target_ehr_id
+				ORDER BY ea.version_int DESC
+				LIMIT 1
+			), TRUE)
+		$$;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create ehr_access_allowed function: %w", err)
+	}
+
+	return nil
+}
+
+func (m *SetupEHRAccessPolicy) Down(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `DROP FUNCTION IF EXISTS openehr.ehr_access_allowed(UUID, TEXT, TEXT[]);`)
+	if err != nil {
+		return fmt.Errorf("failed to drop ehr_access_allowed function: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `DROP INDEX IF EXISTS openehr.idx_ehr_access_ehr_id_version;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop idx_ehr_access_ehr_id_version index: %w", err)
+	}
+
+	return nil
+}
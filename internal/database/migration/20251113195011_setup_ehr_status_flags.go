@@ -0,0 +1,84 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var _ Migration = (*SetupEHRStatusFlags)(nil)
+
+type SetupEHRStatusFlags struct{}
+
+func (m *SetupEHRStatusFlags) Version() uint64 {
+	return 20251113195011
+}
+
+func (m *SetupEHRStatusFlags) Name() string {
+	return "Setup EHR Status Flags"
+}
+
+func (m *SetupEHRStatusFlags) Up(ctx context.Context, tx pgx.Tx) error {
+	// The flags are read from the latest EHR_STATUS for every write and for every EHR a query touches
+	_, err := tx.Exec(ctx, `CREATE INDEX idx_ehr_status_ehr_id_version ON openehr.tbl_ehr_status (ehr_id, version_int DESC);`)
+	if err != nil {
+		return fmt.Errorf("failed to create idx_ehr_status_ehr_id_version index: %w", err)
+	}
+
+	// An EHR without EHR_STATUS, or with the flag left out, is queryable and modifiable
+	_, err = tx.Exec(ctx, `
+		CREATE FUNCTION openehr.ehr_is_queryable(target_ehr_id UUID) RETURNS BOOLEAN
+		LANGUAGE sql STABLE PARALLEL SAFE AS $$
+			SELECT COALESCE((
+				SELECT (esd.data->>'is_queryable')::BOOLEAN
+				FROM openehr.tbl_ehr_status es
+				JOIN openehr.tbl_ehr_status_data esd ON esd.id = es.id
+				WHERE es.ehr_id = target_ehr_id
+				ORDER BY es.version_int DESC
+				LIMIT 1
+			), TRUE)
+		$$;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create ehr_is_queryable function: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		CREATE FUNCTION openehr.ehr_is_modifiable(target_ehr_id UUID) RETURNS BOOLEAN
+		LANGUAGE sql STABLE PARALLEL SAFE AS $$
+			SELECT COALESCE((
+				SELECT (esd.data->>'is_modifiable')::BOOLEAN
+				FROM openehr.tbl_ehr_status es
+				JOIN openehr.tbl_ehr_status_data esd ON esd.id = es.id
+				WHERE es.ehr_id = target_ehr_id
+				ORDER BY es.version_int DESC
+				LIMIT 1
+			), TRUE)
+		$$;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create ehr_is_modifiable function: %w", err)
+	}
+
+	return nil
+}
+
+func (m *SetupEHRStatusFlags) Down(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `DROP FUNCTION IF EXISTS openehr.ehr_is_modifiable(UUID);`)
+	if err != nil {
+		return fmt.Errorf("failed to drop ehr_is_modifiable function: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `DROP FUNCTION IF EXISTS openehr.ehr_is_queryable(UUID);`)
+	if err != nil {
+		return fmt.Errorf("failed to drop ehr_is_queryable function: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `DROP INDEX IF EXISTS openehr.idx_ehr_status_ehr_id_version;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop idx_ehr_status_ehr_id_version index: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var _ Migration = (*SetupPartyTags)(nil)
+
+type SetupPartyTags struct{}
+
+func (m *SetupPartyTags) Version() uint64 {
+	return 20251113195012
+}
+
+func (m *SetupPartyTags) Name() string {
+	return "Setup Party Tags"
+}
+
+func (m *SetupPartyTags) Up(ctx context.Context, tx pgx.Tx) error {
+	// Roles were added after the other party types and never got a tag table
+	_, err := tx.Exec(ctx, `
+		CREATE TABLE openehr.tbl_role_tag (
+			role_id TEXT NOT NULL REFERENCES openehr.tbl_role(id) ON DELETE CASCADE,
+			key TEXT NOT NULL,
+			data JSONB NOT NULL,
+			PRIMARY KEY (role_id, key)
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create tbl_role_tag table: %w", err)
+	}
+
+	// Parties are not part of an EHR, the column made versioned party tags impossible to store
+	_, err = tx.Exec(ctx, `ALTER TABLE openehr.tbl_versioned_party_tag DROP COLUMN ehr_id;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop ehr_id column from tbl_versioned_party_tag table: %w", err)
+	}
+
+	return nil
+}
+
+func (m *SetupPartyTags) Down(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `DELETE FROM openehr.tbl_versioned_party_tag;`)
+	if err != nil {
+		return fmt.Errorf("failed to clear tbl_versioned_party_tag table: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `ALTER TABLE openehr.tbl_versioned_party_tag ADD COLUMN ehr_id UUID NOT NULL REFERENCES openehr.tbl_ehr(id) ON DELETE CASCADE;`)
+	if err != nil {
+		return fmt.Errorf("failed to add ehr_id column to tbl_versioned_party_tag table: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `DROP TABLE IF EXISTS openehr.tbl_role_tag;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop tbl_role_tag table: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var _ Migration = (*SetupTagSearch)(nil)
+
+type SetupTagSearch struct{}
+
+func (m *SetupTagSearch) Version() uint64 {
+	return 20251113195013
+}
+
+func (m *SetupTagSearch) Name() string {
+	return "Setup Tag Search"
+}
+
+func (m *SetupTagSearch) Up(ctx context.Context, tx pgx.Tx) error {
+	// Tags are searched by key across EHRs, the primary keys only cover lookups per tagged object
+	_, err := tx.Exec(ctx, `CREATE INDEX idx_versioned_composition_tag_key ON openehr.tbl_versioned_composition_tag (key, ehr_id);`)
+	if err != nil {
+		return fmt.Errorf("failed to create idx_versioned_composition_tag_key index: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `CREATE INDEX idx_composition_tag_key ON openehr.tbl_composition_tag (key, ehr_id);`)
+	if err != nil {
+		return fmt.Errorf("failed to create idx_composition_tag_key index: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `CREATE INDEX idx_ehr_status_tag_key ON openehr.tbl_ehr_status_tag (key, ehr_id);`)
+	if err != nil {
+		return fmt.Errorf("failed to create idx_ehr_status_tag_key index: %w", err)
+	}
+
+	return nil
+}
+
+func (m *SetupTagSearch) Down(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `DROP INDEX IF EXISTS openehr.idx_ehr_status_tag_key;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop idx_ehr_status_tag_key index: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `DROP INDEX IF EXISTS openehr.idx_composition_tag_key;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop idx_composition_tag_key index: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `DROP INDEX IF EXISTS openehr.idx_versioned_composition_tag_key;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop idx_versioned_composition_tag_key index: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var _ Migration = (*SetupVersionLifecycle)(nil)
+
+type SetupVersionLifecycle struct{}
+
+func (m *SetupVersionLifecycle) Version() uint64 {
+	return 20251113195014
+}
+
+func (m *SetupVersionLifecycle) Name() string {
+	return "Setup Version Lifecycle"
+}
+
+func (m *SetupVersionLifecycle) Up(ctx context.Context, tx pgx.Tx) error {
+	// Queries leave out incomplete compositions by default, so the lifecycle state is kept next to the version instead of only in its version data.
+	// Every composition committed so far is complete (532).
+	_, err := tx.Exec(ctx, `ALTER TABLE openehr.tbl_composition ADD COLUMN lifecycle_state TEXT NOT NULL DEFAULT '532';`)
+	if err != nil {
+		return fmt.Errorf("failed to add lifecycle_state column to tbl_composition: %w", err)
+	}
+
+	// The latest version of a composition is the latest version on the trunk of its version tree
+	_, err = tx.Exec(ctx, `CREATE INDEX idx_composition_versioned_composition_id_version ON openehr.tbl_composition (versioned_composition_id, version_int DESC);`)
+	if err != nil {
+		return fmt.Errorf("failed to create idx_composition_versioned_composition_id_version index: %w", err)
+	}
+
+	return nil
+}
+
+func (m *SetupVersionLifecycle) Down(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `DROP INDEX IF EXISTS openehr.idx_composition_versioned_composition_id_version;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop idx_composition_versioned_composition_id_version index: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `ALTER TABLE openehr.tbl_composition DROP COLUMN IF EXISTS lifecycle_state;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop lifecycle_state column from tbl_composition: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var _ Migration = (*SetupEHRSearch)(nil)
+
+type SetupEHRSearch struct{}
+
+func (m *SetupEHRSearch) Version() uint64 {
+	return 20251113195015
+}
+
+func (m *SetupEHRSearch) Name() string {
+	return "Setup EHR Search"
+}
+
+func (m *SetupEHRSearch) Up(ctx context.Context, tx pgx.Tx) error {
+	// EHRs are listed newest first with keyset pagination on (created_at, id)
+	_, err := tx.Exec(ctx, `CREATE INDEX idx_ehr_created_at_id ON openehr.tbl_ehr (created_at DESC, id DESC);`)
+	if err != nil {
+		return fmt.Errorf("failed to create idx_ehr_created_at_id index: %w", err)
+	}
+
+	return nil
+}
+
+func (m *SetupEHRSearch) Down(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `DROP INDEX IF EXISTS openehr.idx_ehr_created_at_id;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop idx_ehr_created_at_id index: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var _ Migration = (*SetupEHRMerge)(nil)
+
+type SetupEHRMerge struct{}
+
+func (m *SetupEHRMerge) Version() uint64 {
+	return 20251113195016
+}
+
+func (m *SetupEHRMerge) Name() string {
+	return "Setup EHR Merge"
+}
+
+func (m *SetupEHRMerge) Up(ctx context.Context, tx pgx.Tx) error {
+	// A merged EHR keeps pointing at the EHR its versioned objects were moved into
+	_, err := tx.Exec(ctx, `ALTER TABLE openehr.tbl_ehr ADD COLUMN merged_into_ehr_id UUID REFERENCES openehr.tbl_ehr(id) ON DELETE SET NULL;`)
+	if err != nil {
+		return fmt.Errorf("failed to add merged_into_ehr_id column to tbl_ehr: %w", err)
+	}
+
+	return nil
+}
+
+func (m *SetupEHRMerge) Down(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `ALTER TABLE openehr.tbl_ehr DROP COLUMN IF EXISTS merged_into_ehr_id;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop merged_into_ehr_id column from tbl_ehr: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var _ Migration = (*SetupEHRDeletion)(nil)
+
+type SetupEHRDeletion struct{}
+
+func (m *SetupEHRDeletion) Version() uint64 {
+	return 20251113195017
+}
+
+func (m *SetupEHRDeletion) Name() string {
+	return "Setup EHR Deletion"
+}
+
+func (m *SetupEHRDeletion) Up(ctx context.Context, tx pgx.Tx) error {
+	// A deleted EHR is kept until the purger removes it, so it can still be restored within the grace period
+	_, err := tx.Exec(ctx, `ALTER TABLE openehr.tbl_ehr ADD COLUMN deleted_at TIMESTAMPTZ;`)
+	if err != nil {
+		return fmt.Errorf("failed to add deleted_at column to tbl_ehr: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `CREATE INDEX idx_ehr_deleted_at ON openehr.tbl_ehr (deleted_at) WHERE deleted_at IS NOT NULL;`)
+	if err != nil {
+		return fmt.Errorf("failed to create idx_ehr_deleted_at index: %w", err)
+	}
+
+	// An unknown EHR is not reported as deleted, queries joining on it find nothing anyway
+	_, err = tx.Exec(ctx, `
+		CREATE FUNCTION openehr.ehr_is_deleted(target_ehr_id UUID) RETURNS BOOLEAN
+		LANGUAGE sql STABLE PARALLEL SAFE AS $$
+			SELECT COALESCE((
+				SELECT deleted_at IS NOT NULL
+				FROM openehr.tbl_ehr
+				WHERE id = target_ehr_id
+			), FALSE)
+		$$;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create ehr_is_deleted function: %w", err)
+	}
+
+	return nil
+}
+
+func (m *SetupEHRDeletion) Down(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `DROP FUNCTION IF EXISTS openehr.ehr_is_deleted(UUID);`)
+	if err != nil {
+		return fmt.Errorf("failed to drop ehr_is_deleted function: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `DROP INDEX IF EXISTS openehr.idx_ehr_deleted_at;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop idx_ehr_deleted_at index: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `ALTER TABLE openehr.tbl_ehr DROP COLUMN IF EXISTS deleted_at;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop deleted_at column from tbl_ehr: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var _ Migration = (*SetupAttachment)(nil)
+
+type SetupAttachment struct{}
+
+func (m *SetupAttachment) Version() uint64 {
+	return 20251113195018
+}
+
+func (m *SetupAttachment) Name() string {
+	return "Setup Attachment"
+}
+
+func (m *SetupAttachment) Up(ctx context.Context, tx pgx.Tx) error {
+	// The content itself lives in the attachment store under its hash, shared by attachments with the same content
+	_, err := tx.Exec(ctx, `
+		CREATE TABLE openehr.tbl_attachment (
+			id UUID PRIMARY KEY,
+			ehr_id UUID NOT NULL REFERENCES openehr.tbl_ehr(id) ON DELETE CASCADE,
+			hash TEXT NOT NULL,
+			size BIGINT NOT NULL,
+			media_type TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create tbl_attachment table: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `CREATE INDEX idx_attachment_ehr_id ON openehr.tbl_attachment (ehr_id);`)
+	if err != nil {
+		return fmt.Errorf("failed to create idx_attachment_ehr_id index: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `CREATE INDEX idx_attachment_hash ON openehr.tbl_attachment (hash);`)
+	if err != nil {
+		return fmt.Errorf("failed to create idx_attachment_hash index: %w", err)
+	}
+
+	return nil
+}
+
+func (m *SetupAttachment) Down(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `DROP TABLE IF EXISTS openehr.tbl_attachment;`)
+	if err != nil {
+		return fmt.Errorf("failed to drop tbl_attachment table: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var _ Migration = (*SetupQueryEHRAccess)(nil)
+
+type SetupQueryEHRAccess struct{}
+
+func (m *SetupQueryEHRAccess) Version() uint64 {
+	return 20251113195019
+}
+
+func (m *SetupQueryEHRAccess) Name() string {
+	return "Setup Query EHR Access"
+}
+
+func (m *SetupQueryEHRAccess) Up(ctx context.Context, tx pgx.Tx) error {
+	// Standing queries, cohorts and reports run in the background for the caller that registered them,
+	// a NULL access_subject means the caller was not identified and EHR_ACCESS is not enforced
+	for _, table := range []string{"openehr.tbl_standing_query", "openehr.tbl_cohort", "openehr.tbl_report"} {
+		_, err := tx.Exec(ctx, `ALTER TABLE `+table+` ADD COLUMN access_subject TEXT, ADD COLUMN access_roles TEXT[];`)
+		if err != nil {
+			return fmt.Errorf("failed to add access columns to %s: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+func (m *SetupQueryEHRAccess) Down(ctx context.Context, tx pgx.Tx) error {
+	for _, table := range []string{"openehr.tbl_standing_query", "openehr.tbl_cohort", "openehr.tbl_report"} {
+		_, err := tx.Exec(ctx, `ALTER TABLE `+table+` DROP COLUMN IF EXISTS access_subject, DROP COLUMN IF EXISTS access_roles;`)
+		if err != nil {
+			return fmt.Errorf("failed to drop access columns from %s: %w", table, err)
+		}
+	}
+
+	return nil
+}
@@ -9,6 +9,7 @@ const (
 	ScopeEHRRead           Scope = "ehr:read"
 	ScopeEHRWrite          Scope = "ehr:write"
 	ScopeEHRDelete         Scope = "ehr:delete"
+	ScopeEHRAdmin          Scope = "ehr:admin"
 	ScopeDemographicsRead  Scope = "demographics:read"
 	ScopeDemographicsWrite Scope = "demographics:write"
 	ScopeQueryRead         Scope = "query:read"
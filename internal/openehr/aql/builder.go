@@ -3,12 +3,16 @@ package aql
 import (
 	"errors"
 	"fmt"
+	"net/url"
+	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/antlr4-go/antlr/v4"
 	"github.com/freekieb7/gopenehr/internal/openehr/aql/gen"
 	"github.com/freekieb7/gopenehr/internal/openehr/rm"
+	"github.com/freekieb7/gopenehr/internal/openehr/terminology"
 	"github.com/freekieb7/gopenehr/pkg/utils"
 )
 
@@ -16,43 +20,219 @@ type Source struct {
 	Model string
 	Table string
 	Alias string
+	// InModel is set when the source is searched for inside the data of its parent, instead of being read from its own table
+	InModel bool
+}
+
+// Options narrows down the data a query is executed against
+type Options struct {
+	// CompositionID restricts the COMPOSITION sources of the query to a single composition version
+	CompositionID utils.Optional[string]
+	// EHRAccess restricts the EHR data of the query to the EHRs whose EHR_ACCESS settings allow the caller
+	EHRAccess utils.Optional[EHRAccessSubject]
+	// IncludeNonQueryable also returns data of EHRs whose EHR_STATUS has is_queryable set to false
+	IncludeNonQueryable bool
+	// IncludeIncomplete also returns COMPOSITION versions whose lifecycle_state is incomplete
+	IncludeIncomplete bool
+}
+
+// EHRAccessSubject is the caller an EHR access policy is evaluated for
+type EHRAccessSubject struct {
+	Subject string
+	Roles   []string
+}
+
+// ehrIDColumns are the columns that hold the EHR a source belongs to, for the models stored per EHR
+var ehrIDColumns = map[string]string{
+	rm.EHR_TYPE:                   "id",
+	rm.CONTRIBUTION_TYPE:          "ehr_id",
+	rm.VERSIONED_EHR_STATUS_TYPE:  "ehr_id",
+	rm.VERSIONED_EHR_ACCESS_TYPE:  "ehr_id",
+	rm.VERSIONED_COMPOSITION_TYPE: "ehr_id",
+	rm.VERSIONED_FOLDER_TYPE:      "ehr_id",
+	rm.EHR_STATUS_TYPE:            "ehr_id",
+	rm.EHR_ACCESS_TYPE:            "ehr_id",
+	rm.COMPOSITION_TYPE:           "ehr_id",
+	rm.FOLDER_TYPE:                "ehr_id",
 }
 
 func ToSQL(aqlQuery string, params map[string]any) (string, []string, error) {
+	return ToSQLWithOptions(aqlQuery, params, Options{})
+}
+
+func ToSQLWithOptions(aqlQuery string, params map[string]any, options Options) (string, []string, error) {
+	parsed, err := Parse(aqlQuery)
+	if err != nil {
+		return "", nil, err
+	}
+
+	query, columnNames, err := BuildSelectQuery(parsed.SelectQuery(), params, options)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Wrap the query to return a JSON array
+	query = fmt.Sprintf("SELECT jsonb_build_array(%s) FROM (%s) AS result", strings.Join(columnNames, ", "), query)
+
+	return query, columnNames, nil
+}
+
+// Parse parses an AQL query, syntax errors are joined into a single error
+func Parse(aqlQuery string) (*gen.QueryContext, error) {
 	listener := NewTreeShapeListener()
 	errorListener := NewErrorListener()
 
+	// Date arithmetic is not part of the grammar, rewrite it into a form the parser understands
+	aqlQuery = RewriteDateTimeArithmetic(aqlQuery)
+	aqlQuery = RewriteCohortMatches(aqlQuery)
+
 	input := antlr.NewInputStream(aqlQuery)
 	lexer := gen.NewAQLLexer(input)
+	lexer.RemoveErrorListeners()
+	lexer.AddErrorListener(errorListener)
 	stream := antlr.NewCommonTokenStream(lexer, 0)
 
 	p := gen.NewAQLParser(stream)
+	p.RemoveErrorListeners()
 	p.AddErrorListener(errorListener)
 
 	antlr.ParseTreeWalkerDefault.Walk(listener, p.Query())
 
 	if len(errorListener.Errors) > 0 {
-		return "", nil, errors.Join(errorListener.Errors...)
+		return nil, errors.Join(errorListener.Errors...)
 	}
 
-	query, columnNames, err := BuildSelectQuery(listener.Query.SelectQuery(), params)
-	if err != nil {
-		return "", nil, err
+	return listener.Query, nil
+}
+
+// RewriteDateTimeArithmetic rewrites date arithmetic such as `CURRENT_DATE() - P30D` into
+// the function call form `CURRENT_DATE('-P30D')`, where the argument is the offset to apply.
+func RewriteDateTimeArithmetic(aqlQuery string) string {
+	lexer := gen.NewAQLLexer(antlr.NewInputStream(aqlQuery))
+	lexer.RemoveErrorListeners()
+	tokens := lexer.GetAllTokens()
+
+	// Token positions are rune based
+	runes := []rune(aqlQuery)
+
+	var rewritten strings.Builder
+	last := 0
+	for i := 0; i+4 < len(tokens); i++ {
+		if tokens[i].GetTokenType() != gen.AQLLexerDATE_TIME_FUNCTION_ID ||
+			tokens[i+1].GetTokenType() != gen.AQLLexerSYM_LEFT_PAREN ||
+			tokens[i+2].GetTokenType() != gen.AQLLexerSYM_RIGHT_PAREN {
+			continue
+		}
+
+		sign := ""
+		switch tokens[i+3].GetTokenType() {
+		case gen.AQLLexerSYM_MINUS:
+			sign = "-"
+		case gen.AQLLexerSYM_PLUS:
+		default:
+			continue
+		}
+
+		duration := tokens[i+4].GetText()
+		switch tokens[i+4].GetTokenType() {
+		case gen.AQLLexerIDENTIFIER:
+		case gen.AQLLexerSTRING:
+			duration = duration[1 : len(duration)-1] // Remove quotes
+		default:
+			continue
+		}
+		if !isISO8601Duration(duration) || strings.HasPrefix(duration, "-") {
+			continue
+		}
+
+		rewritten.WriteString(string(runes[last:tokens[i].GetStart()]))
+		rewritten.WriteString(fmt.Sprintf("%s('%s%s')", tokens[i].GetText(), sign, duration))
+		last = tokens[i+4].GetStop() + 1
+		i += 4
 	}
 
-	// Wrap the query to return a JSON array
-	query = fmt.Sprintf("SELECT jsonb_build_array(%s) FROM (%s) AS result", strings.Join(columnNames, ", "), query)
+	if last == 0 {
+		return aqlQuery
+	}
 
-	return query, columnNames, nil
+	rewritten.WriteString(string(runes[last:]))
+	return rewritten.String()
 }
 
-func BuildSelectQuery(ctx gen.ISelectQueryContext, params map[string]any) (string, []string, error) {
+// CohortURIScheme is the URI scheme used to reference a saved cohort in a MATCHES expression
+const CohortURIScheme = "cohort"
+
+// RewriteCohortMatches rewrites `MATCHES COHORT('name')` and `MATCHES COHORT('name', version)` into
+// the URI form `MATCHES {cohort:name?version=1}`, which the parser understands.
+func RewriteCohortMatches(aqlQuery string) string {
+	lexer := gen.NewAQLLexer(antlr.NewInputStream(aqlQuery))
+	lexer.RemoveErrorListeners()
+	tokens := lexer.GetAllTokens()
+
+	// Token positions are rune based
+	runes := []rune(aqlQuery)
+
+	var rewritten strings.Builder
+	last := 0
+	for i := 0; i+4 < len(tokens); i++ {
+		if tokens[i].GetTokenType() != gen.AQLLexerMATCHES ||
+			tokens[i+1].GetTokenType() != gen.AQLLexerIDENTIFIER ||
+			!strings.EqualFold(tokens[i+1].GetText(), "COHORT") ||
+			tokens[i+2].GetTokenType() != gen.AQLLexerSYM_LEFT_PAREN ||
+			tokens[i+3].GetTokenType() != gen.AQLLexerSTRING {
+			continue
+		}
+
+		name := tokens[i+3].GetText()
+		name = name[1 : len(name)-1] // Remove quotes
+
+		end := i + 4
+		version := ""
+		if tokens[end].GetTokenType() == gen.AQLLexerSYM_COMMA {
+			if end+2 >= len(tokens) || tokens[end+1].GetTokenType() != gen.AQLLexerINTEGER {
+				continue
+			}
+			version = tokens[end+1].GetText()
+			end += 2
+		}
+		if tokens[end].GetTokenType() != gen.AQLLexerSYM_RIGHT_PAREN {
+			continue
+		}
+
+		uri := CohortURIScheme + ":" + url.PathEscape(name)
+		if version != "" {
+			uri += "?version=" + version
+		}
+
+		rewritten.WriteString(string(runes[last:tokens[i].GetStart()]))
+		rewritten.WriteString(fmt.Sprintf("%s {%s}", tokens[i].GetText(), uri))
+		last = tokens[end].GetStop() + 1
+		i = end
+	}
+
+	if last == 0 {
+		return aqlQuery
+	}
+
+	rewritten.WriteString(string(runes[last:]))
+	return rewritten.String()
+}
+
+func BuildSelectQuery(ctx gen.ISelectQueryContext, params map[string]any, options Options) (string, []string, error) {
 	// FROM
 	fromClause, additionalWhereExpressions, sources, err := BuildFromClause(ctx.FromClause(), params)
 	if err != nil {
 		return "", nil, err
 	}
 
+	optionsExpression, err := BuildOptionsExpr(options, sources)
+	if err != nil {
+		return "", nil, err
+	}
+	if optionsExpression != "" {
+		additionalWhereExpressions = fmt.Sprintf("(%s) AND (%s)", additionalWhereExpressions, optionsExpression)
+	}
+
 	// WHERE
 	whereClause, err := BuildWhereClause(ctx.WhereClause(), params, sources, additionalWhereExpressions)
 	if err != nil {
@@ -85,6 +265,71 @@ func BuildSelectQuery(ctx gen.ISelectQueryContext, params map[string]any) (strin
 	return query, columnNames, nil
 }
 
+func BuildOptionsExpr(options Options, sources []Source) (string, error) {
+	expressions := make([]string, 0)
+
+	if options.CompositionID.E {
+		found := false
+		for _, source := range sources {
+			if source.Model != rm.COMPOSITION_TYPE || source.InModel {
+				continue
+			}
+
+			expressions = append(expressions, fmt.Sprintf("%s.id = %s", source.Table, QuoteLiteral(options.CompositionID.V)))
+			found = true
+		}
+
+		if !found {
+			return "", fmt.Errorf("query must select from COMPOSITION to be restricted to a single composition")
+		}
+	}
+
+	if !options.IncludeIncomplete {
+		for _, source := range sources {
+			if source.Model != rm.COMPOSITION_TYPE || source.InModel {
+				continue
+			}
+
+			// A composition source may be left joined, so EHRs without compositions are kept
+			expressions = append(expressions, fmt.Sprintf("%s.lifecycle_state IS DISTINCT FROM %s", source.Table, QuoteLiteral(terminology.VERSION_LIFECYCLE_STATE_CODE_INCOMPLETE)))
+		}
+	}
+
+	var subject, rolesArray string
+	if options.EHRAccess.E {
+		roles := make([]string, len(options.EHRAccess.V.Roles))
+		for i, role := range options.EHRAccess.V.Roles {
+			roles[i] = QuoteLiteral(role)
+		}
+		subject = QuoteLiteral(options.EHRAccess.V.Subject)
+		rolesArray = fmt.Sprintf("ARRAY[%s]::TEXT[]", strings.Join(roles, ", "))
+	}
+
+	// Sources searched for inside another model are covered by the checks on the model they are found in
+	for _, source := range sources {
+		column, ok := ehrIDColumns[strings.ToUpper(source.Model)]
+		if !ok || source.InModel {
+			continue
+		}
+
+		// Deleted EHRs waiting to be purged are hidden from everyone
+		expressions = append(expressions, fmt.Sprintf("NOT openehr.ehr_is_deleted(%s.%s)", source.Table, column))
+		if !options.IncludeNonQueryable {
+			expressions = append(expressions, fmt.Sprintf("openehr.ehr_is_queryable(%s.%s)", source.Table, column))
+		}
+		if options.EHRAccess.E {
+			expressions = append(expressions, fmt.Sprintf("openehr.ehr_access_allowed(%s.%s, %s, %s)", source.Table, column, subject, rolesArray))
+		}
+	}
+
+	return strings.Join(expressions, " AND "), nil
+}
+
+// QuoteLiteral quotes a value for safe use as SQL string literal
+func QuoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
 func BuildSelectClause(ctx gen.ISelectClauseContext, params map[string]any, sources []Source) (string, []string, []string, bool, error) {
 	clause := "SELECT "
 	if ctx.DISTINCT() != nil {
@@ -335,6 +580,7 @@ func BuildContainsExpr(ctx gen.IContainsExprContext, params map[string]any, prev
 				return "", "", nil, err
 			}
 		}
+		source.InModel = searchInModel
 
 		whereExpression := fmt.Sprintf("%s.data IS NOT NULL", source.Table)
 
@@ -842,6 +1088,14 @@ func BuildWhereExpr(ctx gen.IWhereExprContext, params map[string]any, sources []
 	case ctx.IdentifiedExpr() != nil:
 		return BuildIdentifiedExpr(ctx.IdentifiedExpr(), params, sources)
 	case ctx.AND() != nil:
+		tagExpr, ok, err := BuildTagAndExpr(ctx, params, sources)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return tagExpr, nil
+		}
+
 		leftExpr, err := BuildWhereExpr(ctx.WhereExpr(0), params, sources)
 		if err != nil {
 			return "", err
@@ -907,13 +1161,28 @@ func BuildLimitOperand(ctx gen.ILimitOperandContext, params map[string]any) (str
 	case ctx.INTEGER() != nil:
 		return ctx.INTEGER().GetText(), nil
 	case ctx.PARAMETER() != nil:
-		return BuildParameter(ctx.PARAMETER(), params, false, "int")
+		value, err := BuildParameter(ctx.PARAMETER(), params, true, "number")
+		if err != nil {
+			return "", err
+		}
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return "", fmt.Errorf("parameter %s expected to be an integer, got %s", ctx.PARAMETER().GetText()[1:], value)
+		}
+		return value, nil
 	default:
 		return "", fmt.Errorf("unsupported limit operand")
 	}
 }
 
 func BuildIdentifiedExpr(ctx gen.IIdentifiedExprContext, params map[string]any, sources []Source) (string, error) {
+	tagSource, tagCondition, ok, err := BuildTagCondition(ctx, params, sources)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return BuildTagExistsExpr(tagSource, []string{tagCondition}), nil
+	}
+
 	switch true {
 	case ctx.EXISTS() != nil:
 		source, path, _, _, err := BuildIdentifiedPath(ctx.IdentifiedPath(), params, sources)
@@ -930,7 +1199,7 @@ func BuildIdentifiedExpr(ctx gen.IIdentifiedExprContext, params map[string]any,
 
 		comparison := ctx.COMPARISON_OPERATOR().GetText()
 
-		value, err := BuildTerminal(ctx.Terminal(), params, sources)
+		terminal, err := BuildComparisonTerminal(ctx.Terminal(), params, sources)
 		if err != nil {
 			return "", err
 		}
@@ -940,11 +1209,16 @@ func BuildIdentifiedExpr(ctx gen.IIdentifiedExprContext, params map[string]any,
 			return "", err
 		}
 		if fastPath != "" {
-			return fmt.Sprintf("(%s %s %s)", fastPath, comparison, value), nil
+			kind, err := FastValueTemporalKind(ctx.IdentifiedPath(), params, sources)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("(%s %s %s)", fastPath, comparison, terminal.For(kind)), nil
 		}
 
 		// Worst case scenario, try every possible combination
 		switchExpression := BuildSlowValueExtractionExpr(endsWith)
+		value := BuildSlowComparisonValueExpr(endsWith, terminal)
 		if endsWith == nil {
 			return fmt.Sprintf("EXISTS(SELECT 1 FROM %s target WHERE (%s) %s %s)", source.Table, switchExpression, comparison, value), nil
 		}
@@ -976,6 +1250,10 @@ func BuildIdentifiedExpr(ctx gen.IIdentifiedExprContext, params map[string]any,
 			return "", err
 		}
 
+		if uri := ctx.MatchesOperand().URI(); uri != nil && strings.HasPrefix(uri.GetText(), CohortURIScheme+":") {
+			return BuildCohortMembershipExpr(uri.GetText(), fmt.Sprintf("jsonb_path_query_first(%s.data, '%s') #>> '{}'", source.Table, path))
+		}
+
 		values, err := BuildMatchedOperand(ctx.MatchesOperand(), params)
 		if err != nil {
 			return "", err
@@ -1116,6 +1394,187 @@ func BuildMatchedOperand(ctx gen.IMatchesOperandContext, params map[string]any)
 	}
 }
 
+// BuildCohortMembershipExpr checks the value against the EHR ids of a saved cohort, using the latest version unless one is given
+func BuildCohortMembershipExpr(uri string, valueExpr string) (string, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("invalid cohort reference %s: %w", uri, err)
+	}
+
+	name, err := url.PathUnescape(parsed.Opaque)
+	if err != nil || name == "" {
+		return "", fmt.Errorf("invalid cohort reference %s: missing cohort name", uri)
+	}
+
+	version := fmt.Sprintf("(SELECT c.current_version FROM openehr.tbl_cohort c WHERE c.name = %s)", QuoteLiteral(name))
+	if parsed.Query().Has("version") {
+		number, err := strconv.Atoi(parsed.Query().Get("version"))
+		if err != nil || number < 1 {
+			return "", fmt.Errorf("invalid cohort reference %s: version must be a positive integer", uri)
+		}
+		version = strconv.Itoa(number)
+	}
+
+	return fmt.Sprintf("(%s) IN (SELECT m.ehr_id::text FROM openehr.tbl_cohort_member m WHERE m.cohort_name = %s AND m.version = %s)", valueExpr, QuoteLiteral(name), version), nil
+}
+
+// tagTable is a table holding tags, matched to a source on the column of the tagged object
+type tagTable struct {
+	table        string
+	column       string
+	sourceColumn string
+}
+
+// tagTables are the tables holding the tags of the models that can be tagged, a composition version also carries the tags of its versioned composition
+var tagTables = map[string][]tagTable{
+	rm.COMPOSITION_TYPE: {
+		{table: "openehr.tbl_composition_tag", column: "composition_id", sourceColumn: "id"},
+		{table: "openehr.tbl_versioned_composition_tag", column: "versioned_composition_id", sourceColumn: "versioned_composition_id"},
+	},
+	rm.VERSIONED_COMPOSITION_TYPE: {
+		{table: "openehr.tbl_versioned_composition_tag", column: "versioned_composition_id", sourceColumn: "id"},
+	},
+	rm.EHR_STATUS_TYPE: {
+		{table: "openehr.tbl_ehr_status_tag", column: "ehr_status_id", sourceColumn: "id"},
+	},
+}
+
+// tagFields are the ITEM_TAG attributes that can be queried through a tags path
+var tagFields = []string{"key", "value", "target_path"}
+
+// BuildTagCondition builds the condition on a single tag for expressions on a tags path, such as `c/tags/key = 'triage'`.
+// The condition refers to the tag as `tag`, ok is false when the expression is not about tags.
+func BuildTagCondition(ctx gen.IIdentifiedExprContext, params map[string]any, sources []Source) (Source, string, bool, error) {
+	for ctx.SYM_LEFT_PAREN() != nil {
+		ctx = ctx.IdentifiedExpr()
+	}
+
+	identifiedPath := ctx.IdentifiedPath()
+	if identifiedPath == nil || identifiedPath.NodePredicate() != nil || identifiedPath.ObjectPath() == nil {
+		return Source{}, "", false, nil
+	}
+	pathParts := identifiedPath.ObjectPath().AllPathPart()
+	if pathParts[0].IDENTIFIER().GetText() != "tags" {
+		return Source{}, "", false, nil
+	}
+
+	source := Source{}
+	for _, s := range sources {
+		if s.Alias == identifiedPath.IDENTIFIER().GetText() {
+			source = s
+			break
+		}
+	}
+	if _, ok := tagTables[strings.ToUpper(source.Model)]; !ok || source.InModel {
+		return Source{}, "", false, nil
+	}
+
+	if ctx.EXISTS() != nil && len(pathParts) == 1 {
+		return source, "TRUE", true, nil
+	}
+
+	if len(pathParts) != 2 || pathParts[0].NodePredicate() != nil || pathParts[1].NodePredicate() != nil {
+		return Source{}, "", false, fmt.Errorf("unsupported tags path %s, expected tags/key, tags/value or tags/target_path", identifiedPath.GetText())
+	}
+	field := pathParts[1].IDENTIFIER().GetText()
+	if !slices.Contains(tagFields, field) {
+		return Source{}, "", false, fmt.Errorf("unsupported tag attribute %s, expected one of %s", field, strings.Join(tagFields, ", "))
+	}
+
+	switch true {
+	case ctx.EXISTS() != nil:
+		return source, fmt.Sprintf("tag.data->'%s' IS NOT NULL", field), true, nil
+	case ctx.COMPARISON_OPERATOR() != nil:
+		// Tag values are plain strings, so no temporal normalization is applied
+		value, err := BuildTerminal(ctx.Terminal(), params, sources)
+		if err != nil {
+			return Source{}, "", false, err
+		}
+		return source, fmt.Sprintf("tag.data->'%s' %s %s", field, ctx.COMPARISON_OPERATOR().GetText(), value), true, nil
+	case ctx.LIKE() != nil:
+		operand, err := BuildLikeOperand(ctx.LikeOperand(), params)
+		if err != nil {
+			return Source{}, "", false, err
+		}
+		return source, fmt.Sprintf("tag.data->>'%s' LIKE %s", field, operand), true, nil
+	case ctx.MATCHES() != nil:
+		values, err := BuildMatchedOperand(ctx.MatchesOperand(), params)
+		if err != nil {
+			return Source{}, "", false, err
+		}
+		return source, fmt.Sprintf("tag.data->'%s' IN (%s)", field, values), true, nil
+	default:
+		return Source{}, "", false, fmt.Errorf("unsupported expression on tags path %s", identifiedPath.GetText())
+	}
+}
+
+// BuildTagExistsExpr checks the source carries a single tag meeting all conditions
+func BuildTagExistsExpr(source Source, conditions []string) string {
+	condition := strings.Join(conditions, " AND ")
+
+	tables := tagTables[strings.ToUpper(source.Model)]
+	expressions := make([]string, len(tables))
+	for i, table := range tables {
+		expressions[i] = fmt.Sprintf("EXISTS(SELECT 1 FROM %s tag WHERE tag.%s = %s.%s AND %s)", table.table, table.column, source.Table, table.sourceColumn, condition)
+	}
+
+	return "(" + strings.Join(expressions, " OR ") + ")"
+}
+
+// BuildTagAndExpr builds a chain of AND expressions, where the conditions on the tags of the same source must hold for the same tag.
+// This makes `c/tags/key = 'triage' AND c/tags/value = 'urgent'` match a triage tag with value urgent. ok is false when no tags are involved.
+func BuildTagAndExpr(ctx gen.IWhereExprContext, params map[string]any, sources []Source) (string, bool, error) {
+	var operands []gen.IWhereExprContext
+	var flatten func(expr gen.IWhereExprContext)
+	flatten = func(expr gen.IWhereExprContext) {
+		switch true {
+		case expr.AND() != nil:
+			flatten(expr.WhereExpr(0))
+			flatten(expr.WhereExpr(1))
+		case expr.SYM_LEFT_PAREN() != nil && expr.WhereExpr(0).AND() != nil:
+			flatten(expr.WhereExpr(0))
+		default:
+			operands = append(operands, expr)
+		}
+	}
+	flatten(ctx)
+
+	expressions := make([]string, 0, len(operands))
+	tagSources := make([]Source, 0)
+	tagConditions := make(map[string][]string)
+	for _, operand := range operands {
+		if operand.IdentifiedExpr() != nil {
+			source, condition, ok, err := BuildTagCondition(operand.IdentifiedExpr(), params, sources)
+			if err != nil {
+				return "", false, err
+			}
+			if ok {
+				if _, seen := tagConditions[source.Table]; !seen {
+					tagSources = append(tagSources, source)
+				}
+				tagConditions[source.Table] = append(tagConditions[source.Table], condition)
+				continue
+			}
+		}
+
+		expression, err := BuildWhereExpr(operand, params, sources)
+		if err != nil {
+			return "", false, err
+		}
+		expressions = append(expressions, fmt.Sprintf("(%s)", expression))
+	}
+
+	if len(tagSources) == 0 {
+		return "", false, nil
+	}
+
+	for _, source := range tagSources {
+		expressions = append(expressions, BuildTagExistsExpr(source, tagConditions[source.Table]))
+	}
+
+	return strings.Join(expressions, " AND "), true, nil
+}
+
 func BuildValueListItem(ctx gen.IValueListItemContext, params map[string]any) (string, error) {
 	switch true {
 	case ctx.Primitive() != nil:
@@ -1541,10 +2000,14 @@ func BuildFunctionCall(ctx gen.IFunctionCallContext, params map[string]any, sour
 	case ctx.DATE_TIME_FUNCTION_ID() != nil:
 		switch strings.ToUpper(ctx.DATE_TIME_FUNCTION_ID().GetText()) {
 		case "CURRENT_DATE":
-			if len(ctx.AllTerminal()) != 0 {
-				return "", fmt.Errorf("CURRENT_DATE function requires no arguments")
+			offset, err := BuildDateTimeOffset(ctx, params)
+			if err != nil {
+				return "", err
 			}
 
+			if offset != "" {
+				return fmt.Sprintf("(CURRENT_DATE + %s)::date", offset), nil
+			}
 			return "CURRENT_DATE", nil
 		case "CURRENT_TIME":
 			if len(ctx.AllTerminal()) != 0 {
@@ -1552,17 +2015,25 @@ func BuildFunctionCall(ctx gen.IFunctionCallContext, params map[string]any, sour
 			}
 
 			return "CURRENT_TIME", nil
-		case "CURRENT_DATETIME":
-			if len(ctx.AllTerminal()) != 0 {
-				return "", fmt.Errorf("CURRENT_DATETIME function requires no arguments")
+		case "CURRENT_DATE_TIME", "CURRENT_DATETIME":
+			offset, err := BuildDateTimeOffset(ctx, params)
+			if err != nil {
+				return "", err
 			}
 
+			if offset != "" {
+				return fmt.Sprintf("(NOW() + %s)", offset), nil
+			}
 			return "NOW()", nil
 		case "NOW":
-			if len(ctx.AllTerminal()) != 0 {
-				return "", fmt.Errorf("NOW function requires no arguments")
+			offset, err := BuildDateTimeOffset(ctx, params)
+			if err != nil {
+				return "", err
 			}
 
+			if offset != "" {
+				return fmt.Sprintf("to_jsonb(now() + %s)", offset), nil
+			}
 			return "to_jsonb(now())", nil
 		case "CURRENT_TIMEZONE":
 			if len(ctx.AllTerminal()) != 0 {
@@ -1578,6 +2049,194 @@ func BuildFunctionCall(ctx gen.IFunctionCallContext, params map[string]any, sour
 	}
 }
 
+var (
+	// Full or partial ISO 8601 date/time, at least year and month (e.g. 2023-05, 2023-05-01T10, 2023-05-01T10:00:00+02:00)
+	iso8601DateTimePattern = regexp.MustCompile(`^\d{4}-\d{2}(-\d{2}([T ]\d{2}(:\d{2}(:\d{2}(\.\d+)?)?)?(Z|[+-]\d{2}(:?\d{2})?)?)?)?$`)
+	// ISO 8601 duration, optionally negative (e.g. P1W, P7D, PT1H30M, -P30D)
+	iso8601DurationPattern = regexp.MustCompile(`^-?P(\d+(\.\d+)?[YMWD])*(T(\d+(\.\d+)?[HMS])+)?$`)
+)
+
+func isISO8601Duration(value string) bool {
+	return iso8601DurationPattern.MatchString(value) && value != "P" && value != "PT" && value != "-P" && value != "-PT"
+}
+
+// BuildDateTimeOffset builds the interval for the optional duration argument of a date/time function,
+// as produced by RewriteDateTimeArithmetic. Returns an empty string when there is no argument.
+func BuildDateTimeOffset(ctx gen.IFunctionCallContext, params map[string]any) (string, error) {
+	name := strings.ToUpper(ctx.DATE_TIME_FUNCTION_ID().GetText())
+
+	switch len(ctx.AllTerminal()) {
+	case 0:
+		return "", nil
+	case 1:
+	default:
+		return "", fmt.Errorf("%s function accepts at most one duration argument", name)
+	}
+
+	var duration string
+	terminal := ctx.Terminal(0)
+	switch true {
+	case terminal.Primitive() != nil && terminal.Primitive().STRING() != nil:
+		duration = terminal.Primitive().STRING().GetText()
+		duration = duration[1 : len(duration)-1] // Remove quotes
+	case terminal.PARAMETER() != nil:
+		paramName := terminal.PARAMETER().GetText()[1:] // Remove leading '$'
+		paramValue, ok := params[paramName]
+		if !ok {
+			return "", fmt.Errorf("missing parameter: %s", paramName)
+		}
+		value, ok := paramValue.(string)
+		if !ok {
+			return "", fmt.Errorf("parameter %s expected to be of type duration", paramName)
+		}
+		duration = value
+	default:
+		return "", fmt.Errorf("%s function argument must be a duration", name)
+	}
+
+	if !isISO8601Duration(duration) {
+		return "", fmt.Errorf("invalid ISO 8601 duration: %s", duration)
+	}
+
+	if strings.HasPrefix(duration, "-") {
+		return fmt.Sprintf("(-'%s'::interval)", duration[1:]), nil
+	}
+	return fmt.Sprintf("'%s'::interval", duration), nil
+}
+
+// ComparisonTerminal is the right hand side of a comparison. Besides the plain value it holds the value normalized
+// to seconds when it is an ISO 8601 date/time or duration, matching the values produced for DV_DATE, DV_DATE_TIME and
+// DV_DURATION, so those comparisons happen in time order instead of lexical order.
+type ComparisonTerminal struct {
+	Plain    string
+	Epoch    string // Seconds since epoch, empty when the value is not a date/time
+	Duration string // Seconds, empty when the value is not a duration
+}
+
+// TemporalKind is what a value is normalized to when compared in time order
+type TemporalKind int
+
+const (
+	TemporalNone TemporalKind = iota
+	TemporalDateTime
+	TemporalDuration
+)
+
+// For returns the value to compare with a left hand side of the given kind
+func (t ComparisonTerminal) For(kind TemporalKind) string {
+	switch true {
+	case kind == TemporalDateTime && t.Epoch != "":
+		return t.Epoch
+	case kind == TemporalDuration && t.Duration != "":
+		return t.Duration
+	default:
+		return t.Plain
+	}
+}
+
+// BuildComparisonTerminal builds the right hand side of a comparison in its plain and normalized forms
+func BuildComparisonTerminal(ctx gen.ITerminalContext, params map[string]any, sources []Source) (ComparisonTerminal, error) {
+	plain, err := BuildTerminal(ctx, params, sources)
+	if err != nil {
+		return ComparisonTerminal{}, err
+	}
+	terminal := ComparisonTerminal{Plain: plain}
+
+	switch true {
+	case ctx.Primitive() != nil:
+		primitive := ctx.Primitive()
+		switch true {
+		case primitive.DATE() != nil, primitive.DATETIME() != nil:
+			value := primitive.GetText()
+			terminal.Epoch = BuildISO8601EpochExpr(fmt.Sprintf("'%s'", value[1:len(value)-1]))
+		case primitive.STRING() != nil:
+			value := primitive.STRING().GetText()
+			terminal.Epoch, terminal.Duration = BuildTemporalLiteral(value[1 : len(value)-1])
+		}
+	case ctx.PARAMETER() != nil:
+		paramName := ctx.PARAMETER().GetText()[1:] // Remove leading '$'
+		if value, ok := params[paramName].(string); ok {
+			terminal.Epoch, terminal.Duration = BuildTemporalLiteral(value)
+		}
+	case ctx.FunctionCall() != nil && ctx.FunctionCall().DATE_TIME_FUNCTION_ID() != nil:
+		functionCall := ctx.FunctionCall()
+		switch strings.ToUpper(functionCall.DATE_TIME_FUNCTION_ID().GetText()) {
+		case "CURRENT_DATE":
+			offset, err := BuildDateTimeOffset(functionCall, params)
+			if err != nil {
+				return ComparisonTerminal{}, err
+			}
+			if offset != "" {
+				offset = " + " + offset
+			}
+
+			terminal.Epoch = fmt.Sprintf("to_jsonb(EXTRACT(EPOCH FROM CURRENT_DATE::timestamptz%s))", offset)
+		case "CURRENT_DATE_TIME", "CURRENT_DATETIME", "NOW":
+			offset, err := BuildDateTimeOffset(functionCall, params)
+			if err != nil {
+				return ComparisonTerminal{}, err
+			}
+			if offset != "" {
+				offset = " + " + offset
+			}
+
+			terminal.Epoch = fmt.Sprintf("to_jsonb(EXTRACT(EPOCH FROM NOW()%s))", offset)
+		}
+	}
+
+	return terminal, nil
+}
+
+// BuildSlowComparisonValueExpr builds the right hand side of a comparison with a value extracted by BuildSlowValueExtractionExpr,
+// the type of the value is only known per row so the normalized form is picked the same way the value itself is normalized
+func BuildSlowComparisonValueExpr(ctx gen.IPathPartContext, terminal ComparisonTerminal) string {
+	if terminal.Epoch == "" && terminal.Duration == "" {
+		return terminal.Plain
+	}
+
+	expression := "CASE "
+	if terminal.Duration != "" {
+		expression += fmt.Sprintf(`WHEN target.data @> '{"_type": "DV_DURATION"}' THEN %s `, terminal.Duration)
+	}
+	if terminal.Epoch != "" {
+		expression += fmt.Sprintf(`WHEN target.data @> '{"_type": "DV_DATE_TIME"}' OR target.data @> '{"_type": "DV_DATE"}' THEN %s `, terminal.Epoch)
+	}
+	if ctx != nil && ctx.IDENTIFIER().GetText() == "value" {
+		if terminal.Duration != "" {
+			expression += fmt.Sprintf(`WHEN parent.data @> '{"_type": "DV_DURATION"}' THEN %s `, terminal.Duration)
+		}
+		if terminal.Epoch != "" {
+			expression += fmt.Sprintf(`WHEN parent.data @> '{"_type": "DV_DATE_TIME"}' OR parent.data @> '{"_type": "DV_DATE"}' THEN %s `, terminal.Epoch)
+		}
+	}
+	expression += fmt.Sprintf("ELSE %s END", terminal.Plain)
+
+	return expression
+}
+
+// BuildTemporalLiteral normalizes a string literal when it is an ISO 8601 date/time or duration,
+// returning the seconds since epoch of a date/time or the seconds of a duration.
+func BuildTemporalLiteral(value string) (epoch string, duration string) {
+	switch true {
+	case iso8601DateTimePattern.MatchString(value):
+		return BuildISO8601EpochExpr(fmt.Sprintf("'%s'", value)), ""
+	case isISO8601Duration(value):
+		return "", BuildISO8601DurationExpr(fmt.Sprintf("'%s'", value))
+	default:
+		return "", ""
+	}
+}
+
+// BuildISO8601EpochExpr converts a text expression holding an ISO 8601 (partial) date/time to seconds since epoch
+func BuildISO8601EpochExpr(textExpr string) string {
+	return fmt.Sprintf("to_jsonb(openehr.iso8601_epoch(%s))", textExpr)
+}
+
+// BuildISO8601DurationExpr converts a text expression holding an ISO 8601 duration to seconds
+func BuildISO8601DurationExpr(textExpr string) string {
+	return fmt.Sprintf("to_jsonb(openehr.iso8601_duration_seconds(%s))", textExpr)
+}
+
 func BuildPrimitive(ctx gen.IPrimitiveContext, jsonPathCompatible bool) (string, error) {
 	switch true {
 	case ctx.STRING() != nil:
@@ -1675,13 +2334,13 @@ func BuildSlowValueExtractionExpr(ctx gen.IPathPartContext) string {
 	expression := "CASE "
 
 	expression += `
-		WHEN target.data @> '{"_type": "DV_DURATION"}' THEN to_jsonb(now() + (target.data ->> 'value')::interval)
+		WHEN target.data @> '{"_type": "DV_DURATION"}' THEN to_jsonb(openehr.iso8601_duration_seconds(target.data ->> 'value'))
 		WHEN target.data @> '{"_type": "DV_ELEMENT"}' THEN target.data -> 'magnitude' 
 		WHEN target.data @> '{"_type": "DV_QUANTITY"}' THEN target.data -> 'magnitude' 
 		WHEN target.data @> '{"_type": "DV_COUNT"}' THEN target.data -> 'magnitude' 
-		WHEN target.data @> '{"_type": "DV_DATE_TIME"}' THEN to_jsonb((target.data ->> 'value')::timestamptz)
+		WHEN target.data @> '{"_type": "DV_DATE_TIME"}' THEN to_jsonb(openehr.iso8601_epoch(target.data ->> 'value'))
 		WHEN target.data @> '{"_type": "DV_TIME"}' THEN to_jsonb((target.data ->> 'value')::timetz AT TIME ZONE 'UTC')
-		WHEN target.data @> '{"_type": "DV_DATE"}' THEN to_jsonb((target.data ->> 'value')::date)
+		WHEN target.data @> '{"_type": "DV_DATE"}' THEN to_jsonb(openehr.iso8601_epoch(target.data ->> 'value'))
 		WHEN target.data @> '{"_type": "DV_ORDINAL"}' THEN target.data -> 'value' 
 		WHEN target.data @> '{"_type": "DV_PROPORTION"}' THEN to_jsonb((target.data ->> 'numerator')::numeric / (target.data ->> 'denominator')::numeric) 
 		WHEN target.data @> '{"_type": "DV_BOOLEAN"}' THEN to_jsonb((target.data ->> 'value')::boolean)
@@ -1694,10 +2353,10 @@ func BuildSlowValueExtractionExpr(ctx gen.IPathPartContext) string {
 	identifier := ctx.IDENTIFIER().GetText()
 	if identifier == "value" {
 		expression += `
-			WHEN parent.data @> '{"_type": "DV_DURATION"}' THEN to_jsonb(now() + (target.data #>> '{}')::interval)
-			WHEN parent.data @> '{"_type": "DV_DATE_TIME"}' THEN to_jsonb((target.data #>> '{}')::timestamptz)
+			WHEN parent.data @> '{"_type": "DV_DURATION"}' THEN to_jsonb(openehr.iso8601_duration_seconds(target.data #>> '{}'))
+			WHEN parent.data @> '{"_type": "DV_DATE_TIME"}' THEN to_jsonb(openehr.iso8601_epoch(target.data #>> '{}'))
 			WHEN parent.data @> '{"_type": "DV_TIME"}' THEN to_jsonb((target.data #>> '{}')::timetz AT TIME ZONE 'UTC')
-			WHEN parent.data @> '{"_type": "DV_DATE"}' THEN to_jsonb((target.data #>> '{}')::date)
+			WHEN parent.data @> '{"_type": "DV_DATE"}' THEN to_jsonb(openehr.iso8601_epoch(target.data #>> '{}'))
 		`
 	}
 
@@ -1719,6 +2378,46 @@ func BuildPathEnding(ctx gen.IPathPartContext, params map[string]any) (string, e
 	return path, nil
 }
 
+// FastValueTemporalKind returns how the value extracted by BuildFastValueExtractionExpr is normalized,
+// only DV_DATE, DV_DATE_TIME, DV_DURATION, their value and the composition start and end time are compared in time order
+func FastValueTemporalKind(ctx gen.IIdentifiedPathContext, params map[string]any, sources []Source) (TemporalKind, error) {
+	source, _, _, _, err := BuildIdentifiedPath(ctx, params, sources)
+	if err != nil {
+		return TemporalNone, err
+	}
+
+	relatedModels := ModelInheritanceTable(source.Model)
+
+	var plainPath string
+	for idx, part := range ctx.ObjectPath().AllPathPart() {
+		if idx > 0 {
+			plainPath += "/"
+		}
+
+		plainPath += part.IDENTIFIER().GetText()
+	}
+
+	switch true {
+	case slices.Contains(relatedModels, rm.COMPOSITION_TYPE):
+		switch plainPath {
+		case "start_time", "end_time", "start_time/value", "end_time/value":
+			return TemporalDateTime, nil
+		}
+	case slices.Contains(relatedModels, rm.DV_DATE_TYPE), slices.Contains(relatedModels, rm.DV_DATE_TIME_TYPE):
+		switch plainPath {
+		case "", "value":
+			return TemporalDateTime, nil
+		}
+	case slices.Contains(relatedModels, rm.DV_DURATION_TYPE):
+		switch plainPath {
+		case "", "value":
+			return TemporalDuration, nil
+		}
+	}
+
+	return TemporalNone, nil
+}
+
 func BuildFastValueExtractionExpr(ctx gen.IIdentifiedPathContext, params map[string]any, sources []Source) (string, error) {
 	source, path, _, _, err := BuildIdentifiedPath(ctx, params, sources)
 	if err != nil {
@@ -1746,9 +2445,9 @@ func BuildFastValueExtractionExpr(ctx gen.IIdentifiedPathContext, params map[str
 	case slices.Contains(relatedModels, rm.COMPOSITION_TYPE):
 		switch plainPath {
 		case "start_time", "end_time":
-			return fmt.Sprintf("jsonb_path_query_first(%s.data, '%s.value')", source.Table, path), nil
+			return BuildISO8601EpochExpr(fmt.Sprintf("jsonb_path_query_first(%s.data, '%s.value') #>> '{}'", source.Table, path)), nil
 		case "start_time/value", "end_time/value":
-			return fmt.Sprintf("jsonb_path_query_first(%s.data, '%s')", source.Table, path), nil
+			return BuildISO8601EpochExpr(fmt.Sprintf("jsonb_path_query_first(%s.data, '%s') #>> '{}'", source.Table, path)), nil
 		}
 	case slices.Contains(relatedModels, "LOCATABLE"):
 		switch plainPath {
@@ -1788,23 +2487,23 @@ func BuildFastValueExtractionExpr(ctx gen.IIdentifiedPathContext, params map[str
 	case slices.Contains(relatedModels, rm.DV_DATE_TYPE):
 		switch plainPath {
 		case "":
-			return fmt.Sprintf("jsonb_path_query_first(%s.data, '%s.value')::text::date", source.Table, path), nil
+			return BuildISO8601EpochExpr(fmt.Sprintf("jsonb_path_query_first(%s.data, '%s.value') #>> '{}'", source.Table, path)), nil
 		case "value":
-			return fmt.Sprintf("jsonb_path_query_first(%s.data, '%s')::text::date", source.Table, path), nil
+			return BuildISO8601EpochExpr(fmt.Sprintf("jsonb_path_query_first(%s.data, '%s') #>> '{}'", source.Table, path)), nil
 		}
 	case slices.Contains(relatedModels, rm.DV_DATE_TIME_TYPE):
 		switch plainPath {
 		case "":
-			return fmt.Sprintf("jsonb_path_query_first(%s.data, '%s.value')::text::timestamptz", source.Table, path), nil
+			return BuildISO8601EpochExpr(fmt.Sprintf("jsonb_path_query_first(%s.data, '%s.value') #>> '{}'", source.Table, path)), nil
 		case "value":
-			return fmt.Sprintf("jsonb_path_query_first(%s.data, '%s')::text::timestamptz", source.Table, path), nil
+			return BuildISO8601EpochExpr(fmt.Sprintf("jsonb_path_query_first(%s.data, '%s') #>> '{}'", source.Table, path)), nil
 		}
 	case slices.Contains(relatedModels, rm.DV_DURATION_TYPE):
 		switch plainPath {
 		case "":
-			return fmt.Sprintf("jsonb_path_query_first(%s.data, '%s.value')::text::interval", source.Table, path), nil
+			return BuildISO8601DurationExpr(fmt.Sprintf("jsonb_path_query_first(%s.data, '%s.value') #>> '{}'", source.Table, path)), nil
 		case "value":
-			return fmt.Sprintf("jsonb_path_query_first(%s.data, '%s')::text::interval", source.Table, path), nil
+			return BuildISO8601DurationExpr(fmt.Sprintf("jsonb_path_query_first(%s.data, '%s') #>> '{}'", source.Table, path)), nil
 		}
 	case slices.Contains(relatedModels, rm.DV_PROPORTION_TYPE):
 		switch plainPath {
@@ -1846,7 +2545,7 @@ func BuildFastValueExtractionExpr(ctx gen.IIdentifiedPathContext, params map[str
 		case "uid/value":
 			return fmt.Sprintf("jsonb_path_query_first(%s.data, '%s')", source.Table, path), nil
 		case "time_created/value":
-			return fmt.Sprintf("jsonb_path_query_first(%s.data, '%s')::text::timestamptz", source.Table, path), nil
+			return BuildISO8601EpochExpr(fmt.Sprintf("jsonb_path_query_first(%s.data, '%s') #>> '{}'", source.Table, path)), nil
 		}
 	}
 
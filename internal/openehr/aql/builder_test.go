@@ -1,6 +1,12 @@
 package aql
 
-import "testing"
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/freekieb7/gopenehr/pkg/utils"
+)
 
 func TestToSQL(t *testing.T) {
 	sql, _, err := ToSQL("SELECT * FROM EHR CONTAINS PERSON CONTAINS ITEM_TREE", nil)
@@ -13,3 +19,332 @@ func TestToSQL(t *testing.T) {
 	}
 	_ = sql // Use sql variable to avoid unused variable error
 }
+
+func TestRewriteDateTimeArithmetic(t *testing.T) {
+	tests := map[string]string{
+		"WHERE d/value > CURRENT_DATE() - P30D":         "WHERE d/value > CURRENT_DATE('-P30D')",
+		"WHERE d/value < NOW() + 'PT1H'":                "WHERE d/value < NOW('PT1H')",
+		"WHERE d/value > CURRENT_DATE()":                "WHERE d/value > CURRENT_DATE()",
+		"WHERE d/value = 'CURRENT_DATE() - P30D'":       "WHERE d/value = 'CURRENT_DATE() - P30D'",
+		"WHERE d/value > CURRENT_DATE_TIME() - P1DT12H": "WHERE d/value > CURRENT_DATE_TIME('-P1DT12H')",
+	}
+
+	for input, expected := range tests {
+		if actual := RewriteDateTimeArithmetic(input); actual != expected {
+			t.Errorf("RewriteDateTimeArithmetic(%q) = %q, expected %q", input, actual, expected)
+		}
+	}
+}
+
+func TestToSQLTemporalComparison(t *testing.T) {
+	sql, _, err := ToSQL("SELECT d/value FROM EHR CONTAINS DV_DATE_TIME d WHERE d/value >= '2023-05' AND d/value < CURRENT_DATE() - P30D", nil)
+	if err != nil {
+		t.Fatalf("ToSQL returned an error: %v", err)
+	}
+
+	if !strings.Contains(sql, "openehr.iso8601_epoch('2023-05')") {
+		t.Fatalf("expected partial date literal to be normalized, got: %s", sql)
+	}
+
+	if !strings.Contains(sql, "CURRENT_DATE::timestamptz + (-'P30D'::interval)") {
+		t.Fatalf("expected date arithmetic to be applied, got: %s", sql)
+	}
+}
+
+func TestToSQLTemporalComparisonOnlyForTemporalValues(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		params      map[string]any
+		contains    []string
+		notContains []string
+	}{
+		{
+			name:        "DV_TEXT value",
+			query:       "SELECT t/value FROM EHR CONTAINS DV_TEXT t WHERE t/value = '2023-05'",
+			contains:    []string{`'"2023-05"'::jsonb`},
+			notContains: []string{"iso8601_epoch('2023-05')"},
+		},
+		{
+			name:        "DV_TEXT value looking like a duration",
+			query:       "SELECT t/value FROM EHR CONTAINS DV_TEXT t WHERE t/value = 'P1D'",
+			contains:    []string{`'"P1D"'::jsonb`},
+			notContains: []string{"iso8601_duration_seconds('P1D')"},
+		},
+		{
+			name:        "id compared with a parameter",
+			query:       "SELECT e/ehr_id/value FROM EHR e WHERE e/ehr_id/value = $id",
+			params:      map[string]any{"id": "2023-05"},
+			contains:    []string{`= '"2023-05"'::jsonb`},
+			notContains: []string{"iso8601_epoch"},
+		},
+		{
+			name:     "DV_DURATION value",
+			query:    "SELECT d/value FROM EHR CONTAINS DV_DURATION d WHERE d/value > 'P1D'",
+			contains: []string{"> to_jsonb(openehr.iso8601_duration_seconds('P1D'))"},
+		},
+		{
+			name:     "composition name looking like a duration",
+			query:    "SELECT c/name/value FROM EHR CONTAINS COMPOSITION c WHERE c/name/value = 'P1D'",
+			contains: []string{`ELSE '"P1D"'::jsonb END`},
+		},
+		{
+			// The type of the value is only known per row, so the normalized form is picked per row
+			name:     "value of unknown type",
+			query:    "SELECT o FROM EHR CONTAINS OBSERVATION o WHERE o/data/events/data/items/value/value > '2023-05'",
+			contains: []string{`WHEN target.data @> '{"_type": "DV_DATE_TIME"}' OR target.data @> '{"_type": "DV_DATE"}' THEN to_jsonb(openehr.iso8601_epoch('2023-05'))`, `ELSE '"2023-05"'::jsonb END`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, _, err := ToSQL(tt.query, tt.params)
+			if err != nil {
+				t.Fatalf("ToSQL returned an error: %v", err)
+			}
+
+			for _, expected := range tt.contains {
+				if !strings.Contains(sql, expected) {
+					t.Errorf("expected SQL to contain %q, got: %s", expected, sql)
+				}
+			}
+			for _, unexpected := range tt.notContains {
+				if strings.Contains(sql, unexpected) {
+					t.Errorf("expected SQL not to contain %q, got: %s", unexpected, sql)
+				}
+			}
+		})
+	}
+}
+
+func TestRewriteCohortMatches(t *testing.T) {
+	tests := map[string]string{
+		"WHERE e/ehr_id/value MATCHES COHORT('diabetes-2025')":    "WHERE e/ehr_id/value MATCHES {cohort:diabetes-2025}",
+		"WHERE e/ehr_id/value MATCHES cohort('diabetes-2025', 3)": "WHERE e/ehr_id/value MATCHES {cohort:diabetes-2025?version=3}",
+		"WHERE e/ehr_id/value MATCHES {'COHORT'}":                 "WHERE e/ehr_id/value MATCHES {'COHORT'}",
+	}
+
+	for input, expected := range tests {
+		if actual := RewriteCohortMatches(input); actual != expected {
+			t.Errorf("RewriteCohortMatches(%q) = %q, expected %q", input, actual, expected)
+		}
+	}
+}
+
+func TestToSQLCohortMatches(t *testing.T) {
+	sql, _, err := ToSQL("SELECT e/ehr_id/value FROM EHR e WHERE e/ehr_id/value MATCHES COHORT('diabetes-2025')", nil)
+	if err != nil {
+		t.Fatalf("ToSQL returned an error: %v", err)
+	}
+
+	if !strings.Contains(sql, "m.cohort_name = 'diabetes-2025' AND m.version = (SELECT c.current_version FROM openehr.tbl_cohort c WHERE c.name = 'diabetes-2025')") {
+		t.Fatalf("expected cohort membership check, got: %s", sql)
+	}
+
+	sql, _, err = ToSQL("SELECT e/ehr_id/value FROM EHR e WHERE e/ehr_id/value MATCHES COHORT('diabetes-2025', 2)", nil)
+	if err != nil {
+		t.Fatalf("ToSQL returned an error: %v", err)
+	}
+
+	if !strings.Contains(sql, "m.cohort_name = 'diabetes-2025' AND m.version = 2") {
+		t.Fatalf("expected pinned cohort version, got: %s", sql)
+	}
+}
+
+func TestToSQLEHRAccess(t *testing.T) {
+	options := Options{EHRAccess: utils.Some(EHRAccessSubject{Subject: "alice", Roles: []string{"psychiatry", "o'neill"}})}
+
+	sql, _, err := ToSQLWithOptions("SELECT c FROM EHR e CONTAINS COMPOSITION c CONTAINS OBSERVATION o", nil, options)
+	if err != nil {
+		t.Fatalf("ToSQLWithOptions returned an error: %v", err)
+	}
+
+	if !strings.Contains(sql, "openehr.ehr_access_allowed(source_0.id, 'alice', ARRAY['psychiatry', 'o''neill']::TEXT[])") {
+		t.Fatalf("expected access check on the EHR source, got: %s", sql)
+	}
+	if !strings.Contains(sql, "openehr.ehr_access_allowed(source_1.ehr_id, 'alice', ARRAY['psychiatry', 'o''neill']::TEXT[])") {
+		t.Fatalf("expected access check on the COMPOSITION source, got: %s", sql)
+	}
+	if strings.Contains(sql, "source_2.ehr_id") {
+		t.Fatalf("expected no access check on a source searched inside a composition, got: %s", sql)
+	}
+}
+
+func TestToSQLNonQueryable(t *testing.T) {
+	sql, _, err := ToSQL("SELECT e/ehr_id/value FROM EHR e CONTAINS COMPOSITION c", nil)
+	if err != nil {
+		t.Fatalf("ToSQL returned an error: %v", err)
+	}
+
+	if !strings.Contains(sql, "openehr.ehr_is_queryable(source_0.id)") || !strings.Contains(sql, "openehr.ehr_is_queryable(source_1.ehr_id)") {
+		t.Fatalf("expected non-queryable EHRs to be excluded, got: %s", sql)
+	}
+
+	sql, _, err = ToSQLWithOptions("SELECT e/ehr_id/value FROM EHR e CONTAINS COMPOSITION c", nil, Options{IncludeNonQueryable: true})
+	if err != nil {
+		t.Fatalf("ToSQLWithOptions returned an error: %v", err)
+	}
+
+	if strings.Contains(sql, "openehr.ehr_is_queryable") {
+		t.Fatalf("expected non-queryable EHRs to be included, got: %s", sql)
+	}
+}
+
+func TestToSQLDeleted(t *testing.T) {
+	sql, _, err := ToSQLWithOptions("SELECT e/ehr_id/value FROM EHR e CONTAINS COMPOSITION c", nil, Options{IncludeNonQueryable: true})
+	if err != nil {
+		t.Fatalf("ToSQLWithOptions returned an error: %v", err)
+	}
+
+	if !strings.Contains(sql, "NOT openehr.ehr_is_deleted(source_0.id)") || !strings.Contains(sql, "NOT openehr.ehr_is_deleted(source_1.ehr_id)") {
+		t.Fatalf("expected deleted EHRs to be excluded, got: %s", sql)
+	}
+}
+
+func TestToSQLIncomplete(t *testing.T) {
+	sql, _, err := ToSQL("SELECT c/uid/value FROM EHR e CONTAINS COMPOSITION c CONTAINS OBSERVATION o", nil)
+	if err != nil {
+		t.Fatalf("ToSQL returned an error: %v", err)
+	}
+
+	if !strings.Contains(sql, "source_1.lifecycle_state IS DISTINCT FROM '553'") {
+		t.Fatalf("expected incomplete compositions to be excluded, got: %s", sql)
+	}
+	if strings.Contains(sql, "source_2.lifecycle_state") {
+		t.Fatalf("expected no lifecycle check on a source searched inside a composition, got: %s", sql)
+	}
+
+	sql, _, err = ToSQLWithOptions("SELECT c/uid/value FROM EHR e CONTAINS COMPOSITION c", nil, Options{IncludeIncomplete: true})
+	if err != nil {
+		t.Fatalf("ToSQLWithOptions returned an error: %v", err)
+	}
+
+	if strings.Contains(sql, "lifecycle_state") {
+		t.Fatalf("expected incomplete compositions to be included, got: %s", sql)
+	}
+}
+
+func TestToSQLCompositionID(t *testing.T) {
+	options := Options{CompositionID: utils.Some("8849182c-82ad-4088-a07f-48ead4180515::local.ehrbase.org::1")}
+
+	sql, _, err := ToSQLWithOptions("SELECT o/data FROM EHR e CONTAINS COMPOSITION c CONTAINS OBSERVATION o", nil, options)
+	if err != nil {
+		t.Fatalf("ToSQLWithOptions returned an error: %v", err)
+	}
+
+	if !strings.Contains(sql, "source_1.id = '8849182c-82ad-4088-a07f-48ead4180515::local.ehrbase.org::1'") {
+		t.Fatalf("expected COMPOSITION source to be restricted to the composition, got: %s", sql)
+	}
+	if strings.Contains(sql, "source_2.id =") {
+		t.Fatalf("expected no composition filter on a source searched inside a composition, got: %s", sql)
+	}
+
+	// A quote in the composition id must not end the literal
+	sql, _, err = ToSQLWithOptions("SELECT c/uid/value FROM EHR e CONTAINS COMPOSITION c", nil, Options{CompositionID: utils.Some("x' OR '1'='1")})
+	if err != nil {
+		t.Fatalf("ToSQLWithOptions returned an error: %v", err)
+	}
+
+	if !strings.Contains(sql, "source_1.id = 'x'' OR ''1''=''1'") {
+		t.Fatalf("expected quotes in the composition id to be escaped, got: %s", sql)
+	}
+
+	if _, _, err := ToSQLWithOptions("SELECT e/ehr_id/value FROM EHR e", nil, options); err == nil {
+		t.Fatalf("expected an error for a composition filter on a query without COMPOSITION")
+	}
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	tests := map[string]string{
+		"":            "''",
+		"alice":       "'alice'",
+		"O'Brien":     "'O''Brien'",
+		"''":          "''''''",
+		`back\slash'`: `'back\slash'''`,
+	}
+
+	for input, expected := range tests {
+		if actual := QuoteLiteral(input); actual != expected {
+			t.Errorf("QuoteLiteral(%q) = %q, expected %q", input, actual, expected)
+		}
+	}
+}
+
+func TestExtractParameters(t *testing.T) {
+	parameters, err := ExtractParameters("SELECT c/name/value FROM EHR e CONTAINS COMPOSITION c[$archetype_id] WHERE e/ehr_id/value = $ehr_id AND c/name/value LIKE $name LIMIT $limit")
+	if err != nil {
+		t.Fatalf("ExtractParameters returned an error: %v", err)
+	}
+
+	expected := []Parameter{
+		{Name: "archetype_id", Type: ParameterTypeString, Required: true},
+		{Name: "ehr_id", Type: ParameterTypeAny, Required: true},
+		{Name: "name", Type: ParameterTypeString, Required: true},
+		{Name: "limit", Type: ParameterTypeInteger, Required: true},
+	}
+	if len(parameters) != len(expected) {
+		t.Fatalf("expected %d parameters, got %v", len(expected), parameters)
+	}
+	for i := range expected {
+		if parameters[i] != expected[i] {
+			t.Errorf("parameter %d = %v, expected %v", i, parameters[i], expected[i])
+		}
+	}
+
+	if _, err := ExtractParameters("SELECT c FROM COMPOSITION c LIMIT $x OFFSET"); err == nil {
+		t.Errorf("expected a syntax error")
+	}
+}
+
+func TestCheckParameters(t *testing.T) {
+	declared := []Parameter{
+		{Name: "limit", Type: ParameterTypeInteger, Required: true},
+		{Name: "name", Type: ParameterTypeString, Required: true},
+	}
+
+	checked, err := CheckParameters(declared, map[string]any{"limit": "10", "name": "x"})
+	if err != nil {
+		t.Fatalf("CheckParameters returned an error: %v", err)
+	}
+	if checked["limit"] != int64(10) {
+		t.Errorf("expected limit to be converted to an integer, got %#v", checked["limit"])
+	}
+
+	for _, values := range []map[string]any{
+		{"name": "x"},
+		{"limit": 1.5, "name": "x"},
+		{"limit": 10, "name": 5.0},
+	} {
+		if _, err := CheckParameters(declared, values); !errors.Is(err, ErrInvalidParameter) {
+			t.Errorf("CheckParameters(%v) expected ErrInvalidParameter, got %v", values, err)
+		}
+	}
+}
+
+func TestToSQLTags(t *testing.T) {
+	sql, _, err := ToSQL("SELECT c/uid/value FROM EHR e CONTAINS COMPOSITION c WHERE c/tags/key = 'triage' AND c/tags/value = 'urgent' AND e/ehr_id/value = '7d44b88c-4199-4bad-97dc-d78268e01398'", nil)
+	if err != nil {
+		t.Fatalf("ToSQL returned an error: %v", err)
+	}
+
+	if !strings.Contains(sql, `EXISTS(SELECT 1 FROM openehr.tbl_composition_tag tag WHERE tag.composition_id = source_1.id AND tag.data->'key' = '"triage"'::jsonb AND tag.data->'value' = '"urgent"'::jsonb)`) {
+		t.Fatalf("expected key and value to be checked on the same composition tag, got: %s", sql)
+	}
+	if !strings.Contains(sql, `EXISTS(SELECT 1 FROM openehr.tbl_versioned_composition_tag tag WHERE tag.versioned_composition_id = source_1.versioned_composition_id AND tag.data->'key' = '"triage"'::jsonb AND tag.data->'value' = '"urgent"'::jsonb)`) {
+		t.Fatalf("expected key and value to be checked on the same versioned composition tag, got: %s", sql)
+	}
+
+	sql, _, err = ToSQL("SELECT s/uid/value FROM EHR_STATUS s WHERE s/tags/value LIKE 'urg*'", nil)
+	if err != nil {
+		t.Fatalf("ToSQL returned an error: %v", err)
+	}
+
+	if !strings.Contains(sql, `EXISTS(SELECT 1 FROM openehr.tbl_ehr_status_tag tag WHERE tag.ehr_status_id = source_0.id AND tag.data->>'value' LIKE 'urg%')`) {
+		t.Fatalf("expected tag value LIKE on the EHR Status tags, got: %s", sql)
+	}
+
+	_, _, err = ToSQL("SELECT c/uid/value FROM COMPOSITION c WHERE c/tags/owner_id = 'x'", nil)
+	if err == nil {
+		t.Fatalf("expected an error for an unsupported tag attribute")
+	}
+}
@@ -0,0 +1,166 @@
+package aql
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/antlr4-go/antlr/v4"
+	"github.com/freekieb7/gopenehr/internal/openehr/aql/gen"
+)
+
+var ErrInvalidParameter = errors.New("invalid query parameter")
+
+type ParameterType string
+
+const (
+	ParameterTypeInteger ParameterType = "integer"
+	ParameterTypeString  ParameterType = "string"
+	// ParameterTypeAny accepts a string, number or boolean, its type is decided by the value it is compared with
+	ParameterTypeAny ParameterType = "any"
+)
+
+// Parameter is a $name placeholder used in an AQL query
+type Parameter struct {
+	Name     string        `json:"name"`
+	Type     ParameterType `json:"type"`
+	Required bool          `json:"required"`
+}
+
+type parameterListener struct {
+	*gen.BaseAQLListener
+	Parameters []Parameter
+	Errors     []error
+
+	// The grammar rules the walker is in decide which values a parameter accepts
+	types []ParameterType
+}
+
+func (l *parameterListener) EnterLimitOperand(_ *gen.LimitOperandContext) {
+	l.types = append(l.types, ParameterTypeInteger)
+}
+
+func (l *parameterListener) ExitLimitOperand(_ *gen.LimitOperandContext) {
+	l.types = l.types[:len(l.types)-1]
+}
+
+func (l *parameterListener) EnterNodePredicate(_ *gen.NodePredicateContext) {
+	l.types = append(l.types, ParameterTypeString)
+}
+
+func (l *parameterListener) ExitNodePredicate(_ *gen.NodePredicateContext) {
+	l.types = l.types[:len(l.types)-1]
+}
+
+func (l *parameterListener) EnterLikeOperand(_ *gen.LikeOperandContext) {
+	l.types = append(l.types, ParameterTypeString)
+}
+
+func (l *parameterListener) ExitLikeOperand(_ *gen.LikeOperandContext) {
+	l.types = l.types[:len(l.types)-1]
+}
+
+func (l *parameterListener) VisitTerminal(node antlr.TerminalNode) {
+	if node.GetSymbol().GetTokenType() != gen.AQLParserPARAMETER {
+		return
+	}
+
+	parameterType := ParameterTypeAny
+	if len(l.types) > 0 {
+		parameterType = l.types[len(l.types)-1]
+	}
+
+	name := node.GetText()[1:] // Remove leading '$'
+	for idx, parameter := range l.Parameters {
+		if parameter.Name != name {
+			continue
+		}
+
+		switch {
+		case parameter.Type == parameterType || parameterType == ParameterTypeAny:
+		case parameter.Type == ParameterTypeAny:
+			l.Parameters[idx].Type = parameterType
+		default:
+			l.Errors = append(l.Errors, fmt.Errorf("parameter %s is used as both %s and %s", name, parameter.Type, parameterType))
+		}
+		return
+	}
+
+	// Every parameter is substituted in the query, so none of them can be left out
+	l.Parameters = append(l.Parameters, Parameter{
+		Name:     name,
+		Type:     parameterType,
+		Required: true,
+	})
+}
+
+// ExtractParameters parses the AQL query and returns the parameters it declares, in order of first use
+func ExtractParameters(aqlQuery string) ([]Parameter, error) {
+	query, err := Parse(aqlQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	listener := &parameterListener{
+		Parameters: make([]Parameter, 0),
+	}
+	antlr.ParseTreeWalkerDefault.Walk(listener, query)
+
+	if len(listener.Errors) > 0 {
+		return nil, errors.Join(listener.Errors...)
+	}
+
+	return listener.Parameters, nil
+}
+
+// CheckParameters validates the values against the declared parameters. Values passed as string,
+// such as URL query parameters, are converted to integers where an integer is declared.
+func CheckParameters(declared []Parameter, values map[string]any) (map[string]any, error) {
+	checked := make(map[string]any, len(values))
+	for name, value := range values {
+		checked[name] = value
+	}
+
+	for _, parameter := range declared {
+		value, ok := values[parameter.Name]
+		if !ok || value == nil {
+			if parameter.Required {
+				return nil, fmt.Errorf("%w: missing parameter %s", ErrInvalidParameter, parameter.Name)
+			}
+			continue
+		}
+
+		switch parameter.Type {
+		case ParameterTypeInteger:
+			switch v := value.(type) {
+			case int, int32, int64:
+			case float64:
+				if v != math.Trunc(v) {
+					return nil, fmt.Errorf("%w: parameter %s must be an integer", ErrInvalidParameter, parameter.Name)
+				}
+				checked[parameter.Name] = int64(v)
+			case string:
+				number, err := strconv.ParseInt(v, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("%w: parameter %s must be an integer", ErrInvalidParameter, parameter.Name)
+				}
+				checked[parameter.Name] = number
+			default:
+				return nil, fmt.Errorf("%w: parameter %s must be an integer", ErrInvalidParameter, parameter.Name)
+			}
+		case ParameterTypeString:
+			if _, ok := value.(string); !ok {
+				return nil, fmt.Errorf("%w: parameter %s must be a string", ErrInvalidParameter, parameter.Name)
+			}
+		default:
+			switch value.(type) {
+			case string, bool, int, int32, int64, float32, float64:
+			default:
+				return nil, fmt.Errorf("%w: parameter %s must be a string, number or boolean", ErrInvalidParameter, parameter.Name)
+			}
+		}
+	}
+
+	return checked, nil
+}
@@ -0,0 +1,362 @@
+package openehr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/freekieb7/gopenehr/internal/attachment"
+	"github.com/freekieb7/gopenehr/internal/database"
+	"github.com/freekieb7/gopenehr/internal/openehr/rm"
+	"github.com/freekieb7/gopenehr/internal/openehr/util"
+	"github.com/google/uuid"
+)
+
+// Attachment is binary content uploaded to an EHR, referenced from DV_MULTIMEDIA uri values
+type Attachment struct {
+	ID        uuid.UUID `json:"id"`
+	EHRID     uuid.UUID `json:"ehr_id"`
+	MediaType string    `json:"media_type"`
+	Size      int64     `json:"size"`
+	// Hash is the hex encoded SHA-256 hash of the content, usable as DV_MULTIMEDIA integrity_check
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// attachmentURIPattern matches the path of the download URL of an attachment, which is the uri handed out on upload.
+// The EHR in the path is the EHR the attachment was uploaded to, which may since have been merged into another EHR.
+var attachmentURIPattern = regexp.MustCompile(`/openehr/v1/ehr/[0-9a-fA-F-]{36}/attachment/([0-9a-fA-F-]{36})$`)
+
+// CreateAttachment stores the content in the attachment store and registers it as attachment of the EHR.
+// The content is spooled to a temporary file first, as the hash addressing the content is only known once it has been read completely.
+func (s *Service) CreateAttachment(ctx context.Context, store attachment.Store, ehrID uuid.UUID, mediaType string, content io.Reader, maxSize int64) (Attachment, error) {
+	spool, err := os.CreateTemp("", "gopenehr-attachment-*")
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to create attachment spool file: %w", err)
+	}
+	defer func() {
+		_ = spool.Close()
+		_ = os.Remove(spool.Name())
+	}()
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, hasher), io.LimitReader(content, maxSize+1))
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to read attachment content: %w", err)
+	}
+	if size == 0 {
+		return Attachment{}, ErrAttachmentEmpty
+	}
+	if size > maxSize {
+		return Attachment{}, ErrAttachmentTooLarge
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return Attachment{}, fmt.Errorf("failed to rewind attachment spool file: %w", err)
+	}
+
+	att := Attachment{
+		ID:        uuid.New(),
+		EHRID:     ehrID,
+		MediaType: mediaType,
+		Size:      size,
+		Hash:      hex.EncodeToString(hasher.Sum(nil)),
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && err != database.ErrTxClosed {
+			s.Logger.ErrorContext(ctx, "Failed to rollback transaction", "error", err)
+		}
+	}()
+
+	if err := checkEHRModifiable(ctx, tx, ehrID); err != nil {
+		return Attachment{}, err
+	}
+
+	// Content is shared between attachments, the lock keeps the purger from deleting it while it is being referenced again
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, att.Hash); err != nil {
+		return Attachment{}, fmt.Errorf("failed to lock attachment content: %w", err)
+	}
+
+	if err := store.Put(ctx, att.Hash, spool, size); err != nil {
+		return Attachment{}, fmt.Errorf("failed to store attachment content: %w", err)
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO openehr.tbl_attachment (id, ehr_id, hash, size, media_type)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`, att.ID, att.EHRID, att.Hash, att.Size, att.MediaType).Scan(&att.CreatedAt)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to insert attachment: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return Attachment{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return att, nil
+}
+
+// GetAttachment returns the attachment of the EHR, or of the EHR it was merged into, so uris handed out before a merge keep working.
+// Attachments of deleted EHRs are not found.
+func (s *Service) GetAttachment(ctx context.Context, ehrID, attachmentID uuid.UUID) (Attachment, error) {
+	var att Attachment
+	err := s.DB.QueryRow(ctx, `
+		WITH RECURSIVE successor AS (
+			SELECT id, merged_into_ehr_id FROM openehr.tbl_ehr WHERE id = $2
+			UNION
+			SELECT e.id, e.merged_into_ehr_id FROM openehr.tbl_ehr e JOIN successor s ON e.id = s.merged_into_ehr_id
+		)
+		SELECT a.id, a.ehr_id, a.media_type, a.size, a.hash, a.created_at
+		FROM openehr.tbl_attachment a
+		JOIN openehr.tbl_ehr e ON e.id = a.ehr_id
+		WHERE a.id = $1 AND a.ehr_id IN (SELECT id FROM successor) AND e.deleted_at IS NULL
+	`, attachmentID, ehrID).Scan(&att.ID, &att.EHRID, &att.MediaType, &att.Size, &att.Hash, &att.CreatedAt)
+	if err != nil {
+		if errors.Is(err, database.ErrNoRows) {
+			return Attachment{}, ErrAttachmentNotFound
+		}
+		return Attachment{}, fmt.Errorf("failed to fetch attachment from database: %w", err)
+	}
+	return att, nil
+}
+
+// DeleteOrphanedAttachmentContent removes the content of the given hashes from the store, unless an attachment still refers to it
+func (s *Service) DeleteOrphanedAttachmentContent(ctx context.Context, store attachment.Store, hashes []string) error {
+	for _, hash := range hashes {
+		if err := s.deleteOrphanedAttachmentContent(ctx, store, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) deleteOrphanedAttachmentContent(ctx context.Context, store attachment.Store, hash string) error {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && err != database.ErrTxClosed {
+			s.Logger.ErrorContext(ctx, "Failed to rollback transaction", "error", err)
+		}
+	}()
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, hash); err != nil {
+		return fmt.Errorf("failed to lock attachment content: %w", err)
+	}
+
+	var referenced bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM openehr.tbl_attachment WHERE hash = $1)`, hash).Scan(&referenced); err != nil {
+		return fmt.Errorf("failed to check if attachment content is referenced: %w", err)
+	}
+	if referenced {
+		return nil
+	}
+
+	if err := store.Delete(ctx, hash); err != nil {
+		return fmt.Errorf("failed to delete attachment content: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// multimediaAttachmentRef is a DV_MULTIMEDIA of a composition whose uri points at an attachment
+type multimediaAttachmentRef struct {
+	path                    string
+	attachmentID            string
+	size                    float64
+	mediaType               string
+	integrityCheck          string
+	integrityCheckAlgorithm string
+}
+
+// verifyAttachmentURIs checks every DV_MULTIMEDIA uri in the composition that points at an attachment.
+// The attachment is resolved by its id and must currently belong to the EHR of the composition, whatever EHR the uri was handed out for,
+// and match the size, media type and SHA-256 integrity check given.
+func verifyAttachmentURIs(ctx context.Context, db queryer, ehrID uuid.UUID, path string, composition rm.COMPOSITION) error {
+	data, err := sonic.Marshal(composition)
+	if err != nil {
+		return fmt.Errorf("failed to marshal composition: %w", err)
+	}
+	var tree any
+	if err := sonic.Unmarshal(data, &tree); err != nil {
+		return fmt.Errorf("failed to unmarshal composition: %w", err)
+	}
+
+	refs := []multimediaAttachmentRef{}
+	collectAttachmentRefs(tree, path, &refs)
+	if len(refs) == 0 {
+		return nil
+	}
+
+	return checkAttachmentRefs(ctx, db, ehrID, refs)
+}
+
+// checkAttachmentRefs resolves the attachments of the refs by id among the attachments of the EHR and compares them with the refs
+func checkAttachmentRefs(ctx context.Context, db queryer, ehrID uuid.UUID, refs []multimediaAttachmentRef) error {
+	validateErr := util.ValidateError{}
+	ids := []uuid.UUID{}
+	for _, ref := range refs {
+		if id, err := uuid.Parse(ref.attachmentID); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	rows, err := db.Query(ctx, `SELECT id, size, media_type, hash FROM openehr.tbl_attachment WHERE ehr_id = $1 AND id = ANY($2)`, ehrID, ids)
+	if err != nil {
+		return fmt.Errorf("failed to query attachments: %w", err)
+	}
+	attachments := map[uuid.UUID]Attachment{}
+	for rows.Next() {
+		var att Attachment
+		if err := rows.Scan(&att.ID, &att.Size, &att.MediaType, &att.Hash); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments[att.ID] = att
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating attachments: %w", err)
+	}
+
+	for _, ref := range refs {
+		id, err := uuid.Parse(ref.attachmentID)
+		att, found := attachments[id]
+		if err != nil || !found {
+			validateErr.Errs = append(validateErr.Errs, util.ValidationError{
+				Model:          rm.DV_MULTIMEDIA_TYPE,
+				Path:           ref.path + ".uri",
+				Message:        "uri refers to an attachment that does not exist for this EHR",
+				Recommendation: "Upload the content as attachment of this EHR and use the uri returned",
+			})
+			continue
+		}
+
+		if int64(ref.size) != att.Size {
+			validateErr.Errs = append(validateErr.Errs, util.ValidationError{
+				Model:          rm.DV_MULTIMEDIA_TYPE,
+				Path:           ref.path + ".size",
+				Message:        fmt.Sprintf("size %d does not match the attachment size %d", int64(ref.size), att.Size),
+				Recommendation: "Ensure size is the size of the attachment in bytes",
+			})
+		}
+
+		if !sameMediaType(ref.mediaType, att.MediaType) {
+			validateErr.Errs = append(validateErr.Errs, util.ValidationError{
+				Model:          rm.DV_MULTIMEDIA_TYPE,
+				Path:           ref.path + ".media_type",
+				Message:        fmt.Sprintf("media type %s does not match the attachment media type %s", ref.mediaType, att.MediaType),
+				Recommendation: "Ensure media_type is the media type the attachment was uploaded with",
+			})
+		}
+
+		if ref.integrityCheck != "" && strings.EqualFold(ref.integrityCheckAlgorithm, "SHA-256") && !sameHash(ref.integrityCheck, att.Hash) {
+			validateErr.Errs = append(validateErr.Errs, util.ValidationError{
+				Model:          rm.DV_MULTIMEDIA_TYPE,
+				Path:           ref.path + ".integrity_check",
+				Message:        "integrity_check does not match the SHA-256 hash of the attachment",
+				Recommendation: "Ensure integrity_check is the hex or base64 encoded SHA-256 hash of the attachment",
+			})
+		}
+	}
+
+	if len(validateErr.Errs) > 0 {
+		return validateErr
+	}
+
+	return nil
+}
+
+// collectAttachmentRefs walks the JSON tree of a composition for DV_MULTIMEDIA values with a uri pointing at an attachment
+func collectAttachmentRefs(node any, path string, refs *[]multimediaAttachmentRef) {
+	switch v := node.(type) {
+	case map[string]any:
+		if ref, ok := multimediaAttachmentRefFromJSON(v, path); ok {
+			*refs = append(*refs, ref)
+		}
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			collectAttachmentRefs(v[key], path+"."+key, refs)
+		}
+	case []any:
+		for i, child := range v {
+			collectAttachmentRefs(child, fmt.Sprintf("%s[%d]", path, i), refs)
+		}
+	}
+}
+
+func multimediaAttachmentRefFromJSON(v map[string]any, path string) (multimediaAttachmentRef, bool) {
+	// Only DV_MULTIMEDIA carries a media type, its thumbnail is not typed explicitly
+	if typ, ok := v["_type"].(string); ok && typ != rm.DV_MULTIMEDIA_TYPE {
+		return multimediaAttachmentRef{}, false
+	}
+	mediaType, ok := v["media_type"].(map[string]any)
+	if !ok {
+		return multimediaAttachmentRef{}, false
+	}
+	uri, ok := v["uri"].(map[string]any)
+	if !ok {
+		return multimediaAttachmentRef{}, false
+	}
+	uriValue, _ := uri["value"].(string)
+	parsed, err := url.Parse(uriValue)
+	if err != nil {
+		return multimediaAttachmentRef{}, false
+	}
+	match := attachmentURIPattern.FindStringSubmatch(parsed.Path)
+	if match == nil {
+		return multimediaAttachmentRef{}, false
+	}
+
+	ref := multimediaAttachmentRef{
+		path:         path,
+		attachmentID: match[1],
+	}
+	ref.size, _ = v["size"].(float64)
+	ref.mediaType, _ = mediaType["code_string"].(string)
+	ref.integrityCheck, _ = v["integrity_check"].(string)
+	if algorithm, ok := v["integrity_check_algorithm"].(map[string]any); ok {
+		ref.integrityCheckAlgorithm, _ = algorithm["code_string"].(string)
+	}
+	return ref, true
+}
+
+// sameMediaType compares media types without their parameters, like a charset
+func sameMediaType(a, b string) bool {
+	typeA, _, errA := mime.ParseMediaType(a)
+	typeB, _, errB := mime.ParseMediaType(b)
+	if errA != nil || errB != nil {
+		return strings.EqualFold(a, b)
+	}
+	return typeA == typeB
+}
+
+// sameHash compares an integrity check, either hex or base64 encoded, with a hex encoded hash
+func sameHash(integrityCheck, hash string) bool {
+	if strings.EqualFold(integrityCheck, hash) {
+		return true
+	}
+	sum, err := hex.DecodeString(hash)
+	if err != nil {
+		return false
+	}
+	return integrityCheck == base64.StdEncoding.EncodeToString(sum)
+}
@@ -0,0 +1,170 @@
+package openehr
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/freekieb7/gopenehr/internal/config"
+	"github.com/freekieb7/gopenehr/internal/database"
+	"github.com/freekieb7/gopenehr/internal/openehr/rm"
+	"github.com/freekieb7/gopenehr/internal/openehr/terminology"
+	"github.com/freekieb7/gopenehr/internal/openehr/util"
+	"github.com/freekieb7/gopenehr/pkg/utils"
+	"github.com/google/uuid"
+)
+
+// AttestationRequest is an ATTESTATION as requested by a client, the system id, commit time and change type are set by the server
+type AttestationRequest struct {
+	Reason       rm.DV_CODED_TEXT                   `json:"reason"`
+	Description  utils.Optional[rm.DvTextUnion]     `json:"description,omitzero"`
+	Committer    utils.Optional[rm.PartyProxyUnion] `json:"committer,omitzero"`
+	AttestedView utils.Optional[rm.DV_MULTIMEDIA]   `json:"attested_view,omitzero"`
+	Proof        utils.Optional[string]             `json:"proof,omitzero"`
+	Items        utils.Optional[[]rm.DV_EHR_URI]    `json:"items,omitzero"`
+	IsPending    bool                               `json:"is_pending"`
+}
+
+func validateAttestationRequest(request AttestationRequest) error {
+	var validateErr util.ValidateError
+
+	if request.Reason.DefiningCode.TerminologyID.Value != terminology.ATTESTATION_REASON_TERMINOLOGY_ID_OPENEHR || !terminology.IsValidAttestationReasonCode(request.Reason.DefiningCode.CodeString) {
+		validateErr.Errs = append(validateErr.Errs, util.ValidationError{
+			Model:          rm.ATTESTATION_TYPE,
+			Path:           "$.reason.defining_code",
+			Message:        fmt.Sprintf("invalid attestation reason %s::%s", request.Reason.DefiningCode.TerminologyID.Value, request.Reason.DefiningCode.CodeString),
+			Recommendation: fmt.Sprintf("Use attestation reason signed (%s) or witnessed (%s) of the openehr terminology", terminology.ATTESTATION_CODE_REASON_SIGNED, terminology.ATTESTATION_CODE_REASON_WITNESSED),
+		})
+	}
+	if request.Description.E {
+		validateErr.Errs = append(validateErr.Errs, request.Description.V.Validate("$.description").Errs...)
+	}
+	if request.Committer.E {
+		validateErr.Errs = append(validateErr.Errs, request.Committer.V.Validate("$.committer").Errs...)
+	}
+	if request.AttestedView.E {
+		validateErr.Errs = append(validateErr.Errs, request.AttestedView.V.Validate("$.attested_view").Errs...)
+	}
+	for i := range request.Items.V {
+		validateErr.Errs = append(validateErr.Errs, request.Items.V[i].Validate(fmt.Sprintf("$.items[%d]", i)).Errs...)
+	}
+
+	if len(validateErr.Errs) > 0 {
+		return validateErr
+	}
+	return nil
+}
+
+// NewAttestation creates the ATTESTATION for a request, the committer defaults to the given subject when the request has none
+func NewAttestation(request AttestationRequest, subject string) rm.ATTESTATION {
+	reason := request.Reason
+	if reason.Value == "" {
+		reason.Value = terminology.GetAttestationReasonName(reason.DefiningCode.CodeString)
+	}
+
+	committer := rm.PARTY_PROXY_from_PARTY_SELF(rm.PARTY_SELF{})
+	if request.Committer.E {
+		committer = request.Committer.V
+	} else if subject != "" {
+		committer = rm.PARTY_PROXY_from_PARTY_IDENTIFIED(rm.PARTY_IDENTIFIED{Name: utils.Some(subject)})
+	}
+
+	return rm.ATTESTATION{
+		SystemID:      config.SYSTEM_ID_GOPENEHR,
+		TimeCommitted: rm.DV_DATE_TIME{Value: time.Now().UTC().Format(time.RFC3339)},
+		ChangeType: rm.DV_CODED_TEXT{
+			Value: terminology.GetAuditChangeTypeName(terminology.AUDIT_CHANGE_TYPE_CODE_ATTESTATION),
+			DefiningCode: rm.CODE_PHRASE{
+				CodeString: string(terminology.AUDIT_CHANGE_TYPE_CODE_ATTESTATION),
+				TerminologyID: rm.TERMINOLOGY_ID{
+					Value: string(terminology.AUDIT_CHANGE_TYPE_TERMINOLOGY_ID_OPENEHR),
+				},
+			},
+		},
+		Description:  request.Description,
+		Committer:    committer,
+		AttestedView: request.AttestedView,
+		Proof:        request.Proof,
+		Items:        request.Items,
+		Reason:       rm.DV_TEXT_from_DV_CODED_TEXT(reason),
+		IsPending:    request.IsPending,
+	}
+}
+
+// Path of the attestations within the version data, imported versions hold them on the ORIGINAL_VERSION they wrap
+const attestationsPathSQL = `CASE WHEN cd.version_data->>'_type' = 'IMPORTED_VERSION' THEN '{item,attestations}'::text[] ELSE '{attestations}'::text[] END`
+
+// AttestCompositionVersion appends an attestation to the attestations of the ORIGINAL_VERSION of a composition
+func (s *Service) AttestCompositionVersion(ctx context.Context, ehrID, versionedCompositionID uuid.UUID, versionID string, request AttestationRequest, subject string) (rm.ATTESTATION, error) {
+	if err := validateAttestationRequest(request); err != nil {
+		return rm.ATTESTATION{}, err
+	}
+
+	attestation := NewAttestation(request, subject)
+	attestation.SetModelName()
+
+	attestationData, err := sonic.Marshal(attestation)
+	if err != nil {
+		return rm.ATTESTATION{}, fmt.Errorf("failed to marshal attestation data: %w", err)
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return rm.ATTESTATION{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && err != database.ErrTxClosed {
+			s.Logger.ErrorContext(ctx, "Failed to rollback transaction", "error", err)
+		}
+	}()
+
+	if err := checkEHRModifiable(ctx, tx, ehrID); err != nil {
+		return rm.ATTESTATION{}, err
+	}
+
+	tag, err := tx.Exec(ctx, fmt.Sprintf(`
+		UPDATE openehr.tbl_composition_data cd
+		SET version_data = jsonb_set(
+			cd.version_data,
+			%[1]s,
+			CASE WHEN jsonb_typeof(cd.version_data #> %[1]s) = 'array' THEN cd.version_data #> %[1]s ELSE '[]'::jsonb END || jsonb_build_array($4::jsonb),
+			true
+		)
+		FROM openehr.tbl_composition c
+		WHERE c.id = cd.id AND c.ehr_id = $1 AND c.versioned_composition_id = $2 AND c.id = $3
+	`, attestationsPathSQL), ehrID, versionedCompositionID, versionID, attestationData)
+	if err != nil {
+		return rm.ATTESTATION{}, fmt.Errorf("failed to add attestation to composition version: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return rm.ATTESTATION{}, ErrCompositionNotFound
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return rm.ATTESTATION{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return attestation, nil
+}
+
+func (s *Service) GetCompositionVersionAttestationsRawJSON(ctx context.Context, ehrID, versionedCompositionID uuid.UUID, versionID string) ([]byte, error) {
+	query := fmt.Sprintf(`
+		SELECT COALESCE(cd.version_data #> %s, '[]'::jsonb)
+		FROM openehr.tbl_composition c
+		JOIN openehr.tbl_composition_data cd ON cd.id = c.id
+		WHERE c.ehr_id = $1 AND c.versioned_composition_id = $2 AND c.id = $3
+		LIMIT 1
+	`, attestationsPathSQL)
+	args := []any{ehrID, versionedCompositionID, versionID}
+
+	var data []byte
+	if err := s.DB.QueryRow(ctx, query, args...).Scan(&data); err != nil {
+		if err == database.ErrNoRows {
+			return nil, ErrCompositionNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch Composition version attestations from database: %w", err)
+	}
+
+	return data, nil
+}
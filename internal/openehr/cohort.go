@@ -0,0 +1,360 @@
+package openehr
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/freekieb7/gopenehr/internal/database"
+	"github.com/freekieb7/gopenehr/internal/openehr/aql"
+	"github.com/freekieb7/gopenehr/internal/telemetry"
+	"github.com/freekieb7/gopenehr/pkg/utils"
+	"github.com/google/uuid"
+)
+
+const CohortRefreshPollInterval = time.Minute
+
+var cohortNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Cohort is a named set of EHR ids, produced by an AQL query that selects e/ehr_id/value.
+// Every refresh stores the result as a new version, so earlier results stay reproducible.
+type Cohort struct {
+	Name                   string                `json:"name"`
+	Description            string                `json:"description"`
+	Query                  string                `json:"query"`
+	Parameters             map[string]any        `json:"parameters"`
+	RefreshIntervalSeconds utils.Optional[int64] `json:"refresh_interval_seconds"`
+	CurrentVersion         int                   `json:"current_version"`
+	CreatedAt              time.Time             `json:"created_at"`
+	// EHRAccess is the caller that created the cohort, refreshes only select EHRs they may read
+	EHRAccess utils.Optional[aql.EHRAccessSubject] `json:"-"`
+}
+
+type CohortVersion struct {
+	CohortName  string    `json:"cohort_name"`
+	Version     int       `json:"version"`
+	MemberCount int       `json:"member_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ValidateCohort checks the cohort name and makes sure the query yields a single column of EHR ids
+func ValidateCohort(cohort Cohort) error {
+	if !cohortNamePattern.MatchString(cohort.Name) {
+		return fmt.Errorf("%w: name may only contain letters, digits, '.', '_' and '-'", ErrCohortInvalid)
+	}
+
+	if cohort.RefreshIntervalSeconds.E && cohort.RefreshIntervalSeconds.V < 60 {
+		return fmt.Errorf("%w: refresh interval must be at least 60 seconds", ErrCohortInvalid)
+	}
+
+	_, columns, err := aql.ToSQL(cohort.Query, cohort.Parameters)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCohortInvalid, err)
+	}
+	if len(columns) != 1 {
+		return fmt.Errorf("%w: query must select e/ehr_id/value only", ErrCohortInvalid)
+	}
+
+	return nil
+}
+
+func (s *Service) CreateCohort(ctx context.Context, cohort Cohort) (Cohort, error) {
+	if cohort.Parameters == nil {
+		cohort.Parameters = make(map[string]any)
+	}
+
+	if err := ValidateCohort(cohort); err != nil {
+		return Cohort{}, err
+	}
+
+	accessSubject, accessRoles := ehrAccessColumns(cohort.EHRAccess)
+	row := s.DB.QueryRow(ctx, `
+		INSERT INTO openehr.tbl_cohort (name, description, query, parameters, refresh_interval_seconds, access_subject, access_roles)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING current_version, created_at
+	`, cohort.Name, cohort.Description, cohort.Query, cohort.Parameters, cohort.RefreshIntervalSeconds, accessSubject, accessRoles)
+	if err := row.Scan(&cohort.CurrentVersion, &cohort.CreatedAt); err != nil {
+		if err == database.ErrNoRows {
+			return Cohort{}, ErrCohortAlreadyExists
+		}
+		return Cohort{}, fmt.Errorf("failed to insert cohort: %w", err)
+	}
+
+	return cohort, nil
+}
+
+func (s *Service) ListCohorts(ctx context.Context) ([]Cohort, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT name, description, query, parameters, refresh_interval_seconds, current_version, created_at
+		FROM openehr.tbl_cohort
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cohorts: %w", err)
+	}
+	defer rows.Close()
+
+	cohorts := make([]Cohort, 0)
+	for rows.Next() {
+		var cohort Cohort
+		if err := rows.Scan(&cohort.Name, &cohort.Description, &cohort.Query, &cohort.Parameters, &cohort.RefreshIntervalSeconds, &cohort.CurrentVersion, &cohort.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan cohort: %w", err)
+		}
+		cohorts = append(cohorts, cohort)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating cohorts: %w", err)
+	}
+
+	return cohorts, nil
+}
+
+func (s *Service) GetCohort(ctx context.Context, name string) (Cohort, error) {
+	var cohort Cohort
+	err := s.DB.QueryRow(ctx, `
+		SELECT name, description, query, parameters, refresh_interval_seconds, current_version, created_at
+		FROM openehr.tbl_cohort
+		WHERE name = $1
+	`, name).Scan(&cohort.Name, &cohort.Description, &cohort.Query, &cohort.Parameters, &cohort.RefreshIntervalSeconds, &cohort.CurrentVersion, &cohort.CreatedAt)
+	if err != nil {
+		if err == database.ErrNoRows {
+			return Cohort{}, ErrCohortNotFound
+		}
+		return Cohort{}, fmt.Errorf("failed to get cohort: %w", err)
+	}
+
+	return cohort, nil
+}
+
+func (s *Service) DeleteCohort(ctx context.Context, name string) error {
+	tag, err := s.DB.Exec(ctx, `DELETE FROM openehr.tbl_cohort WHERE name = $1`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete cohort: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrCohortNotFound
+	}
+
+	return nil
+}
+
+func (s *Service) ListCohortVersions(ctx context.Context, name string) ([]CohortVersion, error) {
+	if _, err := s.GetCohort(ctx, name); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.DB.Query(ctx, `
+		SELECT cohort_name, version, member_count, created_at
+		FROM openehr.tbl_cohort_version
+		WHERE cohort_name = $1
+		ORDER BY version DESC
+	`, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cohort versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make([]CohortVersion, 0)
+	for rows.Next() {
+		var version CohortVersion
+		if err := rows.Scan(&version.CohortName, &version.Version, &version.MemberCount, &version.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan cohort version: %w", err)
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating cohort versions: %w", err)
+	}
+
+	return versions, nil
+}
+
+// GetCohortMembers returns the EHR ids of a cohort version, the current version is used when none is given
+func (s *Service) GetCohortMembers(ctx context.Context, name string, version utils.Optional[int]) ([]uuid.UUID, error) {
+	cohort, err := s.GetCohort(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !version.E {
+		version = utils.Some(cohort.CurrentVersion)
+	}
+
+	var exists bool
+	err = s.DB.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM openehr.tbl_cohort_version WHERE cohort_name = $1 AND version = $2)`, name, version.V).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check cohort version: %w", err)
+	}
+	if !exists {
+		return nil, ErrCohortNotFound
+	}
+
+	rows, err := s.DB.Query(ctx, `SELECT ehr_id FROM openehr.tbl_cohort_member WHERE cohort_name = $1 AND version = $2 ORDER BY ehr_id`, name, version.V)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cohort members: %w", err)
+	}
+	defer rows.Close()
+
+	members := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var ehrID uuid.UUID
+		if err := rows.Scan(&ehrID); err != nil {
+			return nil, fmt.Errorf("failed to scan cohort member: %w", err)
+		}
+		members = append(members, ehrID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating cohort members: %w", err)
+	}
+
+	return members, nil
+}
+
+// RefreshCohort executes the cohort query and stores the resulting EHR ids as a new cohort version
+func (s *Service) RefreshCohort(ctx context.Context, name string) (CohortVersion, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return CohortVersion{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && err != database.ErrTxClosed {
+			s.Logger.ErrorContext(ctx, "Failed to rollback transaction", "error", err)
+		}
+	}()
+
+	// Lock the cohort, so concurrent refreshes cannot claim the same version
+	var cohort Cohort
+	var accessSubject utils.Optional[string]
+	var accessRoles []string
+	err = tx.QueryRow(ctx, `SELECT name, query, parameters, current_version, access_subject, access_roles FROM openehr.tbl_cohort WHERE name = $1 FOR UPDATE`, name).
+		Scan(&cohort.Name, &cohort.Query, &cohort.Parameters, &cohort.CurrentVersion, &accessSubject, &accessRoles)
+	if err != nil {
+		if err == database.ErrNoRows {
+			return CohortVersion{}, ErrCohortNotFound
+		}
+		return CohortVersion{}, fmt.Errorf("failed to get cohort: %w", err)
+	}
+	cohort.EHRAccess = ehrAccessFromColumns(accessSubject, accessRoles)
+
+	sqlQuery, columns, err := aql.ToSQLWithOptions(cohort.Query, cohort.Parameters, aql.Options{EHRAccess: cohort.EHRAccess})
+	if err != nil {
+		return CohortVersion{}, fmt.Errorf("%w: %w", ErrCohortInvalid, err)
+	}
+	if len(columns) != 1 {
+		return CohortVersion{}, fmt.Errorf("%w: query must select e/ehr_id/value only", ErrCohortInvalid)
+	}
+
+	version := CohortVersion{
+		CohortName: cohort.Name,
+		Version:    cohort.CurrentVersion + 1,
+	}
+
+	err = tx.QueryRow(ctx, `INSERT INTO openehr.tbl_cohort_version (cohort_name, version, member_count) VALUES ($1, $2, 0) RETURNING created_at`, version.CohortName, version.Version).Scan(&version.CreatedAt)
+	if err != nil {
+		return CohortVersion{}, fmt.Errorf("failed to insert cohort version: %w", err)
+	}
+
+	// Every row is a JSON array holding the selected EHR id
+	tag, err := tx.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO openehr.tbl_cohort_member (cohort_name, version, ehr_id)
+		SELECT DISTINCT $1::text, $2::int, (cohort_result.data ->> 0)::uuid
+		FROM (%s) AS cohort_result(data)
+		WHERE cohort_result.data ->> 0 IS NOT NULL
+	`, sqlQuery), version.CohortName, version.Version)
+	if err != nil {
+		return CohortVersion{}, fmt.Errorf("failed to insert cohort members: %w", err)
+	}
+	version.MemberCount = int(tag.RowsAffected())
+
+	_, err = tx.Exec(ctx, `UPDATE openehr.tbl_cohort_version SET member_count = $3 WHERE cohort_name = $1 AND version = $2`, version.CohortName, version.Version, version.MemberCount)
+	if err != nil {
+		return CohortVersion{}, fmt.Errorf("failed to update cohort version: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE openehr.tbl_cohort SET current_version = $2 WHERE name = $1`, version.CohortName, version.Version)
+	if err != nil {
+		return CohortVersion{}, fmt.Errorf("failed to update cohort: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return CohortVersion{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return version, nil
+}
+
+// ListDueCohorts returns the names of cohorts with a refresh interval that has elapsed since their last version
+func (s *Service) ListDueCohorts(ctx context.Context) ([]string, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT c.name
+		FROM openehr.tbl_cohort c
+		LEFT JOIN openehr.tbl_cohort_version v ON v.cohort_name = c.name AND v.version = c.current_version
+		WHERE c.refresh_interval_seconds IS NOT NULL
+			AND (v.created_at IS NULL OR v.created_at + make_interval(secs => c.refresh_interval_seconds) <= NOW())
+		ORDER BY c.name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due cohorts: %w", err)
+	}
+	defer rows.Close()
+
+	names := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan cohort name: %w", err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating due cohorts: %w", err)
+	}
+
+	return names, nil
+}
+
+// CohortRefresher periodically refreshes cohorts that have a refresh interval
+type CohortRefresher struct {
+	Logger  *telemetry.Logger
+	Service *Service
+}
+
+func NewCohortRefresher(logger *telemetry.Logger, service *Service) *CohortRefresher {
+	return &CohortRefresher{
+		Logger:  logger,
+		Service: service,
+	}
+}
+
+func (r *CohortRefresher) Start(ctx context.Context) error {
+	ticker := time.NewTicker(CohortRefreshPollInterval)
+	defer ticker.Stop()
+
+	r.Logger.Info("Cohort refresher started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.Logger.Info("Cohort refresher shutting down")
+			return nil
+
+		case <-ticker.C:
+			names, err := r.Service.ListDueCohorts(ctx)
+			if err != nil {
+				r.Logger.ErrorContext(ctx, "Failed to list due cohorts", "error", err)
+				continue
+			}
+
+			for _, name := range names {
+				version, err := r.Service.RefreshCohort(ctx, name)
+				if err != nil {
+					r.Logger.ErrorContext(ctx, "Failed to refresh cohort", "cohort", name, "error", err)
+					continue
+				}
+				r.Logger.InfoContext(ctx, "Cohort refreshed", "cohort", name, "version", version.Version, "members", version.MemberCount)
+			}
+		}
+	}
+}
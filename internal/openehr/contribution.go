@@ -0,0 +1,1067 @@
+package openehr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/freekieb7/gopenehr/internal/database"
+	"github.com/freekieb7/gopenehr/internal/openehr/rm"
+	"github.com/freekieb7/gopenehr/internal/openehr/terminology"
+	outil "github.com/freekieb7/gopenehr/internal/openehr/util"
+	"github.com/freekieb7/gopenehr/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ContributionRequest is a CONTRIBUTION as committed by a client, with the versions embedded instead of referenced
+type ContributionRequest struct {
+	UID      utils.Optional[rm.HIER_OBJECT_ID] `json:"uid,omitzero"`
+	Versions []ContributionVersion             `json:"versions"`
+	Audit    ContributionAudit                 `json:"audit"`
+}
+
+// ImportContributionRequest is a CONTRIBUTION of ORIGINAL_VERSIONs committed on another system, which are stored as IMPORTED_VERSIONs.
+// The uid, preceding version and commit audit of each version are kept as they are.
+type ImportContributionRequest struct {
+	UID      utils.Optional[rm.HIER_OBJECT_ID] `json:"uid,omitzero"`
+	Versions []rm.ORIGINAL_VERSION             `json:"versions"`
+	Audit    ContributionAudit                 `json:"audit"`
+}
+
+// ContributionVersion is an ORIGINAL_VERSION together with the audit of its commit, the change type decides how it is applied.
+// A version on a branch of the version tree, such as 1.1.1, branches from the trunk version it names as preceding version,
+// a trunk version merges the branches given as other input versions.
+type ContributionVersion struct {
+	UID                   rm.OBJECT_VERSION_ID                        `json:"uid"`
+	PrecedingVersionUID   utils.Optional[rm.OBJECT_VERSION_ID]        `json:"preceding_version_uid,omitzero"`
+	OtherInputVersionUIDs utils.Optional[[]rm.OBJECT_VERSION_ID]      `json:"other_input_version_uids,omitzero"`
+	LifecycleState        utils.Optional[rm.DV_CODED_TEXT]            `json:"lifecycle_state,omitzero"`
+	CommitAudit           ContributionAudit                           `json:"commit_audit"`
+	Data                  utils.Optional[rm.OriginalVersionDataUnion] `json:"data,omitzero"`
+}
+
+// ContributionAudit holds the audit fields a client may provide, the system id and commit time are set by the server
+type ContributionAudit struct {
+	ChangeType  utils.Optional[rm.DV_CODED_TEXT]   `json:"change_type,omitzero"`
+	Description utils.Optional[rm.DV_TEXT]         `json:"description,omitzero"`
+	Committer   utils.Optional[rm.PartyProxyUnion] `json:"committer,omitzero"`
+}
+
+// ContributionChange describes what a single version of a committed contribution did to its versioned object
+type ContributionChange struct {
+	Type                  string
+	ChangeType            terminology.AuditChangeType
+	LifecycleState        string
+	VersionedObjectID     uuid.UUID
+	PrecedingVersionUID   utils.Optional[rm.OBJECT_VERSION_ID]
+	OtherInputVersionUIDs []rm.OBJECT_VERSION_ID
+	VersionUID            rm.OBJECT_VERSION_ID
+	CommitAudit           ContributionAudit
+}
+
+// Tables holding the versions of each versioned object type that can be part of an EHR contribution
+var contributionVersionTables = map[string]struct {
+	versionedObjectType string
+	table               string
+	versionedIDColumn   string
+}{
+	rm.COMPOSITION_TYPE: {rm.VERSIONED_COMPOSITION_TYPE, "openehr.tbl_composition", "versioned_composition_id"},
+	rm.EHR_STATUS_TYPE:  {rm.VERSIONED_EHR_STATUS_TYPE, "openehr.tbl_ehr_status", "versioned_ehr_status_id"},
+	rm.FOLDER_TYPE:      {rm.VERSIONED_FOLDER_TYPE, "openehr.tbl_folder", "versioned_folder_id"},
+}
+
+func auditChangeTypeFromCodedText(changeType rm.DV_CODED_TEXT) (terminology.AuditChangeType, error) {
+	code := terminology.AuditChangeType(changeType.DefiningCode.CodeString)
+	switch code {
+	case terminology.AUDIT_CHANGE_TYPE_CODE_CREATION,
+		terminology.AUDIT_CHANGE_TYPE_CODE_AMENDMENT,
+		terminology.AUDIT_CHANGE_TYPE_CODE_MODIFICATION,
+		terminology.AUDIT_CHANGE_TYPE_CODE_DELETED:
+		return code, nil
+	}
+	return "", fmt.Errorf("unsupported change type %q, must be one of creation (249), amendment (250), modification (251) or deleted (523)", changeType.DefiningCode.CodeString)
+}
+
+// Tables holding the versions of each party type that can be part of a demographic contribution
+var partyVersionTables = map[string]string{
+	rm.PERSON_TYPE:       "openehr.tbl_person",
+	rm.AGENT_TYPE:        "openehr.tbl_agent",
+	rm.GROUP_TYPE:        "openehr.tbl_group",
+	rm.ORGANISATION_TYPE: "openehr.tbl_organisation",
+	rm.ROLE_TYPE:         "openehr.tbl_role",
+}
+
+// validateContributionRequest checks the contribution level fields and returns its change type and UID
+func validateContributionRequest(request ContributionRequest) (terminology.AuditChangeType, string, error) {
+	if len(request.Versions) == 0 {
+		return "", "", fmt.Errorf("%w: versions must contain at least one version", ErrContributionInvalid)
+	}
+
+	contributionChangeType := terminology.AUDIT_CHANGE_TYPE_CODE_CREATION
+	if request.Audit.ChangeType.E {
+		changeType, err := auditChangeTypeFromCodedText(request.Audit.ChangeType.V)
+		if err != nil {
+			return "", "", fmt.Errorf("%w: $.audit.change_type: %w", ErrContributionInvalid, err)
+		}
+		contributionChangeType = changeType
+	}
+	if request.Audit.Committer.E {
+		if validateErr := request.Audit.Committer.V.Validate("$.audit.committer"); len(validateErr.Errs) > 0 {
+			return "", "", validateErr
+		}
+	}
+
+	contributionID := uuid.NewString()
+	if request.UID.E {
+		if err := uuid.Validate(request.UID.V.Value); err != nil {
+			return "", "", fmt.Errorf("%w: $.uid must be a UUID", ErrContributionInvalid)
+		}
+		contributionID = request.UID.V.Value
+	}
+
+	return contributionChangeType, contributionID, nil
+}
+
+func newContributionFromRequest(request ContributionRequest, contributionID string, changeType terminology.AuditChangeType, versionRefs []rm.OBJECT_REF) rm.CONTRIBUTION {
+	description := "Contribution committed"
+	if request.Audit.Description.E {
+		description = request.Audit.Description.V.Value
+	}
+
+	contribution := NewContribution(description, changeType, versionRefs)
+	contribution.UID.Value = contributionID
+	if request.Audit.Committer.E {
+		contribution.Audit.Committer = request.Audit.Committer.V
+	}
+
+	return contribution
+}
+
+// newVersionCommitAudit returns the commit audit of a version committed in the contribution. The system id and commit time are those
+// of the contribution, set by the server, the change type, description and committer of the version take precedence over the contribution ones.
+func newVersionCommitAudit(contribution rm.CONTRIBUTION, change ContributionChange) rm.AUDIT_DETAILS {
+	audit := contribution.Audit
+	if change.CommitAudit.ChangeType.E {
+		audit.ChangeType = change.CommitAudit.ChangeType.V
+	}
+	if change.CommitAudit.Description.E {
+		audit.Description = change.CommitAudit.Description
+	}
+	if change.CommitAudit.Committer.E {
+		audit.Committer = change.CommitAudit.Committer.V
+	}
+	return audit
+}
+
+// newContributionRef returns the reference to the contribution that versions committed in it point at
+func newContributionRef(contribution rm.CONTRIBUTION) rm.OBJECT_REF {
+	return rm.OBJECT_REF{
+		Type:      rm.CONTRIBUTION_TYPE,
+		Namespace: rm.Namespace_local,
+		ID:        rm.OBJECT_ID_from_HIER_OBJECT_ID(contribution.UID),
+	}
+}
+
+func checkContributionUnused(ctx context.Context, tx pgx.Tx, contributionID string) error {
+	err := tx.QueryRow(ctx, `SELECT 1 FROM openehr.tbl_contribution WHERE id = $1`, contributionID).Scan(new(int))
+	if err == nil {
+		return ErrContributionAlreadyExists
+	}
+	if !errors.Is(err, database.ErrNoRows) {
+		return fmt.Errorf("failed to check if contribution exists: %w", err)
+	}
+	return nil
+}
+
+// validateContributionVersion checks a version on its own and returns the change it makes, without looking at the database
+func validateContributionVersion(path string, version ContributionVersion) (ContributionChange, error) {
+	var validateErr outil.ValidateError
+	validateErr.Errs = append(validateErr.Errs, version.UID.Validate(path+".uid").Errs...)
+	if version.PrecedingVersionUID.E {
+		validateErr.Errs = append(validateErr.Errs, version.PrecedingVersionUID.V.Validate(path+".preceding_version_uid").Errs...)
+	}
+	for idx := range version.OtherInputVersionUIDs.V {
+		validateErr.Errs = append(validateErr.Errs, version.OtherInputVersionUIDs.V[idx].Validate(fmt.Sprintf("%s.other_input_version_uids[%d]", path, idx)).Errs...)
+	}
+	if len(validateErr.Errs) > 0 {
+		return ContributionChange{}, validateErr
+	}
+
+	if !version.CommitAudit.ChangeType.E {
+		return ContributionChange{}, fmt.Errorf("%w: %s.commit_audit.change_type is required", ErrContributionInvalid, path)
+	}
+	changeType, err := auditChangeTypeFromCodedText(version.CommitAudit.ChangeType.V)
+	if err != nil {
+		return ContributionChange{}, fmt.Errorf("%w: %s.commit_audit.change_type: %w", ErrContributionInvalid, path, err)
+	}
+	if version.CommitAudit.Committer.E {
+		if validateErr := version.CommitAudit.Committer.V.Validate(path + ".commit_audit.committer"); len(validateErr.Errs) > 0 {
+			return ContributionChange{}, validateErr
+		}
+	}
+
+	versionedObjectID, err := uuid.Parse(version.UID.UID())
+	if err != nil {
+		return ContributionChange{}, fmt.Errorf("%w: %s.uid must start with a UUID", ErrContributionInvalid, path)
+	}
+	// Versions are ordered by version_int, which has room for 255 trunk versions, branches and versions per branch
+	if !version.UID.VersionTreeIDFits() {
+		return ContributionChange{}, fmt.Errorf("%w: %s.uid version tree id %s exceeds 255 in one of its parts", ErrContributionInvalid, path, strings.Split(version.UID.Value, "::")[2])
+	}
+
+	lifecycleState, err := lifecycleStateFromCodedText(version.LifecycleState, changeType)
+	if err != nil {
+		return ContributionChange{}, fmt.Errorf("%w: %s.lifecycle_state: %w", ErrContributionInvalid, path, err)
+	}
+
+	change := ContributionChange{
+		ChangeType:            changeType,
+		LifecycleState:        lifecycleState,
+		VersionedObjectID:     versionedObjectID,
+		PrecedingVersionUID:   version.PrecedingVersionUID,
+		OtherInputVersionUIDs: version.OtherInputVersionUIDs.V,
+		VersionUID:            version.UID,
+		CommitAudit:           version.CommitAudit,
+	}
+
+	for idx, otherInputVersionUID := range change.OtherInputVersionUIDs {
+		if otherInputVersionUID.UID() != version.UID.UID() {
+			return ContributionChange{}, fmt.Errorf("%w: %s.other_input_version_uids[%d] does not belong to the same versioned object as the version", ErrContributionInvalid, path, idx)
+		}
+	}
+
+	switch changeType {
+	case terminology.AUDIT_CHANGE_TYPE_CODE_CREATION:
+		if version.PrecedingVersionUID.E {
+			return ContributionChange{}, fmt.Errorf("%w: %s.preceding_version_uid must not be set on creation", ErrContributionInvalid, path)
+		}
+		if len(change.OtherInputVersionUIDs) > 0 {
+			return ContributionChange{}, fmt.Errorf("%w: %s.other_input_version_uids must not be set on creation", ErrContributionInvalid, path)
+		}
+		if !version.UID.VersionTreeID().IsTrunk() {
+			return ContributionChange{}, fmt.Errorf("%w: %s.uid must be a trunk version on creation", ErrContributionInvalid, path)
+		}
+	case terminology.AUDIT_CHANGE_TYPE_CODE_DELETED:
+		if !version.PrecedingVersionUID.E {
+			return ContributionChange{}, fmt.Errorf("%w: %s.preceding_version_uid is required on deletion", ErrContributionInvalid, path)
+		}
+		// Deleting removes the whole versioned object, including its branches
+		if !version.PrecedingVersionUID.V.VersionTreeID().IsTrunk() {
+			return ContributionChange{}, fmt.Errorf("%w: %s.preceding_version_uid must be a trunk version on deletion", ErrContributionInvalid, path)
+		}
+		// Deletions carry no data, the versioned object type is looked up when the contribution is applied
+		return change, nil
+	default:
+		if !version.PrecedingVersionUID.E {
+			return ContributionChange{}, fmt.Errorf("%w: %s.preceding_version_uid is required on %s", ErrContributionInvalid, path, terminology.GetAuditChangeTypeName(changeType))
+		}
+		if version.PrecedingVersionUID.V.UID() != version.UID.UID() {
+			return ContributionChange{}, fmt.Errorf("%w: %s.uid does not belong to the same versioned object as the preceding version", ErrContributionInvalid, path)
+		}
+		if !version.UID.VersionTreeID().Follows(version.PrecedingVersionUID.V.VersionTreeID()) {
+			return ContributionChange{}, fmt.Errorf("%w: %s.uid %s cannot follow preceding version %s in the version tree", ErrContributionInvalid, path, version.UID.VersionTreeID(), version.PrecedingVersionUID.V.VersionTreeID())
+		}
+		if len(change.OtherInputVersionUIDs) > 0 && !version.UID.VersionTreeID().IsTrunk() {
+			return ContributionChange{}, fmt.Errorf("%w: %s.other_input_version_uids can only be merged into a trunk version", ErrContributionInvalid, path)
+		}
+	}
+
+	if !version.Data.E {
+		return ContributionChange{}, fmt.Errorf("%w: %s.data is required on %s", ErrContributionInvalid, path, terminology.GetAuditChangeTypeName(changeType))
+	}
+
+	validateErr = version.Data.V.Validate(path + ".data")
+	if len(validateErr.Errs) > 0 {
+		return ContributionChange{}, validateErr
+	}
+
+	// The version decides the UID of its data
+	versionUID := utils.Some(rm.UID_BASED_ID_from_OBJECT_VERSION_ID(&version.UID))
+	switch data := version.Data.V.Value.(type) {
+	case *rm.COMPOSITION:
+		change.Type = rm.COMPOSITION_TYPE
+		data.UID = versionUID
+	case *rm.EHR_STATUS:
+		change.Type = rm.EHR_STATUS_TYPE
+		data.UID = versionUID
+	case *rm.FOLDER:
+		change.Type = rm.FOLDER_TYPE
+		data.UID = versionUID
+	case *rm.PERSON:
+		change.Type = rm.PERSON_TYPE
+		data.UID = versionUID
+	case *rm.AGENT:
+		change.Type = rm.AGENT_TYPE
+		data.UID = versionUID
+	case *rm.GROUP:
+		change.Type = rm.GROUP_TYPE
+		data.UID = versionUID
+	case *rm.ORGANISATION:
+		change.Type = rm.ORGANISATION_TYPE
+		data.UID = versionUID
+	case *rm.ROLE:
+		change.Type = rm.ROLE_TYPE
+		data.UID = versionUID
+	default:
+		return ContributionChange{}, fmt.Errorf("%w: %s.data type is not supported in a contribution", ErrContributionInvalid, path)
+	}
+
+	// Only compositions keep track of their branches
+	if change.Type != rm.COMPOSITION_TYPE {
+		if !version.UID.VersionTreeID().IsTrunk() {
+			return ContributionChange{}, fmt.Errorf("%w: %s.uid must be a trunk version, only COMPOSITION versions can be committed on a branch", ErrContributionInvalid, path)
+		}
+		if len(change.OtherInputVersionUIDs) > 0 {
+			return ContributionChange{}, fmt.Errorf("%w: %s.other_input_version_uids is only supported for COMPOSITION versions", ErrContributionInvalid, path)
+		}
+	}
+
+	return change, nil
+}
+
+// lifecycleStateFromCodedText returns the lifecycle state code of a version, a deletion is always deleted and other versions are complete unless given otherwise
+func lifecycleStateFromCodedText(lifecycleState utils.Optional[rm.DV_CODED_TEXT], changeType terminology.AuditChangeType) (string, error) {
+	defaultState := terminology.VERSION_LIFECYCLE_STATE_CODE_COMPLETE
+	if changeType == terminology.AUDIT_CHANGE_TYPE_CODE_DELETED {
+		defaultState = terminology.VERSION_LIFECYCLE_STATE_CODE_DELETED
+	}
+	if !lifecycleState.E {
+		return defaultState, nil
+	}
+
+	if lifecycleState.V.DefiningCode.TerminologyID.Value != terminology.VERSION_LIFECYCLE_STATE_TERMINOLOGY_ID_OPENEHR {
+		return "", fmt.Errorf("terminology must be %s, got %q", terminology.VERSION_LIFECYCLE_STATE_TERMINOLOGY_ID_OPENEHR, lifecycleState.V.DefiningCode.TerminologyID.Value)
+	}
+
+	code := lifecycleState.V.DefiningCode.CodeString
+	switch code {
+	case terminology.VERSION_LIFECYCLE_STATE_CODE_COMPLETE, terminology.VERSION_LIFECYCLE_STATE_CODE_INCOMPLETE:
+		if changeType == terminology.AUDIT_CHANGE_TYPE_CODE_DELETED {
+			return "", fmt.Errorf("must be deleted (%s) on deletion", terminology.VERSION_LIFECYCLE_STATE_CODE_DELETED)
+		}
+	case terminology.VERSION_LIFECYCLE_STATE_CODE_DELETED:
+		if changeType != terminology.AUDIT_CHANGE_TYPE_CODE_DELETED {
+			return "", fmt.Errorf("deleted (%s) is only allowed on deletion", terminology.VERSION_LIFECYCLE_STATE_CODE_DELETED)
+		}
+	default:
+		return "", fmt.Errorf("unsupported lifecycle state %q, must be one of complete (%s), incomplete (%s) or deleted (%s)", code, terminology.VERSION_LIFECYCLE_STATE_CODE_COMPLETE, terminology.VERSION_LIFECYCLE_STATE_CODE_INCOMPLETE, terminology.VERSION_LIFECYCLE_STATE_CODE_DELETED)
+	}
+
+	return code, nil
+}
+
+// validateContributionVersions validates every version and makes sure no versioned object is changed twice
+func validateContributionVersions(request ContributionRequest, supported func(rmType string) bool, scope string) ([]ContributionChange, error) {
+	changes := make([]ContributionChange, len(request.Versions))
+	seen := make(map[uuid.UUID]bool, len(request.Versions))
+	for idx, version := range request.Versions {
+		path := fmt.Sprintf("$.versions[%d]", idx)
+
+		change, err := validateContributionVersion(path, version)
+		if err != nil {
+			return nil, err
+		}
+
+		if change.Type != "" && !supported(change.Type) {
+			return nil, fmt.Errorf("%w: %s.data of type %s is not supported in %s contribution", ErrContributionInvalid, path, change.Type, scope)
+		}
+
+		if seen[change.VersionedObjectID] {
+			return nil, fmt.Errorf("%w: %s changes a versioned object that is already changed by another version", ErrContributionInvalid, path)
+		}
+		seen[change.VersionedObjectID] = true
+
+		changes[idx] = change
+	}
+
+	return changes, nil
+}
+
+// CommitContribution applies all versions of the contribution to the EHR in a single transaction.
+// Every version that changes an existing object must name the latest version of it as preceding version.
+func (s *Service) CommitContribution(ctx context.Context, ehrID uuid.UUID, request ContributionRequest) (rm.CONTRIBUTION, []ContributionChange, error) {
+	return s.commitContribution(ctx, ehrID, request, nil)
+}
+
+// ImportContribution applies the versions of another system to the EHR like CommitContribution, wrapped as IMPORTED_VERSIONs of an import contribution.
+func (s *Service) ImportContribution(ctx context.Context, ehrID uuid.UUID, request ImportContributionRequest) (rm.CONTRIBUTION, []ContributionChange, error) {
+	if request.Audit.ChangeType.E {
+		return rm.CONTRIBUTION{}, nil, fmt.Errorf("%w: $.audit.change_type must not be set, the change type of an import is set by the server", ErrContributionInvalid)
+	}
+
+	contributionRequest := ContributionRequest{
+		UID:      request.UID,
+		Versions: make([]ContributionVersion, len(request.Versions)),
+		Audit:    request.Audit,
+	}
+	for idx, version := range request.Versions {
+		path := fmt.Sprintf("$.versions[%d]", idx)
+
+		if !version.CommitAudit.E {
+			return rm.CONTRIBUTION{}, nil, fmt.Errorf("%w: %s.commit_audit is required on import", ErrContributionInvalid, path)
+		}
+		if validateErr := version.CommitAudit.V.Validate(path + ".commit_audit"); len(validateErr.Errs) > 0 {
+			return rm.CONTRIBUTION{}, nil, validateErr
+		}
+
+		data := utils.None[rm.OriginalVersionDataUnion]()
+		if version.Data.Value != nil {
+			data = utils.Some(version.Data)
+		}
+		lifecycleState := utils.None[rm.DV_CODED_TEXT]()
+		if version.LifecycleState.DefiningCode.CodeString != "" {
+			lifecycleState = utils.Some(version.LifecycleState)
+		}
+
+		contributionRequest.Versions[idx] = ContributionVersion{
+			UID:                   version.UID,
+			PrecedingVersionUID:   version.PrecedingVersionUID,
+			OtherInputVersionUIDs: version.OtherInputVersionUIDs,
+			LifecycleState:        lifecycleState,
+			CommitAudit: ContributionAudit{
+				ChangeType: utils.Some(version.CommitAudit.V.ChangeType),
+			},
+			Data: data,
+		}
+	}
+
+	return s.commitContribution(ctx, ehrID, contributionRequest, request.Versions)
+}
+
+// commitContribution applies the contribution, when imported is set its versions are stored as IMPORTED_VERSIONs of the originals at the same index
+func (s *Service) commitContribution(ctx context.Context, ehrID uuid.UUID, request ContributionRequest, imported []rm.ORIGINAL_VERSION) (rm.CONTRIBUTION, []ContributionChange, error) {
+	contributionChangeType, contributionID, err := validateContributionRequest(request)
+	if err != nil {
+		return rm.CONTRIBUTION{}, nil, err
+	}
+	if imported != nil {
+		contributionChangeType = terminology.AUDIT_CHANGE_TYPE_CODE_IMPORT
+	}
+
+	changes, err := validateContributionVersions(request, func(rmType string) bool {
+		_, ok := contributionVersionTables[rmType]
+		return ok
+	}, "an EHR")
+	if err != nil {
+		return rm.CONTRIBUTION{}, nil, err
+	}
+
+	for idx, change := range changes {
+		if change.Type != rm.EHR_STATUS_TYPE {
+			continue
+		}
+		if change.ChangeType == terminology.AUDIT_CHANGE_TYPE_CODE_CREATION {
+			return rm.CONTRIBUTION{}, nil, fmt.Errorf("%w: $.versions[%d] EHR_STATUS is created together with the EHR and can only be modified", ErrContributionInvalid, idx)
+		}
+		if err := s.ValidateEHRStatus(ctx, *request.Versions[idx].Data.V.Value.(*rm.EHR_STATUS)); err != nil {
+			return rm.CONTRIBUTION{}, nil, err
+		}
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return rm.CONTRIBUTION{}, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && err != database.ErrTxClosed {
+			s.Logger.ErrorContext(ctx, "Failed to rollback transaction", "error", err)
+		}
+	}()
+
+	// Lock the EHR, so contributions to the same EHR are checked and applied one after the other
+	if err := tx.QueryRow(ctx, `SELECT 1 FROM openehr.tbl_ehr WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, ehrID).Scan(new(int)); err != nil {
+		if errors.Is(err, database.ErrNoRows) {
+			return rm.CONTRIBUTION{}, nil, ErrEHRNotFound
+		}
+		return rm.CONTRIBUTION{}, nil, fmt.Errorf("failed to lock EHR: %w", err)
+	}
+
+	// Like PUT ehr_status, a frozen EHR can still be unfrozen by a contribution holding only EHR_STATUS
+	statusOnly := true
+	for _, change := range changes {
+		if change.Type != rm.EHR_STATUS_TYPE {
+			statusOnly = false
+			break
+		}
+	}
+	if !statusOnly {
+		if err := checkEHRModifiable(ctx, tx, ehrID); err != nil {
+			return rm.CONTRIBUTION{}, nil, err
+		}
+	}
+
+	if request.UID.E {
+		if err := checkContributionUnused(ctx, tx, contributionID); err != nil {
+			return rm.CONTRIBUTION{}, nil, err
+		}
+	}
+
+	for idx := range changes {
+		if err := s.checkContributionChange(ctx, tx, ehrID, fmt.Sprintf("$.versions[%d]", idx), &changes[idx]); err != nil {
+			return rm.CONTRIBUTION{}, nil, err
+		}
+	}
+
+	for idx, version := range request.Versions {
+		composition, ok := version.Data.V.Value.(*rm.COMPOSITION)
+		if !version.Data.E || !ok {
+			continue
+		}
+		if err := verifyAttachmentURIs(ctx, tx, ehrID, fmt.Sprintf("$.versions[%d].data", idx), *composition); err != nil {
+			return rm.CONTRIBUTION{}, nil, err
+		}
+	}
+
+	versionRefs := make([]rm.OBJECT_REF, 0, len(changes))
+	for _, change := range changes {
+		versionRefs = append(versionRefs, rm.OBJECT_REF{
+			Type:      contributionVersionTables[change.Type].versionedObjectType,
+			Namespace: rm.Namespace_local,
+			ID:        rm.OBJECT_ID_from_OBJECT_VERSION_ID(change.VersionUID),
+		})
+	}
+
+	contribution := newContributionFromRequest(request, contributionID, contributionChangeType, versionRefs)
+
+	batch := &pgx.Batch{}
+
+	// Insert CONTRIBUTION
+	contribution.SetModelName()
+	contributionData, err := sonic.Marshal(contribution)
+	if err != nil {
+		return rm.CONTRIBUTION{}, nil, fmt.Errorf("failed to marshal contribution data: %w", err)
+	}
+	batch.Queue(`INSERT INTO openehr.tbl_contribution (id, ehr_id) VALUES ($1, $2)`, contribution.UID.Value, ehrID)
+	batch.Queue(`INSERT INTO openehr.tbl_contribution_data (id, data) VALUES ($1, $2)`, contribution.UID.Value, contributionData)
+
+	for idx, change := range changes {
+		if folder, ok := request.Versions[idx].Data.V.Value.(*rm.FOLDER); ok && request.Versions[idx].Data.E {
+			// Send the versions queued so far, so the folder items can refer to versioned objects created earlier in the contribution
+			if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+				return rm.CONTRIBUTION{}, nil, fmt.Errorf("failed to execute batch for Contribution commit: %w", err)
+			}
+			batch = &pgx.Batch{}
+
+			if err := validateDirectoryItems(ctx, tx, ehrID, *folder); err != nil {
+				if validateErr, ok := err.(outil.ValidateError); ok {
+					for i := range validateErr.Errs {
+						validateErr.Errs[i].Path = fmt.Sprintf("$.versions[%d].data", idx) + strings.TrimPrefix(validateErr.Errs[i].Path, "$")
+					}
+					return rm.CONTRIBUTION{}, nil, validateErr
+				}
+				return rm.CONTRIBUTION{}, nil, err
+			}
+		}
+
+		importedVersion := utils.None[rm.ORIGINAL_VERSION]()
+		if imported != nil {
+			importedVersion = utils.Some(imported[idx])
+		}
+		if err := queueContributionChange(batch, ehrID, contribution, change, request.Versions[idx].Data.V, importedVersion); err != nil {
+			return rm.CONTRIBUTION{}, nil, err
+		}
+	}
+
+	// Update EHR with contribution ref
+	batch.Queue(`
+		UPDATE openehr.tbl_ehr_data
+		SET data = jsonb_insert(data, '{contributions, -1}', $1::jsonb, true)
+		WHERE id = $2
+	`, rm.OBJECT_REF{
+		Type:      rm.CONTRIBUTION_TYPE,
+		Namespace: rm.Namespace_local,
+		ID:        rm.OBJECT_ID_from_HIER_OBJECT_ID(contribution.UID),
+	}, ehrID)
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return rm.CONTRIBUTION{}, nil, fmt.Errorf("failed to execute batch for Contribution commit: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return rm.CONTRIBUTION{}, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return contribution, changes, nil
+}
+
+// checkContributionChange checks the change against the current state of the EHR, and completes the type of deletions
+func (s *Service) checkContributionChange(ctx context.Context, tx pgx.Tx, ehrID uuid.UUID, path string, change *ContributionChange) error {
+	var versionedObjectType string
+	var versionedObjectEHRID utils.Optional[uuid.UUID]
+	err := tx.QueryRow(ctx, `SELECT type, ehr_id FROM openehr.tbl_versioned_object WHERE id = $1`, change.VersionedObjectID).Scan(&versionedObjectType, &versionedObjectEHRID)
+	if err != nil && !errors.Is(err, database.ErrNoRows) {
+		return fmt.Errorf("failed to fetch versioned object: %w", err)
+	}
+	found := err == nil
+
+	if change.ChangeType == terminology.AUDIT_CHANGE_TYPE_CODE_CREATION {
+		if found {
+			return fmt.Errorf("%w: %s.uid is already used by another versioned object", ErrContributionInvalid, path)
+		}
+
+		if change.Type == rm.FOLDER_TYPE {
+			err := tx.QueryRow(ctx, `SELECT 1 FROM openehr.tbl_versioned_object WHERE ehr_id = $1 AND type = $2`, ehrID, rm.VERSIONED_FOLDER_TYPE).Scan(new(int))
+			if err == nil {
+				return ErrDirectoryAlreadyExists
+			}
+			if !errors.Is(err, database.ErrNoRows) {
+				return fmt.Errorf("failed to check if directory exists: %w", err)
+			}
+		}
+		return nil
+	}
+
+	if !found || !versionedObjectEHRID.E || versionedObjectEHRID.V != ehrID {
+		return fmt.Errorf("%w: %s.preceding_version_uid %s does not exist in this EHR", ErrVersionedObjectNotFound, path, change.PrecedingVersionUID.V.Value)
+	}
+
+	dataType := ""
+	for rmType, tables := range contributionVersionTables {
+		if tables.versionedObjectType == versionedObjectType {
+			dataType = rmType
+		}
+	}
+	if dataType == "" {
+		return fmt.Errorf("%w: %s changes a %s, which is not supported in an EHR contribution", ErrContributionInvalid, path, versionedObjectType)
+	}
+	if change.Type != "" && change.Type != dataType {
+		return fmt.Errorf("%w: %s.data is a %s, but the versioned object holds %s versions", ErrContributionInvalid, path, change.Type, dataType)
+	}
+	if dataType == rm.EHR_STATUS_TYPE && change.ChangeType == terminology.AUDIT_CHANGE_TYPE_CODE_DELETED {
+		return fmt.Errorf("%w: %s EHR_STATUS cannot be deleted", ErrContributionInvalid, path)
+	}
+	change.Type = dataType
+
+	tables := contributionVersionTables[dataType]
+
+	for idx, otherInputVersionUID := range change.OtherInputVersionUIDs {
+		query := fmt.Sprintf(`SELECT 1 FROM %s WHERE %s = $1 AND id = $2`, tables.table, tables.versionedIDColumn)
+		if err := tx.QueryRow(ctx, query, change.VersionedObjectID, otherInputVersionUID.Value).Scan(new(int)); err != nil {
+			if errors.Is(err, database.ErrNoRows) {
+				return fmt.Errorf("%w: %s.other_input_version_uids[%d] %s does not exist", ErrVersionedObjectNotFound, path, idx, otherInputVersionUID.Value)
+			}
+			return fmt.Errorf("failed to check other input version: %w", err)
+		}
+	}
+
+	versionTreeID := change.VersionUID.VersionTreeID()
+	if versionTreeID.IsTrunk() {
+		// Versions on the trunk have neither branch number nor branch version in the lower 16 bits of version_int
+		query := fmt.Sprintf(`SELECT id FROM %s WHERE %s = $1 AND version_int & 65535 = 0 ORDER BY version_int DESC LIMIT 1`, tables.table, tables.versionedIDColumn)
+
+		var latestVersionID string
+		if err := tx.QueryRow(ctx, query, change.VersionedObjectID).Scan(&latestVersionID); err != nil {
+			return fmt.Errorf("failed to fetch latest version of versioned object: %w", err)
+		}
+
+		if latestVersionID != change.PrecedingVersionUID.V.Value {
+			return fmt.Errorf("%w: %s.preceding_version_uid is %s, latest version is %s", ErrContributionVersionConflict, path, change.PrecedingVersionUID.V.Value, latestVersionID)
+		}
+
+		return nil
+	}
+
+	// Versions of the same branch share the trunk version and branch number in the upper bits of version_int
+	query := fmt.Sprintf(`SELECT id FROM %s WHERE %s = $1 AND version_int >> 8 = $2 ORDER BY version_int DESC LIMIT 1`, tables.table, tables.versionedIDColumn)
+
+	var latestBranchVersionID string
+	err = tx.QueryRow(ctx, query, change.VersionedObjectID, versionTreeID.Int()>>8).Scan(&latestBranchVersionID)
+	if err != nil && !errors.Is(err, database.ErrNoRows) {
+		return fmt.Errorf("failed to fetch latest version of branch: %w", err)
+	}
+	if err == nil {
+		if latestBranchVersionID != change.PrecedingVersionUID.V.Value {
+			return fmt.Errorf("%w: %s.preceding_version_uid is %s, latest version of the branch is %s", ErrContributionVersionConflict, path, change.PrecedingVersionUID.V.Value, latestBranchVersionID)
+		}
+		return nil
+	}
+
+	// A new branch starts from an existing trunk version
+	query = fmt.Sprintf(`SELECT 1 FROM %s WHERE %s = $1 AND id = $2`, tables.table, tables.versionedIDColumn)
+	if err := tx.QueryRow(ctx, query, change.VersionedObjectID, change.PrecedingVersionUID.V.Value).Scan(new(int)); err != nil {
+		if errors.Is(err, database.ErrNoRows) {
+			return fmt.Errorf("%w: %s.preceding_version_uid %s does not exist in this EHR", ErrVersionedObjectNotFound, path, change.PrecedingVersionUID.V.Value)
+		}
+		return fmt.Errorf("failed to fetch preceding version of branch: %w", err)
+	}
+
+	return nil
+}
+
+func queueContributionChange(batch *pgx.Batch, ehrID uuid.UUID, contribution rm.CONTRIBUTION, change ContributionChange, data rm.OriginalVersionDataUnion, imported utils.Optional[rm.ORIGINAL_VERSION]) error {
+	contributionID := contribution.UID.Value
+	versionID := change.VersionUID
+
+	if change.ChangeType == terminology.AUDIT_CHANGE_TYPE_CODE_DELETED {
+		batch.Queue(`DELETE FROM openehr.tbl_versioned_object WHERE ehr_id = $1 AND id = $2`, ehrID, change.VersionedObjectID)
+
+		switch change.Type {
+		case rm.COMPOSITION_TYPE:
+			batch.Queue(`
+				UPDATE openehr.tbl_ehr_data
+				SET data = data #- COALESCE((
+					SELECT ARRAY['compositions', (idx-1)::text]
+					FROM jsonb_array_elements(data->'compositions') WITH ORDINALITY arr(item, idx)
+					WHERE item->'id'->>'value' = $1::text
+					LIMIT 1
+				), '{}')
+				WHERE id = $2
+			`, change.VersionedObjectID.String(), ehrID)
+		case rm.FOLDER_TYPE:
+			// Folder reference is deleted as just the first entry, like the openehr docs specify
+			batch.Queue(`UPDATE openehr.tbl_ehr_data SET data = data #- '{directory}' #- '{folders, 0}' WHERE id = $1`, ehrID)
+		}
+		return nil
+	}
+
+	precedingVersion := utils.None[rm.OBJECT_VERSION_ID]()
+	if change.ChangeType != terminology.AUDIT_CHANGE_TYPE_CODE_CREATION {
+		precedingVersion = change.PrecedingVersionUID
+	}
+
+	// Path of the data within the version, which is stored separately from the version itself
+	dataPath := []string{"data"}
+
+	var version any
+	if imported.E {
+		importedVersion := NewImportedVersion(contribution, imported.V)
+		importedVersion.Item.Data = data
+		if importedVersion.Item.LifecycleState.DefiningCode.CodeString == "" {
+			importedVersion.Item.LifecycleState = NewVersionLifecycleState(change.LifecycleState)
+		}
+		importedVersion.SetModelName()
+		version = importedVersion
+		dataPath = []string{"item", "data"}
+	} else {
+		originalVersion := NewOriginalVersion(versionID, data, precedingVersion)
+		originalVersion.LifecycleState = NewVersionLifecycleState(change.LifecycleState)
+		originalVersion.Contribution = utils.Some(newContributionRef(contribution))
+		originalVersion.CommitAudit = utils.Some(newVersionCommitAudit(contribution, change))
+		if len(change.OtherInputVersionUIDs) > 0 {
+			originalVersion.OtherInputVersionUIDs = utils.Some(change.OtherInputVersionUIDs)
+		}
+		originalVersion.SetModelName()
+		version = originalVersion
+	}
+
+	versionData, err := sonic.Marshal(version)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s version data: %w", change.Type, err)
+	}
+
+	switch change.Type {
+	case rm.COMPOSITION_TYPE:
+		if change.ChangeType == terminology.AUDIT_CHANGE_TYPE_CODE_CREATION {
+			versionedComposition := NewVersionedComposition(change.VersionedObjectID, ehrID)
+			versionedComposition.SetModelName()
+			batch.Queue(`INSERT INTO openehr.tbl_versioned_object (id, type, ehr_id) VALUES ($1, $2, $3)`, versionedComposition.UID.Value, rm.VERSIONED_COMPOSITION_TYPE, ehrID)
+			batch.Queue(`INSERT INTO openehr.tbl_versioned_composition_data (id, data) VALUES ($1, $2)`, versionedComposition.UID.Value, versionedComposition)
+			batch.Queue(`
+				UPDATE openehr.tbl_ehr_data
+				SET data = jsonb_insert(data, '{compositions, -1}', $1::jsonb, true)
+				WHERE id = $2
+			`, rm.OBJECT_REF{
+				Type:      rm.VERSIONED_COMPOSITION_TYPE,
+				Namespace: rm.Namespace_local,
+				ID:        rm.OBJECT_ID_from_HIER_OBJECT_ID(versionedComposition.UID),
+			}, ehrID)
+		}
+
+		batch.Queue(`INSERT INTO openehr.tbl_composition (id, version_int, versioned_composition_id, ehr_id, contribution_id, lifecycle_state) VALUES ($1, $2, $3, $4, $5, $6)`, versionID.Value, versionID.VersionTreeID().Int(), change.VersionedObjectID, ehrID, contributionID, change.LifecycleState)
+		batch.Queue(`INSERT INTO openehr.tbl_composition_data (id, data, version_data) VALUES ($1, ($2::jsonb) #> $3::text[], jsonb_set($2::jsonb, $3::text[], 'null', true))`, versionID.Value, versionData, dataPath)
+	case rm.EHR_STATUS_TYPE:
+		ehrStatus := data.Value.(*rm.EHR_STATUS)
+
+		// Only 'local' VERSIONED_PARTY external refs are supported
+		localRefVersionedParty := utils.None[uuid.UUID]()
+		if ehrStatus.Subject.ExternalRef.E && ehrStatus.Subject.ExternalRef.V.Namespace == rm.Namespace_local && ehrStatus.Subject.ExternalRef.V.Type == rm.VERSIONED_PARTY_TYPE {
+			localRefVersionedParty = utils.Some(uuid.MustParse(ehrStatus.Subject.ExternalRef.V.ID.Value.(*rm.HIER_OBJECT_ID).Value))
+		}
+
+		batch.Queue(`INSERT INTO openehr.tbl_ehr_status (id, version_int, versioned_ehr_status_id, ehr_id, contribution_id, local_ref_versioned_party_id) VALUES ($1, $2, $3, $4, $5, $6)`, versionID.Value, versionID.VersionTreeID().Int(), change.VersionedObjectID, ehrID, contributionID, localRefVersionedParty)
+		batch.Queue(`INSERT INTO openehr.tbl_ehr_status_data (id, data, version_data) VALUES ($1, ($2::jsonb) #> $3::text[], jsonb_set($2::jsonb, $3::text[], 'null', true))`, versionID.Value, versionData, dataPath)
+	case rm.FOLDER_TYPE:
+		if change.ChangeType == terminology.AUDIT_CHANGE_TYPE_CODE_CREATION {
+			versionedFolder := NewVersionedFolder(change.VersionedObjectID, ehrID)
+			versionedFolder.SetModelName()
+			batch.Queue(`INSERT INTO openehr.tbl_versioned_object (id, type, ehr_id) VALUES ($1, $2, $3)`, versionedFolder.UID.Value, rm.VERSIONED_FOLDER_TYPE, ehrID)
+			batch.Queue(`INSERT INTO openehr.tbl_versioned_folder_data (id, data) VALUES ($1, $2)`, versionedFolder.UID.Value, versionedFolder)
+			batch.Queue(`
+				UPDATE openehr.tbl_ehr_data
+				SET data = jsonb_insert(jsonb_set(data, '{directory}', $1::jsonb), '{folders, 0}', $1::jsonb)
+				WHERE id = $2
+			`, rm.OBJECT_REF{
+				Type:      rm.VERSIONED_FOLDER_TYPE,
+				Namespace: rm.Namespace_local,
+				ID:        rm.OBJECT_ID_from_HIER_OBJECT_ID(versionedFolder.UID),
+			}, ehrID)
+		}
+
+		batch.Queue(`INSERT INTO openehr.tbl_folder (id, version_int, versioned_folder_id, ehr_id, contribution_id) VALUES ($1, $2, $3, $4, $5)`, versionID.Value, versionID.VersionTreeID().Int(), change.VersionedObjectID, ehrID, contributionID)
+		batch.Queue(`INSERT INTO openehr.tbl_folder_data (id, data, version_data) VALUES ($1, ($2::jsonb) #> $3::text[], jsonb_set($2::jsonb, $3::text[], 'null', true))`, versionID.Value, versionData, dataPath)
+	}
+
+	return nil
+}
+
+// Latest version of a versioned party, whichever party type it holds
+const latestPartyVersionQuery = `
+	SELECT id, type FROM (
+		SELECT id, version_int, 'PERSON' AS type FROM openehr.tbl_person WHERE versioned_party_id = $1
+		UNION ALL
+		SELECT id, version_int, 'AGENT' AS type FROM openehr.tbl_agent WHERE versioned_party_id = $1
+		UNION ALL
+		SELECT id, version_int, 'GROUP' AS type FROM openehr.tbl_group WHERE versioned_party_id = $1
+		UNION ALL
+		SELECT id, version_int, 'ORGANISATION' AS type FROM openehr.tbl_organisation WHERE versioned_party_id = $1
+		UNION ALL
+		SELECT id, version_int, 'ROLE' AS type FROM openehr.tbl_role WHERE versioned_party_id = $1
+	) party_version
+	ORDER BY version_int DESC
+	LIMIT 1
+`
+
+// CommitDemographicContribution applies all party versions of the contribution in a single transaction.
+// Parties may refer to each other through relationships and roles, as long as every referred party exists once the contribution is applied.
+func (s *Service) CommitDemographicContribution(ctx context.Context, request ContributionRequest) (rm.CONTRIBUTION, []ContributionChange, error) {
+	contributionChangeType, contributionID, err := validateContributionRequest(request)
+	if err != nil {
+		return rm.CONTRIBUTION{}, nil, err
+	}
+
+	changes, err := validateContributionVersions(request, func(rmType string) bool {
+		_, ok := partyVersionTables[rmType]
+		return ok
+	}, "a demographic")
+	if err != nil {
+		return rm.CONTRIBUTION{}, nil, err
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return rm.CONTRIBUTION{}, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && err != database.ErrTxClosed {
+			s.Logger.ErrorContext(ctx, "Failed to rollback transaction", "error", err)
+		}
+	}()
+
+	if request.UID.E {
+		if err := checkContributionUnused(ctx, tx, contributionID); err != nil {
+			return rm.CONTRIBUTION{}, nil, err
+		}
+	}
+
+	for idx := range changes {
+		if err := s.checkDemographicContributionChange(ctx, tx, fmt.Sprintf("$.versions[%d]", idx), &changes[idx]); err != nil {
+			return rm.CONTRIBUTION{}, nil, err
+		}
+	}
+
+	// Whether each party changed by this contribution still exists once it is applied
+	existsAfterCommit := make(map[uuid.UUID]bool, len(changes))
+	for _, change := range changes {
+		existsAfterCommit[change.VersionedObjectID] = change.ChangeType != terminology.AUDIT_CHANGE_TYPE_CODE_DELETED
+	}
+
+	for idx, change := range changes {
+		if change.ChangeType == terminology.AUDIT_CHANGE_TYPE_CODE_DELETED {
+			continue
+		}
+
+		path := fmt.Sprintf("$.versions[%d].data", idx)
+		for _, ref := range partyReferences(request.Versions[idx].Data.V) {
+			if err := checkPartyReference(ctx, tx, path, ref, existsAfterCommit); err != nil {
+				return rm.CONTRIBUTION{}, nil, err
+			}
+		}
+	}
+
+	versionRefs := make([]rm.OBJECT_REF, 0, len(changes))
+	for _, change := range changes {
+		versionRefs = append(versionRefs, rm.OBJECT_REF{
+			Type:      rm.VERSIONED_PARTY_TYPE,
+			Namespace: rm.Namespace_local,
+			ID:        rm.OBJECT_ID_from_OBJECT_VERSION_ID(change.VersionUID),
+		})
+	}
+
+	contribution := newContributionFromRequest(request, contributionID, contributionChangeType, versionRefs)
+
+	batch := &pgx.Batch{}
+
+	// Insert CONTRIBUTION
+	contribution.SetModelName()
+	contributionData, err := sonic.Marshal(contribution)
+	if err != nil {
+		return rm.CONTRIBUTION{}, nil, fmt.Errorf("failed to marshal contribution data: %w", err)
+	}
+	batch.Queue(`INSERT INTO openehr.tbl_contribution (id) VALUES ($1)`, contribution.UID.Value)
+	batch.Queue(`INSERT INTO openehr.tbl_contribution_data (id, data) VALUES ($1, $2)`, contribution.UID.Value, contributionData)
+
+	for idx, change := range changes {
+		if err := queueDemographicContributionChange(batch, contribution, change, request.Versions[idx].Data.V); err != nil {
+			return rm.CONTRIBUTION{}, nil, err
+		}
+	}
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return rm.CONTRIBUTION{}, nil, fmt.Errorf("failed to execute batch for Demographic Contribution commit: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return rm.CONTRIBUTION{}, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return contribution, changes, nil
+}
+
+// checkDemographicContributionChange checks the change against the current versions of the party, and completes the type of deletions
+func (s *Service) checkDemographicContributionChange(ctx context.Context, tx pgx.Tx, path string, change *ContributionChange) error {
+	// Lock the versioned party, so concurrent contributions cannot both build on the same preceding version
+	var versionedObjectType string
+	err := tx.QueryRow(ctx, `SELECT type FROM openehr.tbl_versioned_object WHERE id = $1 FOR UPDATE`, change.VersionedObjectID).Scan(&versionedObjectType)
+	if err != nil && !errors.Is(err, database.ErrNoRows) {
+		return fmt.Errorf("failed to fetch versioned object: %w", err)
+	}
+	found := err == nil
+
+	if change.ChangeType == terminology.AUDIT_CHANGE_TYPE_CODE_CREATION {
+		if found {
+			return fmt.Errorf("%w: %s.uid is already used by another versioned object", ErrContributionInvalid, path)
+		}
+		return nil
+	}
+
+	if !found || versionedObjectType != rm.VERSIONED_PARTY_TYPE {
+		return fmt.Errorf("%w: %s.preceding_version_uid %s is not a known party version", ErrVersionedObjectNotFound, path, change.PrecedingVersionUID.V.Value)
+	}
+
+	var latestVersionID, partyType string
+	if err := tx.QueryRow(ctx, latestPartyVersionQuery, change.VersionedObjectID).Scan(&latestVersionID, &partyType); err != nil {
+		if errors.Is(err, database.ErrNoRows) {
+			return fmt.Errorf("%w: %s.preceding_version_uid %s is not a known party version", ErrVersionedObjectNotFound, path, change.PrecedingVersionUID.V.Value)
+		}
+		return fmt.Errorf("failed to fetch latest version of versioned party: %w", err)
+	}
+
+	if change.Type != "" && change.Type != partyType {
+		return fmt.Errorf("%w: %s.data is a %s, but the versioned party holds %s versions", ErrContributionInvalid, path, change.Type, partyType)
+	}
+	change.Type = partyType
+
+	if latestVersionID != change.PrecedingVersionUID.V.Value {
+		return fmt.Errorf("%w: %s.preceding_version_uid is %s, latest version is %s", ErrContributionVersionConflict, path, change.PrecedingVersionUID.V.Value, latestVersionID)
+	}
+
+	return nil
+}
+
+// partyReferences returns the parties the party data refers to through its relationships and roles
+func partyReferences(data rm.OriginalVersionDataUnion) []rm.PARTY_REF {
+	var relationships []rm.PARTY_RELATIONSHIP
+	var refs []rm.PARTY_REF
+
+	switch party := data.Value.(type) {
+	case *rm.PERSON:
+		relationships = append(append(relationships, party.Relationships.V...), party.ReverseRelationships.V...)
+		refs = append(refs, party.Roles.V...)
+	case *rm.AGENT:
+		relationships = append(append(relationships, party.Relationships.V...), party.ReverseRelationships.V...)
+		refs = append(refs, party.Roles.V...)
+	case *rm.GROUP:
+		relationships = append(append(relationships, party.Relationships.V...), party.ReverseRelationships.V...)
+		refs = append(refs, party.Roles.V...)
+	case *rm.ORGANISATION:
+		relationships = append(append(relationships, party.Relationships.V...), party.ReverseRelationships.V...)
+		refs = append(refs, party.Roles.V...)
+	case *rm.ROLE:
+		refs = append(refs, party.Performer)
+	}
+
+	for _, relationship := range relationships {
+		refs = append(refs, relationship.Source, relationship.Target)
+	}
+
+	return refs
+}
+
+func checkPartyReference(ctx context.Context, tx pgx.Tx, path string, ref rm.PARTY_REF, existsAfterCommit map[uuid.UUID]bool) error {
+	// Only 'local' references can be checked
+	if ref.Namespace != rm.Namespace_local {
+		return nil
+	}
+
+	var partyIDStr string
+	switch id := ref.ID.Value.(type) {
+	case *rm.HIER_OBJECT_ID:
+		partyIDStr = id.Value
+	case *rm.OBJECT_VERSION_ID:
+		partyIDStr = id.UID()
+	default:
+		return nil
+	}
+
+	partyID, err := uuid.Parse(partyIDStr)
+	if err != nil {
+		return fmt.Errorf("%w: %s refers to party %s, which is not a UUID", ErrContributionInvalid, path, partyIDStr)
+	}
+
+	if exists, ok := existsAfterCommit[partyID]; ok {
+		if !exists {
+			return fmt.Errorf("%w: %s refers to party %s, which is deleted by this contribution", ErrContributionInvalid, path, partyID)
+		}
+		return nil
+	}
+
+	err = tx.QueryRow(ctx, `SELECT 1 FROM openehr.tbl_versioned_object WHERE id = $1 AND type = $2`, partyID, rm.VERSIONED_PARTY_TYPE).Scan(new(int))
+	if err != nil {
+		if errors.Is(err, database.ErrNoRows) {
+			return fmt.Errorf("%w: %s refers to party %s, which does not exist", ErrContributionInvalid, path, partyID)
+		}
+		return fmt.Errorf("failed to check if referred party exists: %w", err)
+	}
+
+	return nil
+}
+
+func queueDemographicContributionChange(batch *pgx.Batch, contribution rm.CONTRIBUTION, change ContributionChange, data rm.OriginalVersionDataUnion) error {
+	contributionID := contribution.UID.Value
+
+	if change.ChangeType == terminology.AUDIT_CHANGE_TYPE_CODE_DELETED {
+		batch.Queue(`DELETE FROM openehr.tbl_versioned_object WHERE id = $1`, change.VersionedObjectID)
+		return nil
+	}
+
+	precedingVersion := utils.None[rm.OBJECT_VERSION_ID]()
+	if change.ChangeType != terminology.AUDIT_CHANGE_TYPE_CODE_CREATION {
+		precedingVersion = change.PrecedingVersionUID
+	}
+
+	version := NewOriginalVersion(change.VersionUID, data, precedingVersion)
+	version.LifecycleState = NewVersionLifecycleState(change.LifecycleState)
+	version.Contribution = utils.Some(newContributionRef(contribution))
+	version.CommitAudit = utils.Some(newVersionCommitAudit(contribution, change))
+	version.SetModelName()
+	versionData, err := sonic.Marshal(version)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s version data: %w", change.Type, err)
+	}
+
+	if change.ChangeType == terminology.AUDIT_CHANGE_TYPE_CODE_CREATION {
+		versionedParty := NewVersionedParty(change.VersionedObjectID)
+		versionedParty.SetModelName()
+		batch.Queue(`INSERT INTO openehr.tbl_versioned_object (id, type) VALUES ($1, $2)`, versionedParty.UID.Value, rm.VERSIONED_PARTY_TYPE)
+		batch.Queue(`INSERT INTO openehr.tbl_versioned_party_data (id, data) VALUES ($1, $2)`, versionedParty.UID.Value, versionedParty)
+	}
+
+	table := partyVersionTables[change.Type]
+	batch.Queue(fmt.Sprintf(`INSERT INTO %s (id, version_int, versioned_party_id, contribution_id) VALUES ($1, $2, $3, $4)`, table), change.VersionUID.Value, change.VersionUID.VersionTreeID().Int(), change.VersionedObjectID, contributionID)
+	batch.Queue(fmt.Sprintf(`INSERT INTO %s_data (id, data, version_data) VALUES ($1, ($2::jsonb)->'data', jsonb_set($2::jsonb, '{data}', 'null', true))`, table), change.VersionUID.Value, versionData)
+
+	return nil
+}
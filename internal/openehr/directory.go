@@ -0,0 +1,585 @@
+package openehr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/freekieb7/gopenehr/internal/config"
+	"github.com/freekieb7/gopenehr/internal/database"
+	"github.com/freekieb7/gopenehr/internal/openehr/rm"
+	"github.com/freekieb7/gopenehr/internal/openehr/terminology"
+	outil "github.com/freekieb7/gopenehr/internal/openehr/util"
+	"github.com/freekieb7/gopenehr/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// queryer is implemented by both the connection pool and a transaction
+type queryer interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// AddDirectoryItem adds an item reference to the folder at the path, producing a new directory version
+func (s *Service) AddDirectoryItem(ctx context.Context, ehrID uuid.UUID, currentDirectoryID rm.OBJECT_VERSION_ID, path string, item rm.OBJECT_REF) (rm.FOLDER, error) {
+	validateErr := item.Validate("$")
+	if len(validateErr.Errs) > 0 {
+		return rm.FOLDER{}, validateErr
+	}
+
+	return s.changeDirectory(ctx, ehrID, currentDirectoryID, "Directory item added", func(directory *rm.FOLDER) error {
+		folder, err := findDirectoryFolder(directory, path)
+		if err != nil {
+			return err
+		}
+
+		itemID := objectRefIDValue(item)
+		for _, existing := range folder.Items.V {
+			if objectRefIDValue(existing) == itemID {
+				return ErrDirectoryItemAlreadyExists
+			}
+		}
+
+		folder.Items = utils.Some(append(folder.Items.V, item))
+		return nil
+	})
+}
+
+// RemoveDirectoryItem removes the item reference with the given id value from the folder at the path, producing a new directory version
+func (s *Service) RemoveDirectoryItem(ctx context.Context, ehrID uuid.UUID, currentDirectoryID rm.OBJECT_VERSION_ID, path string, itemID string) (rm.FOLDER, error) {
+	return s.changeDirectory(ctx, ehrID, currentDirectoryID, "Directory item removed", func(directory *rm.FOLDER) error {
+		folder, err := findDirectoryFolder(directory, path)
+		if err != nil {
+			return err
+		}
+
+		idx := slices.IndexFunc(folder.Items.V, func(item rm.OBJECT_REF) bool {
+			return objectRefIDValue(item) == itemID
+		})
+		if idx < 0 {
+			return ErrDirectoryItemNotFound
+		}
+
+		folder.Items.V = slices.Delete(folder.Items.V, idx, idx+1)
+		if len(folder.Items.V) == 0 {
+			folder.Items = utils.None[[]rm.OBJECT_REF]()
+		}
+		return nil
+	})
+}
+
+// CreateDirectoryFolder adds a sub-folder to the folder at the path, producing a new directory version
+func (s *Service) CreateDirectoryFolder(ctx context.Context, ehrID uuid.UUID, currentDirectoryID rm.OBJECT_VERSION_ID, path string, folder rm.FOLDER) (rm.FOLDER, error) {
+	validateErr := folder.Validate("$")
+	if len(validateErr.Errs) > 0 {
+		return rm.FOLDER{}, validateErr
+	}
+
+	return s.changeDirectory(ctx, ehrID, currentDirectoryID, "Directory folder created", func(directory *rm.FOLDER) error {
+		parent, err := findDirectoryFolder(directory, path)
+		if err != nil {
+			return err
+		}
+
+		if hasSubFolder(*parent, folderName(folder)) {
+			return ErrDirectoryFolderAlreadyExists
+		}
+
+		parent.Folders = utils.Some(append(parent.Folders.V, folder))
+		return nil
+	})
+}
+
+// MoveDirectoryFolder renames the folder at the path and/or moves it under the folder at the parent path, producing a new directory version.
+// The root folder can be renamed but not moved.
+func (s *Service) MoveDirectoryFolder(ctx context.Context, ehrID uuid.UUID, currentDirectoryID rm.OBJECT_VERSION_ID, path string, name utils.Optional[string], parentPath utils.Optional[string]) (rm.FOLDER, error) {
+	segments := splitDirectoryPath(path)
+
+	if parentPath.E {
+		// A folder cannot be moved into itself or into one of its sub-folders
+		parentSegments := splitDirectoryPath(parentPath.V)
+		if len(segments) == 0 || (len(parentSegments) >= len(segments) && slices.Equal(parentSegments[:len(segments)], segments)) {
+			return rm.FOLDER{}, ErrDirectoryFolderMoveInvalid
+		}
+	}
+
+	return s.changeDirectory(ctx, ehrID, currentDirectoryID, "Directory folder moved", func(directory *rm.FOLDER) error {
+		folder, err := findDirectoryFolder(directory, path)
+		if err != nil {
+			return err
+		}
+
+		newName := folderName(*folder)
+		if name.E {
+			newName = name.V
+		}
+
+		if !parentPath.E {
+			if len(segments) > 0 && newName != folderName(*folder) {
+				parent, err := findDirectoryFolder(directory, strings.Join(segments[:len(segments)-1], "/"))
+				if err != nil {
+					return err
+				}
+				if hasSubFolder(*parent, newName) {
+					return ErrDirectoryFolderAlreadyExists
+				}
+			}
+			setFolderName(folder, newName)
+			return nil
+		}
+
+		target, err := findDirectoryFolder(directory, parentPath.V)
+		if err != nil {
+			return err
+		}
+		if hasSubFolder(*target, newName) {
+			return ErrDirectoryFolderAlreadyExists
+		}
+
+		moved := *folder
+		setFolderName(&moved, newName)
+
+		// Detach from the current parent, this shifts the folders slice so the target is looked up again afterwards
+		parent, err := findDirectoryFolder(directory, strings.Join(segments[:len(segments)-1], "/"))
+		if err != nil {
+			return err
+		}
+		idx := slices.IndexFunc(parent.Folders.V, func(f rm.FOLDER) bool {
+			return folderName(f) == segments[len(segments)-1]
+		})
+		parent.Folders.V = slices.Delete(parent.Folders.V, idx, idx+1)
+		if len(parent.Folders.V) == 0 {
+			parent.Folders = utils.None[[]rm.FOLDER]()
+		}
+
+		target, err = findDirectoryFolder(directory, parentPath.V)
+		if err != nil {
+			return err
+		}
+		target.Folders = utils.Some(append(target.Folders.V, moved))
+		return nil
+	})
+}
+
+// ListDirectoryCompositionsRawJSON returns the compositions referenced by the folder at the path of the latest directory version, in item order.
+// A VERSIONED_COMPOSITION reference resolves to its latest version, a COMPOSITION reference to the exact version.
+// With recursive set the items of all sub-folders are included as well.
+func (s *Service) ListDirectoryCompositionsRawJSON(ctx context.Context, ehrID uuid.UUID, path string, recursive bool) ([]byte, error) {
+	var data []byte
+	err := s.DB.QueryRow(ctx, `
+		SELECT fd.data
+		FROM openehr.tbl_folder f
+		JOIN openehr.tbl_folder_data fd ON fd.id = f.id
+		WHERE f.ehr_id = $1
+		ORDER BY f.version_int DESC
+		LIMIT 1
+	`, ehrID).Scan(&data)
+	if err != nil {
+		if err == database.ErrNoRows {
+			return nil, ErrDirectoryNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch Directory from database: %w", err)
+	}
+
+	var directory rm.FOLDER
+	if err := sonic.Unmarshal(data, &directory); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Directory: %w", err)
+	}
+
+	folder, err := findDirectoryFolder(&directory, path)
+	if err != nil {
+		return nil, err
+	}
+
+	order := []string{}
+	versionedIDs := []string{}
+	versionIDs := []string{}
+	var collect func(folder rm.FOLDER)
+	collect = func(folder rm.FOLDER) {
+		for _, item := range folder.Items.V {
+			if item.Namespace != config.NAMESPACE_LOCAL {
+				continue
+			}
+			switch {
+			case item.Type == rm.VERSIONED_COMPOSITION_TYPE && item.ID.Kind == rm.OBJECT_ID_kind_HIER_OBJECT_ID:
+				versionedIDs = append(versionedIDs, item.ID.HIER_OBJECT_ID().Value)
+				order = append(order, item.ID.HIER_OBJECT_ID().Value)
+			case item.Type == rm.COMPOSITION_TYPE && item.ID.Kind == rm.OBJECT_ID_kind_OBJECT_VERSION_ID:
+				versionIDs = append(versionIDs, item.ID.OBJECT_VERSION_ID().Value)
+				order = append(order, item.ID.OBJECT_VERSION_ID().Value)
+			}
+		}
+		if recursive {
+			for _, sub := range folder.Folders.V {
+				collect(sub)
+			}
+		}
+	}
+	collect(*folder)
+
+	rows, err := s.DB.Query(ctx, `
+		SELECT DISTINCT ON (c.versioned_composition_id) c.versioned_composition_id::TEXT, cd.data
+		FROM openehr.tbl_composition c
+		JOIN openehr.tbl_composition_data cd ON cd.id = c.id
+		WHERE c.ehr_id = $1 AND c.versioned_composition_id::TEXT = ANY($2)
+		ORDER BY c.versioned_composition_id, c.version_int DESC
+	`, ehrID, versionedIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Directory compositions: %w", err)
+	}
+	compositions, err := pgx.CollectRows(rows, pgx.RowToStructByPos[struct {
+		ID   string
+		Data json.RawMessage
+	}])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan Directory compositions: %w", err)
+	}
+
+	rows, err = s.DB.Query(ctx, `
+		SELECT c.id, cd.data
+		FROM openehr.tbl_composition c
+		JOIN openehr.tbl_composition_data cd ON cd.id = c.id
+		WHERE c.ehr_id = $1 AND c.id = ANY($2)
+	`, ehrID, versionIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Directory composition versions: %w", err)
+	}
+	versions, err := pgx.CollectRows(rows, pgx.RowToStructByPos[struct {
+		ID   string
+		Data json.RawMessage
+	}])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan Directory composition versions: %w", err)
+	}
+
+	byID := make(map[string]json.RawMessage, len(compositions)+len(versions))
+	for _, c := range append(compositions, versions...) {
+		byID[c.ID] = c.Data
+	}
+
+	// References to compositions that were deleted in the meantime are left out
+	result := []json.RawMessage{}
+	for _, id := range order {
+		if data, ok := byID[id]; ok {
+			result = append(result, data)
+		}
+	}
+
+	return json.Marshal(result)
+}
+
+// changeDirectory applies the change to the latest directory version and stores the result as the next version.
+// The latest version must still be the current directory version the caller based the change on.
+func (s *Service) changeDirectory(ctx context.Context, ehrID uuid.UUID, currentDirectoryID rm.OBJECT_VERSION_ID, description string, change func(directory *rm.FOLDER) error) (rm.FOLDER, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return rm.FOLDER{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && rbErr != database.ErrTxClosed {
+			s.Logger.ErrorContext(ctx, "failed to rollback transaction", "error", rbErr)
+		}
+	}()
+
+	if err := checkEHRModifiable(ctx, tx, ehrID); err != nil {
+		return rm.FOLDER{}, err
+	}
+
+	var latestID string
+	var data []byte
+	err = tx.QueryRow(ctx, `
+		SELECT f.id, fd.data
+		FROM openehr.tbl_folder f
+		JOIN openehr.tbl_folder_data fd ON fd.id = f.id
+		WHERE f.ehr_id = $1
+		ORDER BY f.version_int DESC
+		LIMIT 1
+		FOR UPDATE OF f
+	`, ehrID).Scan(&latestID, &data)
+	if err != nil {
+		if err == database.ErrNoRows {
+			return rm.FOLDER{}, ErrDirectoryNotFound
+		}
+		return rm.FOLDER{}, fmt.Errorf("failed to fetch Directory from database: %w", err)
+	}
+	if latestID != currentDirectoryID.Value {
+		return rm.FOLDER{}, ErrDirectoryVersionConflict
+	}
+
+	var directory rm.FOLDER
+	if err := sonic.Unmarshal(data, &directory); err != nil {
+		return rm.FOLDER{}, fmt.Errorf("failed to unmarshal Directory: %w", err)
+	}
+
+	if err := change(&directory); err != nil {
+		return rm.FOLDER{}, err
+	}
+
+	validateErr := directory.Validate("$")
+	if len(validateErr.Errs) > 0 {
+		return rm.FOLDER{}, validateErr
+	}
+	if err := validateDirectoryItems(ctx, tx, ehrID, directory); err != nil {
+		return rm.FOLDER{}, err
+	}
+
+	nextID, err := UpgradeObjectVersionID(rm.UID_BASED_ID_from_HIER_OBJECT_ID(&rm.HIER_OBJECT_ID{
+		Value: currentDirectoryID.UID(),
+	}), currentDirectoryID)
+	if err != nil {
+		return rm.FOLDER{}, fmt.Errorf("failed to upgrade current Directory UID: %w", err)
+	}
+	directory.UID = utils.Some(rm.UID_BASED_ID_from_OBJECT_VERSION_ID(&nextID))
+
+	batch := &pgx.Batch{}
+	queueDirectoryVersion(batch, ehrID, currentDirectoryID, directory, description)
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return rm.FOLDER{}, fmt.Errorf("failed to execute batch insert for Directory change: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return rm.FOLDER{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return directory, nil
+}
+
+// queueDirectoryVersion queues the contribution and FOLDER version that follow up on the preceding directory version,
+// the directory UID must already hold the new version id
+func queueDirectoryVersion(batch *pgx.Batch, ehrID uuid.UUID, precedingID rm.OBJECT_VERSION_ID, directory rm.FOLDER, description string) {
+	versionID := directory.UID.V.OBJECT_VERSION_ID()
+	folderVersion := NewOriginalVersion(versionID, rm.ORIGINAL_VERSION_DATA_from_FOLDER(directory), utils.Some(precedingID))
+	contribution := NewContribution(description, terminology.AUDIT_CHANGE_TYPE_CODE_MODIFICATION,
+		[]rm.OBJECT_REF{
+			{
+				Type:      rm.VERSIONED_FOLDER_TYPE,
+				Namespace: rm.Namespace_local,
+				ID:        rm.OBJECT_ID_from_OBJECT_VERSION_ID(versionID),
+			},
+		},
+	)
+
+	// Insert CONTRIBUTION
+	batch.Queue(`INSERT INTO openehr.tbl_contribution (id, ehr_id) VALUES ($1, $2)`, contribution.UID.Value, ehrID)
+	contribution.SetModelName()
+	batch.Queue(`INSERT INTO openehr.tbl_contribution_data (id, data) VALUES ($1, $2)`, contribution.UID.Value, contribution)
+
+	// Insert FOLDER
+	batch.Queue(`INSERT INTO openehr.tbl_folder (id, version_int, versioned_folder_id, ehr_id, contribution_id) VALUES ($1, $2, $3, $4, $5)`, folderVersion.UID.Value, versionID.VersionTreeID().Int(), versionID.UID(), ehrID, contribution.UID.Value)
+	folderVersion.SetModelName()
+	batch.Queue(`INSERT INTO openehr.tbl_folder_data (id, data, version_data) VALUES ($1, ($2::jsonb)->'data', jsonb_set($2::jsonb, '{data}', 'null', true))`, folderVersion.UID.Value, folderVersion)
+
+	// Update EHR with contribution ref
+	batch.Queue(`UPDATE openehr.tbl_ehr_data
+		SET data = jsonb_insert(data, '{contributions, -1}', $1::jsonb, true)
+		WHERE id = $2
+	`, rm.OBJECT_REF{
+		Type:      rm.CONTRIBUTION_TYPE,
+		Namespace: rm.Namespace_local,
+		ID:        rm.OBJECT_ID_from_HIER_OBJECT_ID(contribution.UID),
+	}, ehrID)
+}
+
+// validateDirectoryItems checks that every local item reference in the directory points at a versioned object of the same EHR, of the referenced type
+func validateDirectoryItems(ctx context.Context, db queryer, ehrID uuid.UUID, directory rm.FOLDER) error {
+	type itemRef struct {
+		path      string
+		ref       rm.OBJECT_REF
+		id        string
+		versionID string // Set when the reference names a specific version
+	}
+
+	validateErr := outil.ValidateError{}
+	refs := []itemRef{}
+
+	var collect func(folder rm.FOLDER, path string)
+	collect = func(folder rm.FOLDER, path string) {
+		for i, ref := range folder.Items.V {
+			if ref.Namespace != config.NAMESPACE_LOCAL {
+				// Skip external references
+				continue
+			}
+
+			itemPath := fmt.Sprintf("%s.items[%d]", path, i)
+			switch ref.ID.Kind {
+			case rm.OBJECT_ID_kind_HIER_OBJECT_ID:
+				refs = append(refs, itemRef{itemPath, ref, ref.ID.HIER_OBJECT_ID().Value, ""})
+			case rm.OBJECT_ID_kind_OBJECT_VERSION_ID:
+				refs = append(refs, itemRef{itemPath, ref, ref.ID.OBJECT_VERSION_ID().UID(), ref.ID.OBJECT_VERSION_ID().Value})
+			default:
+				validateErr.Errs = append(validateErr.Errs, outil.ValidationError{
+					Model:          rm.OBJECT_REF_TYPE,
+					Path:           itemPath + ".id",
+					Message:        fmt.Sprintf("%s cannot identify a local versioned object", ref.ID.Kind),
+					Recommendation: "Ensure the ID is of type HIER_OBJECT_ID or OBJECT_VERSION_ID",
+				})
+			}
+		}
+		for i, sub := range folder.Folders.V {
+			collect(sub, fmt.Sprintf("%s.folders[%d]", path, i))
+		}
+	}
+	collect(directory, "$")
+
+	ids := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		ids = append(ids, ref.id)
+	}
+
+	rows, err := db.Query(ctx, `SELECT id::TEXT, type FROM openehr.tbl_versioned_object WHERE ehr_id = $1 AND id::TEXT = ANY($2)`, ehrID, ids)
+	if err != nil {
+		return fmt.Errorf("failed to query Directory item references: %w", err)
+	}
+	types := map[string]string{}
+	for rows.Next() {
+		var id, typ string
+		if err := rows.Scan(&id, &typ); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan Directory item reference: %w", err)
+		}
+		types[id] = typ
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating Directory item references: %w", err)
+	}
+
+	versionIDs := []string{}
+	for _, ref := range refs {
+		if ref.versionID != "" {
+			versionIDs = append(versionIDs, ref.versionID)
+		}
+	}
+
+	rows, err = db.Query(ctx, `
+		SELECT id FROM openehr.tbl_composition WHERE ehr_id = $1 AND id = ANY($2)
+		UNION ALL
+		SELECT id FROM openehr.tbl_folder WHERE ehr_id = $1 AND id = ANY($2)
+		UNION ALL
+		SELECT id FROM openehr.tbl_ehr_status WHERE ehr_id = $1 AND id = ANY($2)
+	`, ehrID, versionIDs)
+	if err != nil {
+		return fmt.Errorf("failed to query Directory item versions: %w", err)
+	}
+	versionExists := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan Directory item version: %w", err)
+		}
+		versionExists[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating Directory item versions: %w", err)
+	}
+
+	for _, ref := range refs {
+		typ, ok := types[ref.id]
+		if !ok {
+			validateErr.Errs = append(validateErr.Errs, outil.ValidationError{
+				Model:          rm.OBJECT_REF_TYPE,
+				Path:           ref.path,
+				Message:        fmt.Sprintf("%s %s does not exist for this EHR in the system", ref.ref.Type, ref.id),
+				Recommendation: "Ensure the referenced object exists for this EHR",
+			})
+			continue
+		}
+		if strings.TrimPrefix(typ, "VERSIONED_") != strings.TrimPrefix(ref.ref.Type, "VERSIONED_") {
+			validateErr.Errs = append(validateErr.Errs, outil.ValidationError{
+				Model:          rm.OBJECT_REF_TYPE,
+				Path:           ref.path + ".type",
+				Message:        fmt.Sprintf("Reference type %s does not match the referenced %s", ref.ref.Type, typ),
+				Recommendation: fmt.Sprintf("Ensure the type is %s or %s", typ, strings.TrimPrefix(typ, "VERSIONED_")),
+			})
+			continue
+		}
+		if ref.versionID != "" && !versionExists[ref.versionID] {
+			validateErr.Errs = append(validateErr.Errs, outil.ValidationError{
+				Model:          rm.OBJECT_REF_TYPE,
+				Path:           ref.path + ".id",
+				Message:        fmt.Sprintf("Version %s does not exist for this EHR in the system", ref.versionID),
+				Recommendation: "Ensure the referenced version exists, or refer to the versioned object with a HIER_OBJECT_ID",
+			})
+		}
+	}
+
+	if len(validateErr.Errs) > 0 {
+		return validateErr
+	}
+
+	return nil
+}
+
+// splitDirectoryPath splits a folder path like "episodes/2024" into folder names, the empty path is the root folder
+func splitDirectoryPath(path string) []string {
+	segments := []string{}
+	for segment := range strings.SplitSeq(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// findDirectoryFolder returns the folder at the path, the pointer stays valid until the folders along the path are modified
+func findDirectoryFolder(directory *rm.FOLDER, path string) (*rm.FOLDER, error) {
+	folder := directory
+	for _, segment := range splitDirectoryPath(path) {
+		idx := slices.IndexFunc(folder.Folders.V, func(f rm.FOLDER) bool {
+			return folderName(f) == segment
+		})
+		if idx < 0 {
+			return nil, ErrFolderNotFoundInDirectory
+		}
+		folder = &folder.Folders.V[idx]
+	}
+	return folder, nil
+}
+
+func hasSubFolder(folder rm.FOLDER, name string) bool {
+	return slices.ContainsFunc(folder.Folders.V, func(f rm.FOLDER) bool {
+		return folderName(f) == name
+	})
+}
+
+func folderName(folder rm.FOLDER) string {
+	switch name := folder.Name.Value.(type) {
+	case *rm.DV_TEXT:
+		return name.Value
+	case *rm.DV_CODED_TEXT:
+		return name.Value
+	default:
+		return ""
+	}
+}
+
+// setFolderName renames the folder, a coded name no longer applies once the folder is renamed
+func setFolderName(folder *rm.FOLDER, name string) {
+	if folderName(*folder) != name {
+		folder.Name = rm.DV_TEXT_from_DV_TEXT(rm.DV_TEXT{Value: name})
+	}
+}
+
+// objectRefIDValue returns the id value of the reference, whatever kind of OBJECT_ID it is
+func objectRefIDValue(ref rm.OBJECT_REF) string {
+	switch id := ref.ID.Value.(type) {
+	case *rm.HIER_OBJECT_ID:
+		return id.Value
+	case *rm.OBJECT_VERSION_ID:
+		return id.Value
+	case *rm.ARCHETYPE_ID:
+		return id.Value
+	case *rm.TEMPLATE_ID:
+		return id.Value
+	case *rm.GENERIC_ID:
+		return id.Value
+	default:
+		return ""
+	}
+}
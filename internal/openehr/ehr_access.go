@@ -0,0 +1,215 @@
+package openehr
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/freekieb7/gopenehr/internal/database"
+	"github.com/freekieb7/gopenehr/internal/openehr/rm"
+	"github.com/freekieb7/gopenehr/internal/openehr/terminology"
+	"github.com/freekieb7/gopenehr/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Service) ValidateEHRAccess(ctx context.Context, ehrAccess rm.EHR_ACCESS) error {
+	validateErr := ehrAccess.Validate("$")
+	if len(validateErr.Errs) > 0 {
+		return validateErr
+	}
+
+	return nil
+}
+
+func (s *Service) GetEHRAccessID(ctx context.Context, ehrID uuid.UUID) (rm.OBJECT_VERSION_ID, error) {
+	query := `SELECT id FROM openehr.tbl_ehr_access WHERE ehr_id = $1 ORDER BY version_int DESC LIMIT 1`
+	args := []any{ehrID}
+
+	row := s.DB.QueryRow(ctx, query, args...)
+
+	var id string
+	err := row.Scan(&id)
+	if err != nil {
+		if err == database.ErrNoRows {
+			return rm.OBJECT_VERSION_ID{}, ErrEHRAccessNotFound
+		}
+		return rm.OBJECT_VERSION_ID{}, fmt.Errorf("failed to fetch EHR Access ID from database: %w", err)
+	}
+
+	return rm.OBJECT_VERSION_ID{Value: id}, nil
+}
+
+func (s *Service) GetEHRAccessAtTimeRawJSON(ctx context.Context, ehrID uuid.UUID, filterOnTime time.Time) ([]byte, error) {
+	query := `
+		SELECT ead.data
+		FROM openehr.tbl_ehr_access ea
+		JOIN openehr.tbl_ehr_access_data ead ON ea.id = ead.id
+		WHERE ea.ehr_id = $1
+	`
+	args := []any{ehrID}
+	if !filterOnTime.IsZero() {
+		query += `AND ea.created_at <= $2 `
+		args = append(args, filterOnTime)
+	}
+	query += `ORDER BY ea.version_int DESC LIMIT 1`
+
+	row := s.DB.QueryRow(ctx, query, args...)
+
+	var data []byte
+	err := row.Scan(&data)
+	if err != nil {
+		if err == database.ErrNoRows {
+			return nil, ErrEHRAccessNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch EHR Access from database: %w", err)
+	}
+
+	return data, nil
+}
+
+func (s *Service) GetEHRAccessByIDRawJSON(ctx context.Context, ehrID uuid.UUID, ehrAccessID string) ([]byte, error) {
+	query := `
+		SELECT ead.data
+		FROM openehr.tbl_ehr_access ea
+		JOIN openehr.tbl_ehr_access_data ead ON ea.id = ead.id
+		WHERE ea.ehr_id = $1
+		  AND ea.id = $2
+		LIMIT 1
+	`
+	args := []any{ehrID, ehrAccessID}
+
+	row := s.DB.QueryRow(ctx, query, args...)
+
+	var data []byte
+	err := row.Scan(&data)
+	if err != nil {
+		if err == database.ErrNoRows {
+			return nil, ErrEHRAccessNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch EHR Access from database: %w", err)
+	}
+
+	return data, nil
+}
+
+// UpdateEHRAccess commits the next version of the EHR_ACCESS, the settings it holds are enforced from then on
+func (s *Service) UpdateEHRAccess(ctx context.Context, ehrID uuid.UUID, currentEHRAccessID rm.OBJECT_VERSION_ID, nextEHRAccess rm.EHR_ACCESS) (rm.EHR_ACCESS, error) {
+	if err := s.ValidateEHRAccess(ctx, nextEHRAccess); err != nil {
+		return rm.EHR_ACCESS{}, err
+	}
+
+	// Ensure EHR Access contains a UID to check/upgrade
+	if !nextEHRAccess.UID.E {
+		nextEHRAccess.UID = utils.Some(rm.UID_BASED_ID_from_HIER_OBJECT_ID(&rm.HIER_OBJECT_ID{
+			Value: currentEHRAccessID.UID(),
+		}))
+	}
+
+	updatedID, err := UpgradeObjectVersionID(nextEHRAccess.UID.V, currentEHRAccessID)
+	if err != nil {
+		return rm.EHR_ACCESS{}, err
+	}
+	if updatedID.UID() != currentEHRAccessID.UID() {
+		return rm.EHR_ACCESS{}, ErrInvalidEHRAccessUIDMismatch
+	}
+	nextEHRAccess.UID = utils.Some(rm.UID_BASED_ID_from_OBJECT_VERSION_ID(&updatedID))
+
+	ehrAccessVersion := NewOriginalVersion(updatedID, rm.ORIGINAL_VERSION_DATA_from_EHR_ACCESS(nextEHRAccess), utils.Some(currentEHRAccessID))
+	contribution := NewContribution("EHR Access updated", terminology.AUDIT_CHANGE_TYPE_CODE_MODIFICATION,
+		[]rm.OBJECT_REF{
+			{
+				Type:      rm.VERSIONED_EHR_ACCESS_TYPE,
+				Namespace: rm.Namespace_local,
+				ID:        rm.OBJECT_ID_from_OBJECT_VERSION_ID(updatedID),
+			},
+		},
+	)
+
+	// Start transaction
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return rm.EHR_ACCESS{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && err != database.ErrTxClosed {
+			s.Logger.ErrorContext(ctx, "Failed to rollback transaction", "error", err)
+		}
+	}()
+
+	// Serialize updates of the same EHR, so the version checked is still the latest when committing
+	var latestEHRAccessID string
+	err = tx.QueryRow(ctx, `
+		SELECT ea.id
+		FROM openehr.tbl_ehr e
+		JOIN openehr.tbl_ehr_access ea ON ea.ehr_id = e.id
+		WHERE e.id = $1 AND e.deleted_at IS NULL
+		ORDER BY ea.version_int DESC
+		LIMIT 1
+		FOR UPDATE OF e
+	`, ehrID).Scan(&latestEHRAccessID)
+	if err != nil {
+		if err == database.ErrNoRows {
+			return rm.EHR_ACCESS{}, ErrEHRNotFound
+		}
+		return rm.EHR_ACCESS{}, fmt.Errorf("failed to lock EHR for EHR Access update: %w", err)
+	}
+	if latestEHRAccessID != currentEHRAccessID.Value {
+		return rm.EHR_ACCESS{}, ErrEHRAccessVersionConflict
+	}
+
+	batch := &pgx.Batch{}
+
+	// Insert CONTRIBUTION
+	batch.Queue(`INSERT INTO openehr.tbl_contribution (id, ehr_id) VALUES ($1, $2)`, contribution.UID.Value, ehrID)
+	contribution.SetModelName()
+	batch.Queue(`INSERT INTO openehr.tbl_contribution_data (id, data) VALUES ($1, $2)`, contribution.UID.Value, contribution)
+
+	// Insert EHR_ACCESS
+	batch.Queue(`INSERT INTO openehr.tbl_ehr_access (id, version_int, versioned_ehr_access_id, ehr_id, contribution_id) VALUES ($1, $2, $3, $4, $5)`, updatedID.Value, updatedID.VersionTreeID().Int(), updatedID.UID(), ehrID, contribution.UID.Value)
+	nextEHRAccess.SetModelName()
+	ehrAccessVersion.SetModelName()
+	batch.Queue(`INSERT INTO openehr.tbl_ehr_access_data (id, data, version_data) VALUES ($1, ($2::jsonb)->'data', jsonb_set($2::jsonb, '{data}', 'null', true))`, updatedID.Value, ehrAccessVersion)
+
+	// Update EHR with new contribution reference
+	batch.Queue(`
+		UPDATE openehr.tbl_ehr_data
+		SET data = jsonb_insert(data, '{contributions,-1}', $1::jsonb, true)
+		WHERE id = $2
+	`, rm.OBJECT_REF{
+		Type:      rm.CONTRIBUTION_TYPE,
+		Namespace: rm.Namespace_local,
+		ID:        rm.OBJECT_ID_from_HIER_OBJECT_ID(contribution.UID),
+	}, ehrID)
+
+	br := tx.SendBatch(ctx, batch)
+	_, err = br.Exec()
+	if err != nil {
+		return rm.EHR_ACCESS{}, fmt.Errorf("failed to execute batch insert for EHR Access update: %w", err)
+	}
+	err = br.Close()
+	if err != nil {
+		return rm.EHR_ACCESS{}, fmt.Errorf("failed to close batch result for EHR Access update: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return rm.EHR_ACCESS{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nextEHRAccess, nil
+}
+
+// IsEHRAccessAllowed evaluates the settings of the latest EHR_ACCESS for the caller, the same policy AQL queries apply
+func (s *Service) IsEHRAccessAllowed(ctx context.Context, ehrID uuid.UUID, subject string, roles []string) (bool, error) {
+	if roles == nil {
+		roles = []string{}
+	}
+
+	var allowed bool
+	err := s.DB.QueryRow(ctx, `SELECT openehr.ehr_access_allowed($1, $2, $3)`, ehrID, subject, roles).Scan(&allowed)
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate EHR Access settings: %w", err)
+	}
+
+	return allowed, nil
+}
@@ -0,0 +1,204 @@
+package openehr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/freekieb7/gopenehr/internal/attachment"
+	"github.com/freekieb7/gopenehr/internal/audit"
+	"github.com/freekieb7/gopenehr/internal/config"
+	"github.com/freekieb7/gopenehr/internal/database"
+	"github.com/freekieb7/gopenehr/internal/telemetry"
+	"github.com/freekieb7/gopenehr/internal/webhook"
+	pkgAudit "github.com/freekieb7/gopenehr/pkg/audit"
+	"github.com/freekieb7/gopenehr/pkg/utils"
+	"github.com/google/uuid"
+)
+
+const EHRPurgePollInterval = time.Minute
+
+// DeletedEHR is an EHR marked as deleted that has not been purged yet
+type DeletedEHR struct {
+	ID        uuid.UUID
+	DeletedAt time.Time
+}
+
+// IsEHRDeleted reports if the EHR is marked as deleted, an unknown EHR is not
+func (s *Service) IsEHRDeleted(ctx context.Context, ehrID uuid.UUID) (bool, error) {
+	var deleted bool
+	if err := s.DB.QueryRow(ctx, `SELECT openehr.ehr_is_deleted($1)`, ehrID).Scan(&deleted); err != nil {
+		return false, fmt.Errorf("failed to check if EHR is deleted: %w", err)
+	}
+	return deleted, nil
+}
+
+// RestoreEHR undoes the deletion of an EHR, as long as it was deleted less than the grace period ago
+func (s *Service) RestoreEHR(ctx context.Context, ehrID uuid.UUID, gracePeriod time.Duration) error {
+	var deletedAt utils.Optional[time.Time]
+	if err := s.DB.QueryRow(ctx, `SELECT deleted_at FROM openehr.tbl_ehr WHERE id = $1`, ehrID).Scan(&deletedAt); err != nil {
+		if errors.Is(err, database.ErrNoRows) {
+			return ErrEHRNotFound
+		}
+		return fmt.Errorf("failed to fetch EHR deletion time: %w", err)
+	}
+	if !deletedAt.E {
+		return ErrEHRNotDeleted
+	}
+	if time.Since(deletedAt.V) >= gracePeriod {
+		return ErrEHRRestorePeriodExpired
+	}
+
+	// The purger may have removed the EHR in the meantime
+	err := s.DB.QueryRow(ctx, `UPDATE openehr.tbl_ehr SET deleted_at = NULL WHERE id = $1 AND deleted_at = $2 RETURNING 1`, ehrID, deletedAt.V).Scan(new(int))
+	if err != nil {
+		if errors.Is(err, database.ErrNoRows) {
+			return ErrEHRNotFound
+		}
+		return fmt.Errorf("failed to restore EHR in database: %w", err)
+	}
+
+	return nil
+}
+
+// ListPurgeableEHRs returns the deleted EHRs whose grace period has expired, longest deleted first
+func (s *Service) ListPurgeableEHRs(ctx context.Context, gracePeriod time.Duration, limit int) ([]DeletedEHR, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT id, deleted_at
+		FROM openehr.tbl_ehr
+		WHERE deleted_at IS NOT NULL AND deleted_at <= $1
+		ORDER BY deleted_at
+		LIMIT $2
+	`, time.Now().Add(-gracePeriod), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query purgeable EHRs: %w", err)
+	}
+	defer rows.Close()
+
+	ehrs := []DeletedEHR{}
+	for rows.Next() {
+		var ehr DeletedEHR
+		if err := rows.Scan(&ehr.ID, &ehr.DeletedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan purgeable EHR: %w", err)
+		}
+		ehrs = append(ehrs, ehr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating purgeable EHRs: %w", err)
+	}
+
+	return ehrs, nil
+}
+
+// PurgeEHR physically removes a deleted EHR and everything it holds, the EHR must have been deleted at least the grace period ago.
+// The hashes of the attachment content of the EHR are returned, so content no longer referenced can be removed from the attachment store.
+func (s *Service) PurgeEHR(ctx context.Context, ehrID uuid.UUID, gracePeriod time.Duration) ([]string, error) {
+	var hashes []string
+	err := s.DB.QueryRow(ctx, `
+		WITH purged AS (
+			DELETE FROM openehr.tbl_ehr WHERE id = $1 AND deleted_at IS NOT NULL AND deleted_at <= $2 RETURNING id
+		)
+		SELECT ARRAY(SELECT DISTINCT a.hash FROM openehr.tbl_attachment a JOIN purged ON purged.id = a.ehr_id)
+		FROM purged
+	`, ehrID, time.Now().Add(-gracePeriod)).Scan(&hashes)
+	if err != nil {
+		if errors.Is(err, database.ErrNoRows) {
+			// Restored or purged in the meantime
+			return nil, ErrEHRNotFound
+		}
+		return nil, fmt.Errorf("failed to purge EHR from database: %w", err)
+	}
+	return hashes, nil
+}
+
+// EHRPurger periodically removes the deleted EHRs whose grace period has expired
+type EHRPurger struct {
+	Logger          *telemetry.Logger
+	Service         *Service
+	AuditSink       *audit.Sink
+	WebhookSink     webhook.Sink
+	AttachmentStore attachment.Store
+	GracePeriod     time.Duration
+}
+
+func NewEHRPurger(logger *telemetry.Logger, service *Service, auditSink *audit.Sink, webhookSink webhook.Sink, attachmentStore attachment.Store, gracePeriod time.Duration) *EHRPurger {
+	return &EHRPurger{
+		Logger:          logger,
+		Service:         service,
+		AuditSink:       auditSink,
+		WebhookSink:     webhookSink,
+		AttachmentStore: attachmentStore,
+		GracePeriod:     gracePeriod,
+	}
+}
+
+func (p *EHRPurger) Start(ctx context.Context) error {
+	ticker := time.NewTicker(EHRPurgePollInterval)
+	defer ticker.Stop()
+
+	p.Logger.Info("EHR purger started", "grace_period", p.GracePeriod.String())
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.Logger.Info("EHR purger shutting down")
+			return nil
+
+		case <-ticker.C:
+			ehrs, err := p.Service.ListPurgeableEHRs(ctx, p.GracePeriod, 100)
+			if err != nil {
+				p.Logger.ErrorContext(ctx, "Failed to list purgeable EHRs", "error", err)
+				continue
+			}
+
+			for _, ehr := range ehrs {
+				p.purge(ctx, ehr)
+			}
+		}
+	}
+}
+
+func (p *EHRPurger) purge(ctx context.Context, ehr DeletedEHR) {
+	event := pkgAudit.Event{
+		ID:        uuid.New(),
+		ActorID:   config.SystemUserID,
+		ActorType: "system",
+		Resource:  string(pkgAudit.ResourceEHR),
+		Action:    string(pkgAudit.ActionDelete),
+		Details: map[string]any{
+			"ehr_id":     ehr.ID,
+			"deleted_at": ehr.DeletedAt,
+			"purge":      true,
+		},
+		CreatedAt: time.Now().UTC(),
+	}
+
+	hashes, err := p.Service.PurgeEHR(ctx, ehr.ID, p.GracePeriod)
+	if err != nil {
+		if err == ErrEHRNotFound {
+			return
+		}
+		p.Logger.ErrorContext(ctx, "Failed to purge EHR", "ehr_id", ehr.ID, "error", err)
+
+		event.Details["outcome"] = "failure"
+		event.Details["error"] = err.Error()
+		p.AuditSink.Enqueue(event)
+		return
+	}
+
+	event.Success = true
+	p.AuditSink.Enqueue(event)
+
+	// The EHR is gone already, content left behind is only logged and not retried
+	if err := p.Service.DeleteOrphanedAttachmentContent(ctx, p.AttachmentStore, hashes); err != nil {
+		p.Logger.ErrorContext(ctx, "Failed to delete attachment content of purged EHR", "ehr_id", ehr.ID, "error", err)
+	}
+
+	p.WebhookSink.Enqueue(webhook.EventTypeEHRPurged, map[string]any{
+		"ehr_id":     ehr.ID,
+		"deleted_at": ehr.DeletedAt,
+	})
+
+	p.Logger.InfoContext(ctx, "EHR purged", "ehr_id", ehr.ID, "deleted_at", ehr.DeletedAt)
+}
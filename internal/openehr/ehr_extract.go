@@ -0,0 +1,447 @@
+package openehr
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/freekieb7/gopenehr/internal/config"
+	"github.com/freekieb7/gopenehr/internal/database"
+	"github.com/freekieb7/gopenehr/internal/openehr/rm"
+	"github.com/freekieb7/gopenehr/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	EHRExtractFormatJSON = "json"
+	EHRExtractFormatZip  = "zip"
+)
+
+// EHRExtractDocumentFormat identifies the JSON document written by EHRExtractJSONWriter. It is a gopenehr export format,
+// not the RM EHR_EXTRACT: the RM objects are kept as they are stored instead of being placed in extract chapters.
+const EHRExtractDocumentFormat = "gopenehr.ehr_export.v1"
+
+type EHRExtractItemKind int
+
+const (
+	// EHRExtractItemDocument is a standalone document, like the EHR itself, a contribution or the tags
+	EHRExtractItemDocument EHRExtractItemKind = iota
+	// EHRExtractItemVersionedObject is an X_VERSIONED_OBJECT header, followed by the items of its versions
+	EHRExtractItemVersionedObject
+	// EHRExtractItemVersion is an ORIGINAL_VERSION or IMPORTED_VERSION of the preceding versioned object
+	EHRExtractItemVersion
+)
+
+// EHRExtractItem is a single canonical JSON document of an EHR extract.
+// Items of the same section are written one after the other, the versions of a versioned object right after its header.
+type EHRExtractItem struct {
+	Kind              EHRExtractItemKind
+	Section           string
+	VersionedObjectID string
+	Name              string
+	Data              []byte
+}
+
+// Path is the location of the item in a zip archive, also used to refer to it in the manifest
+func (i EHRExtractItem) Path() string {
+	// Version UIDs hold '::', which is not allowed in file names on every platform
+	name := strings.ReplaceAll(i.Name, "::", "_")
+	return path.Join(i.Section, i.VersionedObjectID, name+".json")
+}
+
+type EHRExtractManifestEntry struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+}
+
+// EHRExtractManifest lists every item of an EHR extract with the checksum of its canonical JSON
+type EHRExtractManifest struct {
+	EHRID       uuid.UUID                 `json:"ehr_id"`
+	SystemID    string                    `json:"system_id"`
+	TimeCreated time.Time                 `json:"time_created"`
+	Items       []EHRExtractManifestEntry `json:"items"`
+}
+
+// EHRExtractWriter receives the items of an EHR extract as they are read, so an extract is never held in memory as a whole
+type EHRExtractWriter interface {
+	Begin(manifest EHRExtractManifest) error
+	WriteItem(item EHRExtractItem) error
+	Close(manifest EHRExtractManifest) error
+}
+
+// ehrExtractVersionTable holds the versioned objects of one section of an extract and their versions
+type ehrExtractVersionTable struct {
+	Section         string
+	Type            string
+	VersionedTable  string
+	Table           string
+	VersionedColumn string
+}
+
+// ehrExtractVersionTables are the version tables of each versioned section of an extract, in the order they are exported
+var ehrExtractVersionTables = []ehrExtractVersionTable{
+	{"ehr_status", rm.VERSIONED_EHR_STATUS_TYPE, "tbl_versioned_ehr_status_data", "tbl_ehr_status", "versioned_ehr_status_id"},
+	{"ehr_access", rm.VERSIONED_EHR_ACCESS_TYPE, "tbl_versioned_ehr_access_data", "tbl_ehr_access", "versioned_ehr_access_id"},
+	{"directory", rm.VERSIONED_FOLDER_TYPE, "tbl_versioned_folder_data", "tbl_folder", "versioned_folder_id"},
+	{"compositions", rm.VERSIONED_COMPOSITION_TYPE, "tbl_versioned_composition_data", "tbl_composition", "versioned_composition_id"},
+}
+
+// ExportEHR writes everything held for the EHR: the EHR, all EHR_STATUS, EHR_ACCESS, folder and composition versions
+// with their audits and attestations, the contributions that committed them and the tags. The extract is read from a
+// single snapshot, so commits running alongside the export are either fully included or not at all.
+func (s *Service) ExportEHR(ctx context.Context, ehrID uuid.UUID, w EHRExtractWriter) error {
+	tx, err := s.DB.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && err != database.ErrTxClosed {
+			s.Logger.ErrorContext(ctx, "Failed to rollback transaction", "error", err)
+		}
+	}()
+
+	var ehrData []byte
+	err = tx.QueryRow(ctx, `SELECT ed.data FROM openehr.tbl_ehr e JOIN openehr.tbl_ehr_data ed ON ed.id = e.id WHERE e.id = $1 AND e.deleted_at IS NULL`, ehrID).Scan(&ehrData)
+	if err != nil {
+		if errors.Is(err, database.ErrNoRows) {
+			return ErrEHRNotFound
+		}
+		return fmt.Errorf("failed to fetch EHR from database: %w", err)
+	}
+
+	manifest := EHRExtractManifest{
+		EHRID:       ehrID,
+		SystemID:    config.SYSTEM_ID_GOPENEHR,
+		TimeCreated: time.Now().UTC(),
+		Items:       []EHRExtractManifestEntry{},
+	}
+	if err := w.Begin(manifest); err != nil {
+		return fmt.Errorf("failed to begin EHR extract: %w", err)
+	}
+
+	emit := ehrExtractEmitter(w, &manifest)
+
+	if err := emit(EHRExtractItem{Kind: EHRExtractItemDocument, Name: "ehr", Data: ehrData}); err != nil {
+		return err
+	}
+
+	for _, versions := range ehrExtractVersionTables {
+		if err := exportEHRVersions(ctx, tx, ehrID, versions.Section, versions.Type, versions.VersionedTable, versions.Table, versions.VersionedColumn, emit); err != nil {
+			return err
+		}
+	}
+
+	// Versioned objects moved in by a merge keep the contributions of the EHR they were committed to
+	rows, err := tx.Query(ctx, `
+		SELECT c.id, cd.data
+		FROM openehr.tbl_contribution c
+		JOIN openehr.tbl_contribution_data cd ON cd.id = c.id
+		WHERE c.ehr_id = $1
+		   OR c.id IN (
+			SELECT contribution_id FROM openehr.tbl_composition WHERE ehr_id = $1
+			UNION
+			SELECT contribution_id FROM openehr.tbl_folder WHERE ehr_id = $1
+		   )
+		ORDER BY c.created_at, c.id
+	`, ehrID)
+	if err != nil {
+		return fmt.Errorf("failed to query contributions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			return fmt.Errorf("failed to scan contribution: %w", err)
+		}
+		if err := emit(EHRExtractItem{Kind: EHRExtractItemDocument, Section: "contributions", Name: id.String(), Data: data}); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating contributions: %w", err)
+	}
+
+	var tags []byte
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(jsonb_agg(data), '[]'::jsonb)
+		FROM (
+			SELECT data FROM openehr.tbl_ehr_status_tag WHERE ehr_id = $1
+			UNION ALL
+			SELECT data FROM openehr.tbl_versioned_ehr_status_tag WHERE ehr_id = $1
+			UNION ALL
+			SELECT data FROM openehr.tbl_composition_tag WHERE ehr_id = $1
+			UNION ALL
+			SELECT data FROM openehr.tbl_versioned_composition_tag WHERE ehr_id = $1
+		)
+	`, ehrID).Scan(&tags)
+	if err != nil {
+		return fmt.Errorf("failed to fetch tags: %w", err)
+	}
+	if err := emit(EHRExtractItem{Kind: EHRExtractItemDocument, Name: "tags", Data: tags}); err != nil {
+		return err
+	}
+
+	if err := w.Close(manifest); err != nil {
+		return fmt.Errorf("failed to close EHR extract: %w", err)
+	}
+	return nil
+}
+
+// ehrExtractEmitter returns a function that writes an item as compact JSON and lists it in the manifest
+func ehrExtractEmitter(w EHRExtractWriter, manifest *EHRExtractManifest) func(EHRExtractItem) error {
+	return func(item EHRExtractItem) error {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, item.Data); err != nil {
+			return fmt.Errorf("failed to compact %s: %w", item.Path(), err)
+		}
+		item.Data = buf.Bytes()
+
+		checksum := sha256.Sum256(item.Data)
+		manifest.Items = append(manifest.Items, EHRExtractManifestEntry{
+			Path:   item.Path(),
+			SHA256: hex.EncodeToString(checksum[:]),
+			Size:   len(item.Data),
+		})
+		if err := w.WriteItem(item); err != nil {
+			return fmt.Errorf("failed to write %s: %w", item.Path(), err)
+		}
+		return nil
+	}
+}
+
+// exportEHRVersions emits each versioned object of the type as X_VERSIONED_OBJECT, followed by all of its versions in version tree order.
+// The version data is stored apart from the version, it is put back at data, or at item.data for imported versions.
+func exportEHRVersions(ctx context.Context, tx pgx.Tx, ehrID uuid.UUID, section, versionedType, versionedTable, table, versionedColumn string, emit func(EHRExtractItem) error) error {
+	query := fmt.Sprintf(`
+		SELECT
+			vo.id,
+			jsonb_build_object(
+				'_type', 'X_VERSIONED_OBJECT',
+				'uid', vd.data->'uid',
+				'owner_id', vd.data->'owner_id',
+				'time_created', vd.data->'time_created',
+				'total_version_count', COUNT(*) OVER (PARTITION BY vo.id),
+				'extract_version_count', COUNT(*) OVER (PARTITION BY vo.id)
+			),
+			v.id,
+			jsonb_set(d.version_data, CASE WHEN d.version_data->>'_type' = 'IMPORTED_VERSION' THEN '{item,data}'::text[] ELSE '{data}'::text[] END, d.data)
+		FROM openehr.tbl_versioned_object vo
+		JOIN openehr.%[1]s vd ON vd.id = vo.id
+		JOIN openehr.%[2]s v ON v.%[3]s = vo.id
+		JOIN openehr.%[2]s_data d ON d.id = v.id
+		WHERE vo.ehr_id = $1 AND vo.type = $2
+		ORDER BY vo.created_at, vo.id, v.version_int
+	`, versionedTable, table, versionedColumn)
+
+	rows, err := tx.Query(ctx, query, ehrID, versionedType)
+	if err != nil {
+		return fmt.Errorf("failed to query %s versions: %w", section, err)
+	}
+	defer rows.Close()
+
+	var current uuid.UUID
+	for rows.Next() {
+		var versionedObjectID uuid.UUID
+		var versionedObject []byte
+		var versionID string
+		var version []byte
+		if err := rows.Scan(&versionedObjectID, &versionedObject, &versionID, &version); err != nil {
+			return fmt.Errorf("failed to scan %s version: %w", section, err)
+		}
+
+		if versionedObjectID != current {
+			current = versionedObjectID
+			if err := emit(EHRExtractItem{Kind: EHRExtractItemVersionedObject, Section: section, VersionedObjectID: versionedObjectID.String(), Name: "versioned_object", Data: versionedObject}); err != nil {
+				return err
+			}
+		}
+
+		if err := emit(EHRExtractItem{Kind: EHRExtractItemVersion, Section: section, VersionedObjectID: versionedObjectID.String(), Name: versionID, Data: version}); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating %s versions: %w", section, err)
+	}
+
+	return nil
+}
+
+// ehrExtractListSections hold any number of items, the other sections hold a single one
+var ehrExtractListSections = map[string]bool{
+	"directory":     true,
+	"compositions":  true,
+	"contributions": true,
+}
+
+// EHRExtractJSONWriter writes the extract as a single document of the EHRExtractDocumentFormat. Next to format, ehr_id, system_id and
+// time_created it holds ehr, the ehr_status, ehr_access, directory and compositions sections of X_VERSIONED_OBJECTs with their versions,
+// contributions and tags, with the manifest as its last attribute.
+type EHRExtractJSONWriter struct {
+	w   io.Writer
+	err error
+
+	section             string
+	items               int
+	versions            int
+	versionedObjectOpen bool
+}
+
+func NewEHRExtractJSONWriter(w io.Writer) *EHRExtractJSONWriter {
+	return &EHRExtractJSONWriter{w: w}
+}
+
+func (w *EHRExtractJSONWriter) write(parts ...[]byte) {
+	for _, part := range parts {
+		if w.err != nil {
+			return
+		}
+		_, w.err = w.w.Write(part)
+	}
+}
+
+func (w *EHRExtractJSONWriter) Begin(manifest EHRExtractManifest) error {
+	header, err := json.Marshal(map[string]any{
+		"format":       EHRExtractDocumentFormat,
+		"ehr_id":       rm.HIER_OBJECT_ID{Type_: utils.Some(rm.HIER_OBJECT_ID_TYPE), Value: manifest.EHRID.String()},
+		"system_id":    rm.HIER_OBJECT_ID{Type_: utils.Some(rm.HIER_OBJECT_ID_TYPE), Value: manifest.SystemID},
+		"time_created": rm.DV_DATE_TIME{Type_: utils.Some(rm.DV_DATE_TIME_TYPE), Value: manifest.TimeCreated.Format(time.RFC3339)},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Leave the document open for the sections that follow
+	w.write(bytes.TrimSuffix(header, []byte("}")))
+	return w.err
+}
+
+func (w *EHRExtractJSONWriter) closeSection() {
+	if w.versionedObjectOpen {
+		w.write([]byte("]}"))
+		w.versionedObjectOpen = false
+	}
+	if w.section != "" && ehrExtractListSections[w.section] {
+		w.write([]byte("]"))
+	}
+	w.section = ""
+}
+
+func (w *EHRExtractJSONWriter) WriteItem(item EHRExtractItem) error {
+	key := item.Section
+	if key == "" {
+		key = item.Name
+	}
+	list := ehrExtractListSections[key]
+
+	if key != w.section {
+		w.closeSection()
+		w.write([]byte(`,"` + key + `":`))
+		if list {
+			w.write([]byte("["))
+		}
+		w.section = key
+		w.items = 0
+	}
+
+	switch item.Kind {
+	case EHRExtractItemVersion:
+		if !w.versionedObjectOpen {
+			return fmt.Errorf("version %s written outside of a versioned object", item.Name)
+		}
+		if w.versions > 0 {
+			w.write([]byte(","))
+		}
+		w.write(item.Data)
+		w.versions++
+	default:
+		if w.versionedObjectOpen {
+			w.write([]byte("]}"))
+			w.versionedObjectOpen = false
+		}
+		if w.items > 0 {
+			if !list {
+				return fmt.Errorf("section %s holds a single item", key)
+			}
+			w.write([]byte(","))
+		}
+
+		if item.Kind == EHRExtractItemVersionedObject {
+			// Reopen the X_VERSIONED_OBJECT to append its versions
+			header := bytes.TrimSuffix(item.Data, []byte("}"))
+			w.write(header)
+			if !bytes.HasSuffix(header, []byte("{")) {
+				w.write([]byte(","))
+			}
+			w.write([]byte(`"versions":[`))
+			w.versionedObjectOpen = true
+			w.versions = 0
+		} else {
+			w.write(item.Data)
+		}
+		w.items++
+	}
+
+	return w.err
+}
+
+func (w *EHRExtractJSONWriter) Close(manifest EHRExtractManifest) error {
+	w.closeSection()
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	w.write([]byte(`,"manifest":`), data, []byte("}"))
+	return w.err
+}
+
+// EHRExtractZipWriter writes every item of the extract as a canonical JSON file of a zip archive, along with manifest.json
+type EHRExtractZipWriter struct {
+	zw *zip.Writer
+}
+
+func NewEHRExtractZipWriter(w io.Writer) *EHRExtractZipWriter {
+	return &EHRExtractZipWriter{zw: zip.NewWriter(w)}
+}
+
+func (w *EHRExtractZipWriter) Begin(manifest EHRExtractManifest) error {
+	return nil
+}
+
+func (w *EHRExtractZipWriter) WriteItem(item EHRExtractItem) error {
+	return w.writeFile(item.Path(), item.Data)
+}
+
+func (w *EHRExtractZipWriter) writeFile(name string, data []byte) error {
+	f, err := w.zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+func (w *EHRExtractZipWriter) Close(manifest EHRExtractManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := w.writeFile("manifest.json", data); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
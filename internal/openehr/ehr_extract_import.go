@@ -0,0 +1,841 @@
+package openehr
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/freekieb7/gopenehr/internal/database"
+	"github.com/freekieb7/gopenehr/internal/openehr/rm"
+	"github.com/freekieb7/gopenehr/internal/openehr/terminology"
+	"github.com/freekieb7/gopenehr/internal/openehr/util"
+	"github.com/freekieb7/gopenehr/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// EHRImportResult counts what was recreated by an import
+type EHRImportResult struct {
+	EHRID                uuid.UUID `json:"ehr_id"`
+	VersionedObjectCount int       `json:"versioned_object_count"`
+	VersionCount         int       `json:"version_count"`
+	ContributionCount    int       `json:"contribution_count"`
+	TagCount             int       `json:"tag_count"`
+}
+
+// ReadEHRExtract reads the items of an extract as written by ExportEHR, either a JSON export document or a zip archive.
+// When the extract carries a manifest, every item must be listed in it with a matching checksum.
+func ReadEHRExtract(data []byte, format string) ([]EHRExtractItem, error) {
+	var items []EHRExtractItem
+	var manifest utils.Optional[EHRExtractManifest]
+	var err error
+
+	switch format {
+	case EHRExtractFormatJSON:
+		items, manifest, err = readEHRExtractJSON(data)
+	case EHRExtractFormatZip:
+		items, manifest, err = readEHRExtractZip(data)
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %s", ErrEHRExtractInvalid, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if manifest.E {
+		if err := verifyEHRExtractManifest(items, manifest.V); err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
+
+func readEHRExtractJSON(data []byte) ([]EHRExtractItem, utils.Optional[EHRExtractManifest], error) {
+	var document map[string]json.RawMessage
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, utils.None[EHRExtractManifest](), fmt.Errorf("%w: %w", ErrEHRExtractInvalid, err)
+	}
+
+	var documentFormat string
+	if err := json.Unmarshal(document["format"], &documentFormat); err != nil || documentFormat != EHRExtractDocumentFormat {
+		return nil, utils.None[EHRExtractManifest](), fmt.Errorf("%w: format must be %s", ErrEHRExtractInvalid, EHRExtractDocumentFormat)
+	}
+
+	items := []EHRExtractItem{}
+	add := func(item EHRExtractItem) error {
+		// Checksums are taken over the compact JSON
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, item.Data); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrEHRExtractInvalid, item.Path(), err)
+		}
+		item.Data = buf.Bytes()
+		items = append(items, item)
+		return nil
+	}
+
+	ehr, ok := document["ehr"]
+	if !ok {
+		return nil, utils.None[EHRExtractManifest](), fmt.Errorf("%w: ehr is required", ErrEHRExtractInvalid)
+	}
+	if err := add(EHRExtractItem{Kind: EHRExtractItemDocument, Name: "ehr", Data: ehr}); err != nil {
+		return nil, utils.None[EHRExtractManifest](), err
+	}
+
+	for _, versions := range ehrExtractVersionTables {
+		section, ok := document[versions.Section]
+		if !ok {
+			continue
+		}
+
+		versionedObjects := []json.RawMessage{section}
+		if ehrExtractListSections[versions.Section] {
+			if err := json.Unmarshal(section, &versionedObjects); err != nil {
+				return nil, utils.None[EHRExtractManifest](), fmt.Errorf("%w: %s must be a list: %w", ErrEHRExtractInvalid, versions.Section, err)
+			}
+		}
+
+		for _, versionedObject := range versionedObjects {
+			header, versionList, err := splitEHRExtractVersionedObject(versionedObject)
+			if err != nil {
+				return nil, utils.None[EHRExtractManifest](), fmt.Errorf("%w: %s: %w", ErrEHRExtractInvalid, versions.Section, err)
+			}
+			versionedObjectID, err := ehrExtractUID(header)
+			if err != nil {
+				return nil, utils.None[EHRExtractManifest](), fmt.Errorf("%w: %s: %w", ErrEHRExtractInvalid, versions.Section, err)
+			}
+
+			if err := add(EHRExtractItem{Kind: EHRExtractItemVersionedObject, Section: versions.Section, VersionedObjectID: versionedObjectID, Name: "versioned_object", Data: header}); err != nil {
+				return nil, utils.None[EHRExtractManifest](), err
+			}
+			for _, version := range versionList {
+				versionID, err := ehrExtractUID(version)
+				if err != nil {
+					return nil, utils.None[EHRExtractManifest](), fmt.Errorf("%w: %s/%s: %w", ErrEHRExtractInvalid, versions.Section, versionedObjectID, err)
+				}
+				if err := add(EHRExtractItem{Kind: EHRExtractItemVersion, Section: versions.Section, VersionedObjectID: versionedObjectID, Name: versionID, Data: version}); err != nil {
+					return nil, utils.None[EHRExtractManifest](), err
+				}
+			}
+		}
+	}
+
+	if section, ok := document["contributions"]; ok {
+		var contributions []json.RawMessage
+		if err := json.Unmarshal(section, &contributions); err != nil {
+			return nil, utils.None[EHRExtractManifest](), fmt.Errorf("%w: contributions must be a list: %w", ErrEHRExtractInvalid, err)
+		}
+		for _, contribution := range contributions {
+			contributionID, err := ehrExtractUID(contribution)
+			if err != nil {
+				return nil, utils.None[EHRExtractManifest](), fmt.Errorf("%w: contributions: %w", ErrEHRExtractInvalid, err)
+			}
+			if err := add(EHRExtractItem{Kind: EHRExtractItemDocument, Section: "contributions", Name: contributionID, Data: contribution}); err != nil {
+				return nil, utils.None[EHRExtractManifest](), err
+			}
+		}
+	}
+
+	if tags, ok := document["tags"]; ok {
+		if err := add(EHRExtractItem{Kind: EHRExtractItemDocument, Name: "tags", Data: tags}); err != nil {
+			return nil, utils.None[EHRExtractManifest](), err
+		}
+	}
+
+	manifest := utils.None[EHRExtractManifest]()
+	if data, ok := document["manifest"]; ok {
+		var m EHRExtractManifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, utils.None[EHRExtractManifest](), fmt.Errorf("%w: manifest: %w", ErrEHRExtractInvalid, err)
+		}
+		manifest = utils.Some(m)
+	}
+
+	return items, manifest, nil
+}
+
+// splitEHRExtractVersionedObject separates an X_VERSIONED_OBJECT into the header as it was exported and its versions
+func splitEHRExtractVersionedObject(data []byte) ([]byte, []json.RawMessage, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return nil, nil, err
+	}
+	compact := buf.Bytes()
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(compact, &fields); err != nil {
+		return nil, nil, err
+	}
+
+	var versions []json.RawMessage
+	if raw, ok := fields["versions"]; ok {
+		if err := json.Unmarshal(raw, &versions); err != nil {
+			return nil, nil, fmt.Errorf("versions must be a list: %w", err)
+		}
+	}
+
+	// The export appends the versions to the header, cutting them off gives back the exact header
+	suffix := append(append([]byte(`,"versions":`), fields["versions"]...), '}')
+	if bytes.HasSuffix(compact, suffix) {
+		header := append(bytes.Clone(compact[:len(compact)-len(suffix)]), '}')
+		return header, versions, nil
+	}
+
+	delete(fields, "versions")
+	header, err := json.Marshal(fields)
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, versions, nil
+}
+
+// ehrExtractUID returns uid.value of a document, or item.uid.value of an IMPORTED_VERSION
+func ehrExtractUID(data []byte) (string, error) {
+	type uid struct {
+		Value string `json:"value"`
+	}
+	var document struct {
+		UID  uid `json:"uid"`
+		Item struct {
+			UID uid `json:"uid"`
+		} `json:"item"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return "", err
+	}
+
+	if document.UID.Value != "" {
+		return document.UID.Value, nil
+	}
+	if document.Item.UID.Value != "" {
+		return document.Item.UID.Value, nil
+	}
+	return "", fmt.Errorf("uid is required")
+}
+
+func readEHRExtractZip(data []byte) ([]EHRExtractItem, utils.Optional[EHRExtractManifest], error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, utils.None[EHRExtractManifest](), fmt.Errorf("%w: %w", ErrEHRExtractInvalid, err)
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	readFile := func(name string) ([]byte, error) {
+		f, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is missing from the archive", ErrEHRExtractInvalid, name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrEHRExtractInvalid, name, err)
+		}
+		defer rc.Close()
+
+		content, err := io.ReadAll(rc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrEHRExtractInvalid, name, err)
+		}
+		return content, nil
+	}
+
+	// The manifest holds the order in which the items were exported
+	manifestData, err := readFile("manifest.json")
+	if err != nil {
+		return nil, utils.None[EHRExtractManifest](), err
+	}
+	var manifest EHRExtractManifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, utils.None[EHRExtractManifest](), fmt.Errorf("%w: manifest.json: %w", ErrEHRExtractInvalid, err)
+	}
+
+	if len(zr.File) != len(manifest.Items)+1 {
+		return nil, utils.None[EHRExtractManifest](), fmt.Errorf("%w: archive holds %d files, the manifest lists %d", ErrEHRExtractInvalid, len(zr.File)-1, len(manifest.Items))
+	}
+
+	items := make([]EHRExtractItem, 0, len(manifest.Items))
+	for _, entry := range manifest.Items {
+		content, err := readFile(entry.Path)
+		if err != nil {
+			return nil, utils.None[EHRExtractManifest](), err
+		}
+
+		item := EHRExtractItem{Kind: EHRExtractItemDocument, Data: content}
+		parts := strings.Split(strings.TrimSuffix(entry.Path, ".json"), "/")
+		switch len(parts) {
+		case 1:
+			item.Name = parts[0]
+		case 2:
+			item.Section, item.Name = parts[0], parts[1]
+		case 3:
+			item.Section, item.VersionedObjectID = parts[0], parts[1]
+			if parts[2] == "versioned_object" {
+				item.Kind = EHRExtractItemVersionedObject
+				item.Name = parts[2]
+			} else {
+				// File names have the '::' of version UIDs replaced, the UID is taken from the version itself
+				item.Kind = EHRExtractItemVersion
+				item.Name, err = ehrExtractUID(content)
+				if err != nil {
+					return nil, utils.None[EHRExtractManifest](), fmt.Errorf("%w: %s: %w", ErrEHRExtractInvalid, entry.Path, err)
+				}
+			}
+		default:
+			return nil, utils.None[EHRExtractManifest](), fmt.Errorf("%w: unexpected file %s", ErrEHRExtractInvalid, entry.Path)
+		}
+		if item.Path() != entry.Path {
+			return nil, utils.None[EHRExtractManifest](), fmt.Errorf("%w: %s does not hold the item its name refers to", ErrEHRExtractInvalid, entry.Path)
+		}
+
+		items = append(items, item)
+	}
+
+	return items, utils.Some(manifest), nil
+}
+
+func verifyEHRExtractManifest(items []EHRExtractItem, manifest EHRExtractManifest) error {
+	if len(items) != len(manifest.Items) {
+		return fmt.Errorf("%w: extract holds %d items, the manifest lists %d", ErrEHRExtractInvalid, len(items), len(manifest.Items))
+	}
+
+	entries := make(map[string]EHRExtractManifestEntry, len(manifest.Items))
+	for _, entry := range manifest.Items {
+		entries[entry.Path] = entry
+	}
+
+	for _, item := range items {
+		entry, ok := entries[item.Path()]
+		if !ok {
+			return fmt.Errorf("%w: %s is not listed in the manifest", ErrEHRExtractInvalid, item.Path())
+		}
+		checksum := sha256.Sum256(item.Data)
+		if entry.Size != len(item.Data) || entry.SHA256 != hex.EncodeToString(checksum[:]) {
+			return fmt.Errorf("%w: checksum of %s does not match the manifest", ErrEHRExtractInvalid, item.Path())
+		}
+	}
+
+	return nil
+}
+
+type ehrExtractImportVersion struct {
+	UID            rm.OBJECT_VERSION_ID
+	ContributionID uuid.UUID
+	// Data is the version as exported, its data is stored apart at DataPath
+	Data                   []byte
+	DataPath               []string
+	LifecycleState         string
+	LocalRefVersionedParty utils.Optional[uuid.UUID]
+}
+
+type ehrExtractImportVersionedObject struct {
+	Tables    ehrExtractVersionTable
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Data      any
+	Versions  []ehrExtractImportVersion
+}
+
+type ehrExtractImportContribution struct {
+	ID          uuid.UUID
+	TimeCreated time.Time
+	Data        rm.CONTRIBUTION
+}
+
+type ehrExtractImportTag struct {
+	Table    string
+	Column   string
+	TargetID string
+	Data     rm.ITEM_TAG
+}
+
+// ehrExtractImport is an extract checked on its own, ready to be inserted
+type ehrExtractImport struct {
+	EHRID            uuid.UUID
+	TimeCreated      time.Time
+	EHR              rm.EHR
+	VersionedObjects []ehrExtractImportVersionedObject
+	Contributions    []ehrExtractImportContribution
+	Tags             []ehrExtractImportTag
+}
+
+// ImportEHR recreates the EHR of an extract with its original ids: the EHR, its versioned objects with all of their versions,
+// the contributions that committed them and the tags. Every version is validated, and the import is refused as a whole when
+// any of the ids is already in use. Everything is inserted in a single transaction.
+func (s *Service) ImportEHR(ctx context.Context, tenantID uuid.UUID, items []EHRExtractItem) (EHRImportResult, error) {
+	extract, err := s.parseEHRExtract(ctx, items)
+	if err != nil {
+		return EHRImportResult{}, err
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return EHRImportResult{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && err != database.ErrTxClosed {
+			s.Logger.ErrorContext(ctx, "Failed to rollback transaction", "error", err)
+		}
+	}()
+
+	if err := checkEHRExtractConflicts(ctx, tx, extract); err != nil {
+		return EHRImportResult{}, err
+	}
+
+	if err := incrementTenantEHRCount(ctx, tx, tenantID); err != nil {
+		return EHRImportResult{}, err
+	}
+
+	result := EHRImportResult{
+		EHRID:             extract.EHRID,
+		ContributionCount: len(extract.Contributions),
+		TagCount:          len(extract.Tags),
+	}
+	contributionTimes := make(map[uuid.UUID]time.Time, len(extract.Contributions))
+
+	batch := &pgx.Batch{}
+
+	extract.EHR.SetModelName()
+	batch.Queue(`INSERT INTO openehr.tbl_ehr (id, created_at) VALUES ($1, $2)`, extract.EHRID, extract.TimeCreated)
+	batch.Queue(`INSERT INTO openehr.tbl_ehr_data (id, data) VALUES ($1, $2)`, extract.EHRID, extract.EHR)
+
+	for _, contribution := range extract.Contributions {
+		contribution.Data.SetModelName()
+		batch.Queue(`INSERT INTO openehr.tbl_contribution (id, ehr_id, created_at) VALUES ($1, $2, $3)`, contribution.ID, extract.EHRID, contribution.TimeCreated)
+		batch.Queue(`INSERT INTO openehr.tbl_contribution_data (id, data) VALUES ($1, $2)`, contribution.ID, contribution.Data)
+		contributionTimes[contribution.ID] = contribution.TimeCreated
+	}
+
+	for _, versionedObject := range extract.VersionedObjects {
+		tables := versionedObject.Tables
+		batch.Queue(`INSERT INTO openehr.tbl_versioned_object (id, type, ehr_id, created_at) VALUES ($1, $2, $3, $4)`, versionedObject.ID, tables.Type, extract.EHRID, versionedObject.CreatedAt)
+		batch.Queue(fmt.Sprintf(`INSERT INTO openehr.%s (id, data) VALUES ($1, $2)`, tables.VersionedTable), versionedObject.ID, versionedObject.Data)
+		result.VersionedObjectCount++
+
+		for _, version := range versionedObject.Versions {
+			// A version is in the state of its time of commit, the time of its contribution
+			createdAt := contributionTimes[version.ContributionID]
+
+			switch tables.Type {
+			case rm.VERSIONED_COMPOSITION_TYPE:
+				batch.Queue(`INSERT INTO openehr.tbl_composition (id, version_int, versioned_composition_id, ehr_id, contribution_id, lifecycle_state, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`, version.UID.Value, version.UID.VersionTreeID().Int(), versionedObject.ID, extract.EHRID, version.ContributionID, version.LifecycleState, createdAt)
+			case rm.VERSIONED_EHR_STATUS_TYPE:
+				batch.Queue(`INSERT INTO openehr.tbl_ehr_status (id, version_int, versioned_ehr_status_id, ehr_id, contribution_id, local_ref_versioned_party_id, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`, version.UID.Value, version.UID.VersionTreeID().Int(), versionedObject.ID, extract.EHRID, version.ContributionID, version.LocalRefVersionedParty, createdAt)
+			default:
+				batch.Queue(fmt.Sprintf(`INSERT INTO openehr.%s (id, version_int, %s, ehr_id, contribution_id, created_at) VALUES ($1, $2, $3, $4, $5, $6)`, tables.Table, tables.VersionedColumn), version.UID.Value, version.UID.VersionTreeID().Int(), versionedObject.ID, extract.EHRID, version.ContributionID, createdAt)
+			}
+			batch.Queue(fmt.Sprintf(`INSERT INTO openehr.%s_data (id, data, version_data) VALUES ($1, ($2::jsonb) #> $3::text[], jsonb_set($2::jsonb, $3::text[], 'null', true))`, tables.Table), version.UID.Value, version.Data, version.DataPath)
+			result.VersionCount++
+		}
+	}
+
+	for _, tag := range extract.Tags {
+		tag.Data.SetModelName()
+		batch.Queue(fmt.Sprintf(`INSERT INTO openehr.%s (%s, key, data, ehr_id) VALUES ($1, $2, $3, $4)`, tag.Table, tag.Column), tag.TargetID, tag.Data.Key, tag.Data, extract.EHRID)
+	}
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return EHRImportResult{}, fmt.Errorf("failed to execute batch for EHR import: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return EHRImportResult{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+// parseEHRExtract validates the items of an extract and checks that they refer to each other, without looking at the database
+// except for the parties referred to by the EHR_STATUS versions.
+func (s *Service) parseEHRExtract(ctx context.Context, items []EHRExtractItem) (ehrExtractImport, error) {
+	var extract ehrExtractImport
+
+	if len(items) == 0 || items[0].Kind != EHRExtractItemDocument || items[0].Name != "ehr" {
+		return ehrExtractImport{}, fmt.Errorf("%w: the extract must start with the EHR", ErrEHRExtractInvalid)
+	}
+	if err := sonic.Unmarshal(items[0].Data, &extract.EHR); err != nil {
+		return ehrExtractImport{}, fmt.Errorf("%w: ehr: %w", ErrEHRExtractInvalid, err)
+	}
+	ehrID, err := uuid.Parse(extract.EHR.EHRID.Value)
+	if err != nil {
+		return ehrExtractImport{}, fmt.Errorf("%w: ehr.ehr_id must be a UUID", ErrEHRExtractInvalid)
+	}
+	extract.EHRID = ehrID
+	extract.TimeCreated, err = parseEHRExtractTime("ehr.time_created", extract.EHR.TimeCreated)
+	if err != nil {
+		return ehrExtractImport{}, err
+	}
+
+	versionedObjectIDs := map[uuid.UUID]string{}
+	versionIDs := map[string]string{}
+	versionContributions := map[uuid.UUID]string{}
+	contributionIDs := map[uuid.UUID]bool{}
+	var tags []rm.ITEM_TAG
+
+	for _, item := range items[1:] {
+		path := item.Path()
+
+		switch item.Kind {
+		case EHRExtractItemVersionedObject:
+			var tables utils.Optional[ehrExtractVersionTable]
+			for _, candidate := range ehrExtractVersionTables {
+				if candidate.Section == item.Section {
+					tables = utils.Some(candidate)
+				}
+			}
+			if !tables.E {
+				return ehrExtractImport{}, fmt.Errorf("%w: %s is not a versioned section", ErrEHRExtractInvalid, item.Section)
+			}
+
+			versionedObject, err := parseEHRExtractVersionedObject(path, tables.V, item.Data)
+			if err != nil {
+				return ehrExtractImport{}, err
+			}
+			if versionedObject.ID.String() != item.VersionedObjectID {
+				return ehrExtractImport{}, fmt.Errorf("%w: %s holds versioned object %s", ErrEHRExtractInvalid, path, versionedObject.ID)
+			}
+			if _, ok := versionedObjectIDs[versionedObject.ID]; ok {
+				return ehrExtractImport{}, fmt.Errorf("%w: versioned object %s occurs more than once", ErrEHRExtractInvalid, versionedObject.ID)
+			}
+			versionedObjectIDs[versionedObject.ID] = tables.V.Type
+
+			extract.VersionedObjects = append(extract.VersionedObjects, versionedObject)
+		case EHRExtractItemVersion:
+			if len(extract.VersionedObjects) == 0 || extract.VersionedObjects[len(extract.VersionedObjects)-1].ID.String() != item.VersionedObjectID {
+				return ehrExtractImport{}, fmt.Errorf("%w: %s does not follow its versioned object", ErrEHRExtractInvalid, path)
+			}
+			versionedObject := &extract.VersionedObjects[len(extract.VersionedObjects)-1]
+
+			version, err := s.parseEHRExtractVersion(ctx, path, versionedObject.Tables, item.Data)
+			if err != nil {
+				return ehrExtractImport{}, err
+			}
+			if version.UID.UID() != versionedObject.ID.String() {
+				return ehrExtractImport{}, fmt.Errorf("%w: %s.uid does not belong to versioned object %s", ErrEHRExtractInvalid, path, versionedObject.ID)
+			}
+			if _, ok := versionIDs[version.UID.Value]; ok {
+				return ehrExtractImport{}, fmt.Errorf("%w: version %s occurs more than once", ErrEHRExtractInvalid, version.UID.Value)
+			}
+			versionIDs[version.UID.Value] = versionedObject.Tables.Type
+			versionContributions[version.ContributionID] = path
+
+			versionedObject.Versions = append(versionedObject.Versions, version)
+		default:
+			switch {
+			case item.Section == "contributions":
+				var contribution rm.CONTRIBUTION
+				if err := sonic.Unmarshal(item.Data, &contribution); err != nil {
+					return ehrExtractImport{}, fmt.Errorf("%w: %s: %w", ErrEHRExtractInvalid, path, err)
+				}
+				if validateErr := contribution.Validate(path); len(validateErr.Errs) > 0 {
+					return ehrExtractImport{}, validateErr
+				}
+				contributionID, err := uuid.Parse(contribution.UID.Value)
+				if err != nil {
+					return ehrExtractImport{}, fmt.Errorf("%w: %s.uid must be a UUID", ErrEHRExtractInvalid, path)
+				}
+				if contributionIDs[contributionID] {
+					return ehrExtractImport{}, fmt.Errorf("%w: contribution %s occurs more than once", ErrEHRExtractInvalid, contributionID)
+				}
+				contributionIDs[contributionID] = true
+
+				timeCreated, err := parseEHRExtractTime(path+".audit.time_committed", contribution.Audit.TimeCommitted)
+				if err != nil {
+					return ehrExtractImport{}, err
+				}
+
+				extract.Contributions = append(extract.Contributions, ehrExtractImportContribution{
+					ID:          contributionID,
+					TimeCreated: timeCreated,
+					Data:        contribution,
+				})
+			case item.Section == "" && item.Name == "tags":
+				if err := sonic.Unmarshal(item.Data, &tags); err != nil {
+					return ehrExtractImport{}, fmt.Errorf("%w: %s: %w", ErrEHRExtractInvalid, path, err)
+				}
+			default:
+				return ehrExtractImport{}, fmt.Errorf("%w: unexpected item %s", ErrEHRExtractInvalid, path)
+			}
+		}
+	}
+
+	// Every EHR has exactly one EHR_STATUS and one EHR_ACCESS
+	for _, versionedType := range []string{rm.VERSIONED_EHR_STATUS_TYPE, rm.VERSIONED_EHR_ACCESS_TYPE} {
+		count := 0
+		for _, versionedObject := range extract.VersionedObjects {
+			if versionedObject.Tables.Type == versionedType {
+				count++
+			}
+		}
+		if count != 1 {
+			return ehrExtractImport{}, fmt.Errorf("%w: the extract must hold exactly one %s, found %d", ErrEHRExtractInvalid, versionedType, count)
+		}
+	}
+	for _, versionedObject := range extract.VersionedObjects {
+		if len(versionedObject.Versions) == 0 {
+			return ehrExtractImport{}, fmt.Errorf("%w: versioned object %s has no versions", ErrEHRExtractInvalid, versionedObject.ID)
+		}
+	}
+
+	for contributionID, path := range versionContributions {
+		if !contributionIDs[contributionID] {
+			return ehrExtractImport{}, fmt.Errorf("%w: contribution %s of %s is missing from the extract", ErrEHRExtractInvalid, contributionID, path)
+		}
+	}
+
+	extract.Tags, err = parseEHRExtractTags(tags, versionedObjectIDs, versionIDs)
+	if err != nil {
+		return ehrExtractImport{}, err
+	}
+
+	return extract, nil
+}
+
+func parseEHRExtractVersionedObject(path string, tables ehrExtractVersionTable, data []byte) (ehrExtractImportVersionedObject, error) {
+	var header struct {
+		UID         rm.HIER_OBJECT_ID `json:"uid"`
+		OwnerID     rm.OBJECT_REF     `json:"owner_id"`
+		TimeCreated rm.DV_DATE_TIME   `json:"time_created"`
+	}
+	if err := sonic.Unmarshal(data, &header); err != nil {
+		return ehrExtractImportVersionedObject{}, fmt.Errorf("%w: %s: %w", ErrEHRExtractInvalid, path, err)
+	}
+
+	id, err := uuid.Parse(header.UID.Value)
+	if err != nil {
+		return ehrExtractImportVersionedObject{}, fmt.Errorf("%w: %s.uid must be a UUID", ErrEHRExtractInvalid, path)
+	}
+	createdAt, err := parseEHRExtractTime(path+".time_created", header.TimeCreated)
+	if err != nil {
+		return ehrExtractImportVersionedObject{}, err
+	}
+
+	var versionedObject any
+	switch tables.Type {
+	case rm.VERSIONED_EHR_STATUS_TYPE:
+		v := rm.VERSIONED_EHR_STATUS{UID: header.UID, OwnerID: header.OwnerID, TimeCreated: header.TimeCreated}
+		v.SetModelName()
+		versionedObject = v
+	case rm.VERSIONED_EHR_ACCESS_TYPE:
+		v := rm.VERSIONED_EHR_ACCESS{UID: header.UID, OwnerID: header.OwnerID, TimeCreated: header.TimeCreated}
+		v.SetModelName()
+		versionedObject = v
+	case rm.VERSIONED_FOLDER_TYPE:
+		v := rm.VERSIONED_FOLDER{UID: header.UID, OwnerID: header.OwnerID, TimeCreated: header.TimeCreated}
+		v.SetModelName()
+		versionedObject = v
+	case rm.VERSIONED_COMPOSITION_TYPE:
+		v := rm.VERSIONED_COMPOSITION{UID: header.UID, OwnerID: header.OwnerID, TimeCreated: header.TimeCreated}
+		v.SetModelName()
+		versionedObject = v
+	}
+
+	return ehrExtractImportVersionedObject{
+		Tables:    tables,
+		ID:        id,
+		CreatedAt: createdAt,
+		Data:      versionedObject,
+	}, nil
+}
+
+// parseEHRExtractVersion validates an ORIGINAL_VERSION or IMPORTED_VERSION, which must hold the data of the section it is in
+func (s *Service) parseEHRExtractVersion(ctx context.Context, path string, tables ehrExtractVersionTable, data []byte) (ehrExtractImportVersion, error) {
+	var original rm.ORIGINAL_VERSION
+	var contribution rm.OBJECT_REF
+	dataPath := []string{"data"}
+
+	switch util.UnsafeTypeFieldExtraction(data) {
+	case rm.ORIGINAL_VERSION_TYPE:
+		if err := sonic.Unmarshal(data, &original); err != nil {
+			return ehrExtractImportVersion{}, fmt.Errorf("%w: %s: %w", ErrEHRExtractInvalid, path, err)
+		}
+		if validateErr := original.Validate(path); len(validateErr.Errs) > 0 {
+			return ehrExtractImportVersion{}, validateErr
+		}
+		if !original.Contribution.E {
+			return ehrExtractImportVersion{}, fmt.Errorf("%w: %s.contribution is required", ErrEHRExtractInvalid, path)
+		}
+		contribution = original.Contribution.V
+	case rm.IMPORTED_VERSION_TYPE:
+		var imported rm.IMPORTED_VERSION
+		if err := sonic.Unmarshal(data, &imported); err != nil {
+			return ehrExtractImportVersion{}, fmt.Errorf("%w: %s: %w", ErrEHRExtractInvalid, path, err)
+		}
+		if validateErr := imported.Validate(path); len(validateErr.Errs) > 0 {
+			return ehrExtractImportVersion{}, validateErr
+		}
+		original = imported.Item
+		contribution = imported.Contribution
+		dataPath = []string{"item", "data"}
+	default:
+		return ehrExtractImportVersion{}, fmt.Errorf("%w: %s must be an ORIGINAL_VERSION or IMPORTED_VERSION", ErrEHRExtractInvalid, path)
+	}
+
+	version := ehrExtractImportVersion{
+		UID:            original.UID,
+		Data:           data,
+		DataPath:       dataPath,
+		LifecycleState: terminology.VERSION_LIFECYCLE_STATE_CODE_COMPLETE,
+	}
+
+	hierObjectID := contribution.ID.HIER_OBJECT_ID()
+	if hierObjectID == nil {
+		return ehrExtractImportVersion{}, fmt.Errorf("%w: %s.contribution.id must be a HIER_OBJECT_ID", ErrEHRExtractInvalid, path)
+	}
+	contributionID, err := uuid.Parse(hierObjectID.Value)
+	if err != nil {
+		return ehrExtractImportVersion{}, fmt.Errorf("%w: %s.contribution.id must be a UUID", ErrEHRExtractInvalid, path)
+	}
+	version.ContributionID = contributionID
+
+	switch versionData := original.Data.Value.(type) {
+	case *rm.EHR_STATUS:
+		if tables.Type != rm.VERSIONED_EHR_STATUS_TYPE {
+			break
+		}
+		if err := s.ValidateEHRStatus(ctx, *versionData); err != nil {
+			return ehrExtractImportVersion{}, err
+		}
+
+		// Only 'local' VERSIONED_PARTY external refs are supported
+		if versionData.Subject.ExternalRef.E && versionData.Subject.ExternalRef.V.Namespace == rm.Namespace_local && versionData.Subject.ExternalRef.V.Type == rm.VERSIONED_PARTY_TYPE {
+			version.LocalRefVersionedParty = utils.Some(uuid.MustParse(versionData.Subject.ExternalRef.V.ID.Value.(*rm.HIER_OBJECT_ID).Value))
+		}
+		return version, nil
+	case *rm.EHR_ACCESS:
+		if tables.Type == rm.VERSIONED_EHR_ACCESS_TYPE {
+			return version, nil
+		}
+	case *rm.FOLDER:
+		if tables.Type == rm.VERSIONED_FOLDER_TYPE {
+			return version, nil
+		}
+	case *rm.COMPOSITION:
+		if tables.Type == rm.VERSIONED_COMPOSITION_TYPE {
+			if original.LifecycleState.DefiningCode.CodeString != "" {
+				version.LifecycleState = original.LifecycleState.DefiningCode.CodeString
+			}
+			return version, nil
+		}
+	}
+
+	return ehrExtractImportVersion{}, fmt.Errorf("%w: %s.data does not belong in %s", ErrEHRExtractInvalid, path, tables.Section)
+}
+
+// parseEHRExtractTags validates the tags and decides where each is stored from its target,
+// a version or versioned object of an EHR_STATUS or composition of the extract.
+func parseEHRExtractTags(tags []rm.ITEM_TAG, versionedObjectIDs map[uuid.UUID]string, versionIDs map[string]string) ([]ehrExtractImportTag, error) {
+	var validateErr util.ValidateError
+	for i, tag := range tags {
+		validateErr.Errs = append(validateErr.Errs, tag.Validate(fmt.Sprintf("tags[%d]", i)).Errs...)
+	}
+	if len(validateErr.Errs) > 0 {
+		return nil, validateErr
+	}
+
+	result := make([]ehrExtractImportTag, 0, len(tags))
+	keys := map[string]bool{}
+	for i, tag := range tags {
+		path := fmt.Sprintf("tags[%d]", i)
+
+		var table, column, targetID string
+		switch tag.Target.Kind {
+		case rm.UID_BASED_ID_kind_HIER_OBJECT_ID:
+			targetID = tag.Target.HIER_OBJECT_ID().Value
+			id, err := uuid.Parse(targetID)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s.target must be a UUID", ErrEHRExtractInvalid, path)
+			}
+			switch versionedObjectIDs[id] {
+			case rm.VERSIONED_EHR_STATUS_TYPE:
+				table, column = "tbl_versioned_ehr_status_tag", "versioned_ehr_status_id"
+			case rm.VERSIONED_COMPOSITION_TYPE:
+				table, column = "tbl_versioned_composition_tag", "versioned_composition_id"
+			}
+		case rm.UID_BASED_ID_kind_OBJECT_VERSION_ID:
+			targetID = tag.Target.OBJECT_VERSION_ID().Value
+			switch versionIDs[targetID] {
+			case rm.VERSIONED_EHR_STATUS_TYPE:
+				table, column = "tbl_ehr_status_tag", "ehr_status_id"
+			case rm.VERSIONED_COMPOSITION_TYPE:
+				table, column = "tbl_composition_tag", "composition_id"
+			}
+		}
+		if table == "" {
+			return nil, fmt.Errorf("%w: %s.target %q is not an EHR_STATUS or composition of the extract", ErrEHRExtractInvalid, path, targetID)
+		}
+
+		if keys[targetID+"/"+tag.Key] {
+			return nil, fmt.Errorf("%w: %s.key %q is used more than once for %s", ErrEHRExtractInvalid, path, tag.Key, targetID)
+		}
+		keys[targetID+"/"+tag.Key] = true
+
+		result = append(result, ehrExtractImportTag{
+			Table:    table,
+			Column:   column,
+			TargetID: targetID,
+			Data:     tag,
+		})
+	}
+
+	return result, nil
+}
+
+// checkEHRExtractConflicts refuses the import when the EHR, one of its versioned objects or contributions already exists.
+// Version ids start with the id of their versioned object, so they cannot be in use either.
+func checkEHRExtractConflicts(ctx context.Context, tx pgx.Tx, extract ehrExtractImport) error {
+	// A deleted EHR keeps its id until it is purged
+	err := tx.QueryRow(ctx, `SELECT 1 FROM openehr.tbl_ehr WHERE id = $1`, extract.EHRID).Scan(new(int))
+	if err == nil {
+		return fmt.Errorf("%w: EHR %s already exists", ErrEHRExtractConflict, extract.EHRID)
+	}
+	if !errors.Is(err, database.ErrNoRows) {
+		return fmt.Errorf("failed to check if EHR exists: %w", err)
+	}
+
+	versionedObjectIDs := make([]uuid.UUID, len(extract.VersionedObjects))
+	for i, versionedObject := range extract.VersionedObjects {
+		versionedObjectIDs[i] = versionedObject.ID
+	}
+	var id uuid.UUID
+	err = tx.QueryRow(ctx, `SELECT id FROM openehr.tbl_versioned_object WHERE id = ANY($1) LIMIT 1`, versionedObjectIDs).Scan(&id)
+	if err == nil {
+		return fmt.Errorf("%w: versioned object %s already exists", ErrEHRExtractConflict, id)
+	}
+	if !errors.Is(err, database.ErrNoRows) {
+		return fmt.Errorf("failed to check if versioned objects exist: %w", err)
+	}
+
+	contributionIDs := make([]uuid.UUID, len(extract.Contributions))
+	for i, contribution := range extract.Contributions {
+		contributionIDs[i] = contribution.ID
+	}
+	err = tx.QueryRow(ctx, `SELECT id FROM openehr.tbl_contribution WHERE id = ANY($1) LIMIT 1`, contributionIDs).Scan(&id)
+	if err == nil {
+		return fmt.Errorf("%w: contribution %s already exists", ErrEHRExtractConflict, id)
+	}
+	if !errors.Is(err, database.ErrNoRows) {
+		return fmt.Errorf("failed to check if contributions exist: %w", err)
+	}
+
+	return nil
+}
+
+func parseEHRExtractTime(path string, value rm.DV_DATE_TIME) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, value.Value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s must be an ISO 8601 date time: %w", ErrEHRExtractInvalid, path, err)
+	}
+	return t, nil
+}
@@ -0,0 +1,206 @@
+package openehr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newTestEHRExtract writes a synthetic extract with several versioned objects and versions, it returns the items as written and the manifest
+func newTestEHRExtract(t *testing.T, w EHRExtractWriter) ([]EHRExtractItem, EHRExtractManifest) {
+	t.Helper()
+
+	ehrID := uuid.New()
+	manifest := EHRExtractManifest{
+		EHRID:       ehrID,
+		SystemID:    "test.gopenehr",
+		TimeCreated: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+		Items:       []EHRExtractManifestEntry{},
+	}
+
+	versionedObject := func(section, rmType string, id uuid.UUID) EHRExtractItem {
+		return EHRExtractItem{
+			Kind:              EHRExtractItemVersionedObject,
+			Section:           section,
+			VersionedObjectID: id.String(),
+			Name:              "versioned_object",
+			Data:              fmt.Appendf(nil, `{"_type":"%s","uid":{"value":"%s"},"owner_id":{"value":"%s"}}`, rmType, id, ehrID),
+		}
+	}
+	version := func(section, rmType string, id uuid.UUID, versionTreeID string) EHRExtractItem {
+		versionID := id.String() + "::test.gopenehr::" + versionTreeID
+		return EHRExtractItem{
+			Kind:              EHRExtractItemVersion,
+			Section:           section,
+			VersionedObjectID: id.String(),
+			Name:              versionID,
+			Data:              fmt.Appendf(nil, `{"_type":"ORIGINAL_VERSION","uid":{"value":"%s"},"data":{"_type":"%s","name":{"value":"version %s"}}}`, versionID, rmType, versionTreeID),
+		}
+	}
+
+	ehrStatusID, compositionID, otherCompositionID := uuid.New(), uuid.New(), uuid.New()
+	importedVersionID := otherCompositionID.String() + "::remote.system::1"
+	contributionID, otherContributionID := uuid.New(), uuid.New()
+
+	items := []EHRExtractItem{
+		{Kind: EHRExtractItemDocument, Name: "ehr", Data: fmt.Appendf(nil, `{"_type":"EHR","ehr_id":{"value":"%s"}}`, ehrID)},
+		versionedObject("ehr_status", "VERSIONED_EHR_STATUS", ehrStatusID),
+		version("ehr_status", "EHR_STATUS", ehrStatusID, "1"),
+		version("ehr_status", "EHR_STATUS", ehrStatusID, "2"),
+		versionedObject("compositions", "VERSIONED_COMPOSITION", compositionID),
+		version("compositions", "COMPOSITION", compositionID, "1"),
+		version("compositions", "COMPOSITION", compositionID, "1.1.1"),
+		version("compositions", "COMPOSITION", compositionID, "2"),
+		versionedObject("compositions", "VERSIONED_COMPOSITION", otherCompositionID),
+		{
+			Kind:              EHRExtractItemVersion,
+			Section:           "compositions",
+			VersionedObjectID: otherCompositionID.String(),
+			Name:              importedVersionID,
+			Data:              fmt.Appendf(nil, `{"_type":"IMPORTED_VERSION","item":{"_type":"ORIGINAL_VERSION","uid":{"value":"%s"}}}`, importedVersionID),
+		},
+		{Kind: EHRExtractItemDocument, Section: "contributions", Name: contributionID.String(), Data: fmt.Appendf(nil, `{"_type":"CONTRIBUTION","uid":{"value":"%s"}}`, contributionID)},
+		{Kind: EHRExtractItemDocument, Section: "contributions", Name: otherContributionID.String(), Data: fmt.Appendf(nil, `{"_type":"CONTRIBUTION","uid":{"value":"%s"}}`, otherContributionID)},
+		{Kind: EHRExtractItemDocument, Name: "tags", Data: []byte(`[{"_type":"ITEM_TAG","key":"it's a tag"}]`)},
+	}
+
+	if err := w.Begin(manifest); err != nil {
+		t.Fatalf("Failed to begin extract: %v", err)
+	}
+	emit := ehrExtractEmitter(w, &manifest)
+	for _, item := range items {
+		if err := emit(item); err != nil {
+			t.Fatalf("Failed to write %s: %v", item.Path(), err)
+		}
+	}
+	if err := w.Close(manifest); err != nil {
+		t.Fatalf("Failed to close extract: %v", err)
+	}
+
+	return items, manifest
+}
+
+func checkEHRExtractItems(t *testing.T, expected, actual []EHRExtractItem) {
+	t.Helper()
+
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected %d items, got %d", len(expected), len(actual))
+	}
+	for i := range expected {
+		if actual[i].Kind != expected[i].Kind || actual[i].Section != expected[i].Section || actual[i].VersionedObjectID != expected[i].VersionedObjectID || actual[i].Name != expected[i].Name {
+			t.Errorf("Expected item %d to be %s, got %s", i, expected[i].Path(), actual[i].Path())
+		}
+		if !bytes.Equal(actual[i].Data, expected[i].Data) {
+			t.Errorf("Expected item %s to hold %s, got %s", expected[i].Path(), expected[i].Data, actual[i].Data)
+		}
+	}
+}
+
+func TestEHRExtractJSONRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	items, manifest := newTestEHRExtract(t, NewEHRExtractJSONWriter(&buf))
+
+	read, readManifest, err := readEHRExtractJSON(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to read extract: %v", err)
+	}
+	checkEHRExtractItems(t, items, read)
+
+	if !readManifest.E || len(readManifest.V.Items) != len(manifest.Items) || readManifest.V.EHRID != manifest.EHRID {
+		t.Fatalf("Expected the manifest to be read back, got %+v", readManifest)
+	}
+	if err := verifyEHRExtractManifest(read, readManifest.V); err != nil {
+		t.Errorf("Expected read items to match the manifest, got %v", err)
+	}
+
+	if _, err := ReadEHRExtract(buf.Bytes(), EHRExtractFormatJSON); err != nil {
+		t.Errorf("Failed to read extract through ReadEHRExtract: %v", err)
+	}
+}
+
+func TestEHRExtractJSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	newTestEHRExtract(t, NewEHRExtractJSONWriter(&buf))
+
+	if !strings.HasPrefix(buf.String(), `{"ehr_id":`) || !strings.Contains(buf.String(), `"format":"`+EHRExtractDocumentFormat+`"`) {
+		t.Fatalf("Expected the document to carry the export format, got %s", buf.String())
+	}
+
+	data := bytes.Replace(buf.Bytes(), []byte(EHRExtractDocumentFormat), []byte("EHR_EXTRACT"), 1)
+	if _, _, err := readEHRExtractJSON(data); !errors.Is(err, ErrEHRExtractInvalid) {
+		t.Errorf("Expected ErrEHRExtractInvalid for an unknown format, got %v", err)
+	}
+}
+
+func TestEHRExtractZipRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	items, manifest := newTestEHRExtract(t, NewEHRExtractZipWriter(&buf))
+
+	read, readManifest, err := readEHRExtractZip(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to read extract: %v", err)
+	}
+	checkEHRExtractItems(t, items, read)
+
+	if !readManifest.E || len(readManifest.V.Items) != len(manifest.Items) || readManifest.V.EHRID != manifest.EHRID {
+		t.Fatalf("Expected the manifest to be read back, got %+v", readManifest)
+	}
+	if err := verifyEHRExtractManifest(read, readManifest.V); err != nil {
+		t.Errorf("Expected read items to match the manifest, got %v", err)
+	}
+
+	if _, err := ReadEHRExtract(buf.Bytes(), EHRExtractFormatZip); err != nil {
+		t.Errorf("Failed to read extract through ReadEHRExtract: %v", err)
+	}
+}
+
+func TestVerifyEHRExtractManifest(t *testing.T) {
+	var buf bytes.Buffer
+	items, manifest := newTestEHRExtract(t, NewEHRExtractJSONWriter(&buf))
+
+	tests := []struct {
+		name    string
+		modify  func(items []EHRExtractItem) []EHRExtractItem
+		message string
+	}{
+		{
+			name: "checksum mismatch",
+			modify: func(items []EHRExtractItem) []EHRExtractItem {
+				items[2].Data = bytes.Replace(items[2].Data, []byte("version 1"), []byte("version X"), 1)
+				return items
+			},
+			message: "checksum of",
+		},
+		{
+			name: "missing item",
+			modify: func(items []EHRExtractItem) []EHRExtractItem {
+				return append(items[:3], items[4:]...)
+			},
+			message: "the manifest lists",
+		},
+		{
+			name: "item not in manifest",
+			modify: func(items []EHRExtractItem) []EHRExtractItem {
+				items[len(items)-2].Name = uuid.NewString()
+				return items
+			},
+			message: "is not listed in the manifest",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modified := tt.modify(append([]EHRExtractItem(nil), items...))
+
+			err := verifyEHRExtractManifest(modified, manifest)
+			if !errors.Is(err, ErrEHRExtractInvalid) || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Expected ErrEHRExtractInvalid containing %q, got %v", tt.message, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package openehr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bytedance/sonic"
+	"github.com/freekieb7/gopenehr/internal/database"
+	"github.com/freekieb7/gopenehr/internal/openehr/rm"
+	"github.com/freekieb7/gopenehr/internal/openehr/terminology"
+	"github.com/freekieb7/gopenehr/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// EHRMergeResult lists what moved, or would move on a dry run, from the source EHR into the target EHR
+type EHRMergeResult struct {
+	SourceEHRID             uuid.UUID              `json:"source_ehr_id"`
+	TargetEHRID             uuid.UUID              `json:"target_ehr_id"`
+	DryRun                  bool                   `json:"dry_run"`
+	VersionedCompositionIDs []uuid.UUID            `json:"versioned_composition_ids"`
+	VersionedFolderIDs      []uuid.UUID            `json:"versioned_folder_ids"`
+	TagCount                int                    `json:"tag_count"`
+	AttachmentCount         int                    `json:"attachment_count"`
+	ContributionUID         utils.Optional[string] `json:"contribution_uid,omitzero"`
+	SourceEHRStatusUID      utils.Optional[string] `json:"source_ehr_status_uid,omitzero"`
+}
+
+// MergeEHRs moves every versioned composition, versioned folder, composition tag and attachment of the source EHR into the target EHR.
+// The source EHR gets a new EHR_STATUS version that is neither modifiable nor queryable, committed in a contribution with the
+// given description, and keeps a pointer to the target. On a dry run nothing is changed and only the result is reported.
+func (s *Service) MergeEHRs(ctx context.Context, sourceEHRID, targetEHRID uuid.UUID, description string, dryRun bool) (EHRMergeResult, error) {
+	if sourceEHRID == targetEHRID {
+		return EHRMergeResult{}, ErrEHRMergeSameEHR
+	}
+
+	result := EHRMergeResult{
+		SourceEHRID:             sourceEHRID,
+		TargetEHRID:             targetEHRID,
+		DryRun:                  dryRun,
+		VersionedCompositionIDs: []uuid.UUID{},
+		VersionedFolderIDs:      []uuid.UUID{},
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return EHRMergeResult{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && err != database.ErrTxClosed {
+			s.Logger.ErrorContext(ctx, "Failed to rollback transaction", "error", err)
+		}
+	}()
+
+	// Lock both EHRs in a fixed order, so concurrent merges and contributions cannot interleave
+	rows, err := tx.Query(ctx, `SELECT id, merged_into_ehr_id FROM openehr.tbl_ehr WHERE id = ANY($1) AND deleted_at IS NULL ORDER BY id FOR UPDATE`, []uuid.UUID{sourceEHRID, targetEHRID})
+	if err != nil {
+		return EHRMergeResult{}, fmt.Errorf("failed to lock EHRs: %w", err)
+	}
+	locked := 0
+	for rows.Next() {
+		var id uuid.UUID
+		var mergedInto utils.Optional[uuid.UUID]
+		if err := rows.Scan(&id, &mergedInto); err != nil {
+			rows.Close()
+			return EHRMergeResult{}, fmt.Errorf("failed to scan locked EHR: %w", err)
+		}
+		if mergedInto.E {
+			rows.Close()
+			return EHRMergeResult{}, fmt.Errorf("%w: EHR %s was merged into %s", ErrEHRAlreadyMerged, id, mergedInto.V)
+		}
+		locked++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return EHRMergeResult{}, fmt.Errorf("failed to lock EHRs: %w", err)
+	}
+	if locked != 2 {
+		return EHRMergeResult{}, ErrEHRNotFound
+	}
+
+	if err := checkEHRModifiable(ctx, tx, sourceEHRID); err != nil {
+		return EHRMergeResult{}, err
+	}
+	if err := checkEHRModifiable(ctx, tx, targetEHRID); err != nil {
+		return EHRMergeResult{}, err
+	}
+
+	rows, err = tx.Query(ctx, `SELECT id, type FROM openehr.tbl_versioned_object WHERE ehr_id = $1 AND type = ANY($2) ORDER BY created_at, id`, sourceEHRID, []string{rm.VERSIONED_COMPOSITION_TYPE, rm.VERSIONED_FOLDER_TYPE})
+	if err != nil {
+		return EHRMergeResult{}, fmt.Errorf("failed to fetch versioned objects of source EHR: %w", err)
+	}
+	for rows.Next() {
+		var id uuid.UUID
+		var versionedObjectType string
+		if err := rows.Scan(&id, &versionedObjectType); err != nil {
+			rows.Close()
+			return EHRMergeResult{}, fmt.Errorf("failed to scan versioned object: %w", err)
+		}
+		if versionedObjectType == rm.VERSIONED_FOLDER_TYPE {
+			result.VersionedFolderIDs = append(result.VersionedFolderIDs, id)
+		} else {
+			result.VersionedCompositionIDs = append(result.VersionedCompositionIDs, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return EHRMergeResult{}, fmt.Errorf("failed to fetch versioned objects of source EHR: %w", err)
+	}
+
+	// An EHR has a single directory, the folders of both EHRs cannot be combined by moving them
+	if len(result.VersionedFolderIDs) > 0 {
+		var targetHasDirectory bool
+		err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM openehr.tbl_versioned_object WHERE ehr_id = $1 AND type = $2)`, targetEHRID, rm.VERSIONED_FOLDER_TYPE).Scan(&targetHasDirectory)
+		if err != nil {
+			return EHRMergeResult{}, fmt.Errorf("failed to check directory of target EHR: %w", err)
+		}
+		if targetHasDirectory {
+			return EHRMergeResult{}, ErrEHRMergeDirectoryConflict
+		}
+	}
+
+	err = tx.QueryRow(ctx, `
+		SELECT (SELECT COUNT(*) FROM openehr.tbl_composition_tag WHERE ehr_id = $1)
+		     + (SELECT COUNT(*) FROM openehr.tbl_versioned_composition_tag WHERE ehr_id = $1)
+	`, sourceEHRID).Scan(&result.TagCount)
+	if err != nil {
+		return EHRMergeResult{}, fmt.Errorf("failed to count tags of source EHR: %w", err)
+	}
+
+	err = tx.QueryRow(ctx, `SELECT COUNT(*) FROM openehr.tbl_attachment WHERE ehr_id = $1`, sourceEHRID).Scan(&result.AttachmentCount)
+	if err != nil {
+		return EHRMergeResult{}, fmt.Errorf("failed to count attachments of source EHR: %w", err)
+	}
+
+	if dryRun {
+		return result, nil
+	}
+
+	// Freeze the source EHR with a new EHR_STATUS version
+	var currentEHRStatusID rm.OBJECT_VERSION_ID
+	var versionedEHRStatusID uuid.UUID
+	var ehrStatusData []byte
+	err = tx.QueryRow(ctx, `
+		SELECT es.id, es.versioned_ehr_status_id, esd.data
+		FROM openehr.tbl_ehr_status es
+		JOIN openehr.tbl_ehr_status_data esd ON esd.id = es.id
+		WHERE es.ehr_id = $1
+		ORDER BY es.version_int DESC
+		LIMIT 1
+	`, sourceEHRID).Scan(&currentEHRStatusID.Value, &versionedEHRStatusID, &ehrStatusData)
+	if err != nil {
+		if errors.Is(err, database.ErrNoRows) {
+			return EHRMergeResult{}, ErrEHRStatusNotFound
+		}
+		return EHRMergeResult{}, fmt.Errorf("failed to fetch EHR Status of source EHR: %w", err)
+	}
+
+	var ehrStatus rm.EHR_STATUS
+	if err := sonic.Unmarshal(ehrStatusData, &ehrStatus); err != nil {
+		return EHRMergeResult{}, fmt.Errorf("failed to unmarshal EHR Status of source EHR: %w", err)
+	}
+	ehrStatus.IsModifiable = false
+	ehrStatus.IsQueryable = false
+
+	nextEHRStatusID, err := UpgradeObjectVersionID(rm.UID_BASED_ID_from_HIER_OBJECT_ID(&rm.HIER_OBJECT_ID{
+		Value: currentEHRStatusID.UID(),
+	}), currentEHRStatusID)
+	if err != nil {
+		return EHRMergeResult{}, fmt.Errorf("failed to upgrade EHR Status UID of source EHR: %w", err)
+	}
+	ehrStatus.UID = utils.Some(rm.UID_BASED_ID_from_OBJECT_VERSION_ID(&nextEHRStatusID))
+
+	if description == "" {
+		description = "EHR merged into " + targetEHRID.String()
+	}
+	contribution := NewContribution(description, terminology.AUDIT_CHANGE_TYPE_CODE_MODIFICATION, []rm.OBJECT_REF{
+		{
+			Type:      rm.VERSIONED_EHR_STATUS_TYPE,
+			Namespace: rm.Namespace_local,
+			ID:        rm.OBJECT_ID_from_OBJECT_VERSION_ID(nextEHRStatusID),
+		},
+	})
+
+	// The contributions that committed the moved versions move along, deleting the source EHR would otherwise cascade into the moved versions
+	var movedContributionIDs []uuid.UUID
+	err = tx.QueryRow(ctx, `
+		SELECT ARRAY(
+			SELECT contribution_id FROM openehr.tbl_composition WHERE ehr_id = $1
+			UNION
+			SELECT contribution_id FROM openehr.tbl_folder WHERE ehr_id = $1
+		)
+	`, sourceEHRID).Scan(&movedContributionIDs)
+	if err != nil {
+		return EHRMergeResult{}, fmt.Errorf("failed to fetch contributions of source EHR: %w", err)
+	}
+	movedContributionUIDs := make([]string, 0, len(movedContributionIDs))
+	for _, id := range movedContributionIDs {
+		movedContributionUIDs = append(movedContributionUIDs, id.String())
+	}
+
+	batch := &pgx.Batch{}
+
+	// Insert CONTRIBUTION
+	contribution.SetModelName()
+	batch.Queue(`INSERT INTO openehr.tbl_contribution (id, ehr_id) VALUES ($1, $2)`, contribution.UID.Value, sourceEHRID)
+	batch.Queue(`INSERT INTO openehr.tbl_contribution_data (id, data) VALUES ($1, $2)`, contribution.UID.Value, contribution)
+
+	err = queueContributionChange(batch, sourceEHRID, contribution, ContributionChange{
+		Type:                rm.EHR_STATUS_TYPE,
+		ChangeType:          terminology.AUDIT_CHANGE_TYPE_CODE_MODIFICATION,
+		LifecycleState:      terminology.VERSION_LIFECYCLE_STATE_CODE_COMPLETE,
+		VersionedObjectID:   versionedEHRStatusID,
+		PrecedingVersionUID: utils.Some(currentEHRStatusID),
+		VersionUID:          nextEHRStatusID,
+	}, rm.ORIGINAL_VERSION_DATA_from_EHR_STATUS(ehrStatus), utils.None[rm.ORIGINAL_VERSION]())
+	if err != nil {
+		return EHRMergeResult{}, err
+	}
+
+	batch.Queue(`
+		UPDATE openehr.tbl_ehr_data
+		SET data = jsonb_insert(data, '{contributions, -1}', $1::jsonb, true)
+		WHERE id = $2
+	`, rm.OBJECT_REF{
+		Type:      rm.CONTRIBUTION_TYPE,
+		Namespace: rm.Namespace_local,
+		ID:        rm.OBJECT_ID_from_HIER_OBJECT_ID(contribution.UID),
+	}, sourceEHRID)
+
+	// Move the versioned objects, their versions and tags, and point their owner at the target EHR
+	movedIDs := append(append([]uuid.UUID{}, result.VersionedCompositionIDs...), result.VersionedFolderIDs...)
+	batch.Queue(`UPDATE openehr.tbl_versioned_object SET ehr_id = $1 WHERE id = ANY($2)`, targetEHRID, movedIDs)
+	batch.Queue(`UPDATE openehr.tbl_versioned_composition_data SET data = jsonb_set(data, '{owner_id, id, value}', to_jsonb($1::text)) WHERE id = ANY($2)`, targetEHRID.String(), result.VersionedCompositionIDs)
+	batch.Queue(`UPDATE openehr.tbl_versioned_folder_data SET data = jsonb_set(data, '{owner_id, id, value}', to_jsonb($1::text)) WHERE id = ANY($2)`, targetEHRID.String(), result.VersionedFolderIDs)
+	batch.Queue(`UPDATE openehr.tbl_composition SET ehr_id = $1 WHERE ehr_id = $2`, targetEHRID, sourceEHRID)
+	batch.Queue(`UPDATE openehr.tbl_folder SET ehr_id = $1 WHERE ehr_id = $2`, targetEHRID, sourceEHRID)
+	batch.Queue(`UPDATE openehr.tbl_composition_tag SET ehr_id = $1 WHERE ehr_id = $2`, targetEHRID, sourceEHRID)
+	batch.Queue(`UPDATE openehr.tbl_versioned_composition_tag SET ehr_id = $1 WHERE ehr_id = $2`, targetEHRID, sourceEHRID)
+	batch.Queue(`UPDATE openehr.tbl_contribution SET ehr_id = $1 WHERE id = ANY($2)`, targetEHRID, movedContributionIDs)
+	batch.Queue(`UPDATE openehr.tbl_attachment SET ehr_id = $1 WHERE ehr_id = $2`, targetEHRID, sourceEHRID)
+
+	// Move the references held by the EHRs themselves
+	batch.Queue(`
+		UPDATE openehr.tbl_ehr_data target
+		SET data = CASE WHEN source.data ? 'directory'
+			THEN jsonb_set(jsonb_set(target.data, '{directory}', source.data->'directory'), '{folders}', COALESCE(source.data->'folders', '[]'::jsonb))
+			ELSE target.data
+		END || jsonb_build_object('compositions', COALESCE(target.data->'compositions', '[]'::jsonb) || COALESCE(source.data->'compositions', '[]'::jsonb))
+		FROM openehr.tbl_ehr_data source
+		WHERE target.id = $1 AND source.id = $2
+	`, targetEHRID, sourceEHRID)
+	batch.Queue(`
+		UPDATE openehr.tbl_ehr_data target
+		SET data = jsonb_set(target.data, '{contributions}', COALESCE(target.data->'contributions', '[]'::jsonb) || COALESCE((
+			SELECT jsonb_agg(ref) FROM jsonb_array_elements(source.data->'contributions') ref WHERE ref->'id'->>'value' = ANY($3)
+		), '[]'::jsonb))
+		FROM openehr.tbl_ehr_data source
+		WHERE target.id = $1 AND source.id = $2
+	`, targetEHRID, sourceEHRID, movedContributionUIDs)
+	batch.Queue(`
+		UPDATE openehr.tbl_ehr_data
+		SET data = (data #- '{directory}') || jsonb_build_object(
+			'compositions', '[]'::jsonb,
+			'folders', '[]'::jsonb,
+			'contributions', COALESCE((
+				SELECT jsonb_agg(ref) FROM jsonb_array_elements(data->'contributions') ref WHERE NOT ref->'id'->>'value' = ANY($2)
+			), '[]'::jsonb)
+		)
+		WHERE id = $1
+	`, sourceEHRID, movedContributionUIDs)
+	batch.Queue(`UPDATE openehr.tbl_ehr SET merged_into_ehr_id = $1 WHERE id = $2`, targetEHRID, sourceEHRID)
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return EHRMergeResult{}, fmt.Errorf("failed to execute batch for EHR merge: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return EHRMergeResult{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	result.ContributionUID = utils.Some(contribution.UID.Value)
+	result.SourceEHRStatusUID = utils.Some(nextEHRStatusID.Value)
+	return result, nil
+}
+
+// GetEHRMergedInto returns the EHR the given EHR was merged into, if any
+func (s *Service) GetEHRMergedInto(ctx context.Context, ehrID uuid.UUID) (utils.Optional[uuid.UUID], error) {
+	var mergedInto utils.Optional[uuid.UUID]
+	if err := s.DB.QueryRow(ctx, `SELECT merged_into_ehr_id FROM openehr.tbl_ehr WHERE id = $1`, ehrID).Scan(&mergedInto); err != nil {
+		if errors.Is(err, database.ErrNoRows) {
+			return utils.None[uuid.UUID](), ErrEHRNotFound
+		}
+		return utils.None[uuid.UUID](), fmt.Errorf("failed to fetch merged EHR of EHR: %w", err)
+	}
+	return mergedInto, nil
+}
@@ -0,0 +1,208 @@
+package openehr
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/freekieb7/gopenehr/internal/openehr/aql"
+	"github.com/freekieb7/gopenehr/pkg/utils"
+	"github.com/google/uuid"
+)
+
+// EHRSearch selects EHRs on their creation time, the subject and flags of their latest EHR_STATUS and the tags they carry
+type EHRSearch struct {
+	CreatedAfter     utils.Optional[time.Time]
+	CreatedBefore    utils.Optional[time.Time]
+	SubjectNamespace utils.Optional[string]
+	IsQueryable      utils.Optional[bool]
+	IsModifiable     utils.Optional[bool]
+	// TagKey only selects EHRs with a tag of this key on their EHR_STATUS or compositions
+	TagKey   utils.Optional[string]
+	PageSize int
+	Token    string
+}
+
+type EHRSearchResult struct {
+	EHRs      []json.RawMessage
+	NextToken utils.Optional[string]
+	PrevToken utils.Optional[string]
+}
+
+type EHRSearchCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        uuid.UUID `json:"id"`
+	Direction string    `json:"direction"`
+}
+
+// SearchEHRsPaginated lists the matching EHRs newest first, paginated on (created_at, id).
+// EHRs hidden from queries or refused by their EHR_ACCESS settings are left out, following the AQL options.
+func (s *Service) SearchEHRsPaginated(ctx context.Context, search EHRSearch, options aql.Options) (EHRSearchResult, error) {
+	// Set default page size if invalid
+	pageSize := search.PageSize
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 25
+	}
+
+	cursor, err := decodeEHRSearchCursor(search.Token)
+	if err != nil {
+		return EHRSearchResult{}, fmt.Errorf("%w: %w", ErrInvalidPageToken, err)
+	}
+
+	// Extract direction from cursor, default to "next" for first page
+	direction := "next"
+	if cursor.E {
+		direction = cursor.V.Direction
+	}
+
+	order := "DESC"
+	cmp := "<"
+	if direction == "prev" {
+		order = "ASC"
+		cmp = ">"
+	}
+
+	var subject utils.Optional[string]
+	roles := []string{}
+	if options.EHRAccess.E {
+		subject = utils.Some(options.EHRAccess.V.Subject)
+		roles = options.EHRAccess.V.Roles
+	}
+
+	query := `
+		SELECT e.id, e.created_at, ed.data
+		FROM openehr.tbl_ehr e
+		JOIN openehr.tbl_ehr_data ed ON ed.id = e.id
+		LEFT JOIN LATERAL (
+			SELECT esd.data
+			FROM openehr.tbl_ehr_status es
+			JOIN openehr.tbl_ehr_status_data esd ON esd.id = es.id
+			WHERE es.ehr_id = e.id
+			ORDER BY es.version_int DESC
+			LIMIT 1
+		) status ON TRUE
+		WHERE e.deleted_at IS NULL
+		  AND ($1::TIMESTAMPTZ IS NULL OR e.created_at >= $1)
+		  AND ($2::TIMESTAMPTZ IS NULL OR e.created_at < $2)
+		  AND ($3::TEXT IS NULL OR status.data->'subject'->'external_ref'->>'namespace' = $3)
+		  AND ($4::BOOLEAN IS NULL OR COALESCE((status.data->>'is_queryable')::BOOLEAN, TRUE) = $4)
+		  AND ($5::BOOLEAN IS NULL OR COALESCE((status.data->>'is_modifiable')::BOOLEAN, TRUE) = $5)
+		  AND ($6::TEXT IS NULL OR EXISTS (
+			SELECT 1 FROM openehr.tbl_ehr_status_tag WHERE key = $6 AND ehr_id = e.id
+			UNION ALL
+			SELECT 1 FROM openehr.tbl_composition_tag WHERE key = $6 AND ehr_id = e.id
+			UNION ALL
+			SELECT 1 FROM openehr.tbl_versioned_composition_tag WHERE key = $6 AND ehr_id = e.id
+		  ))
+		  AND ($7 OR COALESCE((status.data->>'is_queryable')::BOOLEAN, TRUE))
+		  AND ($8::TEXT IS NULL OR openehr.ehr_access_allowed(e.id, $8, $9::TEXT[]))
+	`
+	args := []any{search.CreatedAfter, search.CreatedBefore, search.SubjectNamespace, search.IsQueryable, search.IsModifiable, search.TagKey, options.IncludeNonQueryable, subject, roles}
+	argIdx := len(args) + 1
+
+	if cursor.E {
+		query += fmt.Sprintf("AND (e.created_at, e.id) %s ($%d, $%d) ", cmp, argIdx, argIdx+1)
+		args = append(args, cursor.V.CreatedAt, cursor.V.ID)
+		argIdx += 2
+	}
+
+	query += fmt.Sprintf("ORDER BY e.created_at %s, e.id %s ", order, order)
+	query += fmt.Sprintf("LIMIT $%d", argIdx)
+	args = append(args, pageSize+1) // Fetch one extra to detect more pages
+
+	rows, err := s.DB.Query(ctx, query, args...)
+	if err != nil {
+		return EHRSearchResult{}, fmt.Errorf("failed to search EHRs in database: %w", err)
+	}
+	defer rows.Close()
+
+	ehrs := []json.RawMessage{}
+	cursors := []EHRSearchCursor{}
+	for rows.Next() {
+		var row EHRSearchCursor
+		var data []byte
+		if err := rows.Scan(&row.ID, &row.CreatedAt, &data); err != nil {
+			return EHRSearchResult{}, fmt.Errorf("failed to scan EHR: %w", err)
+		}
+		ehrs = append(ehrs, data)
+		cursors = append(cursors, row)
+	}
+	if err := rows.Err(); err != nil {
+		return EHRSearchResult{}, fmt.Errorf("failed to iterate EHRs: %w", err)
+	}
+
+	if len(ehrs) == 0 {
+		return EHRSearchResult{
+			EHRs: ehrs,
+		}, nil
+	}
+
+	// Check if we have more results than requested (indicates more pages)
+	hasMore := len(ehrs) > pageSize
+	if hasMore {
+		ehrs = ehrs[:pageSize]
+		cursors = cursors[:pageSize]
+	}
+
+	// If fetching previous page, reverse results to maintain order
+	if direction == "prev" {
+		for i, j := 0, len(ehrs)-1; i < j; i, j = i+1, j-1 {
+			ehrs[i], ehrs[j] = ehrs[j], ehrs[i]
+			cursors[i], cursors[j] = cursors[j], cursors[i]
+		}
+	}
+
+	var nextToken, prevToken utils.Optional[string]
+
+	// Generate next token (for older EHRs)
+	if (direction == "next" && hasMore) || direction == "prev" {
+		next := cursors[len(cursors)-1]
+		next.Direction = "next"
+		if token, err := encodeEHRSearchCursor(next); err == nil {
+			nextToken = utils.Some(token)
+		}
+	}
+
+	// Generate prev token (for newer EHRs)
+	if (direction == "prev" && hasMore) || (direction == "next" && search.Token != "") {
+		prev := cursors[0]
+		prev.Direction = "prev"
+		if token, err := encodeEHRSearchCursor(prev); err == nil {
+			prevToken = utils.Some(token)
+		}
+	}
+
+	return EHRSearchResult{
+		EHRs:      ehrs,
+		NextToken: nextToken,
+		PrevToken: prevToken,
+	}, nil
+}
+
+func encodeEHRSearchCursor(cursor EHRSearchCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(data), nil
+}
+
+func decodeEHRSearchCursor(token string) (utils.Optional[EHRSearchCursor], error) {
+	if token == "" {
+		return utils.None[EHRSearchCursor](), nil
+	}
+	data, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return utils.None[EHRSearchCursor](), err
+	}
+	var cursor EHRSearchCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return utils.None[EHRSearchCursor](), err
+	}
+	if cursor.Direction != "next" && cursor.Direction != "prev" {
+		return utils.None[EHRSearchCursor](), fmt.Errorf("unknown direction %q", cursor.Direction)
+	}
+	return utils.Some(cursor), nil
+}
@@ -1,18 +1,35 @@
 package openehr
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/bytedance/sonic"
+	"github.com/freekieb7/gopenehr/internal/attachment"
 	intAudit "github.com/freekieb7/gopenehr/internal/audit"
 	"github.com/freekieb7/gopenehr/internal/config"
 	"github.com/freekieb7/gopenehr/internal/oauth"
+	"github.com/freekieb7/gopenehr/internal/openehr/aql"
 	"github.com/freekieb7/gopenehr/internal/openehr/rm"
+	"github.com/freekieb7/gopenehr/internal/openehr/terminology"
 	"github.com/freekieb7/gopenehr/internal/openehr/util"
 	"github.com/freekieb7/gopenehr/internal/telemetry"
 	"github.com/freekieb7/gopenehr/internal/webhook"
 	"github.com/freekieb7/gopenehr/pkg/audit"
+	"github.com/freekieb7/gopenehr/pkg/jsonpatch"
 	"github.com/freekieb7/gopenehr/pkg/utils"
 	"github.com/freekieb7/gopenehr/pkg/web/middleware"
 	"github.com/gofiber/fiber/v2"
@@ -28,18 +45,23 @@ type Handler struct {
 	AuditSink      *intAudit.Sink
 	WebhookSink    webhook.Sink
 	OAuthService   *oauth.Service
+
+	StandingQueryEvaluator *StandingQueryEvaluator
+	AttachmentStore        attachment.Store
 }
 
-func NewHandler(settings *config.Settings, telemetry *telemetry.Telemetry, openEHRService *Service, auditService *intAudit.Service, webhookService *webhook.Service, auditSink *intAudit.Sink, webhookSink webhook.Sink, oauthService *oauth.Service) Handler {
+func NewHandler(settings *config.Settings, telemetry *telemetry.Telemetry, openEHRService *Service, auditService *intAudit.Service, webhookService *webhook.Service, auditSink *intAudit.Sink, webhookSink webhook.Sink, oauthService *oauth.Service, standingQueryEvaluator *StandingQueryEvaluator, attachmentStore attachment.Store) Handler {
 	return Handler{
-		Settings:       settings,
-		Telemetry:      telemetry,
-		OpenEHRService: openEHRService,
-		AuditService:   auditService,
-		WebhookService: webhookService,
-		AuditSink:      auditSink,
-		WebhookSink:    webhookSink,
-		OAuthService:   oauthService,
+		Settings:               settings,
+		Telemetry:              telemetry,
+		OpenEHRService:         openEHRService,
+		AuditService:           auditService,
+		WebhookService:         webhookService,
+		AuditSink:              auditSink,
+		WebhookSink:            webhookSink,
+		OAuthService:           oauthService,
+		StandingQueryEvaluator: standingQueryEvaluator,
+		AttachmentStore:        attachmentStore,
 	}
 }
 
@@ -61,96 +83,120 @@ func (h *Handler) RegisterRoutes(app *fiber.App) {
 
 	v1.Options("", h.SystemInfo)
 
-	v1.Get("/ehr", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHR, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.GetEHRBySubject)
+	v1.Get("/ehr", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHR, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.ConditionalGET, h.GetEHRBySubject)
 	v1.Post("/ehr", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHR, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.CreateEHR)
-	v1.Get("/ehr/:ehr_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHR, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.GetEHR)
+	v1.Get("/ehr/:ehr_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHR, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.ConditionalGET, h.GetEHR)
 	v1.Put("/ehr/:ehr_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHR, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.CreateEHRWithID)
-
-	v1.Get("/ehr/:ehr_id/ehr_status", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHRStatus, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.GetEHRStatus)
-	v1.Put("/ehr/:ehr_id/ehr_status", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHRStatus, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.UpdateEhrStatus)
-	v1.Get("/ehr/:ehr_id/ehr_status/:version_uid", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHRStatus, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.GetEHRStatusByVersionID)
-
-	v1.Get("/ehr/:ehr_id/versioned_ehr_status", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedEHRStatus, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.GetVersionedEHRStatus)
-	v1.Get("/ehr/:ehr_id/versioned_ehr_status/revision_history", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedEHRStatus, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.GetVersionedEHRStatusRevisionHistory)
-	v1.Get("/ehr/:ehr_id/versioned_ehr_status/version", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedEHRStatusVersion, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.GetVersionedEHRStatusVersion)
-	v1.Get("/ehr/:ehr_id/versioned_ehr_status/version/:version_uid", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedEHRStatusVersion, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.GetVersionedEHRStatusVersionByID)
-
-	v1.Post("/ehr/:ehr_id/composition", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceComposition, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.CreateComposition)
-	v1.Get("/ehr/:ehr_id/composition/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceComposition, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.GetComposition)
-	v1.Put("/ehr/:ehr_id/composition/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceComposition, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.UpdateComposition)
-	v1.Delete("/ehr/:ehr_id/composition/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceComposition, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.DeleteComposition)
-
-	v1.Get("/ehr/:ehr_id/versioned_composition/:versioned_object_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedComposition, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.GetVersionedCompositionByID)
-	v1.Get("/ehr/:ehr_id/versioned_composition/:versioned_object_id/revision_history", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedComposition, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.GetVersionedCompositionRevisionHistory)
-	v1.Get("/ehr/:ehr_id/versioned_composition/:versioned_object_id/version", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedCompositionVersion, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.GetVersionedCompositionVersionAtTime)
-	v1.Get("/ehr/:ehr_id/versioned_composition/:versioned_object_id/version/:version_uid", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedCompositionVersion, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.GetVersionedCompositionVersionByID)
-
-	v1.Post("/ehr/:ehr_id/directory", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceDirectory, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.CreateDirectory)
-	v1.Put("/ehr/:ehr_id/directory", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceDirectory, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.UpdateDirectory)
-	v1.Delete("/ehr/:ehr_id/directory", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceDirectory, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.DeleteDirectory)
-	v1.Get("/ehr/:ehr_id/directory", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceDirectory, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.GetFolderInDirectoryVersionAtTime)
-	v1.Get("/ehr/:ehr_id/directory/:version_uid", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceDirectory, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.GetFolderInDirectoryVersion)
-	v1.Post("/ehr/:ehr_id/contribution", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceContribution, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.CreateContribution)
-	v1.Get("/ehr/:ehr_id/contribution/:contribution_uid", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceContribution, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.GetContribution)
-
-	v1.Get("/ehr/:ehr_id/tags", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.GetEHRTags)
-	v1.Get("/ehr/:ehr_id/composition/:uid_based_id/tags", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.GetCompositionTags)
-	v1.Put("/ehr/:ehr_id/composition/:uid_based_id/tags", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.UpdateCompositionTags)
-	// v1.Delete("/ehr/:ehr_id/composition/:uid_based_id/tags", h.DeleteCompositionTagByKey)
-	// v1.Get("/ehr/:ehr_id/ehr_status/tags", h.GetEHRStatusTags)
-	// v1.Get("/ehr/:ehr_id/ehr_status/:version_uid/tags", h.GetEHRStatusVersionTags)
-	// v1.Put("/ehr/:ehr_id/ehr_status/:version_uid/tags", h.UpdateEHRStatusVersionTags)
-	// v1.Delete("/ehr/:ehr_id/ehr_status/:version_uid/tags/:key", h.DeleteEHRStatusVersionTagByKey)
+	v1.Post("/ehr/:ehr_id/merge", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHR, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeEHRAdmin.String()}, validateToken), h.EHRAccessProtected, h.MergeEHR)
+
+	v1.Get("/ehr/:ehr_id/ehr_status", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHRStatus, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.ConditionalGET, h.GetEHRStatus)
+	v1.Put("/ehr/:ehr_id/ehr_status", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHRStatus, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.EHRAccessProtected, h.UpdateEhrStatus)
+	v1.Get("/ehr/:ehr_id/ehr_status/tags", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.EHRQueryableProtected, h.GetEHRStatusTags)
+	v1.Get("/ehr/:ehr_id/ehr_status/:version_uid", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHRStatus, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.ConditionalGET, h.GetEHRStatusByVersionID)
+
+	v1.Get("/ehr/:ehr_id/ehr_access", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHRAccess, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.ConditionalGET, h.GetEHRAccess)
+	v1.Put("/ehr/:ehr_id/ehr_access", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHRAccess, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.EHRAccessProtected, h.UpdateEHRAccess)
+	v1.Get("/ehr/:ehr_id/ehr_access/:version_uid", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHRAccess, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.ConditionalGET, h.GetEHRAccessByVersionID)
+
+	v1.Get("/ehr/:ehr_id/versioned_ehr_status", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedEHRStatus, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.ConditionalGET, h.GetVersionedEHRStatus)
+	v1.Get("/ehr/:ehr_id/versioned_ehr_status/revision_history", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedEHRStatus, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.ConditionalGET, h.GetVersionedEHRStatusRevisionHistory)
+	v1.Get("/ehr/:ehr_id/versioned_ehr_status/version", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedEHRStatusVersion, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.ConditionalGET, h.GetVersionedEHRStatusVersion)
+	v1.Get("/ehr/:ehr_id/versioned_ehr_status/version/:version_uid", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedEHRStatusVersion, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.ConditionalGET, h.GetVersionedEHRStatusVersionByID)
+
+	v1.Post("/ehr/:ehr_id/composition", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceComposition, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.EHRAccessProtected, h.CreateComposition)
+	v1.Get("/ehr/:ehr_id/composition/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceComposition, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.ConditionalGET, h.GetComposition)
+	v1.Put("/ehr/:ehr_id/composition/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceComposition, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.EHRAccessProtected, h.UpdateComposition)
+	v1.Patch("/ehr/:ehr_id/composition/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceComposition, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.EHRAccessProtected, h.PatchComposition)
+	v1.Delete("/ehr/:ehr_id/composition/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceComposition, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.EHRAccessProtected, h.DeleteComposition)
+
+	v1.Get("/ehr/:ehr_id/versioned_composition/:versioned_object_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedComposition, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.ConditionalGET, h.GetVersionedCompositionByID)
+	v1.Get("/ehr/:ehr_id/versioned_composition/:versioned_object_id/revision_history", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedComposition, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.ConditionalGET, h.GetVersionedCompositionRevisionHistory)
+	v1.Get("/ehr/:ehr_id/versioned_composition/:versioned_object_id/version", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedCompositionVersion, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.ConditionalGET, h.GetVersionedCompositionVersionAtTime)
+	v1.Get("/ehr/:ehr_id/versioned_composition/:versioned_object_id/version/:version_uid", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedCompositionVersion, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.ConditionalGET, h.GetVersionedCompositionVersionByID)
+	v1.Get("/ehr/:ehr_id/versioned_composition/:versioned_object_id/version/:version_uid/attestation", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceAttestation, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.ConditionalGET, h.GetCompositionVersionAttestations)
+	v1.Post("/ehr/:ehr_id/versioned_composition/:versioned_object_id/version/:version_uid/attestation", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceAttestation, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.EHRAccessProtected, h.AttestCompositionVersion)
+
+	v1.Post("/ehr/:ehr_id/directory", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceDirectory, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.EHRAccessProtected, h.CreateDirectory)
+	v1.Put("/ehr/:ehr_id/directory", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceDirectory, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.EHRAccessProtected, h.UpdateDirectory)
+	v1.Delete("/ehr/:ehr_id/directory", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceDirectory, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.EHRAccessProtected, h.DeleteDirectory)
+	v1.Get("/ehr/:ehr_id/directory", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceDirectory, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.ConditionalGET, h.GetFolderInDirectoryVersionAtTime)
+	v1.Post("/ehr/:ehr_id/directory/folder", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceDirectory, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.EHRAccessProtected, h.CreateDirectoryFolder)
+	v1.Patch("/ehr/:ehr_id/directory/folder", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceDirectory, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.EHRAccessProtected, h.MoveDirectoryFolder)
+	v1.Post("/ehr/:ehr_id/directory/item", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceDirectory, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.EHRAccessProtected, h.AddDirectoryItem)
+	v1.Delete("/ehr/:ehr_id/directory/item", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceDirectory, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.EHRAccessProtected, h.RemoveDirectoryItem)
+	v1.Get("/ehr/:ehr_id/directory/compositions", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceDirectory, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.ListDirectoryCompositions)
+	v1.Get("/ehr/:ehr_id/directory/:version_uid", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceDirectory, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.ConditionalGET, h.GetFolderInDirectoryVersion)
+
+	v1.Post("/ehr/:ehr_id/attachment", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceAttachment, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.EHRAccessProtected, h.CreateAttachment)
+	v1.Get("/ehr/:ehr_id/attachment/:attachment_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceAttachment, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.GetAttachment)
+
+	v1.Post("/ehr/:ehr_id/contribution", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceContribution, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.EHRAccessProtected, h.CreateContribution)
+	v1.Post("/ehr/:ehr_id/contribution/import", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceContribution, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeEHRAdmin.String()}, validateToken), h.EHRAccessProtected, h.ImportContribution)
+	v1.Get("/ehr/:ehr_id/contribution/:contribution_uid", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceContribution, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.ConditionalGET, h.GetContribution)
+
+	v1.Get("/ehr/:ehr_id/tags", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.EHRQueryableProtected, h.GetEHRTags)
+	v1.Get("/ehr/:ehr_id/tags/search", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.EHRQueryableProtected, h.SearchEHRTags)
+	v1.Get("/tags/search", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.SearchTags)
+	v1.Get("/ehr/:ehr_id/composition/:uid_based_id/tags", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.EHRQueryableProtected, h.GetCompositionTags)
+	v1.Put("/ehr/:ehr_id/composition/:uid_based_id/tags", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.EHRAccessProtected, h.UpdateCompositionTags)
+	// v1.Delete("/ehr/:ehr_id/composition/:uid_based_id/tags/:key", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.EHRAccessProtected, h.DeleteCompositionTagByKey)
+	v1.Get("/ehr/:ehr_id/ehr_status/:version_uid/tags", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRRead.String()}, validateToken), h.EHRAccessProtected, h.EHRQueryableProtected, h.GetEHRStatusVersionTags)
+	v1.Put("/ehr/:ehr_id/ehr_status/:version_uid/tags", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.EHRAccessProtected, h.UpdateEHRStatusVersionTags)
+	v1.Delete("/ehr/:ehr_id/ehr_status/:version_uid/tags/:key", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeEHRWrite.String()}, validateToken), h.EHRAccessProtected, h.DeleteEHRStatusVersionTagByKey)
 
 	v1.Post("/demographic/agent", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceAgent, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.CreateAgent)
-	v1.Get("/demographic/agent/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceAgent, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.GetAgent)
+	v1.Get("/demographic/agent/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceAgent, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.ConditionalGET, h.GetAgent)
 	v1.Put("/demographic/agent/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceAgent, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.UpdateAgent)
+	v1.Patch("/demographic/agent/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceAgent, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.PatchAgent)
 	v1.Delete("/demographic/agent/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceAgent, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.DeleteAgent)
 
 	v1.Post("/demographic/group", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceGroup, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.CreateGroup)
-	v1.Get("/demographic/group/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceGroup, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.GetGroup)
+	v1.Get("/demographic/group/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceGroup, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.ConditionalGET, h.GetGroup)
 	v1.Put("/demographic/group/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceGroup, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.UpdateGroup)
+	v1.Patch("/demographic/group/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceGroup, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.PatchGroup)
 	v1.Delete("/demographic/group/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceGroup, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.DeleteGroup)
 
 	v1.Post("/demographic/person", middleware.Audit(h.AuditSink.Enqueue, audit.ResourcePerson, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.CreatePerson)
-	v1.Get("/demographic/person/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourcePerson, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.GetPerson)
+	v1.Get("/demographic/person/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourcePerson, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.ConditionalGET, h.GetPerson)
 	v1.Put("/demographic/person/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourcePerson, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.UpdatePerson)
+	v1.Patch("/demographic/person/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourcePerson, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.PatchPerson)
 	v1.Delete("/demographic/person/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourcePerson, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.DeletePerson)
 
 	v1.Post("/demographic/organisation", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceOrganisation, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.CreateOrganisation)
-	v1.Get("/demographic/organisation/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceOrganisation, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.GetOrganisation)
+	v1.Get("/demographic/organisation/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceOrganisation, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.ConditionalGET, h.GetOrganisation)
 	v1.Put("/demographic/organisation/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceOrganisation, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.UpdateOrganisation)
+	v1.Patch("/demographic/organisation/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceOrganisation, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.PatchOrganisation)
 	v1.Delete("/demographic/organisation/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceOrganisation, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.DeleteOrganisation)
 
-	// v1.Post("/demographic/role", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceRole, audit.ActionCreate), h.CreateRole)
-	// v1.Get("/demographic/role/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceRole, audit.ActionRead), h.GetRole)
-	// v1.Put("/demographic/role/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceRole, audit.ActionUpdate), h.UpdateRole)
-	// v1.Delete("/demographic/role/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceRole, audit.ActionDelete), h.DeleteRole)
+	v1.Post("/demographic/role", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceRole, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.CreateRole)
+	v1.Get("/demographic/role/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceRole, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.ConditionalGET, h.GetRole)
+	v1.Put("/demographic/role/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceRole, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.UpdateRole)
+	v1.Delete("/demographic/role/:uid_based_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceRole, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.DeleteRole)
 
-	// v1.Get("/demographic/versioned_party/:versioned_object_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedParty, audit.ActionRead), h.GetVersionedParty)
-	// v1.Get("/demographic/versioned_party/:versioned_object_id/revision_history", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedParty, audit.ActionRead), h.GetVersionedPartyRevisionHistory)
-	// v1.Get("/demographic/versioned_party/:versioned_object_id/version", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedPartyVersion, audit.ActionRead), h.GetVersionedPartyVersionAtTime)
-	// v1.Get("/demographic/versioned_party/:versioned_object_id/version/:version_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedPartyVersion, audit.ActionRead), h.GetVersionedPartyVersion)
+	v1.Get("/demographic/versioned_party/:versioned_object_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedParty, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.ConditionalGET, h.GetVersionedParty)
+	v1.Get("/demographic/versioned_party/:versioned_object_id/revision_history", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedParty, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.ConditionalGET, h.GetVersionedPartyRevisionHistory)
+	v1.Get("/demographic/versioned_party/:versioned_object_id/version", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedPartyVersion, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.ConditionalGET, h.GetVersionedPartyVersionAtTime)
+	v1.Get("/demographic/versioned_party/:versioned_object_id/version/:version_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceVersionedPartyVersion, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.ConditionalGET, h.GetVersionedPartyVersion)
 
 	v1.Post("/demographic/contribution", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceContribution, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.CreateDemographicContribution)
-	v1.Get("/demographic/contribution/:contribution_uid", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceContribution, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.GetDemographicContribution)
-
-	// v1.Get("/demographic/tags", h.GetDemographicTags)
-	// v1.Get("/demographic/agent/:uid_based_id/tags", h.GetAgentTags)
-	// v1.Put("/demographic/agent/:uid_based_id/tags", h.UpdateAgentTags)
-	// v1.Delete("/demographic/agent/:uid_based_id/tags/:key", h.DeleteAgentTagByKey)
-	// v1.Get("/demographic/group/:uid_based_id/tags", h.GetGroupTags)
-	// v1.Put("/demographic/group/:uid_based_id/tags", h.UpdateGroupTags)
-	// v1.Delete("/demographic/group/:uid_based_id/tags/:key", h.DeleteGroupTagByKey)
-	// v1.Get("/demographic/person/:uid_based_id/tags", h.GetPersonTags)
-	// v1.Put("/demographic/person/:uid_based_id/tags", h.UpdatePersonTags)
-	// v1.Delete("/demographic/person/:uid_based_id/tags/:key", h.DeletePersonTagByKey)
-	// v1.Get("/demographic/organisation/:uid_based_id/tags", h.GetOrganisationTags)
-	// v1.Put("/demographic/organisation/:uid_based_id/tags", h.UpdateOrganisationTags)
-	// v1.Delete("/demographic/organisation/:uid_based_id/tags/:key", h.DeleteOrganisationTagByKey)
-	// v1.Get("/demographic/role/:uid_based_id/tags", h.GetRoleTags)
-	// v1.Put("/demographic/role/:uid_based_id/tags", h.UpdateRoleTags)
-	// v1.Delete("/demographic/role/:uid_based_id/tags/:key", h.DeleteRoleTagByKey)
+	v1.Get("/demographic/contribution/:contribution_uid", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceContribution, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.ConditionalGET, h.GetDemographicContribution)
+
+	v1.Get("/demographic/tags", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.GetDemographicTags)
+	v1.Get("/demographic/agent/:uid_based_id/tags", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.GetAgentTags)
+	v1.Put("/demographic/agent/:uid_based_id/tags", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.UpdateAgentTags)
+	v1.Delete("/demographic/agent/:uid_based_id/tags/:key", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.DeleteAgentTagByKey)
+	v1.Get("/demographic/group/:uid_based_id/tags", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.GetGroupTags)
+	v1.Put("/demographic/group/:uid_based_id/tags", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.UpdateGroupTags)
+	v1.Delete("/demographic/group/:uid_based_id/tags/:key", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.DeleteGroupTagByKey)
+	v1.Get("/demographic/person/:uid_based_id/tags", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.GetPersonTags)
+	v1.Put("/demographic/person/:uid_based_id/tags", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.UpdatePersonTags)
+	v1.Delete("/demographic/person/:uid_based_id/tags/:key", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.DeletePersonTagByKey)
+	v1.Get("/demographic/organisation/:uid_based_id/tags", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.GetOrganisationTags)
+	v1.Put("/demographic/organisation/:uid_based_id/tags", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.UpdateOrganisationTags)
+	v1.Delete("/demographic/organisation/:uid_based_id/tags/:key", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.DeleteOrganisationTagByKey)
+	v1.Get("/demographic/role/:uid_based_id/tags", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeDemographicsRead.String()}, validateToken), h.GetRoleTags)
+	v1.Put("/demographic/role/:uid_based_id/tags", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.UpdateRoleTags)
+	v1.Delete("/demographic/role/:uid_based_id/tags/:key", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceItemTag, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeDemographicsWrite.String()}, validateToken), h.DeleteRoleTagByKey)
 
 	v1.Get("/query/aql", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceQuery, audit.ActionExecute), middleware.JWTProtected([]string{oauth.ScopeQueryExecute.String()}, validateToken), h.ExecuteAdHocAQL)
 	v1.Post("/query/aql", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceQuery, audit.ActionExecute), middleware.JWTProtected([]string{oauth.ScopeQueryExecute.String()}, validateToken), h.ExecuteAdHocAQLPost)
@@ -172,9 +218,34 @@ func (h *Handler) RegisterRoutes(app *fiber.App) {
 	v1.Put("/definition/query/:qualified_query_name", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceQuery, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeQueryWrite.String()}, validateToken), h.StoreQuery)
 	v1.Put("/definition/query/:qualified_query_name/:version", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceQuery, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeQueryWrite.String()}, validateToken), h.StoreQueryVersion)
 	v1.Get("/definition/query/:qualified_query_name/:version", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceQuery, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeQueryRead.String()}, validateToken), h.GetStoredQueryAtVersion)
+	v1.Delete("/definition/query/:qualified_query_name", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceQuery, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeQueryWrite.String()}, validateToken), h.DeleteStoredQuery)
+	v1.Delete("/definition/query/:qualified_query_name/:version", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceQuery, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeQueryWrite.String()}, validateToken), h.DeleteStoredQueryVersion)
+
+	v1.Get("/standing_query", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceStandingQuery, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeQueryRead.String()}, validateToken), h.ListStandingQueries)
+	v1.Post("/standing_query", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceStandingQuery, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeQueryWrite.String()}, validateToken), h.RegisterStandingQuery)
+	v1.Get("/standing_query/:standing_query_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceStandingQuery, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeQueryRead.String()}, validateToken), h.GetStandingQuery)
+	v1.Delete("/standing_query/:standing_query_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceStandingQuery, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeQueryWrite.String()}, validateToken), h.DeleteStandingQuery)
+
+	v1.Get("/cohort", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceCohort, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeQueryRead.String()}, validateToken), h.ListCohorts)
+	v1.Post("/cohort", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceCohort, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeQueryWrite.String()}, validateToken), h.CreateCohort)
+	v1.Get("/cohort/:cohort_name", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceCohort, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeQueryRead.String()}, validateToken), h.GetCohort)
+	v1.Delete("/cohort/:cohort_name", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceCohort, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeQueryWrite.String()}, validateToken), h.DeleteCohort)
+	v1.Post("/cohort/:cohort_name/refresh", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceCohort, audit.ActionExecute), middleware.JWTProtected([]string{oauth.ScopeQueryExecute.String()}, validateToken), h.RefreshCohort)
+	v1.Get("/cohort/:cohort_name/version", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceCohort, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeQueryRead.String()}, validateToken), h.ListCohortVersions)
+	v1.Get("/cohort/:cohort_name/member", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceCohort, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeQueryExecute.String()}, validateToken), h.GetCohortMembers)
+
+	v1.Get("/report", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceReport, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeQueryRead.String()}, validateToken), h.ListReports)
+	v1.Post("/report", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceReport, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeQueryWrite.String()}, validateToken), h.CreateReport)
+	v1.Get("/report/:report_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceReport, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeQueryRead.String()}, validateToken), h.GetReport)
+	v1.Delete("/report/:report_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceReport, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeQueryWrite.String()}, validateToken), h.DeleteReport)
+	v1.Get("/report/:report_id/run", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceReport, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeQueryRead.String()}, validateToken), h.ListReportRuns)
+	v1.Get("/report/:report_id/run/:run_id/output", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceReport, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeQueryExecute.String()}, validateToken), h.GetReportRunOutput)
 
 	v1.Delete("/admin/ehr/all", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHR, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeEHRDelete.String()}, validateToken), h.DeleteMultipleEHRs)
 	v1.Delete("/admin/ehr/:ehr_id", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHR, audit.ActionDelete), middleware.JWTProtected([]string{oauth.ScopeEHRDelete.String()}, validateToken), h.DeleteEHRByID)
+	v1.Get("/admin/ehr/:ehr_id/extract", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHR, audit.ActionRead), middleware.JWTProtected([]string{oauth.ScopeEHRAdmin.String()}, validateToken), h.ExportEHR)
+	v1.Post("/admin/ehr/import", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHR, audit.ActionCreate), middleware.JWTProtected([]string{oauth.ScopeEHRAdmin.String()}, validateToken), h.ImportEHR)
+	v1.Post("/admin/ehr/:ehr_id/restore", middleware.Audit(h.AuditSink.Enqueue, audit.ResourceEHR, audit.ActionUpdate), middleware.JWTProtected([]string{oauth.ScopeEHRDelete.String()}, validateToken), h.RestoreEHR)
 }
 
 func (h *Handler) SystemInfo(c *fiber.Ctx) error {
@@ -200,13 +271,10 @@ func (h *Handler) GetEHRBySubject(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
+	// Without a subject the EHRs are listed instead
 	subjectID := c.Query("subject_id")
 	if subjectID == "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "subject_id query parameters are required",
-			Status:  "bad_request",
-		})
+		return h.ListEHRs(c)
 	}
 
 	subjectNamespace := c.Query("subject_namespace")
@@ -237,10 +305,127 @@ func (h *Handler) GetEHRBySubject(c *fiber.Ctx) error {
 		})
 	}
 
+	// The EHR id is only known once found, so the access settings are checked here instead of by route
+	var ehr struct {
+		EHRID rm.HIER_OBJECT_ID `json:"ehr_id"`
+	}
+	if err := sonic.Unmarshal(ehrJSON, &ehr); err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to read EHR id of EHR found by subject", "error", err)
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get EHR by subject",
+			Status:  "error",
+		})
+	}
+	ehrID, err := uuid.Parse(ehr.EHRID.Value)
+	if err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Invalid EHR id of EHR found by subject", "error", err)
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get EHR by subject",
+			Status:  "error",
+		})
+	}
+	if allowed, err := h.EHRAccessAllowed(c, auditCtx, ehrID); !allowed {
+		return err
+	}
+
 	auditCtx.Success()
 
-	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(ehrJSON)
+	return SendRawRepresentation[rm.EHR](c, fiber.StatusOK, ehrJSON)
+}
+
+// ListEHRs lists the EHRs the caller may read, newest first and paginated with the token of the previous page
+func (h *Handler) ListEHRs(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json")
+	if err != nil {
+		return err
+	}
+
+	search := EHRSearch{
+		PageSize: 25,
+		Token:    c.Query("token"),
+	}
+	if pageSizeParam := c.Query("page_size"); pageSizeParam != "" {
+		if ps, err := strconv.Atoi(pageSizeParam); err == nil && ps > 0 && ps <= 100 {
+			search.PageSize = ps
+		}
+	}
+
+	if c.Query("created_after") != "" {
+		createdAfter, err := TimeFromQuery(c, auditCtx, "created_after")
+		if err != nil {
+			return err
+		}
+		search.CreatedAfter = utils.Some(createdAfter)
+		auditCtx.Event.Details["created_after"] = createdAfter
+	}
+	if c.Query("created_before") != "" {
+		createdBefore, err := TimeFromQuery(c, auditCtx, "created_before")
+		if err != nil {
+			return err
+		}
+		search.CreatedBefore = utils.Some(createdBefore)
+		auditCtx.Event.Details["created_before"] = createdBefore
+	}
+	if subjectNamespace := c.Query("subject_namespace"); subjectNamespace != "" {
+		search.SubjectNamespace = utils.Some(subjectNamespace)
+		auditCtx.Event.Details["subject_namespace"] = subjectNamespace
+	}
+	if tagKey := c.Query("tag_key"); tagKey != "" {
+		search.TagKey = utils.Some(tagKey)
+		auditCtx.Event.Details["tag_key"] = tagKey
+	}
+
+	for name, flag := range map[string]*utils.Optional[bool]{
+		"is_queryable":  &search.IsQueryable,
+		"is_modifiable": &search.IsModifiable,
+	} {
+		valueStr := c.Query(name)
+		if valueStr == "" {
+			continue
+		}
+		value, err := strconv.ParseBool(valueStr)
+		if err != nil {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Invalid " + name + " format, must be true or false",
+				Status:  "bad_request",
+			})
+		}
+		*flag = utils.Some(value)
+		auditCtx.Event.Details[name] = value
+	}
+
+	result, err := h.OpenEHRService.SearchEHRsPaginated(ctx, search, AQLOptionsFrom(c))
+	if err != nil {
+		if errors.Is(err, ErrInvalidPageToken) {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Invalid page token",
+				Status:  "bad_request",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to list EHRs", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to list EHRs",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	return c.JSON(fiber.Map{
+		"ehrs":       result.EHRs,
+		"token":      result.NextToken,
+		"prev_token": result.PrevToken,
+	})
 }
 
 func (h *Handler) CreateEHR(c *fiber.Ctx) error {
@@ -248,7 +433,7 @@ func (h *Handler) CreateEHR(c *fiber.Ctx) error {
 	auditCtx := middleware.AuditFrom(c)
 	tenantID := middleware.TenantFrom(c)
 
-	err := Accepts(c, auditCtx, "application/json")
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
 	if err != nil {
 		return err
 	}
@@ -313,9 +498,9 @@ func (h *Handler) CreateEHR(c *fiber.Ctx) error {
 		c.Status(fiber.StatusCreated)
 		return nil
 	case ReturnTypeRepresentation:
-		return c.JSON(ehr)
+		return SendRepresentation(c, fiber.StatusOK, ehr)
 	default:
-		return c.JSON(ehr)
+		return SendRepresentation(c, fiber.StatusOK, ehr)
 	}
 }
 
@@ -347,10 +532,23 @@ func (h *Handler) GetEHR(c *fiber.Ctx) error {
 		})
 	}
 
+	// A merged EHR points at the EHR its data was moved into
+	mergedInto, err := h.OpenEHRService.GetEHRMergedInto(ctx, ehrID)
+	if err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get merged EHR of EHR", "error", err)
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Status:  "error",
+		})
+	}
+	if mergedInto.E {
+		c.Set(fiber.HeaderLink, "<"+c.Protocol()+"://"+c.Hostname()+"/openehr/v1/ehr/"+mergedInto.V.String()+">; rel=\"successor-version\"")
+	}
+
 	auditCtx.Success()
 
-	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(ehrJSON)
+	return SendRawRepresentation[rm.EHR](c, fiber.StatusOK, ehrJSON)
 }
 
 func (h *Handler) CreateEHRWithID(c *fiber.Ctx) error {
@@ -358,7 +556,7 @@ func (h *Handler) CreateEHRWithID(c *fiber.Ctx) error {
 	auditCtx := middleware.AuditFrom(c)
 	tenantID := middleware.TenantFrom(c)
 
-	err := Accepts(c, auditCtx, "application/json")
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
 	if err != nil {
 		return err
 	}
@@ -434,12 +632,12 @@ func (h *Handler) CreateEHRWithID(c *fiber.Ctx) error {
 		c.Status(fiber.StatusCreated)
 		return nil
 	case ReturnTypeRepresentation:
-		return c.Status(fiber.StatusCreated).JSON(ehr)
+		return SendRepresentation(c, fiber.StatusCreated, ehr)
 	case ReturnTypeIdentifier:
 		return c.Status(fiber.StatusCreated).JSON(`{"uid":"` + ehrID.String() + `"}`)
 	default:
 		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
-		return c.Status(fiber.StatusCreated).JSON(ehr)
+		return SendRepresentation(c, fiber.StatusCreated, ehr)
 	}
 }
 
@@ -486,15 +684,14 @@ func (h *Handler) GetEHRStatus(c *fiber.Ctx) error {
 
 	auditCtx.Success()
 
-	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(ehrStatusJSON)
+	return SendRawRepresentation[rm.EHR_STATUS](c, fiber.StatusOK, ehrStatusJSON)
 }
 
 func (h *Handler) UpdateEhrStatus(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
-	err := Accepts(c, auditCtx, "application/json")
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
 	if err != nil {
 		return err
 	}
@@ -538,7 +735,7 @@ func (h *Handler) UpdateEhrStatus(c *fiber.Ctx) error {
 			Status:  "error",
 		})
 	}
-	if currentEHRStatusID.Value != ifMatch {
+	if !ETagMatches(ifMatch, currentEHRStatusID.Value) {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusPreconditionFailed,
 			Message: "EHR Status has been modified since the provided version",
@@ -610,12 +807,12 @@ func (h *Handler) UpdateEhrStatus(c *fiber.Ctx) error {
 		c.Status(fiber.StatusNoContent)
 		return nil
 	case ReturnTypeRepresentation:
-		return c.Status(fiber.StatusOK).JSON(updatedEHRStatus)
+		return SendRepresentation(c, fiber.StatusOK, updatedEHRStatus)
 	case ReturnTypeIdentifier:
 		return c.Status(fiber.StatusOK).JSON(`{"uid":"` + updatedEHRStatusID + `"}`)
 	default:
 		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
-		return c.Status(fiber.StatusOK).JSON(updatedEHRStatus)
+		return SendRepresentation(c, fiber.StatusOK, updatedEHRStatus)
 	}
 }
 
@@ -663,8 +860,7 @@ func (h *Handler) GetEHRStatusByVersionID(c *fiber.Ctx) error {
 
 	auditCtx.Success()
 
-	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(ehrStatusJSON)
+	return SendRawRepresentation[rm.EHR_STATUS](c, fiber.StatusOK, ehrStatusJSON)
 }
 
 func (h *Handler) GetVersionedEHRStatus(c *fiber.Ctx) error {
@@ -697,8 +893,7 @@ func (h *Handler) GetVersionedEHRStatus(c *fiber.Ctx) error {
 
 	auditCtx.Success()
 
-	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(versionedEHRStatusJSON)
+	return SendRawRepresentation[rm.VERSIONED_EHR_STATUS](c, fiber.StatusOK, versionedEHRStatusJSON)
 }
 
 func (h *Handler) GetVersionedEHRStatusRevisionHistory(c *fiber.Ctx) error {
@@ -731,8 +926,7 @@ func (h *Handler) GetVersionedEHRStatusRevisionHistory(c *fiber.Ctx) error {
 
 	auditCtx.Success()
 
-	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(revisionHistoryJSON)
+	return SendRawRepresentation[rm.REVISION_HISTORY](c, fiber.StatusOK, revisionHistoryJSON)
 }
 
 func (h *Handler) GetVersionedEHRStatusVersion(c *fiber.Ctx) error {
@@ -779,8 +973,7 @@ func (h *Handler) GetVersionedEHRStatusVersion(c *fiber.Ctx) error {
 
 	auditCtx.Success()
 
-	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(ehrStatusVersionJSON)
+	return SendRawRepresentation[rm.EHR_STATUS](c, fiber.StatusOK, ehrStatusVersionJSON)
 }
 
 func (h *Handler) GetVersionedEHRStatusVersionByID(c *fiber.Ctx) error {
@@ -823,78 +1016,43 @@ func (h *Handler) GetVersionedEHRStatusVersionByID(c *fiber.Ctx) error {
 
 	auditCtx.Success()
 
-	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(ehrStatusVersionJSON)
+	return SendRawRepresentation[rm.EHR_STATUS](c, fiber.StatusOK, ehrStatusVersionJSON)
 }
 
-func (h *Handler) CreateComposition(c *fiber.Ctx) error {
+func (h *Handler) GetEHRAccess(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
-	err := Accepts(c, auditCtx, "application/json")
-	if err != nil {
-		return err
-	}
-
 	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
 	if err != nil {
 		return err
 	}
 	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	returnType, err := ReturnTypeFromHeader(c, auditCtx)
-	if err != nil {
-		return err
-	}
-
-	var requestComposition rm.COMPOSITION
-	if err := ParseBody(c, auditCtx, &requestComposition); err != nil {
-		return err
-	}
-
-	exists, err := h.OpenEHRService.ExistsEHR(ctx, ehrID)
-	if err != nil {
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to check if EHR exists", "error", err)
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusInternalServerError,
-			Message: "Internal server error",
-			Status:  "error",
-		})
-	}
-	if !exists {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotFound,
-			Message: "EHR not found for the given EHR ID",
-			Status:  "not_found",
-		})
+	var filterAtTime time.Time
+	if atTimeStr := c.Query("version_at_time"); atTimeStr != "" {
+		parsedTime, err := time.Parse(time.RFC3339, atTimeStr)
+		if err != nil {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Invalid version_at_time format. Use RFC3339 format.",
+				Status:  "bad_request",
+			})
+		}
+		filterAtTime = parsedTime
 	}
 
-	composition, err := h.OpenEHRService.CreateComposition(ctx, ehrID, requestComposition)
+	ehrAccessJSON, err := h.OpenEHRService.GetEHRAccessAtTimeRawJSON(ctx, ehrID, filterAtTime)
 	if err != nil {
-		if err == ErrEHRNotFound {
+		if err == ErrEHRAccessNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "EHR not found for the given EHR ID",
+				Message: "EHR Access not found for the given EHR ID at the specified time",
 				Status:  "not_found",
 			})
 		}
-		if err == ErrCompositionAlreadyExists {
-			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusConflict,
-				Message: "Composition with the given UID already exists",
-				Status:  "conflict",
-			})
-		}
-		if validationErrs, ok := err.(util.ValidateError); ok {
-			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusBadRequest,
-				Message: "Validation error in request body",
-				Status:  "bad_request",
-				Details: validationErrs,
-			})
-		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to create Composition", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get EHR Access at time", "error", err)
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
 			Message: "Internal server error",
@@ -904,30 +1062,10 @@ func (h *Handler) CreateComposition(c *fiber.Ctx) error {
 
 	auditCtx.Success()
 
-	h.WebhookSink.Enqueue(webhook.EventTypeCompositionCreated, map[string]any{
-		"ehr_id":          ehrID,
-		"composition_uid": composition.UID.V.OBJECT_VERSION_ID().Value,
-	})
-
-	compositionID := composition.UID.V.OBJECT_VERSION_ID().Value
-	c.Set("ETag", "\""+compositionID+"\"")
-	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/ehr/"+ehrID.String()+"/composition/"+compositionID)
-
-	switch returnType {
-	case ReturnTypeMinimal:
-		c.Status(fiber.StatusCreated)
-		return nil
-	case ReturnTypeRepresentation:
-		return c.Status(fiber.StatusCreated).JSON(composition)
-	case ReturnTypeIdentifier:
-		return c.Status(fiber.StatusCreated).JSON(`{"uid":"` + compositionID + `"}`)
-	default:
-		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
-		return c.Status(fiber.StatusCreated).JSON(composition)
-	}
+	return SendRawRepresentation[rm.EHR_ACCESS](c, fiber.StatusOK, ehrAccessJSON)
 }
 
-func (h *Handler) GetComposition(c *fiber.Ctx) error {
+func (h *Handler) GetEHRAccessByVersionID(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
@@ -937,34 +1075,22 @@ func (h *Handler) GetComposition(c *fiber.Ctx) error {
 	}
 	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	versionUID, err := StringFromPath(c, auditCtx, "version_uid")
 	if err != nil {
 		return err
 	}
 
-	var compositionJSON []byte
-	if uuid.Validate(uidBasedID) == nil {
-		compositionJSON, err = h.OpenEHRService.GetCompositionByVersionedCompositionIDRawJSON(ctx, ehrID, uuid.MustParse(uidBasedID))
-	} else {
-		compositionJSON, err = h.OpenEHRService.GetCompositionRawJSON(ctx, ehrID, uidBasedID)
-	}
+	ehrAccessJSON, err := h.OpenEHRService.GetEHRAccessByIDRawJSON(ctx, ehrID, versionUID)
 	if err != nil {
-		if err == ErrEHRNotFound {
-			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusNotFound,
-				Message: "EHR not found for the given EHR ID",
-				Status:  "not_found",
-			})
-		}
-		if err == ErrCompositionNotFound {
+		if err == ErrEHRAccessNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Composition not found for the given UID",
+				Message: "EHR Access not found for the given EHR ID and version UID",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Composition by ID", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get EHR Access by version ID", "error", err)
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
 			Message: "Internal server error",
@@ -974,15 +1100,14 @@ func (h *Handler) GetComposition(c *fiber.Ctx) error {
 
 	auditCtx.Success()
 
-	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(compositionJSON)
+	return SendRawRepresentation[rm.EHR_ACCESS](c, fiber.StatusOK, ehrAccessJSON)
 }
 
-func (h *Handler) UpdateComposition(c *fiber.Ctx) error {
+func (h *Handler) UpdateEHRAccess(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
-	err := Accepts(c, auditCtx, "application/json")
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
 	if err != nil {
 		return err
 	}
@@ -993,12 +1118,6 @@ func (h *Handler) UpdateComposition(c *fiber.Ctx) error {
 	}
 	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
-	if err != nil {
-		return err
-	}
-	auditCtx.Event.Details["uid_based_id"] = uidBasedID
-
 	ifMatch, err := StringFromHeader(c, auditCtx, "If-Match")
 	if err != nil {
 		return err
@@ -1009,52 +1128,38 @@ func (h *Handler) UpdateComposition(c *fiber.Ctx) error {
 		return err
 	}
 
-	versionedCompositionID, err := uuid.Parse(strings.Split(uidBasedID, "::")[0])
-	if err != nil {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "Invalid UID format",
-			Status:  "bad_request",
-		})
+	var ehrAccess rm.EHR_ACCESS
+	if err := ParseBody(c, auditCtx, &ehrAccess); err != nil {
+		return err
 	}
 
-	currentCompositionID, err := h.OpenEHRService.GetCompositionID(ctx, ehrID, versionedCompositionID)
+	// Check collision using If-Match header
+	currentEHRAccessID, err := h.OpenEHRService.GetEHRAccessID(ctx, ehrID)
 	if err != nil {
-		if err == ErrCompositionNotFound {
+		if err == ErrEHRAccessNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Composition not found for the given EHR ID and UID",
+				Message: "EHR Access not found for the given EHR ID",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Composition", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current EHR Access", "error", err)
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
 			Message: "Internal server error",
 			Status:  "error",
 		})
 	}
-	if currentCompositionID.Value != ifMatch {
+	if !ETagMatches(ifMatch, currentEHRAccessID.Value) {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusPreconditionFailed,
-			Message: "Composition has been modified since the provided version",
+			Message: "EHR Access has been modified since the provided version",
 			Status:  "precondition_failed",
 		})
 	}
 
-	var composition rm.COMPOSITION
-	if err := ParseBody(c, auditCtx, &composition); err != nil {
-		return err
-	}
-
-	if !composition.UID.E {
-		composition.UID = utils.Some(rm.UID_BASED_ID_from_HIER_OBJECT_ID(&rm.HIER_OBJECT_ID{
-			Value: versionedCompositionID.String(),
-		}))
-	}
-
-	updatedComposition, err := h.OpenEHRService.UpdateComposition(ctx, ehrID, currentCompositionID, composition)
+	updatedEHRAccess, err := h.OpenEHRService.UpdateEHRAccess(ctx, ehrID, currentEHRAccessID, ehrAccess)
 	if err != nil {
 		if err == ErrEHRNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
@@ -1063,131 +1168,121 @@ func (h *Handler) UpdateComposition(c *fiber.Ctx) error {
 				Status:  "not_found",
 			})
 		}
-		if err == ErrCompositionNotFound {
-			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusNotFound,
-				Message: "Composition not found for the given UID",
-				Status:  "not_found",
-			})
-		}
-		if err == ErrCompositionAlreadyExists {
+		if err == ErrEHRAccessVersionConflict {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusConflict,
-				Message: "Composition with the given UID already exists",
-				Status:  "conflict",
+				Code:    fiber.StatusPreconditionFailed,
+				Message: "EHR Access has been modified since the provided version",
+				Status:  "precondition_failed",
 			})
 		}
-		if err == ErrCompositionVersionLowerOrEqualToCurrent {
+		if err == ErrVersionLowerOrEqualToCurrent {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusBadRequest,
-				Message: "Composition version in request body is lower or equal to the current version",
+				Message: "EHR Access version in request body must be incremented",
 				Status:  "bad_request",
 			})
 		}
-		if err == ErrInvalidCompositionUIDMismatch {
+		if err == ErrInvalidEHRAccessUIDMismatch {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusBadRequest,
-				Message: "Composition UID HIER_OBJECT_ID in request body does not match current Composition UID",
+				Message: "EHR Access UID in request body does not match current EHR Access UID",
 				Status:  "bad_request",
 			})
 		}
-		if err == ErrCompositionUIDNotProvided {
+		if validationErrs, ok := err.(util.ValidateError); ok {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusBadRequest,
-				Message: "Composition UID must be provided in the request body for update",
+				Message: "Validation error in request body",
 				Status:  "bad_request",
+				Details: validationErrs,
 			})
 		}
-		if validationErrs, ok := err.(util.ValidateError); ok {
-			return c.Status(fiber.StatusBadRequest).JSON(validationErrs)
-		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to update Composition", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to update EHR Access", "error", err)
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
 			Message: "Internal server error",
 			Status:  "error",
 		})
 	}
-	updatedCompositionID := updatedComposition.UID.V.OBJECT_VERSION_ID().Value
+	updatedEHRAccessID := updatedEHRAccess.UID.V.OBJECT_VERSION_ID().Value
 
 	auditCtx.Success()
 
-	h.WebhookSink.Enqueue(webhook.EventTypeCompositionUpdated, map[string]any{
-		"ehr_id":               ehrID,
-		"prev_composition_uid": currentCompositionID,
-		"curr_composition_uid": updatedCompositionID,
+	h.WebhookSink.Enqueue(webhook.EventTypeEHRAccessUpdated, map[string]any{
+		"ehr_id":              ehrID,
+		"prev_ehr_access_uid": currentEHRAccessID.Value,
+		"curr_ehr_access_uid": updatedEHRAccessID,
 	})
 
-	c.Set("ETag", "\""+updatedCompositionID+"\"")
-	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/ehr/"+ehrID.String()+"/composition/"+updatedCompositionID)
+	c.Set("ETag", "\""+updatedEHRAccessID+"\"")
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/ehr/"+ehrID.String()+"/ehr_access/"+updatedEHRAccessID)
 
 	switch returnType {
 	case ReturnTypeMinimal:
 		c.Status(fiber.StatusNoContent)
 		return nil
 	case ReturnTypeRepresentation:
-		return c.Status(fiber.StatusOK).JSON(updatedComposition)
+		return SendRepresentation(c, fiber.StatusOK, updatedEHRAccess)
 	case ReturnTypeIdentifier:
-		return c.JSON(`{"uid":"` + updatedCompositionID + `"}`)
+		return c.Status(fiber.StatusOK).JSON(`{"uid":"` + updatedEHRAccessID + `"}`)
 	default:
-		h.Telemetry.Logger.ErrorContext(ctx, "Unhandled Prefer header value", "value", returnType)
-		return c.Status(fiber.StatusOK).JSON(updatedComposition)
+		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
+		return SendRepresentation(c, fiber.StatusOK, updatedEHRAccess)
 	}
 }
 
-func (h *Handler) DeleteComposition(c *fiber.Ctx) error {
+func (h *Handler) CreateComposition(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
+	if err != nil {
+		return err
+	}
+
 	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
 	if err != nil {
 		return err
 	}
 	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	returnType, err := ReturnTypeFromHeader(c, auditCtx)
 	if err != nil {
 		return err
 	}
-	auditCtx.Event.Details["uid_based_id"] = uidBasedID
 
-	versionedCompositionID, err := uuid.Parse(strings.Split(uidBasedID, "::")[0])
-	if err != nil {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "Invalid UID format",
-			Status:  "bad_request",
-		})
+	var requestComposition rm.COMPOSITION
+	if err := ParseBody(c, auditCtx, &requestComposition); err != nil {
+		return err
 	}
 
-	// Check matching latest composition version
-	currentCompositionID, err := h.OpenEHRService.GetCompositionID(ctx, ehrID, versionedCompositionID)
+	exists, err := h.OpenEHRService.ExistsEHR(ctx, ehrID)
 	if err != nil {
-		if err == ErrCompositionNotFound {
-			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusNotFound,
-				Message: "Composition not found for the given UID and EHR ID",
-				Status:  "not_found",
-			})
-		}
-
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Composition by ID before deletion", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to check if EHR exists", "error", err)
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
 			Message: "Internal server error",
 			Status:  "error",
 		})
 	}
-	if currentCompositionID.Value != uidBasedID {
+	if !exists {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusPreconditionFailed,
-			Message: "Composition has been modified since the provided version",
-			Status:  "precondition_failed",
+			Code:    fiber.StatusNotFound,
+			Message: "EHR not found for the given EHR ID",
+			Status:  "not_found",
 		})
 	}
 
-	if err := h.OpenEHRService.DeleteVersionedComposition(ctx, ehrID, versionedCompositionID); err != nil {
+	composition, err := h.OpenEHRService.CreateComposition(ctx, ehrID, requestComposition)
+	if err != nil {
+		if err == ErrEHRNotModifiable {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "EHR is not modifiable, its EHR Status has is_modifiable set to false",
+				Status:  "conflict",
+			})
+		}
 		if err == ErrEHRNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
@@ -1195,16 +1290,23 @@ func (h *Handler) DeleteComposition(c *fiber.Ctx) error {
 				Status:  "not_found",
 			})
 		}
-		if err == ErrCompositionNotFound {
+		if err == ErrCompositionAlreadyExists {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusNotFound,
-				Message: "Composition not found for the given UID",
-				Status:  "not_found",
+				Code:    fiber.StatusConflict,
+				Message: "Composition with the given UID already exists",
+				Status:  "conflict",
+			})
+		}
+		if validationErrs, ok := err.(util.ValidateError); ok {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Validation error in request body",
+				Status:  "bad_request",
+				Details: validationErrs,
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete Composition by ID", "error", err)
-
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to create Composition", "error", err)
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
 			Message: "Internal server error",
@@ -1214,16 +1316,31 @@ func (h *Handler) DeleteComposition(c *fiber.Ctx) error {
 
 	auditCtx.Success()
 
-	h.WebhookSink.Enqueue(webhook.EventTypeCompositionDeleted, map[string]any{
+	h.WebhookSink.Enqueue(webhook.EventTypeCompositionCreated, map[string]any{
 		"ehr_id":          ehrID,
-		"composition_uid": currentCompositionID.Value,
+		"composition_uid": composition.UID.V.OBJECT_VERSION_ID().Value,
 	})
 
-	c.Status(fiber.StatusNoContent)
-	return nil
+	compositionID := composition.UID.V.OBJECT_VERSION_ID().Value
+	h.StandingQueryEvaluator.Enqueue(ehrID, compositionID)
+	c.Set("ETag", "\""+compositionID+"\"")
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/ehr/"+ehrID.String()+"/composition/"+compositionID)
+
+	switch returnType {
+	case ReturnTypeMinimal:
+		c.Status(fiber.StatusCreated)
+		return nil
+	case ReturnTypeRepresentation:
+		return SendRepresentation(c, fiber.StatusCreated, composition)
+	case ReturnTypeIdentifier:
+		return c.Status(fiber.StatusCreated).JSON(`{"uid":"` + compositionID + `"}`)
+	default:
+		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
+		return SendRepresentation(c, fiber.StatusCreated, composition)
+	}
 }
 
-func (h *Handler) GetVersionedCompositionByID(c *fiber.Ctx) error {
+func (h *Handler) GetComposition(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
@@ -1233,13 +1350,17 @@ func (h *Handler) GetVersionedCompositionByID(c *fiber.Ctx) error {
 	}
 	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	versionedCompositionID, err := StringFromPath(c, auditCtx, "versioned_object_id")
+	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
 	if err != nil {
 		return err
 	}
-	auditCtx.Event.Details["versioned_composition_uid"] = versionedCompositionID
 
-	versionedCompositionJSON, err := h.OpenEHRService.GetVersionedCompositionRawJSON(ctx, ehrID, versionedCompositionID)
+	var compositionJSON []byte
+	if uuid.Validate(uidBasedID) == nil {
+		compositionJSON, err = h.OpenEHRService.GetCompositionByVersionedCompositionIDRawJSON(ctx, ehrID, uuid.MustParse(uidBasedID))
+	} else {
+		compositionJSON, err = h.OpenEHRService.GetCompositionRawJSON(ctx, ehrID, uidBasedID)
+	}
 	if err != nil {
 		if err == ErrEHRNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
@@ -1251,13 +1372,12 @@ func (h *Handler) GetVersionedCompositionByID(c *fiber.Ctx) error {
 		if err == ErrCompositionNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Versioned Composition not found for the given versioned object ID",
+				Message: "Composition not found for the given UID",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Versioned Composition by ID", "error", err)
-
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Composition by ID", "error", err)
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
 			Message: "Internal server error",
@@ -1267,141 +1387,117 @@ func (h *Handler) GetVersionedCompositionByID(c *fiber.Ctx) error {
 
 	auditCtx.Success()
 
-	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(versionedCompositionJSON)
+	return SendRawRepresentation[rm.COMPOSITION](c, fiber.StatusOK, compositionJSON)
 }
 
-func (h *Handler) GetVersionedCompositionRevisionHistory(c *fiber.Ctx) error {
-	ctx := c.Context()
+func (h *Handler) UpdateComposition(c *fiber.Ctx) error {
+	return h.updateComposition(c, false)
+}
+
+func (h *Handler) PatchComposition(c *fiber.Ctx) error {
+	return h.updateComposition(c, true)
+}
 
+func (h *Handler) updateComposition(c *fiber.Ctx, patch bool) error {
+	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
-	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
 	if err != nil {
 		return err
 	}
-	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	versionedCompositionID, err := StringFromPath(c, auditCtx, "versioned_object_id")
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
 	if err != nil {
 		return err
 	}
-	auditCtx.Event.Details["versioned_composition_uid"] = versionedCompositionID
+	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	revisionHistoryJSON, err := h.OpenEHRService.GetVersionedCompositionRevisionHistoryRawJSON(ctx, ehrID, versionedCompositionID)
+	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
 	if err != nil {
-		if err == ErrEHRNotFound {
-			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusNotFound,
-				Message: "EHR not found for the given EHR ID",
-				Status:  "not_found",
-			})
-		}
-		if err == ErrCompositionNotFound {
-			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusNotFound,
-				Message: "Versioned Composition revision history not found for the given versioned object ID",
-				Status:  "not_found",
-			})
-		}
-
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Versioned Composition revision history", "error", err)
-
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusInternalServerError,
-			Message: "Internal server error",
-			Status:  "error",
-		})
+		return err
 	}
+	auditCtx.Event.Details["uid_based_id"] = uidBasedID
 
-	auditCtx.Success()
-
-	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(revisionHistoryJSON)
-}
-
-func (h *Handler) GetVersionedCompositionVersionAtTime(c *fiber.Ctx) error {
-	ctx := c.Context()
-	auditCtx := middleware.AuditFrom(c)
-
-	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
+	ifMatch, err := StringFromHeader(c, auditCtx, "If-Match")
 	if err != nil {
 		return err
 	}
-	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	versionedCompositionID, err := UUIDFromPath(c, auditCtx, "versioned_object_id")
+	returnType, err := ReturnTypeFromHeader(c, auditCtx)
 	if err != nil {
 		return err
 	}
-	auditCtx.Event.Details["versioned_composition_uid"] = versionedCompositionID
 
-	var filterAtTime time.Time
-	if c.Query("version_at_time") != "" {
-		versionAtTime, err := TimeFromQuery(c, auditCtx, "version_at_time")
-		if err != nil {
-			return err
-		}
-		filterAtTime = versionAtTime
-		auditCtx.Event.Details["version_at_time"] = filterAtTime.String()
+	versionedCompositionID, err := uuid.Parse(strings.Split(uidBasedID, "::")[0])
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Invalid UID format",
+			Status:  "bad_request",
+		})
 	}
 
-	compositionJSON, err := h.OpenEHRService.GetCompositionAtTimeRawJSON(ctx, ehrID, versionedCompositionID, filterAtTime)
+	currentCompositionID, err := h.OpenEHRService.GetCompositionID(ctx, ehrID, versionedCompositionID)
 	if err != nil {
-		if err == ErrEHRNotFound {
-			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusNotFound,
-				Message: "EHR not found for the given EHR ID",
-				Status:  "not_found",
-			})
-		}
 		if err == ErrCompositionNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Versioned Composition version not found for the given versioned object ID at the specified time",
+				Message: "Composition not found for the given EHR ID and UID",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Versioned Composition version at time", "error", err)
-
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Composition", "error", err)
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
 			Message: "Internal server error",
 			Status:  "error",
 		})
 	}
-
-	auditCtx.Success()
-
-	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(compositionJSON)
-}
-
-func (h *Handler) GetVersionedCompositionVersionByID(c *fiber.Ctx) error {
-	ctx := c.Context()
-	auditCtx := middleware.AuditFrom(c)
-
-	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
-	if err != nil {
-		return err
+	if !ETagMatches(ifMatch, currentCompositionID.Value) {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusPreconditionFailed,
+			Message: "Composition has been modified since the provided version",
+			Status:  "precondition_failed",
+		})
 	}
-	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	versionedCompositionID, err := UUIDFromPath(c, auditCtx, "versioned_object_id")
-	if err != nil {
+	var composition rm.COMPOSITION
+	if patch {
+		currentCompositionJSON, err := h.OpenEHRService.GetCompositionRawJSON(ctx, ehrID, currentCompositionID.Value)
+		if err != nil {
+			h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Composition", "error", err)
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusInternalServerError,
+				Message: "Internal server error",
+				Status:  "error",
+			})
+		}
+
+		if ok, err := PatchBody(c, auditCtx, currentCompositionJSON, &composition); !ok {
+			return err
+		}
+		composition.UID = PatchedUID(composition.UID, currentCompositionID)
+	} else if err := ParseBody(c, auditCtx, &composition); err != nil {
 		return err
 	}
-	auditCtx.Event.Details["versioned_composition_uid"] = versionedCompositionID
 
-	versionID, err := StringFromPath(c, auditCtx, "version_uid")
-	if err != nil {
-		return err
+	if !composition.UID.E {
+		composition.UID = utils.Some(rm.UID_BASED_ID_from_HIER_OBJECT_ID(&rm.HIER_OBJECT_ID{
+			Value: versionedCompositionID.String(),
+		}))
 	}
-	auditCtx.Event.Details["version_uid"] = versionID
 
-	versionJSON, err := h.OpenEHRService.GetCompositionByIDRawJSON(ctx, ehrID, versionedCompositionID, versionID)
+	updatedComposition, err := h.OpenEHRService.UpdateComposition(ctx, ehrID, currentCompositionID, composition)
 	if err != nil {
+		if err == ErrEHRNotModifiable {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "EHR is not modifiable, its EHR Status has is_modifiable set to false",
+				Status:  "conflict",
+			})
+		}
 		if err == ErrEHRNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
@@ -1412,241 +1508,175 @@ func (h *Handler) GetVersionedCompositionVersionByID(c *fiber.Ctx) error {
 		if err == ErrCompositionNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Versioned Composition version not found for the given versioned object ID and version UID",
+				Message: "Composition not found for the given UID",
 				Status:  "not_found",
 			})
 		}
-
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Versioned Composition version by ID", "error", err)
-
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusInternalServerError,
-			Message: "Internal server error",
-			Status:  "error",
-		})
-	}
-
-	auditCtx.Success()
-	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(versionJSON)
-}
-
-func (h *Handler) CreateDirectory(c *fiber.Ctx) error {
-	ctx := c.Context()
-	auditCtx := middleware.AuditFrom(c)
-
-	err := Accepts(c, auditCtx, "application/json")
-	if err != nil {
-		return err
-	}
-
-	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
-	if err != nil {
-		return err
-	}
-	auditCtx.Event.Details["ehr_id"] = ehrID
-
-	returnType, err := ReturnTypeFromHeader(c, auditCtx)
-	if err != nil {
-		return err
-	}
-
-	var requestDirectory rm.FOLDER
-	if err := ParseBody(c, auditCtx, &requestDirectory); err != nil {
-		return err
-	}
-
-	directory, err := h.OpenEHRService.CreateDirectory(ctx, ehrID, requestDirectory)
-	if err != nil {
-		if err == ErrEHRNotFound {
+		if err == ErrCompositionAlreadyExists {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusNotFound,
-				Message: "EHR not found for the given EHR ID",
-				Status:  "not_found",
+				Code:    fiber.StatusConflict,
+				Message: "Composition with the given UID already exists",
+				Status:  "conflict",
 			})
 		}
-		if err == ErrDirectoryAlreadyExists {
+		if err == ErrCompositionVersionLowerOrEqualToCurrent {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusConflict,
-				Message: "Directory with the given UID already exists",
-				Status:  "conflict",
+				Code:    fiber.StatusBadRequest,
+				Message: "Composition version in request body is lower or equal to the current version",
+				Status:  "bad_request",
 			})
 		}
-		if validationErrs, ok := err.(util.ValidateError); ok {
+		if err == ErrInvalidCompositionUIDMismatch {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusBadRequest,
-				Message: "Validation error in request body",
+				Message: "Composition UID HIER_OBJECT_ID in request body does not match current Composition UID",
 				Status:  "bad_request",
-				Details: validationErrs,
 			})
 		}
+		if err == ErrCompositionUIDNotProvided {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Composition UID must be provided in the request body for update",
+				Status:  "bad_request",
+			})
+		}
+		if validationErrs, ok := err.(util.ValidateError); ok {
+			return c.Status(fiber.StatusBadRequest).JSON(validationErrs)
+		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to create Directory", "error", err)
-
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to update Composition", "error", err)
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
 			Message: "Internal server error",
 			Status:  "error",
 		})
 	}
-	directoryID := directory.UID.V.OBJECT_VERSION_ID().Value
+	updatedCompositionID := updatedComposition.UID.V.OBJECT_VERSION_ID().Value
 
 	auditCtx.Success()
 
-	h.WebhookSink.Enqueue(webhook.EventTypeDirectoryCreated, map[string]any{
-		"directory_id": directoryID,
+	h.WebhookSink.Enqueue(webhook.EventTypeCompositionUpdated, map[string]any{
+		"ehr_id":               ehrID,
+		"prev_composition_uid": currentCompositionID,
+		"curr_composition_uid": updatedCompositionID,
 	})
+	h.StandingQueryEvaluator.Enqueue(ehrID, updatedCompositionID)
 
-	c.Set("ETag", "\""+directoryID+"\"")
-	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/ehr/"+ehrID.String()+"/directory/"+directoryID)
+	c.Set("ETag", "\""+updatedCompositionID+"\"")
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/ehr/"+ehrID.String()+"/composition/"+updatedCompositionID)
 
 	switch returnType {
 	case ReturnTypeMinimal:
-		c.Status(fiber.StatusCreated)
+		c.Status(fiber.StatusNoContent)
 		return nil
 	case ReturnTypeRepresentation:
-		return c.Status(fiber.StatusCreated).JSON(directory)
+		return SendRepresentation(c, fiber.StatusOK, updatedComposition)
 	case ReturnTypeIdentifier:
-		return c.Status(fiber.StatusCreated).JSON(`{"uid":"` + directoryID + `"}`)
+		return c.JSON(`{"uid":"` + updatedCompositionID + `"}`)
 	default:
-		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
-		return c.Status(fiber.StatusCreated).JSON(directory)
+		h.Telemetry.Logger.ErrorContext(ctx, "Unhandled Prefer header value", "value", returnType)
+		return SendRepresentation(c, fiber.StatusOK, updatedComposition)
 	}
 }
 
-func (h *Handler) UpdateDirectory(c *fiber.Ctx) error {
+func (h *Handler) DeleteComposition(c *fiber.Ctx) error {
 	ctx := c.Context()
-
 	auditCtx := middleware.AuditFrom(c)
 
-	err := Accepts(c, auditCtx, "application/json")
-	if err != nil {
-		return err
-	}
-
 	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
 	if err != nil {
 		return err
 	}
 	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	ifMatch, err := StringFromHeader(c, auditCtx, "If-Match")
+	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
 	if err != nil {
 		return err
 	}
+	auditCtx.Event.Details["uid_based_id"] = uidBasedID
 
-	returnType, err := ReturnTypeFromHeader(c, auditCtx)
+	versionedCompositionID, err := uuid.Parse(strings.Split(uidBasedID, "::")[0])
 	if err != nil {
-		return err
-	}
-
-	var directory rm.FOLDER
-	if err := ParseBody(c, auditCtx, &directory); err != nil {
-		return err
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Invalid UID format",
+			Status:  "bad_request",
+		})
 	}
 
-	currentDirectoryID, err := h.OpenEHRService.GetDirectoryID(ctx, ehrID)
+	// Check matching latest composition version
+	currentCompositionID, err := h.OpenEHRService.GetCompositionID(ctx, ehrID, versionedCompositionID)
 	if err != nil {
-		if err == ErrEHRNotFound {
-			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusNotFound,
-				Message: "EHR not found for the given EHR ID",
-				Status:  "not_found",
-			})
-		}
-		if err == ErrDirectoryNotFound {
+		if err == ErrCompositionNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Directory not found for the given EHR ID",
+				Message: "Composition not found for the given UID and EHR ID",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Directory", "error", err)
-
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Composition by ID before deletion", "error", err)
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get current Directory",
+			Message: "Internal server error",
 			Status:  "error",
 		})
 	}
-	if currentDirectoryID.Value != ifMatch {
+	if currentCompositionID.Value != uidBasedID {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusPreconditionFailed,
-			Message: "Directory has been modified since the provided version",
+			Message: "Composition has been modified since the provided version",
 			Status:  "precondition_failed",
 		})
 	}
+	if ok, err := IfMatch(c, auditCtx, currentCompositionID.Value, "Composition"); !ok {
+		return err
+	}
 
-	updatedDirectory, err := h.OpenEHRService.UpdateDirectory(ctx, ehrID, currentDirectoryID, directory)
-	if err != nil {
-		if err == ErrDirectoryNotFound {
-			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusNotFound,
-				Message: "Directory not found for the given EHR ID",
-				Status:  "not_found",
-			})
-		}
-		if err == ErrDirectoryAlreadyExists {
+	if err := h.OpenEHRService.DeleteVersionedComposition(ctx, ehrID, versionedCompositionID); err != nil {
+		if err == ErrEHRNotModifiable {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusConflict,
-				Message: "Directory with the given UID already exists",
+				Message: "EHR is not modifiable, its EHR Status has is_modifiable set to false",
 				Status:  "conflict",
 			})
 		}
-		if err == ErrDirectoryVersionLowerOrEqualToCurrent {
+		if err == ErrEHRNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusBadRequest,
-				Message: "Directory version in request body is lower or equal to the current version",
-				Status:  "bad_request",
+				Code:    fiber.StatusNotFound,
+				Message: "EHR not found for the given EHR ID",
+				Status:  "not_found",
 			})
 		}
-		if err == ErrInvalidDirectoryUIDMismatch {
+		if err == ErrCompositionNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusBadRequest,
-				Message: "Directory UID HIER_OBJECT_ID in request body does not match current Directory UID",
-				Status:  "bad_request",
+				Code:    fiber.StatusNotFound,
+				Message: "Composition not found for the given UID",
+				Status:  "not_found",
 			})
 		}
-		if validationErrs, ok := err.(util.ValidateError); ok {
-			return c.Status(fiber.StatusBadRequest).JSON(validationErrs)
-		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to update Directory", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete Composition by ID", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to update Directory",
+			Message: "Internal server error",
 			Status:  "error",
 		})
 	}
-	updatedDirectoryID := updatedDirectory.UID.V.OBJECT_VERSION_ID().Value
 
 	auditCtx.Success()
 
-	h.WebhookSink.Enqueue(webhook.EventTypeDirectoryUpdated, map[string]any{
-		"prev_directory_id": currentDirectoryID.Value,
-		"curr_directory_id": updatedDirectoryID,
+	h.WebhookSink.Enqueue(webhook.EventTypeCompositionDeleted, map[string]any{
+		"ehr_id":          ehrID,
+		"composition_uid": currentCompositionID.Value,
 	})
 
-	c.Set("ETag", "\""+updatedDirectoryID+"\"")
-	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/ehr/"+ehrID.String()+"/directory/"+updatedDirectoryID)
-
-	switch returnType {
-	case ReturnTypeMinimal:
-		c.Status(fiber.StatusNoContent)
-		return nil
-	case ReturnTypeRepresentation:
-		return c.Status(fiber.StatusOK).JSON(updatedDirectory)
-	case ReturnTypeIdentifier:
-		return c.Status(fiber.StatusOK).JSON(`{"uid":"` + updatedDirectoryID + `"}`)
-	default:
-		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
-		return c.Status(fiber.StatusOK).JSON(updatedDirectory)
-	}
+	c.Status(fiber.StatusNoContent)
+	return nil
 }
 
-func (h *Handler) DeleteDirectory(c *fiber.Ctx) error {
+func (h *Handler) GetVersionedCompositionByID(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
@@ -1656,12 +1686,13 @@ func (h *Handler) DeleteDirectory(c *fiber.Ctx) error {
 	}
 	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	ifMatch, err := StringFromHeader(c, auditCtx, "If-Match")
+	versionedCompositionID, err := StringFromPath(c, auditCtx, "versioned_object_id")
 	if err != nil {
 		return err
 	}
+	auditCtx.Event.Details["versioned_composition_uid"] = versionedCompositionID
 
-	currentDirectoryID, err := h.OpenEHRService.GetDirectoryID(ctx, ehrID)
+	versionedCompositionJSON, err := h.OpenEHRService.GetVersionedCompositionRawJSON(ctx, ehrID, versionedCompositionID)
 	if err != nil {
 		if err == ErrEHRNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
@@ -1670,31 +1701,47 @@ func (h *Handler) DeleteDirectory(c *fiber.Ctx) error {
 				Status:  "not_found",
 			})
 		}
-		if err == ErrDirectoryNotFound {
+		if err == ErrCompositionNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Directory not found for the given EHR ID",
+				Message: "Versioned Composition not found for the given versioned object ID",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Directory", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Versioned Composition by ID", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get current Directory",
+			Message: "Internal server error",
 			Status:  "error",
 		})
 	}
-	if currentDirectoryID.Value != ifMatch {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusPreconditionFailed,
-			Message: "Directory has been modified since the provided version",
-			Status:  "precondition_failed",
-		})
+
+	auditCtx.Success()
+
+	return SendRawRepresentation[rm.VERSIONED_COMPOSITION](c, fiber.StatusOK, versionedCompositionJSON)
+}
+
+func (h *Handler) GetVersionedCompositionRevisionHistory(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
+	if err != nil {
+		return err
 	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	if err := h.OpenEHRService.DeleteDirectory(ctx, ehrID, uuid.MustParse(currentDirectoryID.Value)); err != nil {
+	versionedCompositionID, err := StringFromPath(c, auditCtx, "versioned_object_id")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["versioned_composition_uid"] = versionedCompositionID
+
+	revisionHistoryJSON, err := h.OpenEHRService.GetVersionedCompositionRevisionHistoryRawJSON(ctx, ehrID, versionedCompositionID)
+	if err != nil {
 		if err == ErrEHRNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
@@ -1702,36 +1749,30 @@ func (h *Handler) DeleteDirectory(c *fiber.Ctx) error {
 				Status:  "not_found",
 			})
 		}
-		if err == ErrDirectoryNotFound {
+		if err == ErrCompositionNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Directory not found for the given EHR ID",
+				Message: "Versioned Composition revision history not found for the given versioned object ID",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete Directory", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Versioned Composition revision history", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to delete Directory",
+			Message: "Internal server error",
 			Status:  "error",
 		})
 	}
 
 	auditCtx.Success()
 
-	h.WebhookSink.Enqueue(webhook.EventTypeDirectoryDeleted, map[string]any{
-		"directory_id": currentDirectoryID.UID(),
-	})
-
-	c.Status(fiber.StatusNoContent)
-	return nil
+	return SendRawRepresentation[rm.REVISION_HISTORY](c, fiber.StatusOK, revisionHistoryJSON)
 }
 
-func (h *Handler) GetFolderInDirectoryVersionAtTime(c *fiber.Ctx) error {
+func (h *Handler) GetVersionedCompositionVersionAtTime(c *fiber.Ctx) error {
 	ctx := c.Context()
-
 	auditCtx := middleware.AuditFrom(c)
 
 	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
@@ -1740,58 +1781,54 @@ func (h *Handler) GetFolderInDirectoryVersionAtTime(c *fiber.Ctx) error {
 	}
 	auditCtx.Event.Details["ehr_id"] = ehrID
 
+	versionedCompositionID, err := UUIDFromPath(c, auditCtx, "versioned_object_id")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["versioned_composition_uid"] = versionedCompositionID
+
 	var filterAtTime time.Time
-	atTimeStr := c.Query("version_at_time")
-	if atTimeStr != "" {
-		parsedTime, err := TimeFromQuery(c, auditCtx, "version_at_time")
+	if c.Query("version_at_time") != "" {
+		versionAtTime, err := TimeFromQuery(c, auditCtx, "version_at_time")
 		if err != nil {
 			return err
 		}
-		filterAtTime = parsedTime
-	}
-
-	path := c.Query("path")
+		filterAtTime = versionAtTime
+		auditCtx.Event.Details["version_at_time"] = filterAtTime.String()
+	}
 
-	folderJSON, err := h.OpenEHRService.GetFolderAtTimeRawJSON(ctx, ehrID, filterAtTime, path)
+	compositionJSON, err := h.OpenEHRService.GetCompositionAtTimeRawJSON(ctx, ehrID, versionedCompositionID, filterAtTime)
 	if err != nil {
 		if err == ErrEHRNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Directory not found for the given EHR ID at the specified time",
-				Status:  "not_found",
-			})
-		}
-		if err == ErrDirectoryNotFound {
-			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusNotFound,
-				Message: "Directory version not found at the specified time for the given EHR ID",
+				Message: "EHR not found for the given EHR ID",
 				Status:  "not_found",
 			})
 		}
-		if err == ErrFolderNotFoundInDirectory {
+		if err == ErrCompositionNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Folder not found in Directory version at the specified time for the given path",
+				Message: "Versioned Composition version not found for the given versioned object ID at the specified time",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Folder in Directory version at time", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Versioned Composition version at time", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get Folder in Directory version at time",
+			Message: "Internal server error",
 			Status:  "error",
 		})
 	}
 
 	auditCtx.Success()
 
-	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(folderJSON)
+	return SendRawRepresentation[rm.COMPOSITION](c, fiber.StatusOK, compositionJSON)
 }
 
-func (h *Handler) GetFolderInDirectoryVersion(c *fiber.Ctx) error {
+func (h *Handler) GetVersionedCompositionVersionByID(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
@@ -1801,94 +1838,84 @@ func (h *Handler) GetFolderInDirectoryVersion(c *fiber.Ctx) error {
 	}
 	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	versionUID, err := UUIDFromPath(c, auditCtx, "version_uid")
+	versionedCompositionID, err := UUIDFromPath(c, auditCtx, "versioned_object_id")
 	if err != nil {
 		return err
 	}
-	auditCtx.Event.Details["version_uid"] = versionUID
+	auditCtx.Event.Details["versioned_composition_uid"] = versionedCompositionID
 
-	path := c.Query("path")
+	versionID, err := StringFromPath(c, auditCtx, "version_uid")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["version_uid"] = versionID
 
-	folderJSON, err := h.OpenEHRService.GetFolderInDirectoryByIDRawJSON(ctx, ehrID, versionUID, path)
+	versionJSON, err := h.OpenEHRService.GetCompositionByIDRawJSON(ctx, ehrID, versionedCompositionID, versionID)
 	if err != nil {
 		if err == ErrEHRNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Directory not found for the given EHR ID",
-				Status:  "not_found",
-			})
-		}
-		if err == ErrDirectoryNotFound {
-			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusNotFound,
-				Message: "Directory version not found at the specified time for the given EHR ID",
+				Message: "EHR not found for the given EHR ID",
 				Status:  "not_found",
 			})
 		}
-		if err == ErrFolderNotFoundInDirectory {
+		if err == ErrCompositionNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Folder not found in Directory version at the specified time for the given path",
+				Message: "Versioned Composition version not found for the given versioned object ID and version UID",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Folder in Directory version", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Versioned Composition version by ID", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get Folder in Directory version",
+			Message: "Internal server error",
 			Status:  "error",
 		})
 	}
 
 	auditCtx.Success()
-
-	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(folderJSON)
-}
-
-func (h *Handler) CreateContribution(c *fiber.Ctx) error {
-	c.Status(fiber.StatusNotImplemented)
-	return nil
+	return SendRawRepresentation[rm.COMPOSITION](c, fiber.StatusOK, versionJSON)
 }
 
-func (h *Handler) GetContribution(c *fiber.Ctx) error {
+func (h *Handler) GetCompositionVersionAttestations(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
-	err := Accepts(c, auditCtx, "application/json")
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
 	if err != nil {
 		return err
 	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
+	versionedCompositionID, err := UUIDFromPath(c, auditCtx, "versioned_object_id")
 	if err != nil {
 		return err
 	}
-	auditCtx.Event.Details["ehr_id"] = ehrID
+	auditCtx.Event.Details["versioned_composition_uid"] = versionedCompositionID
 
-	contributionUID, err := StringFromPath(c, auditCtx, "contribution_uid")
+	versionID, err := StringFromPath(c, auditCtx, "version_uid")
 	if err != nil {
 		return err
 	}
-	auditCtx.Event.Details["contribution_uid"] = contributionUID
+	auditCtx.Event.Details["version_uid"] = versionID
 
-	contributionJSON, err := h.OpenEHRService.GetContributionRawJSON(ctx, contributionUID, utils.Some(ehrID))
+	attestationsJSON, err := h.OpenEHRService.GetCompositionVersionAttestationsRawJSON(ctx, ehrID, versionedCompositionID, versionID)
 	if err != nil {
-		if err == ErrContributionNotFound {
+		if err == ErrCompositionNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Contribution not found for the given EHR ID and Contribution UID",
+				Message: "Versioned Composition version not found for the given versioned object ID and version UID",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Contribution by ID", "error", err)
-
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Composition version attestations", "error", err)
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get Contribution by ID",
+			Message: "Internal server error",
 			Status:  "error",
 		})
 	}
@@ -1896,14 +1923,14 @@ func (h *Handler) GetContribution(c *fiber.Ctx) error {
 	auditCtx.Success()
 
 	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(contributionJSON)
+	return c.Status(fiber.StatusOK).Send(attestationsJSON)
 }
 
-func (h *Handler) GetEHRTags(c *fiber.Ctx) error {
+func (h *Handler) AttestCompositionVersion(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
-	err := Accepts(c, auditCtx, "application/json")
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
 	if err != nil {
 		return err
 	}
@@ -1914,71 +1941,84 @@ func (h *Handler) GetEHRTags(c *fiber.Ctx) error {
 	}
 	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	tagsJSON, err := h.OpenEHRService.GetEHRTagsRawJSON(ctx, ehrID)
+	versionedCompositionID, err := UUIDFromPath(c, auditCtx, "versioned_object_id")
 	if err != nil {
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get EHR Tags", "error", err)
-
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get EHR Tags",
-			Status:  "error",
-		})
+		return err
 	}
+	auditCtx.Event.Details["versioned_composition_uid"] = versionedCompositionID
 
-	auditCtx.Success()
-
-	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(tagsJSON)
-}
-
-func (h *Handler) GetCompositionTags(c *fiber.Ctx) error {
-	ctx := c.Context()
-	auditCtx := middleware.AuditFrom(c)
-
-	err := Accepts(c, auditCtx, "application/json")
+	versionID, err := StringFromPath(c, auditCtx, "version_uid")
 	if err != nil {
 		return err
 	}
+	auditCtx.Event.Details["version_uid"] = versionID
 
-	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
-	if err != nil {
+	var request AttestationRequest
+	if err := ParseBody(c, auditCtx, &request); err != nil {
 		return err
 	}
-	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
-	if err != nil {
+	// An attestation is added to the exact version it attests
+	if ok, err := IfMatch(c, auditCtx, versionID, "Composition version"); !ok {
 		return err
 	}
-	auditCtx.Event.Details["uid_based_id"] = uidBasedID
 
-	var tagsJSON []byte
-	if uuid.Validate(uidBasedID) == nil {
-		tagsJSON, err = h.OpenEHRService.GetVersionedCompositionTagsRawJSON(ctx, ehrID, uuid.MustParse(uidBasedID))
-	} else {
-		tagsJSON, err = h.OpenEHRService.GetCompositionTagsRawJSON(ctx, ehrID, uidBasedID)
+	var subject string
+	if principal, ok := middleware.PrincipalFrom(c); ok {
+		subject = principal.Subject
 	}
+
+	attestation, err := h.OpenEHRService.AttestCompositionVersion(ctx, ehrID, versionedCompositionID, versionID, request, subject)
 	if err != nil {
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Composition Tags", "error", err)
+		if err == ErrCompositionNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Versioned Composition version not found for the given versioned object ID and version UID",
+				Status:  "not_found",
+			})
+		}
+		if err == ErrEHRNotModifiable {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "EHR is not modifiable, its EHR Status has is_modifiable set to false",
+				Status:  "conflict",
+			})
+		}
+		if validationErrs, ok := err.(util.ValidateError); ok {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Validation error in attestation",
+				Status:  "bad_request",
+				Details: validationErrs,
+			})
+		}
 
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to attest Composition version", "error", err)
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get Composition Tags",
+			Message: "Failed to attest Composition version",
 			Status:  "error",
 		})
 	}
 
+	auditCtx.Event.Details["attestation_reason"] = attestation.Reason.DV_CODED_TEXT().DefiningCode.CodeString
 	auditCtx.Success()
 
-	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(tagsJSON)
+	h.WebhookSink.Enqueue(webhook.EventTypeCompositionAttested, map[string]any{
+		"ehr_id":          ehrID,
+		"composition_uid": versionID,
+		"reason":          attestation.Reason.DV_CODED_TEXT().DefiningCode.CodeString,
+		"is_pending":      attestation.IsPending,
+	})
+
+	return SendRepresentation(c, fiber.StatusCreated, attestation)
 }
 
-func (h *Handler) UpdateCompositionTags(c *fiber.Ctx) error {
+func (h *Handler) CreateDirectory(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
-	err := Accepts(c, auditCtx, "application/json")
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
 	if err != nil {
 		return err
 	}
@@ -1989,76 +2029,98 @@ func (h *Handler) UpdateCompositionTags(c *fiber.Ctx) error {
 	}
 	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	returnType, err := ReturnTypeFromHeader(c, auditCtx)
 	if err != nil {
 		return err
 	}
-	auditCtx.Event.Details["uid_based_id"] = uidBasedID
 
-	var tags []rm.ITEM_TAG
-	err = c.BodyParser(&tags)
-	if err != nil {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "Failed to parse request body",
-			Status:  "bad_request",
-		})
+	var requestDirectory rm.FOLDER
+	if err := ParseBody(c, auditCtx, &requestDirectory); err != nil {
+		return err
 	}
 
-	if uuid.Validate(uidBasedID) == nil {
-		versionedCompositionID := uuid.MustParse(uidBasedID)
-		tags, err = h.OpenEHRService.ReplaceVersionedCompositionTags(ctx, ehrID, versionedCompositionID, tags)
-	} else {
-		tags, err = h.OpenEHRService.ReplaceCompositionTags(ctx, ehrID, uidBasedID, tags)
-	}
+	directory, err := h.OpenEHRService.CreateDirectory(ctx, ehrID, requestDirectory)
 	if err != nil {
-		if err, ok := err.(util.ValidateError); ok {
+		if err == ErrEHRNotModifiable {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "EHR is not modifiable, its EHR Status has is_modifiable set to false",
+				Status:  "conflict",
+			})
+		}
+		if err == ErrEHRNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "EHR not found for the given EHR ID",
+				Status:  "not_found",
+			})
+		}
+		if err == ErrDirectoryAlreadyExists {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "Directory with the given UID already exists",
+				Status:  "conflict",
+			})
+		}
+		if validationErrs, ok := err.(util.ValidateError); ok {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusBadRequest,
 				Message: "Validation error in request body",
 				Status:  "bad_request",
-				Details: err,
+				Details: validationErrs,
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to update Composition Tags", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to create Directory", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to update Composition Tags",
+			Message: "Internal server error",
 			Status:  "error",
 		})
 	}
+	directoryID := directory.UID.V.OBJECT_VERSION_ID().Value
 
 	auditCtx.Success()
-	return c.Status(fiber.StatusOK).JSON(tags)
-}
-
-func (h *Handler) DeleteCompositionTagByKey(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Delete Composition Tag By Key not implemented yet")
-}
-
-func (h *Handler) GetEHRStatusTags(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Get EHR Status Tags not implemented yet")
-}
 
-func (h *Handler) GetEHRStatusVersionTags(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Get EHR Status Version Tags not implemented yet")
-}
+	h.WebhookSink.Enqueue(webhook.EventTypeDirectoryCreated, map[string]any{
+		"directory_id": directoryID,
+	})
 
-func (h *Handler) UpdateEHRStatusVersionTags(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Update EHR Status Version Tags not implemented yet")
-}
+	c.Set("ETag", "\""+directoryID+"\"")
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/ehr/"+ehrID.String()+"/directory/"+directoryID)
 
-func (h *Handler) DeleteEHRStatusVersionTagByKey(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Delete EHR Status Version Tag By Key not implemented yet")
+	switch returnType {
+	case ReturnTypeMinimal:
+		c.Status(fiber.StatusCreated)
+		return nil
+	case ReturnTypeRepresentation:
+		return SendRepresentation(c, fiber.StatusCreated, directory)
+	case ReturnTypeIdentifier:
+		return c.Status(fiber.StatusCreated).JSON(`{"uid":"` + directoryID + `"}`)
+	default:
+		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
+		return SendRepresentation(c, fiber.StatusCreated, directory)
+	}
 }
 
-func (h *Handler) CreateAgent(c *fiber.Ctx) error {
+func (h *Handler) UpdateDirectory(c *fiber.Ctx) error {
 	ctx := c.Context()
+
 	auditCtx := middleware.AuditFrom(c)
 
-	err := Accepts(c, auditCtx, "application/json")
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
+	if err != nil {
+		return err
+	}
+
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
+
+	ifMatch, err := StringFromHeader(c, auditCtx, "If-Match")
 	if err != nil {
 		return err
 	}
@@ -2068,822 +2130,929 @@ func (h *Handler) CreateAgent(c *fiber.Ctx) error {
 		return err
 	}
 
-	var agent rm.AGENT
-	if err := ParseBody(c, auditCtx, &agent); err != nil {
+	var directory rm.FOLDER
+	if err := ParseBody(c, auditCtx, &directory); err != nil {
 		return err
 	}
 
-	createdAgent, err := h.OpenEHRService.CreateAgent(ctx, agent)
+	currentDirectoryID, err := h.OpenEHRService.GetDirectoryID(ctx, ehrID)
 	if err != nil {
-		if err == ErrAgentAlreadyExists {
-			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusConflict,
-				Message: "Agent with the given UID already exists",
-				Status:  "conflict",
-			})
-		}
-		if err, ok := err.(util.ValidateError); ok {
-			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusBadRequest,
-				Message: "Validation error in request body",
-				Status:  "bad_request",
-				Details: err,
-			})
-		}
-
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to create Agent", "error", err)
-
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to create Agent",
-			Status:  "error",
-		})
-	}
-	createdAgentID := createdAgent.UID.V.OBJECT_VERSION_ID().Value
-
-	auditCtx.Success()
-
-	h.WebhookSink.Enqueue(webhook.EventTypeAgentCreated, map[string]any{
-		"agent_uid": createdAgentID,
-	})
-
-	c.Set("ETag", "\""+createdAgentID+"\"")
-	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/agent/"+createdAgentID)
-
-	switch returnType {
-	case ReturnTypeMinimal:
-		c.Status(fiber.StatusCreated)
-		return nil
-	case ReturnTypeRepresentation:
-		return c.Status(fiber.StatusCreated).JSON(createdAgent)
-	case ReturnTypeIdentifier:
-		return c.Status(fiber.StatusCreated).JSON(`{"uid":"` + createdAgentID + `"}`)
-	default:
-		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
-		return c.Status(fiber.StatusCreated).JSON(createdAgent)
-	}
-}
-
-func (h *Handler) GetAgent(c *fiber.Ctx) error {
-	ctx := c.Context()
-	auditCtx := middleware.AuditFrom(c)
-
-	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
-	if err != nil {
-		return err
-	}
-	auditCtx.Event.Details["uid_based_id"] = uidBasedID
-
-	var agentJSON []byte
-	if uuid.Validate(uidBasedID) == nil {
-		agentJSON, err = h.OpenEHRService.GetAgentByVersionedPartyIDRawJSON(ctx, uuid.MustParse(uidBasedID))
-	} else {
-		agentJSON, err = h.OpenEHRService.GetAgentAtVersionRawJSON(ctx, uidBasedID)
-	}
-	if err != nil {
-		if err == ErrAgentNotFound {
+		if err == ErrEHRNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Agent not found for the given agent ID",
+				Message: "EHR not found for the given EHR ID",
 				Status:  "not_found",
 			})
 		}
-
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Agent by ID", "error", err)
-
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get Agent by ID",
-			Status:  "error",
-		})
-	}
-
-	auditCtx.Success()
-
-	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(agentJSON)
-}
-
-func (h *Handler) UpdateAgent(c *fiber.Ctx) error {
-	ctx := c.Context()
-	auditCtx := middleware.AuditFrom(c)
-
-	err := Accepts(c, auditCtx, "application/json")
-	if err != nil {
-		return err
-	}
-
-	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
-	if err != nil {
-		return err
-	}
-	auditCtx.Event.Details["uid_based_id"] = uidBasedID
-
-	ifMatch, err := StringFromHeader(c, auditCtx, "If-Match")
-	if err != nil {
-		return err
-	}
-
-	returnType, err := ReturnTypeFromHeader(c, auditCtx)
-	if err != nil {
-		return err
-	}
-
-	versionedPartyID, err := uuid.Parse(strings.Split(uidBasedID, "::")[0])
-	if err != nil {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "Invalid uid_based_id format",
-			Status:  "bad_request",
-		})
-	}
-
-	var agent rm.AGENT
-	if err := ParseBody(c, auditCtx, &agent); err != nil {
-		return err
-	}
-
-	currentAgentID, err := h.OpenEHRService.GetAgentID(ctx, versionedPartyID)
-	if err != nil {
-		if err == ErrAgentNotFound {
+		if err == ErrDirectoryNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Agent not found for the given agent ID",
+				Message: "Directory not found for the given EHR ID",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Agent by ID", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Directory", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get current Agent by ID",
+			Message: "Failed to get current Directory",
 			Status:  "error",
 		})
 	}
-	if currentAgentID.Value != ifMatch {
+	if !ETagMatches(ifMatch, currentDirectoryID.Value) {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusPreconditionFailed,
-			Message: "Agent has been modified since the provided version",
+			Message: "Directory has been modified since the provided version",
 			Status:  "precondition_failed",
 		})
 	}
 
-	updatedAgent, err := h.OpenEHRService.UpdateAgent(ctx, currentAgentID, agent)
+	updatedDirectory, err := h.OpenEHRService.UpdateDirectory(ctx, ehrID, currentDirectoryID, directory)
 	if err != nil {
-		if err == ErrAgentNotFound {
+		if err == ErrEHRNotModifiable {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "EHR is not modifiable, its EHR Status has is_modifiable set to false",
+				Status:  "conflict",
+			})
+		}
+		if err == ErrDirectoryNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Agent not found for the given agent ID",
+				Message: "Directory not found for the given EHR ID",
 				Status:  "not_found",
 			})
 		}
-		if err == ErrAgentVersionLowerOrEqualToCurrent {
+		if err == ErrDirectoryAlreadyExists {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusConflict,
-				Message: "Agent version is lower than or equal to the current version",
+				Message: "Directory with the given UID already exists",
 				Status:  "conflict",
 			})
 		}
-		if err == ErrInvalidAgentUIDMismatch {
+		if err == ErrDirectoryVersionLowerOrEqualToCurrent {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusBadRequest,
-				Message: "Agent UID HIER_OBJECT_ID in request body does not match current Agent UID",
+				Message: "Directory version in request body is lower or equal to the current version",
 				Status:  "bad_request",
 			})
 		}
-		if validationErrs, ok := err.(util.ValidateError); ok {
+		if err == ErrInvalidDirectoryUIDMismatch {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusBadRequest,
-				Message: "Validation error in request body",
+				Message: "Directory UID HIER_OBJECT_ID in request body does not match current Directory UID",
 				Status:  "bad_request",
-				Details: validationErrs,
 			})
 		}
+		if validationErrs, ok := err.(util.ValidateError); ok {
+			return c.Status(fiber.StatusBadRequest).JSON(validationErrs)
+		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to update Agent", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to update Directory", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to update Agent",
+			Message: "Failed to update Directory",
 			Status:  "error",
 		})
 	}
-	updatedAgentID := updatedAgent.UID.V.OBJECT_VERSION_ID().Value
+	updatedDirectoryID := updatedDirectory.UID.V.OBJECT_VERSION_ID().Value
 
 	auditCtx.Success()
 
-	h.WebhookSink.Enqueue(webhook.EventTypeAgentUpdated, map[string]any{
-		"prev_agent_uid": currentAgentID.Value,
-		"curr_agent_uid": updatedAgentID,
+	h.WebhookSink.Enqueue(webhook.EventTypeDirectoryUpdated, map[string]any{
+		"prev_directory_id": currentDirectoryID.Value,
+		"curr_directory_id": updatedDirectoryID,
 	})
 
-	c.Set("ETag", "\""+updatedAgentID+"\"")
-	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/agent/"+updatedAgentID)
+	c.Set("ETag", "\""+updatedDirectoryID+"\"")
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/ehr/"+ehrID.String()+"/directory/"+updatedDirectoryID)
 
 	switch returnType {
 	case ReturnTypeMinimal:
 		c.Status(fiber.StatusNoContent)
 		return nil
 	case ReturnTypeRepresentation:
-		return c.Status(fiber.StatusOK).JSON(updatedAgent)
+		return SendRepresentation(c, fiber.StatusOK, updatedDirectory)
 	case ReturnTypeIdentifier:
-		return c.Status(fiber.StatusOK).JSON(`{"uid":"` + updatedAgentID + `"}`)
+		return c.Status(fiber.StatusOK).JSON(`{"uid":"` + updatedDirectoryID + `"}`)
 	default:
 		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
-		return c.Status(fiber.StatusOK).JSON(updatedAgent)
+		return SendRepresentation(c, fiber.StatusOK, updatedDirectory)
 	}
 }
 
-func (h *Handler) DeleteAgent(c *fiber.Ctx) error {
+func (h *Handler) DeleteDirectory(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
-	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
 	if err != nil {
 		return err
 	}
-	auditCtx.Event.Details["uid_based_id"] = uidBasedID
-
-	if strings.Count(uidBasedID, "::") != 2 {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "Cannot delete Agent by versioned object ID. Please provide the object version ID.",
-			Status:  "bad_request",
-		})
-	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	versionedPartyID, err := uuid.Parse(strings.Split(uidBasedID, "::")[0])
+	ifMatch, err := StringFromHeader(c, auditCtx, "If-Match")
 	if err != nil {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "Invalid uid_based_id format",
-			Status:  "bad_request",
-		})
+		return err
 	}
 
-	currentAgentID, err := h.OpenEHRService.GetAgentID(ctx, versionedPartyID)
+	currentDirectoryID, err := h.OpenEHRService.GetDirectoryID(ctx, ehrID)
 	if err != nil {
-		if err == ErrAgentNotFound {
+		if err == ErrEHRNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Agent not found for the given agent ID",
+				Message: "EHR not found for the given EHR ID",
+				Status:  "not_found",
+			})
+		}
+		if err == ErrDirectoryNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Directory not found for the given EHR ID",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Agent by ID before deletion", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Directory", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get Agent by ID before deletion",
+			Message: "Failed to get current Directory",
 			Status:  "error",
 		})
 	}
-	if currentAgentID.Value != uidBasedID {
+	if !ETagMatches(ifMatch, currentDirectoryID.Value) {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusPreconditionFailed,
-			Message: "Agent has been modified since the provided version",
+			Message: "Directory has been modified since the provided version",
 			Status:  "precondition_failed",
 		})
 	}
 
-	if err := h.OpenEHRService.DeleteAgent(ctx, uuid.MustParse(currentAgentID.UID())); err != nil {
-		if err == ErrAgentNotFound {
+	if err := h.OpenEHRService.DeleteDirectory(ctx, ehrID, uuid.MustParse(currentDirectoryID.Value)); err != nil {
+		if err == ErrEHRNotModifiable {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "EHR is not modifiable, its EHR Status has is_modifiable set to false",
+				Status:  "conflict",
+			})
+		}
+		if err == ErrEHRNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Agent not found for the given agent ID",
+				Message: "EHR not found for the given EHR ID",
+				Status:  "not_found",
+			})
+		}
+		if err == ErrDirectoryNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Directory not found for the given EHR ID",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete Agent by ID", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete Directory", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to delete Agent by ID",
+			Message: "Failed to delete Directory",
 			Status:  "error",
 		})
 	}
 
 	auditCtx.Success()
 
-	h.WebhookSink.Enqueue(webhook.EventTypeAgentDeleted, map[string]any{
-		"versioned_party_id": versionedPartyID,
+	h.WebhookSink.Enqueue(webhook.EventTypeDirectoryDeleted, map[string]any{
+		"directory_id": currentDirectoryID.UID(),
 	})
 
 	c.Status(fiber.StatusNoContent)
 	return nil
 }
 
-func (h *Handler) CreateGroup(c *fiber.Ctx) error {
+func (h *Handler) GetFolderInDirectoryVersionAtTime(c *fiber.Ctx) error {
 	ctx := c.Context()
-	auditCtx := middleware.AuditFrom(c)
 
-	err := Accepts(c, auditCtx, "application/json")
-	if err != nil {
-		return err
-	}
+	auditCtx := middleware.AuditFrom(c)
 
-	returnType, err := ReturnTypeFromHeader(c, auditCtx)
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
 	if err != nil {
 		return err
 	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	var group rm.GROUP
-	if err := ParseBody(c, auditCtx, &group); err != nil {
-		return err
-	}
-
-	createdGroup, err := h.OpenEHRService.CreateGroup(ctx, group)
+	var filterAtTime time.Time
+	atTimeStr := c.Query("version_at_time")
+	if atTimeStr != "" {
+		parsedTime, err := TimeFromQuery(c, auditCtx, "version_at_time")
+		if err != nil {
+			return err
+		}
+		filterAtTime = parsedTime
+	}
+
+	path := c.Query("path")
+
+	folderJSON, err := h.OpenEHRService.GetFolderAtTimeRawJSON(ctx, ehrID, filterAtTime, path)
 	if err != nil {
-		if err == ErrGroupAlreadyExists {
+		if err == ErrEHRNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusConflict,
-				Message: "Group with the given UID already exists",
-				Status:  "conflict",
+				Code:    fiber.StatusNotFound,
+				Message: "Directory not found for the given EHR ID at the specified time",
+				Status:  "not_found",
 			})
 		}
-		if err, ok := err.(util.ValidateError); ok {
-			return c.Status(fiber.StatusBadRequest).JSON(err)
+		if err == ErrDirectoryNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Directory version not found at the specified time for the given EHR ID",
+				Status:  "not_found",
+			})
+		}
+		if err == ErrFolderNotFoundInDirectory {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Folder not found in Directory version at the specified time for the given path",
+				Status:  "not_found",
+			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to create Group", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Folder in Directory version at time", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to create Group",
+			Message: "Failed to get Folder in Directory version at time",
 			Status:  "error",
 		})
 	}
-	createdGroupID := createdGroup.UID.V.OBJECT_VERSION_ID().Value
 
 	auditCtx.Success()
 
-	h.WebhookSink.Enqueue(webhook.EventTypeGroupCreated, map[string]any{
-		"group_uid": createdGroupID,
-	})
-
-	c.Set("ETag", "\""+createdGroupID+"\"")
-	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/group/"+createdGroupID)
-
-	switch returnType {
-	case ReturnTypeMinimal:
-		c.Status(fiber.StatusCreated)
-		return nil
-	case ReturnTypeRepresentation:
-		return c.Status(fiber.StatusCreated).JSON(createdGroup)
-	case ReturnTypeIdentifier:
-		return c.Status(fiber.StatusCreated).JSON(`{"uid":"` + createdGroupID + `"}`)
-	default:
-		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
-		return c.Status(fiber.StatusCreated).JSON(createdGroup)
-	}
+	return SendRawRepresentation[rm.FOLDER](c, fiber.StatusOK, folderJSON)
 }
 
-func (h *Handler) GetGroup(c *fiber.Ctx) error {
+func (h *Handler) GetFolderInDirectoryVersion(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
-	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
 	if err != nil {
 		return err
 	}
-	auditCtx.Event.Details["uid_based_id"] = uidBasedID
+	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	var groupJSON []byte
-	if uuid.Validate(uidBasedID) == nil {
-		groupJSON, err = h.OpenEHRService.GetGroupByVersionedPartyIDRawJSON(ctx, uuid.MustParse(uidBasedID))
-	} else {
-		groupJSON, err = h.OpenEHRService.GetGroupRawJSON(ctx, uidBasedID)
+	versionUID, err := UUIDFromPath(c, auditCtx, "version_uid")
+	if err != nil {
+		return err
 	}
+	auditCtx.Event.Details["version_uid"] = versionUID
+
+	path := c.Query("path")
+
+	folderJSON, err := h.OpenEHRService.GetFolderInDirectoryByIDRawJSON(ctx, ehrID, versionUID, path)
 	if err != nil {
-		if err == ErrGroupNotFound {
+		if err == ErrEHRNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Group not found for the given group ID",
+				Message: "Directory not found for the given EHR ID",
+				Status:  "not_found",
+			})
+		}
+		if err == ErrDirectoryNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Directory version not found at the specified time for the given EHR ID",
+				Status:  "not_found",
+			})
+		}
+		if err == ErrFolderNotFoundInDirectory {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Folder not found in Directory version at the specified time for the given path",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Group by ID", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Folder in Directory version", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get Group by ID",
+			Message: "Failed to get Folder in Directory version",
 			Status:  "error",
 		})
 	}
 
 	auditCtx.Success()
 
-	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(groupJSON)
+	return SendRawRepresentation[rm.FOLDER](c, fiber.StatusOK, folderJSON)
 }
 
-func (h *Handler) UpdateGroup(c *fiber.Ctx) error {
-	ctx := c.Context()
+func (h *Handler) CreateDirectoryFolder(c *fiber.Ctx) error {
 	auditCtx := middleware.AuditFrom(c)
 
-	err := Accepts(c, auditCtx, "application/json")
-	if err != nil {
+	path := c.Query("path")
+	auditCtx.Event.Details["path"] = path
+
+	var folder rm.FOLDER
+	if err := ParseBody(c, auditCtx, &folder); err != nil {
 		return err
 	}
 
-	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
-	if err != nil {
+	return h.changeDirectory(c, "create_folder", func(ctx context.Context, ehrID uuid.UUID, currentDirectoryID rm.OBJECT_VERSION_ID) (rm.FOLDER, error) {
+		return h.OpenEHRService.CreateDirectoryFolder(ctx, ehrID, currentDirectoryID, path, folder)
+	})
+}
+
+type MoveDirectoryFolderRequest struct {
+	Name       utils.Optional[string] `json:"name,omitzero"`
+	ParentPath utils.Optional[string] `json:"parent_path,omitzero"`
+}
+
+func (h *Handler) MoveDirectoryFolder(c *fiber.Ctx) error {
+	auditCtx := middleware.AuditFrom(c)
+
+	path := c.Query("path")
+	auditCtx.Event.Details["path"] = path
+
+	var req MoveDirectoryFolderRequest
+	if err := ParseBody(c, auditCtx, &req); err != nil {
 		return err
 	}
+	if !req.Name.E && !req.ParentPath.E {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Either name or parent_path must be provided",
+			Status:  "bad_request",
+		})
+	}
+	if req.ParentPath.E {
+		auditCtx.Event.Details["parent_path"] = req.ParentPath.V
+	}
 
-	ifMatch, err := StringFromHeader(c, auditCtx, "If-Match")
+	return h.changeDirectory(c, "move_folder", func(ctx context.Context, ehrID uuid.UUID, currentDirectoryID rm.OBJECT_VERSION_ID) (rm.FOLDER, error) {
+		return h.OpenEHRService.MoveDirectoryFolder(ctx, ehrID, currentDirectoryID, path, req.Name, req.ParentPath)
+	})
+}
+
+func (h *Handler) AddDirectoryItem(c *fiber.Ctx) error {
+	auditCtx := middleware.AuditFrom(c)
+
+	path := c.Query("path")
+	auditCtx.Event.Details["path"] = path
+
+	var item rm.OBJECT_REF
+	if err := ParseBody(c, auditCtx, &item); err != nil {
+		return err
+	}
+
+	return h.changeDirectory(c, "add_item", func(ctx context.Context, ehrID uuid.UUID, currentDirectoryID rm.OBJECT_VERSION_ID) (rm.FOLDER, error) {
+		return h.OpenEHRService.AddDirectoryItem(ctx, ehrID, currentDirectoryID, path, item)
+	})
+}
+
+func (h *Handler) RemoveDirectoryItem(c *fiber.Ctx) error {
+	auditCtx := middleware.AuditFrom(c)
+
+	path := c.Query("path")
+	auditCtx.Event.Details["path"] = path
+
+	itemID := c.Query("id")
+	if itemID == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Query parameter id is required",
+			Status:  "bad_request",
+		})
+	}
+	auditCtx.Event.Details["item_id"] = itemID
+
+	return h.changeDirectory(c, "remove_item", func(ctx context.Context, ehrID uuid.UUID, currentDirectoryID rm.OBJECT_VERSION_ID) (rm.FOLDER, error) {
+		return h.OpenEHRService.RemoveDirectoryItem(ctx, ehrID, currentDirectoryID, path, itemID)
+	})
+}
+
+// changeDirectory runs a folder-level operation against the directory version named in If-Match and responds like UpdateDirectory
+func (h *Handler) changeDirectory(c *fiber.Ctx, operation string, change func(ctx context.Context, ehrID uuid.UUID, currentDirectoryID rm.OBJECT_VERSION_ID) (rm.FOLDER, error)) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+	auditCtx.Event.Details["operation"] = operation
+
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
 	if err != nil {
 		return err
 	}
 
-	returnType, err := ReturnTypeFromHeader(c, auditCtx)
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
 	if err != nil {
 		return err
 	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	versionedPartyID, err := uuid.Parse(strings.Split(uidBasedID, "::")[0])
+	ifMatch, err := StringFromHeader(c, auditCtx, "If-Match")
 	if err != nil {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "Invalid uid_based_id format",
-			Status:  "bad_request",
-		})
+		return err
 	}
 
-	var group rm.GROUP
-	if err := ParseBody(c, auditCtx, &group); err != nil {
+	returnType, err := ReturnTypeFromHeader(c, auditCtx)
+	if err != nil {
 		return err
 	}
 
-	currentGroupID, err := h.OpenEHRService.GetGroupID(ctx, versionedPartyID)
+	currentDirectoryID, err := h.OpenEHRService.GetDirectoryID(ctx, ehrID)
 	if err != nil {
-		if err == ErrGroupNotFound {
+		if err == ErrDirectoryNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Group not found for the given group ID",
+				Message: "Directory not found for the given EHR ID",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Group by ID", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Directory", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get current Group by ID",
+			Message: "Failed to get current Directory",
 			Status:  "error",
 		})
 	}
-	if currentGroupID.Value != ifMatch {
+	if !ETagMatches(ifMatch, currentDirectoryID.Value) {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusPreconditionFailed,
-			Message: "Group has been modified since the provided version",
+			Message: "Directory has been modified since the provided version",
 			Status:  "precondition_failed",
 		})
 	}
 
-	updatedGroup, err := h.OpenEHRService.UpdateGroup(ctx, currentGroupID, group)
+	updatedDirectory, err := change(ctx, ehrID, currentDirectoryID)
 	if err != nil {
-		if err == ErrGroupNotFound {
+		if err == ErrEHRNotModifiable {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "EHR is not modifiable, its EHR Status has is_modifiable set to false",
+				Status:  "conflict",
+			})
+		}
+		if err == ErrEHRNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Group not found for the given group ID",
+				Message: "EHR not found for the given EHR ID",
 				Status:  "not_found",
 			})
 		}
-		if err == ErrGroupVersionLowerOrEqualToCurrent {
+		if err == ErrDirectoryNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Directory not found for the given EHR ID",
+				Status:  "not_found",
+			})
+		}
+		if err == ErrDirectoryVersionConflict {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusPreconditionFailed,
+				Message: "Directory has been modified since the provided version",
+				Status:  "precondition_failed",
+			})
+		}
+		if err == ErrFolderNotFoundInDirectory {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Folder not found in Directory for the given path",
+				Status:  "not_found",
+			})
+		}
+		if err == ErrDirectoryItemNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Item with the given id not found in the Folder",
+				Status:  "not_found",
+			})
+		}
+		if err == ErrDirectoryItemAlreadyExists {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusConflict,
-				Message: "Group version is lower than or equal to the current version",
+				Message: "Item with the given id already exists in the Folder",
 				Status:  "conflict",
 			})
 		}
-		if err == ErrInvalidGroupUIDMismatch {
+		if err == ErrDirectoryFolderAlreadyExists {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusBadRequest,
-				Message: "Group UID HIER_OBJECT_ID in request body does not match current Group UID",
-				Status:  "bad_request",
+				Code:    fiber.StatusConflict,
+				Message: "Folder with the given name already exists in the parent Folder",
+				Status:  "conflict",
 			})
 		}
-		if validationErrs, ok := err.(util.ValidateError); ok {
+		if err == ErrDirectoryFolderMoveInvalid {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusBadRequest,
-				Message: "Validation error in request body",
+				Message: "Folder cannot be moved into itself or one of its sub-folders, and the root Folder cannot be moved",
 				Status:  "bad_request",
-				Details: validationErrs,
 			})
 		}
+		if validationErrs, ok := err.(util.ValidateError); ok {
+			return c.Status(fiber.StatusBadRequest).JSON(validationErrs)
+		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to update Agent", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to change Directory", "operation", operation, "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to update Agent",
+			Message: "Failed to change Directory",
 			Status:  "error",
 		})
 	}
-	updatedGroupID := updatedGroup.UID.V.OBJECT_VERSION_ID().Value
+	updatedDirectoryID := updatedDirectory.UID.V.OBJECT_VERSION_ID().Value
 
 	auditCtx.Success()
 
-	h.WebhookSink.Enqueue(webhook.EventTypeGroupUpdated, map[string]any{
-		"prev_group_uid": currentGroupID.Value,
-		"curr_group_uid": updatedGroupID,
+	h.WebhookSink.Enqueue(webhook.EventTypeDirectoryUpdated, map[string]any{
+		"prev_directory_id": currentDirectoryID.Value,
+		"curr_directory_id": updatedDirectoryID,
+		"operation":         operation,
 	})
 
-	c.Set("ETag", "\""+updatedGroupID+"\"")
-	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/group/"+updatedGroupID)
+	c.Set("ETag", "\""+updatedDirectoryID+"\"")
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/ehr/"+ehrID.String()+"/directory/"+updatedDirectoryID)
 
 	switch returnType {
 	case ReturnTypeMinimal:
 		c.Status(fiber.StatusNoContent)
 		return nil
 	case ReturnTypeRepresentation:
-		return c.Status(fiber.StatusOK).JSON(updatedGroup)
+		return SendRepresentation(c, fiber.StatusOK, updatedDirectory)
 	case ReturnTypeIdentifier:
-		return c.Status(fiber.StatusOK).JSON(`{"uid":"` + updatedGroupID + `"}`)
+		return c.Status(fiber.StatusOK).JSON(`{"uid":"` + updatedDirectoryID + `"}`)
 	default:
 		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
-		return c.Status(fiber.StatusOK).JSON(updatedGroup)
+		return SendRepresentation(c, fiber.StatusOK, updatedDirectory)
 	}
 }
 
-func (h *Handler) DeleteGroup(c *fiber.Ctx) error {
+func (h *Handler) ListDirectoryCompositions(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
-	versionUID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
 	if err != nil {
 		return err
 	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	if strings.Count(versionUID, "::") != 2 {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "Cannot delete Group by versioned object ID. Please provide the object version ID.",
-			Status:  "bad_request",
-		})
-	}
+	path := c.Query("path")
+	auditCtx.Event.Details["path"] = path
 
-	versionedPartyID, err := uuid.Parse(strings.Split(versionUID, "::")[0])
+	compositionsJSON, err := h.OpenEHRService.ListDirectoryCompositionsRawJSON(ctx, ehrID, path, c.QueryBool("recursive"))
 	if err != nil {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "Invalid uid_based_id format",
-			Status:  "bad_request",
-		})
-	}
-
-	currentGroupID, err := h.OpenEHRService.GetGroupID(ctx, versionedPartyID)
-	if err != nil {
-		if err == ErrGroupNotFound {
+		if err == ErrDirectoryNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Group not found for the given group ID",
+				Message: "Directory not found for the given EHR ID",
 				Status:  "not_found",
 			})
 		}
-
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Group by ID before deletion", "error", err)
-
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get Group by ID before deletion",
-			Status:  "error",
-		})
-	}
-	if currentGroupID.Value != versionUID {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusPreconditionFailed,
-			Message: "Group has been modified since the provided version",
-			Status:  "precondition_failed",
-		})
-	}
-
-	if err := h.OpenEHRService.DeleteGroup(ctx, versionedPartyID); err != nil {
-		if err == ErrGroupNotFound {
+		if err == ErrFolderNotFoundInDirectory {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Group not found for the given group ID",
+				Message: "Folder not found in Directory for the given path",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete Group by ID", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to list Directory compositions", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to delete Group by ID",
+			Message: "Failed to list Directory compositions",
 			Status:  "error",
 		})
 	}
 
 	auditCtx.Success()
 
-	h.WebhookSink.Enqueue(webhook.EventTypeGroupDeleted, map[string]any{
-		"versioned_party_id": versionedPartyID,
-	})
+	c.Set("Content-Type", "application/json")
+	return c.Status(fiber.StatusOK).Send(compositionsJSON)
+}
 
-	c.Status(fiber.StatusNoContent)
-	return nil
+type AttachmentResponse struct {
+	Attachment
+	URI string `json:"uri"`
 }
 
-func (h *Handler) CreatePerson(c *fiber.Ctx) error {
+func (h *Handler) CreateAttachment(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
-	err := Accepts(c, auditCtx, "application/json")
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
 	if err != nil {
 		return err
 	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	returnType, err := ReturnTypeFromHeader(c, auditCtx)
-	if err != nil {
-		return err
+	mediaType := c.Get(fiber.HeaderContentType)
+	if mediaType == "" {
+		mediaType = fiber.MIMEOctetStream
+	}
+	if _, _, err := mime.ParseMediaType(mediaType); err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Invalid Content-Type header",
+			Status:  "bad_request",
+		})
 	}
 
-	var person rm.PERSON
-	if err := ParseBody(c, auditCtx, &person); err != nil {
-		return err
+	// Stream the body when the server is configured for it, so large uploads are not held in memory
+	var content io.Reader = c.Context().RequestBodyStream()
+	if content == nil {
+		content = bytes.NewReader(c.Body())
 	}
 
-	createdPerson, err := h.OpenEHRService.CreatePerson(ctx, person)
+	createdAttachment, err := h.OpenEHRService.CreateAttachment(ctx, h.AttachmentStore, ehrID, mediaType, content, h.Settings.AttachmentMaxSize)
 	if err != nil {
-		if err == ErrPersonAlreadyExists {
+		if err == ErrEHRNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "EHR not found for the given EHR ID",
+				Status:  "not_found",
+			})
+		}
+		if err == ErrEHRNotModifiable {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusConflict,
-				Message: "Person with the given UID already exists",
+				Message: "EHR is not modifiable, its EHR Status has is_modifiable set to false",
 				Status:  "conflict",
 			})
 		}
-		if validationErrs, ok := err.(util.ValidateError); ok {
+		if err == ErrAttachmentEmpty {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusBadRequest,
-				Message: "Validation error in request body",
+				Message: "Attachment content is empty",
 				Status:  "bad_request",
-				Details: validationErrs,
+			})
+		}
+		if err == ErrAttachmentTooLarge {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusRequestEntityTooLarge,
+				Message: fmt.Sprintf("Attachment exceeds the maximum size of %d bytes", h.Settings.AttachmentMaxSize),
+				Status:  "request_entity_too_large",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to create Person", "error", err)
-
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to create Attachment", "error", err)
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to create Person",
+			Message: "Internal server error",
 			Status:  "error",
 		})
 	}
-	createdPersonID := createdPerson.UID.V.OBJECT_VERSION_ID().Value
+	auditCtx.Event.Details["attachment_id"] = createdAttachment.ID
 
 	auditCtx.Success()
 
-	h.WebhookSink.Enqueue(webhook.EventTypePersonCreated, map[string]any{
-		"person_uid": createdPersonID,
+	h.WebhookSink.Enqueue(webhook.EventTypeAttachmentCreated, map[string]any{
+		"ehr_id":        ehrID,
+		"attachment_id": createdAttachment.ID,
 	})
 
-	c.Set("ETag", "\""+createdPersonID+"\"")
-	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/person/"+createdPersonID)
+	uri := c.Protocol() + "://" + c.Hostname() + "/openehr/v1/ehr/" + ehrID.String() + "/attachment/" + createdAttachment.ID.String()
+	c.Set("Location", uri)
+	c.Set("ETag", "\""+createdAttachment.Hash+"\"")
 
-	switch returnType {
-	case ReturnTypeMinimal:
-		c.Status(fiber.StatusCreated)
-		return nil
-	case ReturnTypeRepresentation:
-		return c.Status(fiber.StatusCreated).JSON(createdPerson)
-	case ReturnTypeIdentifier:
-		return c.Status(fiber.StatusCreated).JSON(`{"uid":"` + createdPersonID + `"}`)
-	default:
-		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
-		return c.Status(fiber.StatusCreated).JSON(createdPerson)
-	}
+	return c.Status(fiber.StatusCreated).JSON(AttachmentResponse{
+		Attachment: createdAttachment,
+		URI:        uri,
+	})
 }
 
-func (h *Handler) GetPerson(c *fiber.Ctx) error {
+func (h *Handler) GetAttachment(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
-	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
 	if err != nil {
 		return err
 	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	var personJSON []byte
-	if uuid.Validate(uidBasedID) == nil {
-		personJSON, err = h.OpenEHRService.GetPersonByVersionedPartyIDRawJSON(ctx, uuid.MustParse(uidBasedID))
-	} else {
-		personJSON, err = h.OpenEHRService.GetPersonByIDRawJSON(ctx, uidBasedID)
+	attachmentID, err := UUIDFromPath(c, auditCtx, "attachment_id")
+	if err != nil {
+		return err
 	}
+	auditCtx.Event.Details["attachment_id"] = attachmentID
+
+	foundAttachment, err := h.OpenEHRService.GetAttachment(ctx, ehrID, attachmentID)
 	if err != nil {
-		if err == ErrPersonNotFound {
+		if err == ErrAttachmentNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Person not found for the given person ID",
+				Message: "Attachment not found for the given EHR ID and attachment ID",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Person by ID", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Attachment", "error", err)
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Status:  "error",
+		})
+	}
+
+	// Content is addressed by its hash, so the hash is a strong ETag
+	c.Set("ETag", "\""+foundAttachment.Hash+"\"")
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+
+	if ifNoneMatch := c.Get(fiber.HeaderIfNoneMatch); ifNoneMatch != "" && ETagMatches(ifNoneMatch, foundAttachment.Hash) {
+		auditCtx.Success()
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	status := fiber.StatusOK
+	offset, length := int64(0), foundAttachment.Size
+
+	rangeHeader := c.Get(fiber.HeaderRange)
+	ifRange := c.Get(fiber.HeaderIfRange)
+	if rangeHeader != "" && (ifRange == "" || ETagMatches(ifRange, foundAttachment.Hash)) {
+		start, end, ok, satisfiable := parseByteRange(rangeHeader, foundAttachment.Size)
+		if ok && !satisfiable {
+			c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", foundAttachment.Size))
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusRequestedRangeNotSatisfiable,
+				Message: "Requested range not satisfiable",
+				Status:  "range_not_satisfiable",
+			})
+		}
+		if ok {
+			status = fiber.StatusPartialContent
+			offset, length = start, end-start+1
+			c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", start, end, foundAttachment.Size))
+		}
+	}
 
+	content, err := h.AttachmentStore.Get(ctx, foundAttachment.Hash, offset, length)
+	if err != nil {
+		if err == attachment.ErrNotFound {
+			h.Telemetry.Logger.ErrorContext(ctx, "Attachment content missing from store", "attachment_id", attachmentID, "hash", foundAttachment.Hash)
+		} else {
+			h.Telemetry.Logger.ErrorContext(ctx, "Failed to read Attachment content", "error", err)
+		}
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get Person by ID",
+			Message: "Internal server error",
 			Status:  "error",
 		})
 	}
 
 	auditCtx.Success()
 
-	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(personJSON)
+	c.Set(fiber.HeaderContentType, foundAttachment.MediaType)
+	return c.Status(status).SendStream(content, int(length))
 }
 
-func (h *Handler) UpdatePerson(c *fiber.Ctx) error {
-	ctx := c.Context()
-	auditCtx := middleware.AuditFrom(c)
+// parseByteRange parses a Range header holding a single byte range against content of the given size.
+// Headers that are malformed, use another unit or ask for multiple ranges are not ok and should be ignored,
+// a valid range that lies beyond the content is ok but not satisfiable.
+func parseByteRange(header string, size int64) (start, end int64, ok, satisfiable bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false, false
+	}
 
-	err := Accepts(c, auditCtx, "application/json")
-	if err != nil {
-		return err
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false, false
 	}
 
-	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
-	if err != nil {
-		return err
+	if first == "" {
+		// Suffix range, the last n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, false, false
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, true, false
+		}
+		return max(size-n, 0), size - 1, true, true
 	}
 
-	ifMatch, err := StringFromHeader(c, auditCtx, "If-Match")
-	if err != nil {
-		return err
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false, false
+	}
+	end = size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false, false
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return 0, 0, true, false
 	}
 
-	returnType, err := ReturnTypeFromHeader(c, auditCtx)
+	return start, end, true, true
+}
+
+func (h *Handler) CreateContribution(c *fiber.Ctx) error {
+	return h.commitContribution(c, false)
+}
+
+func (h *Handler) ImportContribution(c *fiber.Ctx) error {
+	return h.commitContribution(c, true)
+}
+
+func (h *Handler) commitContribution(c *fiber.Ctx, imported bool) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
 	if err != nil {
 		return err
 	}
 
-	versionedPartyID, err := uuid.Parse(strings.Split(uidBasedID, "::")[0])
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
 	if err != nil {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "Invalid uid_based_id format",
-			Status:  "bad_request",
-		})
+		return err
 	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	var person rm.PERSON
-	if err := ParseBody(c, auditCtx, &person); err != nil {
+	returnType, err := ReturnTypeFromHeader(c, auditCtx)
+	if err != nil {
 		return err
 	}
 
-	currentPersonID, err := h.OpenEHRService.GetCurrentPersonID(ctx, versionedPartyID)
+	var contribution rm.CONTRIBUTION
+	var changes []ContributionChange
+	if imported {
+		var request ImportContributionRequest
+		if err := ParseBody(c, auditCtx, &request); err != nil {
+			return err
+		}
+		contribution, changes, err = h.OpenEHRService.ImportContribution(ctx, ehrID, request)
+	} else {
+		var request ContributionRequest
+		if err := ParseBody(c, auditCtx, &request); err != nil {
+			return err
+		}
+		contribution, changes, err = h.OpenEHRService.CommitContribution(ctx, ehrID, request)
+	}
 	if err != nil {
-		if err == ErrPersonNotFound {
+		if err == ErrEHRNotModifiable {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusNotFound,
-				Message: "Person not found for the given person ID",
-				Status:  "not_found",
+				Code:    fiber.StatusConflict,
+				Message: "EHR is not modifiable, its EHR Status has is_modifiable set to false",
+				Status:  "conflict",
 			})
 		}
-
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Person by ID", "error", err)
-
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get current Person",
-			Status:  "error",
-		})
-	}
-	if currentPersonID.Value != ifMatch {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusPreconditionFailed,
-			Message: "Person has been modified since the provided version",
-			Status:  "precondition_failed",
-		})
-	}
-
-	updatedPerson, err := h.OpenEHRService.UpdatePerson(ctx, currentPersonID, person)
-	if err != nil {
-		if err == ErrPersonNotFound {
+		if err == ErrEHRNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Person not found for the given person ID",
+				Message: "EHR not found for the given EHR ID",
 				Status:  "not_found",
 			})
 		}
-		if err == ErrPersonVersionLowerOrEqualToCurrent {
+		if err == ErrContributionAlreadyExists {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusConflict,
-				Message: "Person version is lower than or equal to the current version",
+				Message: "Contribution with the given UID already exists",
 				Status:  "conflict",
 			})
 		}
-		if err == ErrInvalidPersonUIDMismatch {
+		if err == ErrDirectoryAlreadyExists {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "Directory already exists for the given EHR ID",
+				Status:  "conflict",
+			})
+		}
+		if errors.Is(err, ErrVersionedObjectNotFound) {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: err.Error(),
+				Status:  "not_found",
+			})
+		}
+		if errors.Is(err, ErrContributionVersionConflict) {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusPreconditionFailed,
+				Message: err.Error(),
+				Status:  "precondition_failed",
+			})
+		}
+		if errors.Is(err, ErrContributionInvalid) {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusBadRequest,
-				Message: "Person UID HIER_OBJECT_ID in request body does not match current Person UID",
+				Message: err.Error(),
+				Status:  "bad_request",
+			})
+		}
+		if err == ErrVersionLowerOrEqualToCurrent {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Version in request body is lower or equal to the preceding version",
 				Status:  "bad_request",
 			})
 		}
@@ -2896,120 +3065,204 @@ func (h *Handler) UpdatePerson(c *fiber.Ctx) error {
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to update Person", "error", err)
-
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to commit Contribution", "error", err)
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to update Person",
+			Message: "Internal server error",
 			Status:  "error",
 		})
 	}
-	updatedPersonID := updatedPerson.UID.V.OBJECT_VERSION_ID().Value
+	contributionID := contribution.UID.Value
+	auditCtx.Event.Details["contribution_uid"] = contributionID
 
 	auditCtx.Success()
 
-	h.WebhookSink.Enqueue(webhook.EventTypePersonUpdated, map[string]any{
-		"prev_person_uid": currentPersonID.Value,
-		"curr_person_uid": updatedPersonID,
-	})
+	for _, change := range changes {
+		switch change.Type {
+		case rm.COMPOSITION_TYPE:
+			switch change.ChangeType {
+			case terminology.AUDIT_CHANGE_TYPE_CODE_CREATION:
+				h.WebhookSink.Enqueue(webhook.EventTypeCompositionCreated, map[string]any{
+					"ehr_id":          ehrID,
+					"composition_uid": change.VersionUID.Value,
+				})
+				h.StandingQueryEvaluator.Enqueue(ehrID, change.VersionUID.Value)
+			case terminology.AUDIT_CHANGE_TYPE_CODE_DELETED:
+				h.WebhookSink.Enqueue(webhook.EventTypeCompositionDeleted, map[string]any{
+					"ehr_id":          ehrID,
+					"composition_uid": change.PrecedingVersionUID.V.Value,
+				})
+			default:
+				h.WebhookSink.Enqueue(webhook.EventTypeCompositionUpdated, map[string]any{
+					"ehr_id":               ehrID,
+					"prev_composition_uid": change.PrecedingVersionUID.V.Value,
+					"curr_composition_uid": change.VersionUID.Value,
+				})
+				h.StandingQueryEvaluator.Enqueue(ehrID, change.VersionUID.Value)
+			}
+		case rm.EHR_STATUS_TYPE:
+			h.WebhookSink.Enqueue(webhook.EventTypeEHRStatusUpdated, map[string]any{
+				"ehr_id":              ehrID,
+				"prev_ehr_status_uid": change.PrecedingVersionUID.V.Value,
+				"curr_ehr_status_uid": change.VersionUID.Value,
+			})
+		case rm.FOLDER_TYPE:
+			switch change.ChangeType {
+			case terminology.AUDIT_CHANGE_TYPE_CODE_CREATION:
+				h.WebhookSink.Enqueue(webhook.EventTypeDirectoryCreated, map[string]any{
+					"directory_id": change.VersionUID.Value,
+				})
+			case terminology.AUDIT_CHANGE_TYPE_CODE_DELETED:
+				h.WebhookSink.Enqueue(webhook.EventTypeDirectoryDeleted, map[string]any{
+					"directory_id": change.VersionedObjectID.String(),
+				})
+			default:
+				h.WebhookSink.Enqueue(webhook.EventTypeDirectoryUpdated, map[string]any{
+					"prev_directory_id": change.PrecedingVersionUID.V.Value,
+					"curr_directory_id": change.VersionUID.Value,
+				})
+			}
+		}
+	}
 
-	c.Set("ETag", "\""+updatedPersonID+"\"")
-	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/person/"+updatedPersonID)
+	c.Set("ETag", "\""+contributionID+"\"")
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/ehr/"+ehrID.String()+"/contribution/"+contributionID)
 
 	switch returnType {
 	case ReturnTypeMinimal:
-		c.Status(fiber.StatusNoContent)
+		c.Status(fiber.StatusCreated)
 		return nil
 	case ReturnTypeRepresentation:
-		return c.Status(fiber.StatusOK).JSON(updatedPerson)
+		return SendRepresentation(c, fiber.StatusCreated, contribution)
 	case ReturnTypeIdentifier:
-		return c.Status(fiber.StatusOK).JSON(`{"uid":"` + updatedPersonID + `"}`)
+		return c.Status(fiber.StatusCreated).JSON(`{"uid":"` + contributionID + `"}`)
 	default:
 		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
-		return c.Status(fiber.StatusOK).JSON(updatedPerson)
+		return SendRepresentation(c, fiber.StatusCreated, contribution)
 	}
 }
 
-func (h *Handler) DeletePerson(c *fiber.Ctx) error {
+func (h *Handler) GetContribution(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
-	versionID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
 	if err != nil {
 		return err
 	}
 
-	if strings.Count(versionID, "::") != 2 {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "Cannot delete Person by versioned object ID. Please provide the object version ID.",
-			Status:  "bad_request",
-		})
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
+	if err != nil {
+		return err
 	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	versionedPartyID, err := uuid.Parse(strings.Split(versionID, "::")[0])
+	contributionUID, err := StringFromPath(c, auditCtx, "contribution_uid")
 	if err != nil {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "Invalid uid_based_id format",
-			Status:  "bad_request",
-		})
+		return err
 	}
-	currentPersonID, err := h.OpenEHRService.GetCurrentPersonID(ctx, versionedPartyID)
+	auditCtx.Event.Details["contribution_uid"] = contributionUID
+
+	contributionJSON, err := h.OpenEHRService.GetContributionRawJSON(ctx, contributionUID, utils.Some(ehrID))
 	if err != nil {
-		if err == ErrPersonNotFound {
+		if err == ErrContributionNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Person not found for the given person ID",
+				Message: "Contribution not found for the given EHR ID and Contribution UID",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Person by ID before deletion", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Contribution by ID", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get Person by ID before deletion",
+			Message: "Failed to get Contribution by ID",
 			Status:  "error",
 		})
 	}
-	if currentPersonID.Value != versionID {
+
+	auditCtx.Success()
+
+	return SendRawRepresentation[rm.CONTRIBUTION](c, fiber.StatusOK, contributionJSON)
+}
+
+func (h *Handler) GetEHRTags(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json")
+	if err != nil {
+		return err
+	}
+
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
+
+	tagsJSON, err := h.OpenEHRService.GetEHRTagsRawJSON(ctx, ehrID)
+	if err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get EHR Tags", "error", err)
+
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusPreconditionFailed,
-			Message: "Person has been modified since the provided version",
-			Status:  "precondition_failed",
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get EHR Tags",
+			Status:  "error",
 		})
 	}
 
-	if err := h.OpenEHRService.DeletePerson(ctx, versionedPartyID); err != nil {
-		if err == ErrPersonNotFound {
-			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusNotFound,
-				Message: "Person not found for the given person ID",
-				Status:  "not_found",
-			})
-		}
+	auditCtx.Success()
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete Person by ID", "error", err)
+	c.Set("Content-Type", "application/json")
+	return c.Status(fiber.StatusOK).Send(tagsJSON)
+}
+
+func (h *Handler) GetCompositionTags(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json")
+	if err != nil {
+		return err
+	}
+
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
+
+	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["uid_based_id"] = uidBasedID
+
+	var tagsJSON []byte
+	if uuid.Validate(uidBasedID) == nil {
+		tagsJSON, err = h.OpenEHRService.GetVersionedCompositionTagsRawJSON(ctx, ehrID, uuid.MustParse(uidBasedID))
+	} else {
+		tagsJSON, err = h.OpenEHRService.GetCompositionTagsRawJSON(ctx, ehrID, uidBasedID)
+	}
+	if err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Composition Tags", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to delete Person by ID",
+			Message: "Failed to get Composition Tags",
 			Status:  "error",
 		})
 	}
 
 	auditCtx.Success()
 
-	h.WebhookSink.Enqueue(webhook.EventTypePersonDeleted, map[string]any{
-		"versioned_party_id": versionedPartyID,
-	})
-
-	c.Status(fiber.StatusNoContent)
-	return nil
+	c.Set("Content-Type", "application/json")
+	return c.Status(fiber.StatusOK).Send(tagsJSON)
 }
 
-func (h *Handler) CreateOrganisation(c *fiber.Ctx) error {
+func (h *Handler) UpdateCompositionTags(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
@@ -3018,25 +3271,61 @@ func (h *Handler) CreateOrganisation(c *fiber.Ctx) error {
 		return err
 	}
 
-	returnType, err := ReturnTypeFromHeader(c, auditCtx)
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
 	if err != nil {
 		return err
 	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	var organisation rm.ORGANISATION
-	if err := ParseBody(c, auditCtx, &organisation); err != nil {
+	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	if err != nil {
 		return err
 	}
+	auditCtx.Event.Details["uid_based_id"] = uidBasedID
 
-	createdOrganisation, err := h.OpenEHRService.CreateOrganisation(ctx, organisation)
+	var tags []rm.ITEM_TAG
+	err = c.BodyParser(&tags)
 	if err != nil {
-		if err == ErrOrganisationAlreadyExists {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Failed to parse request body",
+			Status:  "bad_request",
+		})
+	}
+
+	// Tags of a versioned composition go with its latest version, tags of a composition version with that version
+	currentCompositionID := uidBasedID
+	if uuid.Validate(uidBasedID) == nil {
+		latestCompositionID, err := h.OpenEHRService.GetCompositionID(ctx, ehrID, uuid.MustParse(uidBasedID))
+		if err != nil {
+			if err == ErrCompositionNotFound {
+				return SendErrorResponse(c, auditCtx, ErrorResponse{
+					Code:    fiber.StatusNotFound,
+					Message: "Composition not found for the given UID and EHR ID",
+					Status:  "not_found",
+				})
+			}
+
+			h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Composition", "error", err)
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusConflict,
-				Message: "Organisation with the given UID already exists",
-				Status:  "conflict",
+				Code:    fiber.StatusInternalServerError,
+				Message: "Internal server error",
+				Status:  "error",
 			})
 		}
+		currentCompositionID = latestCompositionID.Value
+	}
+	if ok, err := IfMatch(c, auditCtx, currentCompositionID, "Composition"); !ok {
+		return err
+	}
+
+	if uuid.Validate(uidBasedID) == nil {
+		versionedCompositionID := uuid.MustParse(uidBasedID)
+		tags, err = h.OpenEHRService.ReplaceVersionedCompositionTags(ctx, ehrID, versionedCompositionID, tags)
+	} else {
+		tags, err = h.OpenEHRService.ReplaceCompositionTags(ctx, ehrID, uidBasedID, tags)
+	}
+	if err != nil {
 		if err, ok := err.(util.ValidateError); ok {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusBadRequest,
@@ -3046,77 +3335,102 @@ func (h *Handler) CreateOrganisation(c *fiber.Ctx) error {
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to create Organisation", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to update Composition Tags", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to create Organisation",
+			Message: "Failed to update Composition Tags",
 			Status:  "error",
 		})
 	}
-	createdOrganisationID := createdOrganisation.UID.V.OBJECT_VERSION_ID().Value
 
 	auditCtx.Success()
+	return c.Status(fiber.StatusOK).JSON(tags)
+}
 
-	h.WebhookSink.Enqueue(webhook.EventTypeOrganisationCreated, map[string]any{
-		"organisation_uid": createdOrganisationID,
-	})
+func (h *Handler) DeleteCompositionTagByKey(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotImplemented).SendString("Delete Composition Tag By Key not implemented yet")
+}
 
-	c.Set("ETag", "\""+createdOrganisationID+"\"")
-	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/organisation/"+createdOrganisationID)
+func (h *Handler) GetEHRStatusTags(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
 
-	switch returnType {
-	case ReturnTypeMinimal:
-		c.Status(fiber.StatusCreated)
-		return nil
-	case ReturnTypeRepresentation:
-		return c.Status(fiber.StatusCreated).JSON(organisation)
-	case ReturnTypeIdentifier:
-		return c.Status(fiber.StatusCreated).JSON(`{"uid":"` + createdOrganisationID + `"}`)
-	default:
-		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
-		return c.Status(fiber.StatusCreated).JSON(organisation)
+	err := Accepts(c, auditCtx, "application/json")
+	if err != nil {
+		return err
 	}
+
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
+
+	tagsJSON, err := h.OpenEHRService.GetEHRStatusTagsRawJSON(ctx, ehrID)
+	if err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get EHR Status Tags", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get EHR Status Tags",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	c.Set("Content-Type", "application/json")
+	return c.Status(fiber.StatusOK).Send(tagsJSON)
 }
 
-func (h *Handler) GetOrganisation(c *fiber.Ctx) error {
+func (h *Handler) GetEHRStatusVersionTags(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
-	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	err := Accepts(c, auditCtx, "application/json")
 	if err != nil {
 		return err
 	}
 
-	var organisationJSON []byte
-	if uuid.Validate(uidBasedID) == nil {
-		organisationJSON, err = h.OpenEHRService.GetOrganisationByVersionedPartyIDRawJSON(ctx, uuid.MustParse(uidBasedID))
-	} else {
-		organisationJSON, err = h.OpenEHRService.GetOrganisationByIDRawJSON(ctx, uidBasedID)
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
+
+	versionUID, err := StringFromPath(c, auditCtx, "version_uid")
+	if err != nil {
+		return err
 	}
+	auditCtx.Event.Details["version_uid"] = versionUID
+
+	tagsJSON, err := h.OpenEHRService.GetEHRStatusVersionTagsRawJSON(ctx, ehrID, versionUID)
 	if err != nil {
-		if err == ErrOrganisationNotFound {
+		if err == ErrEHRStatusNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Organisation not found for the given organisation ID",
+				Message: "EHR Status version not found for the given EHR ID and version UID",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Organisation by ID", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get EHR Status Version Tags", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get Organisation by ID",
+			Message: "Failed to get EHR Status Version Tags",
 			Status:  "error",
 		})
 	}
 
+	auditCtx.Success()
+
 	c.Set("Content-Type", "application/json")
-	return c.Status(fiber.StatusOK).Send(organisationJSON)
+	return c.Status(fiber.StatusOK).Send(tagsJSON)
 }
 
-func (h *Handler) UpdateOrganisation(c *fiber.Ctx) error {
+func (h *Handler) UpdateEHRStatusVersionTags(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
@@ -3125,128 +3439,256 @@ func (h *Handler) UpdateOrganisation(c *fiber.Ctx) error {
 		return err
 	}
 
-	versionID, err := StringFromPath(c, auditCtx, "uid_based_id")
-	if err != nil {
-		return err
-	}
-
-	ifMatch, err := StringFromHeader(c, auditCtx, "If-Match")
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
 	if err != nil {
 		return err
 	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	returnType, err := ReturnTypeFromHeader(c, auditCtx)
+	versionUID, err := StringFromPath(c, auditCtx, "version_uid")
 	if err != nil {
 		return err
 	}
+	auditCtx.Event.Details["version_uid"] = versionUID
 
-	versionedPartyID, err := uuid.Parse(strings.Split(versionID, "::")[0])
-	if err != nil {
+	var tags []rm.ITEM_TAG
+	if err := c.BodyParser(&tags); err != nil {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusBadRequest,
-			Message: "Invalid uid_based_id format",
+			Message: "Failed to parse request body",
 			Status:  "bad_request",
 		})
 	}
 
-	var organisation rm.ORGANISATION
-	if err := ParseBody(c, auditCtx, &organisation); err != nil {
+	if ok, err := IfMatch(c, auditCtx, versionUID, "EHR Status version"); !ok {
 		return err
 	}
 
-	currentOrganisationID, err := h.OpenEHRService.GetCurrentOrganisationID(ctx, versionedPartyID)
+	tags, err = h.OpenEHRService.ReplaceEHRStatusVersionTags(ctx, ehrID, versionUID, tags)
 	if err != nil {
-		if err == ErrOrganisationNotFound {
+		if err == ErrEHRStatusNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Organisation not found for the given organisation ID",
+				Message: "EHR Status version not found for the given EHR ID and version UID",
 				Status:  "not_found",
 			})
 		}
+		if err, ok := err.(util.ValidateError); ok {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Validation error in request body",
+				Status:  "bad_request",
+				Details: err,
+			})
+		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Organisation by ID", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to update EHR Status Version Tags", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get current Organisation by ID",
+			Message: "Failed to update EHR Status Version Tags",
 			Status:  "error",
 		})
 	}
-	if currentOrganisationID.Value != ifMatch {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusPreconditionFailed,
-			Message: "Organisation has been modified since the provided version",
-			Status:  "precondition_failed",
-		})
+
+	auditCtx.Success()
+	return c.Status(fiber.StatusOK).JSON(tags)
+}
+
+func (h *Handler) DeleteEHRStatusVersionTagByKey(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
+	if err != nil {
+		return err
 	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	updatedOrganisation, err := h.OpenEHRService.UpdateOrganisation(ctx, currentOrganisationID, organisation)
+	versionUID, err := StringFromPath(c, auditCtx, "version_uid")
 	if err != nil {
-		if err == ErrOrganisationNotFound {
+		return err
+	}
+	auditCtx.Event.Details["version_uid"] = versionUID
+
+	key, err := StringFromPath(c, auditCtx, "key")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["key"] = key
+
+	if ok, err := IfMatch(c, auditCtx, versionUID, "EHR Status version"); !ok {
+		return err
+	}
+
+	if err := h.OpenEHRService.DeleteEHRStatusVersionTagByKey(ctx, ehrID, versionUID, key); err != nil {
+		if err == ErrTagNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Organisation not found for the given organisation ID",
+				Message: "Tag not found for the given EHR Status version and key",
 				Status:  "not_found",
 			})
 		}
-		if err == ErrOrganisationVersionLowerOrEqualToCurrent {
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete EHR Status Version Tag", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to delete EHR Status Version Tag",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	c.Status(fiber.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) SearchEHRTags(c *fiber.Ctx) error {
+	ehrID, err := UUIDFromPath(c, middleware.AuditFrom(c), "ehr_id")
+	if err != nil {
+		return err
+	}
+
+	return h.searchTags(c, utils.Some(ehrID))
+}
+
+func (h *Handler) SearchTags(c *fiber.Ctx) error {
+	return h.searchTags(c, utils.None[uuid.UUID]())
+}
+
+// searchTags finds the tagged versioned objects of a single EHR, or of all EHRs the caller may query
+func (h *Handler) searchTags(c *fiber.Ctx, ehrID utils.Optional[uuid.UUID]) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json")
+	if err != nil {
+		return err
+	}
+
+	search := TagSearch{
+		EHRID: ehrID,
+		Key:   c.Query("key"),
+	}
+	if ehrID.E {
+		auditCtx.Event.Details["ehr_id"] = ehrID.V
+	}
+	auditCtx.Event.Details["key"] = search.Key
+
+	if search.Key == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "key query parameter is required",
+			Status:  "bad_request",
+		})
+	}
+	if value := c.Query("value"); value != "" {
+		search.Value = utils.Some(value)
+		auditCtx.Event.Details["value"] = value
+	}
+	if jsonPath := c.Query("jsonpath"); jsonPath != "" {
+		search.JSONPath = utils.Some(jsonPath)
+		auditCtx.Event.Details["jsonpath"] = jsonPath
+	}
+
+	resultJSON, err := h.OpenEHRService.SearchTagsRawJSON(ctx, search, AQLOptionsFrom(c))
+	if err != nil {
+		if errors.Is(err, ErrInvalidTagJSONPath) {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-				Code:    fiber.StatusConflict,
-				Message: "Organisation version is lower than or equal to the current version",
-				Status:  "conflict",
+				Code:    fiber.StatusBadRequest,
+				Message: "Invalid jsonpath query parameter, expected a PostgreSQL JSONPath expression",
+				Status:  "bad_request",
 			})
 		}
-		if err == ErrInvalidOrganisationUIDMismatch {
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to search Tags", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to search Tags",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	c.Set("Content-Type", "application/json")
+	return c.Status(fiber.StatusOK).Send(resultJSON)
+}
+
+func (h *Handler) CreateAgent(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
+	if err != nil {
+		return err
+	}
+
+	returnType, err := ReturnTypeFromHeader(c, auditCtx)
+	if err != nil {
+		return err
+	}
+
+	var agent rm.AGENT
+	if err := ParseBody(c, auditCtx, &agent); err != nil {
+		return err
+	}
+
+	createdAgent, err := h.OpenEHRService.CreateAgent(ctx, agent)
+	if err != nil {
+		if err == ErrAgentAlreadyExists {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusConflict,
-				Message: "Organisation with the given UID already exists",
+				Message: "Agent with the given UID already exists",
 				Status:  "conflict",
 			})
 		}
-		if validationErrs, ok := err.(util.ValidateError); ok {
+		if err, ok := err.(util.ValidateError); ok {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusBadRequest,
 				Message: "Validation error in request body",
 				Status:  "bad_request",
-				Details: validationErrs,
+				Details: err,
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to update Organisation", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to create Agent", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to update Organisation",
+			Message: "Failed to create Agent",
 			Status:  "error",
 		})
 	}
-	updatedOrganisationID := updatedOrganisation.UID.V.OBJECT_VERSION_ID().Value
+	createdAgentID := createdAgent.UID.V.OBJECT_VERSION_ID().Value
 
 	auditCtx.Success()
 
-	h.WebhookSink.Enqueue(webhook.EventTypeOrganisationUpdated, map[string]any{
-		"prev_organisation_uid": currentOrganisationID.Value,
-		"curr_organisation_uid": updatedOrganisationID,
+	h.WebhookSink.Enqueue(webhook.EventTypeAgentCreated, map[string]any{
+		"agent_uid": createdAgentID,
 	})
 
-	c.Set("ETag", "\""+updatedOrganisationID+"\"")
-	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/organisation/"+updatedOrganisationID)
+	c.Set("ETag", "\""+createdAgentID+"\"")
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/agent/"+createdAgentID)
 
 	switch returnType {
 	case ReturnTypeMinimal:
-		c.Status(fiber.StatusNoContent)
+		c.Status(fiber.StatusCreated)
 		return nil
 	case ReturnTypeRepresentation:
-		return c.Status(fiber.StatusOK).JSON(updatedOrganisation)
+		return SendRepresentation(c, fiber.StatusCreated, createdAgent)
 	case ReturnTypeIdentifier:
-		return c.Status(fiber.StatusOK).JSON(`{"uid":"` + updatedOrganisationID + `"}`)
+		return c.Status(fiber.StatusCreated).JSON(`{"uid":"` + createdAgentID + `"}`)
 	default:
 		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
-		return c.Status(fiber.StatusOK).JSON(updatedOrganisation)
+		return SendRepresentation(c, fiber.StatusCreated, createdAgent)
 	}
 }
 
-func (h *Handler) DeleteOrganisation(c *fiber.Ctx) error {
+func (h *Handler) GetAgent(c *fiber.Ctx) error {
 	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
@@ -3254,15 +3696,70 @@ func (h *Handler) DeleteOrganisation(c *fiber.Ctx) error {
 	if err != nil {
 		return err
 	}
+	auditCtx.Event.Details["uid_based_id"] = uidBasedID
+
+	var agentJSON []byte
+	if uuid.Validate(uidBasedID) == nil {
+		agentJSON, err = h.OpenEHRService.GetAgentByVersionedPartyIDRawJSON(ctx, uuid.MustParse(uidBasedID))
+	} else {
+		agentJSON, err = h.OpenEHRService.GetAgentAtVersionRawJSON(ctx, uidBasedID)
+	}
+	if err != nil {
+		if err == ErrAgentNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Agent not found for the given agent ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Agent by ID", "error", err)
 
-	if strings.Count(uidBasedID, "::") != 2 {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "Cannot delete Organisation by versioned object ID. Please provide the object version ID.",
-			Status:  "bad_request",
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get Agent by ID",
+			Status:  "error",
 		})
 	}
 
+	auditCtx.Success()
+
+	return SendRawRepresentation[rm.AGENT](c, fiber.StatusOK, agentJSON)
+}
+
+func (h *Handler) UpdateAgent(c *fiber.Ctx) error {
+	return h.updateAgent(c, false)
+}
+
+func (h *Handler) PatchAgent(c *fiber.Ctx) error {
+	return h.updateAgent(c, true)
+}
+
+func (h *Handler) updateAgent(c *fiber.Ctx, patch bool) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
+	if err != nil {
+		return err
+	}
+
+	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["uid_based_id"] = uidBasedID
+
+	ifMatch, err := StringFromHeader(c, auditCtx, "If-Match")
+	if err != nil {
+		return err
+	}
+
+	returnType, err := ReturnTypeFromHeader(c, auditCtx)
+	if err != nil {
+		return err
+	}
+
 	versionedPartyID, err := uuid.Parse(strings.Split(uidBasedID, "::")[0])
 	if err != nil {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
@@ -3272,1329 +3769,3994 @@ func (h *Handler) DeleteOrganisation(c *fiber.Ctx) error {
 		})
 	}
 
-	currentOrganisationID, err := h.OpenEHRService.GetCurrentOrganisationID(ctx, versionedPartyID)
+	currentAgentID, err := h.OpenEHRService.GetAgentID(ctx, versionedPartyID)
 	if err != nil {
-		if err == ErrOrganisationNotFound {
+		if err == ErrAgentNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Organisation not found for the given organisation ID",
+				Message: "Agent not found for the given agent ID",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Organisation by ID before deletion", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Agent by ID", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get Organisation by ID before deletion",
+			Message: "Failed to get current Agent by ID",
 			Status:  "error",
 		})
 	}
-	if currentOrganisationID.Value != uidBasedID {
+	if !ETagMatches(ifMatch, currentAgentID.Value) {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusPreconditionFailed,
-			Message: "Organisation has been modified since the provided version",
+			Message: "Agent has been modified since the provided version",
 			Status:  "precondition_failed",
 		})
 	}
 
-	if err := h.OpenEHRService.DeleteOrganisation(ctx, versionedPartyID); err != nil {
-		if err == ErrOrganisationNotFound {
+	var agent rm.AGENT
+	if patch {
+		currentAgentJSON, err := h.OpenEHRService.GetAgentAtVersionRawJSON(ctx, currentAgentID.Value)
+		if err != nil {
+			h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Agent", "error", err)
+
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusInternalServerError,
+				Message: "Failed to get current Agent",
+				Status:  "error",
+			})
+		}
+
+		if ok, err := PatchBody(c, auditCtx, currentAgentJSON, &agent); !ok {
+			return err
+		}
+		agent.UID = PatchedUID(agent.UID, currentAgentID)
+	} else if err := ParseBody(c, auditCtx, &agent); err != nil {
+		return err
+	}
+
+	updatedAgent, err := h.OpenEHRService.UpdateAgent(ctx, currentAgentID, agent)
+	if err != nil {
+		if err == ErrAgentNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Organisation not found for the given organisation ID",
+				Message: "Agent not found for the given agent ID",
 				Status:  "not_found",
 			})
 		}
+		if err == ErrAgentVersionLowerOrEqualToCurrent {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "Agent version is lower than or equal to the current version",
+				Status:  "conflict",
+			})
+		}
+		if err == ErrInvalidAgentUIDMismatch {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Agent UID HIER_OBJECT_ID in request body does not match current Agent UID",
+				Status:  "bad_request",
+			})
+		}
+		if validationErrs, ok := err.(util.ValidateError); ok {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Validation error in request body",
+				Status:  "bad_request",
+				Details: validationErrs,
+			})
+		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete Organisation by ID", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to update Agent", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to delete Organisation by ID",
+			Message: "Failed to update Agent",
+			Status:  "error",
+		})
+	}
+	updatedAgentID := updatedAgent.UID.V.OBJECT_VERSION_ID().Value
+
+	auditCtx.Success()
+
+	h.WebhookSink.Enqueue(webhook.EventTypeAgentUpdated, map[string]any{
+		"prev_agent_uid": currentAgentID.Value,
+		"curr_agent_uid": updatedAgentID,
+	})
+
+	c.Set("ETag", "\""+updatedAgentID+"\"")
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/agent/"+updatedAgentID)
+
+	switch returnType {
+	case ReturnTypeMinimal:
+		c.Status(fiber.StatusNoContent)
+		return nil
+	case ReturnTypeRepresentation:
+		return SendRepresentation(c, fiber.StatusOK, updatedAgent)
+	case ReturnTypeIdentifier:
+		return c.Status(fiber.StatusOK).JSON(`{"uid":"` + updatedAgentID + `"}`)
+	default:
+		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
+		return SendRepresentation(c, fiber.StatusOK, updatedAgent)
+	}
+}
+
+func (h *Handler) DeleteAgent(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["uid_based_id"] = uidBasedID
+
+	if strings.Count(uidBasedID, "::") != 2 {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Cannot delete Agent by versioned object ID. Please provide the object version ID.",
+			Status:  "bad_request",
+		})
+	}
+
+	versionedPartyID, err := uuid.Parse(strings.Split(uidBasedID, "::")[0])
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Invalid uid_based_id format",
+			Status:  "bad_request",
+		})
+	}
+
+	currentAgentID, err := h.OpenEHRService.GetAgentID(ctx, versionedPartyID)
+	if err != nil {
+		if err == ErrAgentNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Agent not found for the given agent ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Agent by ID before deletion", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get Agent by ID before deletion",
+			Status:  "error",
+		})
+	}
+	if currentAgentID.Value != uidBasedID {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusPreconditionFailed,
+			Message: "Agent has been modified since the provided version",
+			Status:  "precondition_failed",
+		})
+	}
+	if ok, err := IfMatch(c, auditCtx, currentAgentID.Value, "Agent"); !ok {
+		return err
+	}
+
+	if err := h.OpenEHRService.DeleteAgent(ctx, uuid.MustParse(currentAgentID.UID())); err != nil {
+		if err == ErrAgentNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Agent not found for the given agent ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete Agent by ID", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to delete Agent by ID",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	h.WebhookSink.Enqueue(webhook.EventTypeAgentDeleted, map[string]any{
+		"versioned_party_id": versionedPartyID,
+	})
+
+	c.Status(fiber.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) CreateGroup(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
+	if err != nil {
+		return err
+	}
+
+	returnType, err := ReturnTypeFromHeader(c, auditCtx)
+	if err != nil {
+		return err
+	}
+
+	var group rm.GROUP
+	if err := ParseBody(c, auditCtx, &group); err != nil {
+		return err
+	}
+
+	createdGroup, err := h.OpenEHRService.CreateGroup(ctx, group)
+	if err != nil {
+		if err == ErrGroupAlreadyExists {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "Group with the given UID already exists",
+				Status:  "conflict",
+			})
+		}
+		if err, ok := err.(util.ValidateError); ok {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to create Group", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to create Group",
+			Status:  "error",
+		})
+	}
+	createdGroupID := createdGroup.UID.V.OBJECT_VERSION_ID().Value
+
+	auditCtx.Success()
+
+	h.WebhookSink.Enqueue(webhook.EventTypeGroupCreated, map[string]any{
+		"group_uid": createdGroupID,
+	})
+
+	c.Set("ETag", "\""+createdGroupID+"\"")
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/group/"+createdGroupID)
+
+	switch returnType {
+	case ReturnTypeMinimal:
+		c.Status(fiber.StatusCreated)
+		return nil
+	case ReturnTypeRepresentation:
+		return SendRepresentation(c, fiber.StatusCreated, createdGroup)
+	case ReturnTypeIdentifier:
+		return c.Status(fiber.StatusCreated).JSON(`{"uid":"` + createdGroupID + `"}`)
+	default:
+		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
+		return SendRepresentation(c, fiber.StatusCreated, createdGroup)
+	}
+}
+
+func (h *Handler) GetGroup(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["uid_based_id"] = uidBasedID
+
+	var groupJSON []byte
+	if uuid.Validate(uidBasedID) == nil {
+		groupJSON, err = h.OpenEHRService.GetGroupByVersionedPartyIDRawJSON(ctx, uuid.MustParse(uidBasedID))
+	} else {
+		groupJSON, err = h.OpenEHRService.GetGroupRawJSON(ctx, uidBasedID)
+	}
+	if err != nil {
+		if err == ErrGroupNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Group not found for the given group ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Group by ID", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get Group by ID",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	return SendRawRepresentation[rm.GROUP](c, fiber.StatusOK, groupJSON)
+}
+
+func (h *Handler) UpdateGroup(c *fiber.Ctx) error {
+	return h.updateGroup(c, false)
+}
+
+func (h *Handler) PatchGroup(c *fiber.Ctx) error {
+	return h.updateGroup(c, true)
+}
+
+func (h *Handler) updateGroup(c *fiber.Ctx, patch bool) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
+	if err != nil {
+		return err
+	}
+
+	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	if err != nil {
+		return err
+	}
+
+	ifMatch, err := StringFromHeader(c, auditCtx, "If-Match")
+	if err != nil {
+		return err
+	}
+
+	returnType, err := ReturnTypeFromHeader(c, auditCtx)
+	if err != nil {
+		return err
+	}
+
+	versionedPartyID, err := uuid.Parse(strings.Split(uidBasedID, "::")[0])
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Invalid uid_based_id format",
+			Status:  "bad_request",
+		})
+	}
+
+	currentGroupID, err := h.OpenEHRService.GetGroupID(ctx, versionedPartyID)
+	if err != nil {
+		if err == ErrGroupNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Group not found for the given group ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Group by ID", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get current Group by ID",
+			Status:  "error",
+		})
+	}
+	if !ETagMatches(ifMatch, currentGroupID.Value) {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusPreconditionFailed,
+			Message: "Group has been modified since the provided version",
+			Status:  "precondition_failed",
+		})
+	}
+
+	var group rm.GROUP
+	if patch {
+		currentGroupJSON, err := h.OpenEHRService.GetGroupRawJSON(ctx, currentGroupID.Value)
+		if err != nil {
+			h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Group", "error", err)
+
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusInternalServerError,
+				Message: "Failed to get current Group",
+				Status:  "error",
+			})
+		}
+
+		if ok, err := PatchBody(c, auditCtx, currentGroupJSON, &group); !ok {
+			return err
+		}
+		group.UID = PatchedUID(group.UID, currentGroupID)
+	} else if err := ParseBody(c, auditCtx, &group); err != nil {
+		return err
+	}
+
+	updatedGroup, err := h.OpenEHRService.UpdateGroup(ctx, currentGroupID, group)
+	if err != nil {
+		if err == ErrGroupNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Group not found for the given group ID",
+				Status:  "not_found",
+			})
+		}
+		if err == ErrGroupVersionLowerOrEqualToCurrent {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "Group version is lower than or equal to the current version",
+				Status:  "conflict",
+			})
+		}
+		if err == ErrInvalidGroupUIDMismatch {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Group UID HIER_OBJECT_ID in request body does not match current Group UID",
+				Status:  "bad_request",
+			})
+		}
+		if validationErrs, ok := err.(util.ValidateError); ok {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Validation error in request body",
+				Status:  "bad_request",
+				Details: validationErrs,
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to update Agent", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to update Agent",
+			Status:  "error",
+		})
+	}
+	updatedGroupID := updatedGroup.UID.V.OBJECT_VERSION_ID().Value
+
+	auditCtx.Success()
+
+	h.WebhookSink.Enqueue(webhook.EventTypeGroupUpdated, map[string]any{
+		"prev_group_uid": currentGroupID.Value,
+		"curr_group_uid": updatedGroupID,
+	})
+
+	c.Set("ETag", "\""+updatedGroupID+"\"")
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/group/"+updatedGroupID)
+
+	switch returnType {
+	case ReturnTypeMinimal:
+		c.Status(fiber.StatusNoContent)
+		return nil
+	case ReturnTypeRepresentation:
+		return SendRepresentation(c, fiber.StatusOK, updatedGroup)
+	case ReturnTypeIdentifier:
+		return c.Status(fiber.StatusOK).JSON(`{"uid":"` + updatedGroupID + `"}`)
+	default:
+		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
+		return SendRepresentation(c, fiber.StatusOK, updatedGroup)
+	}
+}
+
+func (h *Handler) DeleteGroup(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	versionUID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	if err != nil {
+		return err
+	}
+
+	if strings.Count(versionUID, "::") != 2 {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Cannot delete Group by versioned object ID. Please provide the object version ID.",
+			Status:  "bad_request",
+		})
+	}
+
+	versionedPartyID, err := uuid.Parse(strings.Split(versionUID, "::")[0])
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Invalid uid_based_id format",
+			Status:  "bad_request",
+		})
+	}
+
+	currentGroupID, err := h.OpenEHRService.GetGroupID(ctx, versionedPartyID)
+	if err != nil {
+		if err == ErrGroupNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Group not found for the given group ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Group by ID before deletion", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get Group by ID before deletion",
+			Status:  "error",
+		})
+	}
+	if currentGroupID.Value != versionUID {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusPreconditionFailed,
+			Message: "Group has been modified since the provided version",
+			Status:  "precondition_failed",
+		})
+	}
+	if ok, err := IfMatch(c, auditCtx, currentGroupID.Value, "Group"); !ok {
+		return err
+	}
+
+	if err := h.OpenEHRService.DeleteGroup(ctx, versionedPartyID); err != nil {
+		if err == ErrGroupNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Group not found for the given group ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete Group by ID", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to delete Group by ID",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	h.WebhookSink.Enqueue(webhook.EventTypeGroupDeleted, map[string]any{
+		"versioned_party_id": versionedPartyID,
+	})
+
+	c.Status(fiber.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) CreatePerson(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
+	if err != nil {
+		return err
+	}
+
+	returnType, err := ReturnTypeFromHeader(c, auditCtx)
+	if err != nil {
+		return err
+	}
+
+	var person rm.PERSON
+	if err := ParseBody(c, auditCtx, &person); err != nil {
+		return err
+	}
+
+	createdPerson, err := h.OpenEHRService.CreatePerson(ctx, person)
+	if err != nil {
+		if err == ErrPersonAlreadyExists {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "Person with the given UID already exists",
+				Status:  "conflict",
+			})
+		}
+		if validationErrs, ok := err.(util.ValidateError); ok {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Validation error in request body",
+				Status:  "bad_request",
+				Details: validationErrs,
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to create Person", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to create Person",
+			Status:  "error",
+		})
+	}
+	createdPersonID := createdPerson.UID.V.OBJECT_VERSION_ID().Value
+
+	auditCtx.Success()
+
+	h.WebhookSink.Enqueue(webhook.EventTypePersonCreated, map[string]any{
+		"person_uid": createdPersonID,
+	})
+
+	c.Set("ETag", "\""+createdPersonID+"\"")
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/person/"+createdPersonID)
+
+	switch returnType {
+	case ReturnTypeMinimal:
+		c.Status(fiber.StatusCreated)
+		return nil
+	case ReturnTypeRepresentation:
+		return SendRepresentation(c, fiber.StatusCreated, createdPerson)
+	case ReturnTypeIdentifier:
+		return c.Status(fiber.StatusCreated).JSON(`{"uid":"` + createdPersonID + `"}`)
+	default:
+		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
+		return SendRepresentation(c, fiber.StatusCreated, createdPerson)
+	}
+}
+
+func (h *Handler) GetPerson(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	if err != nil {
+		return err
+	}
+
+	var personJSON []byte
+	if uuid.Validate(uidBasedID) == nil {
+		personJSON, err = h.OpenEHRService.GetPersonByVersionedPartyIDRawJSON(ctx, uuid.MustParse(uidBasedID))
+	} else {
+		personJSON, err = h.OpenEHRService.GetPersonByIDRawJSON(ctx, uidBasedID)
+	}
+	if err != nil {
+		if err == ErrPersonNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Person not found for the given person ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Person by ID", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get Person by ID",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	return SendRawRepresentation[rm.PERSON](c, fiber.StatusOK, personJSON)
+}
+
+func (h *Handler) UpdatePerson(c *fiber.Ctx) error {
+	return h.updatePerson(c, false)
+}
+
+func (h *Handler) PatchPerson(c *fiber.Ctx) error {
+	return h.updatePerson(c, true)
+}
+
+func (h *Handler) updatePerson(c *fiber.Ctx, patch bool) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
+	if err != nil {
+		return err
+	}
+
+	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	if err != nil {
+		return err
+	}
+
+	ifMatch, err := StringFromHeader(c, auditCtx, "If-Match")
+	if err != nil {
+		return err
+	}
+
+	returnType, err := ReturnTypeFromHeader(c, auditCtx)
+	if err != nil {
+		return err
+	}
+
+	versionedPartyID, err := uuid.Parse(strings.Split(uidBasedID, "::")[0])
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Invalid uid_based_id format",
+			Status:  "bad_request",
+		})
+	}
+
+	currentPersonID, err := h.OpenEHRService.GetCurrentPersonID(ctx, versionedPartyID)
+	if err != nil {
+		if err == ErrPersonNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Person not found for the given person ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Person by ID", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get current Person",
+			Status:  "error",
+		})
+	}
+	if !ETagMatches(ifMatch, currentPersonID.Value) {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusPreconditionFailed,
+			Message: "Person has been modified since the provided version",
+			Status:  "precondition_failed",
+		})
+	}
+
+	var person rm.PERSON
+	if patch {
+		currentPersonJSON, err := h.OpenEHRService.GetPersonByIDRawJSON(ctx, currentPersonID.Value)
+		if err != nil {
+			h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Person", "error", err)
+
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusInternalServerError,
+				Message: "Failed to get current Person",
+				Status:  "error",
+			})
+		}
+
+		if ok, err := PatchBody(c, auditCtx, currentPersonJSON, &person); !ok {
+			return err
+		}
+		person.UID = PatchedUID(person.UID, currentPersonID)
+	} else if err := ParseBody(c, auditCtx, &person); err != nil {
+		return err
+	}
+
+	updatedPerson, err := h.OpenEHRService.UpdatePerson(ctx, currentPersonID, person)
+	if err != nil {
+		if err == ErrPersonNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Person not found for the given person ID",
+				Status:  "not_found",
+			})
+		}
+		if err == ErrPersonVersionLowerOrEqualToCurrent {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "Person version is lower than or equal to the current version",
+				Status:  "conflict",
+			})
+		}
+		if err == ErrInvalidPersonUIDMismatch {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Person UID HIER_OBJECT_ID in request body does not match current Person UID",
+				Status:  "bad_request",
+			})
+		}
+		if validationErrs, ok := err.(util.ValidateError); ok {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Validation error in request body",
+				Status:  "bad_request",
+				Details: validationErrs,
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to update Person", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to update Person",
+			Status:  "error",
+		})
+	}
+	updatedPersonID := updatedPerson.UID.V.OBJECT_VERSION_ID().Value
+
+	auditCtx.Success()
+
+	h.WebhookSink.Enqueue(webhook.EventTypePersonUpdated, map[string]any{
+		"prev_person_uid": currentPersonID.Value,
+		"curr_person_uid": updatedPersonID,
+	})
+
+	c.Set("ETag", "\""+updatedPersonID+"\"")
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/person/"+updatedPersonID)
+
+	switch returnType {
+	case ReturnTypeMinimal:
+		c.Status(fiber.StatusNoContent)
+		return nil
+	case ReturnTypeRepresentation:
+		return SendRepresentation(c, fiber.StatusOK, updatedPerson)
+	case ReturnTypeIdentifier:
+		return c.Status(fiber.StatusOK).JSON(`{"uid":"` + updatedPersonID + `"}`)
+	default:
+		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
+		return SendRepresentation(c, fiber.StatusOK, updatedPerson)
+	}
+}
+
+func (h *Handler) DeletePerson(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	versionID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	if err != nil {
+		return err
+	}
+
+	if strings.Count(versionID, "::") != 2 {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Cannot delete Person by versioned object ID. Please provide the object version ID.",
+			Status:  "bad_request",
+		})
+	}
+
+	versionedPartyID, err := uuid.Parse(strings.Split(versionID, "::")[0])
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Invalid uid_based_id format",
+			Status:  "bad_request",
+		})
+	}
+	currentPersonID, err := h.OpenEHRService.GetCurrentPersonID(ctx, versionedPartyID)
+	if err != nil {
+		if err == ErrPersonNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Person not found for the given person ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Person by ID before deletion", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get Person by ID before deletion",
+			Status:  "error",
+		})
+	}
+	if currentPersonID.Value != versionID {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusPreconditionFailed,
+			Message: "Person has been modified since the provided version",
+			Status:  "precondition_failed",
+		})
+	}
+	if ok, err := IfMatch(c, auditCtx, currentPersonID.Value, "Person"); !ok {
+		return err
+	}
+
+	if err := h.OpenEHRService.DeletePerson(ctx, versionedPartyID); err != nil {
+		if err == ErrPersonNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Person not found for the given person ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete Person by ID", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to delete Person by ID",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	h.WebhookSink.Enqueue(webhook.EventTypePersonDeleted, map[string]any{
+		"versioned_party_id": versionedPartyID,
+	})
+
+	c.Status(fiber.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) CreateOrganisation(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
+	if err != nil {
+		return err
+	}
+
+	returnType, err := ReturnTypeFromHeader(c, auditCtx)
+	if err != nil {
+		return err
+	}
+
+	var organisation rm.ORGANISATION
+	if err := ParseBody(c, auditCtx, &organisation); err != nil {
+		return err
+	}
+
+	createdOrganisation, err := h.OpenEHRService.CreateOrganisation(ctx, organisation)
+	if err != nil {
+		if err == ErrOrganisationAlreadyExists {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "Organisation with the given UID already exists",
+				Status:  "conflict",
+			})
+		}
+		if err, ok := err.(util.ValidateError); ok {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Validation error in request body",
+				Status:  "bad_request",
+				Details: err,
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to create Organisation", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to create Organisation",
+			Status:  "error",
+		})
+	}
+	createdOrganisationID := createdOrganisation.UID.V.OBJECT_VERSION_ID().Value
+
+	auditCtx.Success()
+
+	h.WebhookSink.Enqueue(webhook.EventTypeOrganisationCreated, map[string]any{
+		"organisation_uid": createdOrganisationID,
+	})
+
+	c.Set("ETag", "\""+createdOrganisationID+"\"")
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/organisation/"+createdOrganisationID)
+
+	switch returnType {
+	case ReturnTypeMinimal:
+		c.Status(fiber.StatusCreated)
+		return nil
+	case ReturnTypeRepresentation:
+		return SendRepresentation(c, fiber.StatusCreated, organisation)
+	case ReturnTypeIdentifier:
+		return c.Status(fiber.StatusCreated).JSON(`{"uid":"` + createdOrganisationID + `"}`)
+	default:
+		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
+		return SendRepresentation(c, fiber.StatusCreated, organisation)
+	}
+}
+
+func (h *Handler) GetOrganisation(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	if err != nil {
+		return err
+	}
+
+	var organisationJSON []byte
+	if uuid.Validate(uidBasedID) == nil {
+		organisationJSON, err = h.OpenEHRService.GetOrganisationByVersionedPartyIDRawJSON(ctx, uuid.MustParse(uidBasedID))
+	} else {
+		organisationJSON, err = h.OpenEHRService.GetOrganisationByIDRawJSON(ctx, uidBasedID)
+	}
+	if err != nil {
+		if err == ErrOrganisationNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Organisation not found for the given organisation ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Organisation by ID", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get Organisation by ID",
+			Status:  "error",
+		})
+	}
+
+	return SendRawRepresentation[rm.ORGANISATION](c, fiber.StatusOK, organisationJSON)
+}
+
+func (h *Handler) UpdateOrganisation(c *fiber.Ctx) error {
+	return h.updateOrganisation(c, false)
+}
+
+func (h *Handler) PatchOrganisation(c *fiber.Ctx) error {
+	return h.updateOrganisation(c, true)
+}
+
+func (h *Handler) updateOrganisation(c *fiber.Ctx, patch bool) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
+	if err != nil {
+		return err
+	}
+
+	versionID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	if err != nil {
+		return err
+	}
+
+	ifMatch, err := StringFromHeader(c, auditCtx, "If-Match")
+	if err != nil {
+		return err
+	}
+
+	returnType, err := ReturnTypeFromHeader(c, auditCtx)
+	if err != nil {
+		return err
+	}
+
+	versionedPartyID, err := uuid.Parse(strings.Split(versionID, "::")[0])
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Invalid uid_based_id format",
+			Status:  "bad_request",
+		})
+	}
+
+	currentOrganisationID, err := h.OpenEHRService.GetCurrentOrganisationID(ctx, versionedPartyID)
+	if err != nil {
+		if err == ErrOrganisationNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Organisation not found for the given organisation ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Organisation by ID", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get current Organisation by ID",
+			Status:  "error",
+		})
+	}
+	if !ETagMatches(ifMatch, currentOrganisationID.Value) {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusPreconditionFailed,
+			Message: "Organisation has been modified since the provided version",
+			Status:  "precondition_failed",
+		})
+	}
+
+	var organisation rm.ORGANISATION
+	if patch {
+		currentOrganisationJSON, err := h.OpenEHRService.GetOrganisationByIDRawJSON(ctx, currentOrganisationID.Value)
+		if err != nil {
+			h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Organisation", "error", err)
+
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusInternalServerError,
+				Message: "Failed to get current Organisation",
+				Status:  "error",
+			})
+		}
+
+		if ok, err := PatchBody(c, auditCtx, currentOrganisationJSON, &organisation); !ok {
+			return err
+		}
+		organisation.UID = PatchedUID(organisation.UID, currentOrganisationID)
+	} else if err := ParseBody(c, auditCtx, &organisation); err != nil {
+		return err
+	}
+
+	updatedOrganisation, err := h.OpenEHRService.UpdateOrganisation(ctx, currentOrganisationID, organisation)
+	if err != nil {
+		if err == ErrOrganisationNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Organisation not found for the given organisation ID",
+				Status:  "not_found",
+			})
+		}
+		if err == ErrOrganisationVersionLowerOrEqualToCurrent {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "Organisation version is lower than or equal to the current version",
+				Status:  "conflict",
+			})
+		}
+		if err == ErrInvalidOrganisationUIDMismatch {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "Organisation with the given UID already exists",
+				Status:  "conflict",
+			})
+		}
+		if validationErrs, ok := err.(util.ValidateError); ok {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Validation error in request body",
+				Status:  "bad_request",
+				Details: validationErrs,
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to update Organisation", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to update Organisation",
+			Status:  "error",
+		})
+	}
+	updatedOrganisationID := updatedOrganisation.UID.V.OBJECT_VERSION_ID().Value
+
+	auditCtx.Success()
+
+	h.WebhookSink.Enqueue(webhook.EventTypeOrganisationUpdated, map[string]any{
+		"prev_organisation_uid": currentOrganisationID.Value,
+		"curr_organisation_uid": updatedOrganisationID,
+	})
+
+	c.Set("ETag", "\""+updatedOrganisationID+"\"")
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/organisation/"+updatedOrganisationID)
+
+	switch returnType {
+	case ReturnTypeMinimal:
+		c.Status(fiber.StatusNoContent)
+		return nil
+	case ReturnTypeRepresentation:
+		return SendRepresentation(c, fiber.StatusOK, updatedOrganisation)
+	case ReturnTypeIdentifier:
+		return c.Status(fiber.StatusOK).JSON(`{"uid":"` + updatedOrganisationID + `"}`)
+	default:
+		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
+		return SendRepresentation(c, fiber.StatusOK, updatedOrganisation)
+	}
+}
+
+func (h *Handler) DeleteOrganisation(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	if err != nil {
+		return err
+	}
+
+	if strings.Count(uidBasedID, "::") != 2 {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Cannot delete Organisation by versioned object ID. Please provide the object version ID.",
+			Status:  "bad_request",
+		})
+	}
+
+	versionedPartyID, err := uuid.Parse(strings.Split(uidBasedID, "::")[0])
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Invalid uid_based_id format",
+			Status:  "bad_request",
+		})
+	}
+
+	currentOrganisationID, err := h.OpenEHRService.GetCurrentOrganisationID(ctx, versionedPartyID)
+	if err != nil {
+		if err == ErrOrganisationNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Organisation not found for the given organisation ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Organisation by ID before deletion", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get Organisation by ID before deletion",
+			Status:  "error",
+		})
+	}
+	if currentOrganisationID.Value != uidBasedID {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusPreconditionFailed,
+			Message: "Organisation has been modified since the provided version",
+			Status:  "precondition_failed",
+		})
+	}
+	if ok, err := IfMatch(c, auditCtx, currentOrganisationID.Value, "Organisation"); !ok {
+		return err
+	}
+
+	if err := h.OpenEHRService.DeleteOrganisation(ctx, versionedPartyID); err != nil {
+		if err == ErrOrganisationNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Organisation not found for the given organisation ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete Organisation by ID", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to delete Organisation by ID",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	h.WebhookSink.Enqueue(webhook.EventTypeOrganisationDeleted, map[string]any{
+		"versioned_party_id": versionedPartyID,
+	})
+
+	c.Status(fiber.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) CreateRole(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
+	if err != nil {
+		return err
+	}
+
+	returnType, err := ReturnTypeFromHeader(c, auditCtx)
+	if err != nil {
+		return err
+	}
+
+	var role rm.ROLE
+	if err := ParseBody(c, auditCtx, &role); err != nil {
+		return err
+	}
+
+	createdRole, err := h.OpenEHRService.CreateRole(ctx, role)
+	if err != nil {
+		if err == ErrRoleAlreadyExists {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "Role with the given UID already exists",
+				Status:  "conflict",
+			})
+		}
+		if err, ok := err.(util.ValidateError); ok {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Validation error in request body",
+				Status:  "bad_request",
+				Details: err,
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to create Role", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to create Role",
+			Status:  "error",
+		})
+	}
+	createdRoleID := createdRole.UID.V.OBJECT_VERSION_ID().Value
+
+	auditCtx.Success()
+
+	h.WebhookSink.Enqueue(webhook.EventTypeRoleCreated, map[string]any{
+		"role_uid": createdRoleID,
+	})
+
+	c.Set("ETag", "\""+createdRoleID+"\"")
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/demographic/role/"+createdRoleID)
+
+	switch returnType {
+	case ReturnTypeMinimal:
+		c.Status(fiber.StatusCreated)
+		return nil
+	case ReturnTypeRepresentation:
+		return SendRepresentation(c, fiber.StatusCreated, createdRole)
+	case ReturnTypeIdentifier:
+		return c.Status(fiber.StatusCreated).JSON(`{"uid":"` + createdRoleID + `"}`)
+	default:
+		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
+		return SendRepresentation(c, fiber.StatusCreated, createdRole)
+	}
+}
+
+func (h *Handler) GetRole(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	if err != nil {
+		return err
+	}
+
+	var roleJSON []byte
+	if uuid.Validate(uidBasedID) == nil {
+		roleJSON, err = h.OpenEHRService.GetRoleByVersionedPartyIDRawJSON(ctx, uuid.MustParse(uidBasedID))
+	} else {
+		roleJSON, err = h.OpenEHRService.GetRoleByIDRawJSON(ctx, uidBasedID)
+	}
+	if err != nil {
+		if err == ErrRoleNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Role not found for the given role ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Role by ID", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get Role by ID",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	return SendRawRepresentation[rm.ROLE](c, fiber.StatusOK, roleJSON)
+}
+
+func (h *Handler) UpdateRole(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
+	if err != nil {
+		return err
+	}
+
+	versionID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	if err != nil {
+		return err
+	}
+
+	ifMatch, err := StringFromHeader(c, auditCtx, "If-Match")
+	if err != nil {
+		return err
+	}
+
+	returnType, err := ReturnTypeFromHeader(c, auditCtx)
+	if err != nil {
+		return err
+	}
+
+	versionedPartyID, err := uuid.Parse(strings.Split(versionID, "::")[0])
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Invalid uid_based_id format",
+			Status:  "bad_request",
+		})
+	}
+
+	var role rm.ROLE
+	if err := ParseBody(c, auditCtx, &role); err != nil {
+		return err
+	}
+
+	currentRoleID, err := h.OpenEHRService.GetCurrentRoleID(ctx, versionedPartyID)
+	if err != nil {
+		if err == ErrRoleNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Role not found for the given role ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Role by ID", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get current Role by ID",
+			Status:  "error",
+		})
+	}
+	if !ETagMatches(ifMatch, currentRoleID.Value) {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusPreconditionFailed,
+			Message: "Role has been modified since the provided version",
+			Status:  "precondition_failed",
+		})
+	}
+
+	updatedRole, err := h.OpenEHRService.UpdateRole(ctx, currentRoleID, role)
+	if err != nil {
+		if err == ErrRoleVersionLowerOrEqualToCurrent {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "Role version is lower than or equal to the current version",
+				Status:  "conflict",
+			})
+		}
+		if err == ErrInvalidRoleUIDMismatch {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Role UID in request body does not match current Role UID",
+				Status:  "bad_request",
+			})
+		}
+		if validationErrs, ok := err.(util.ValidateError); ok {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Validation error in request body",
+				Status:  "bad_request",
+				Details: validationErrs,
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to update Role", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to update Role",
+			Status:  "error",
+		})
+	}
+	updatedRoleID := updatedRole.UID.V.OBJECT_VERSION_ID().Value
+
+	auditCtx.Success()
+
+	h.WebhookSink.Enqueue(webhook.EventTypeRoleUpdated, map[string]any{
+		"prev_role_uid": currentRoleID.Value,
+		"curr_role_uid": updatedRoleID,
+	})
+
+	c.Set("ETag", "\""+updatedRoleID+"\"")
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/demographic/role/"+updatedRoleID)
+
+	switch returnType {
+	case ReturnTypeMinimal:
+		c.Status(fiber.StatusNoContent)
+		return nil
+	case ReturnTypeRepresentation:
+		return SendRepresentation(c, fiber.StatusOK, updatedRole)
+	case ReturnTypeIdentifier:
+		return c.Status(fiber.StatusOK).JSON(`{"uid":"` + updatedRoleID + `"}`)
+	default:
+		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
+		return SendRepresentation(c, fiber.StatusOK, updatedRole)
+	}
+}
+
+func (h *Handler) DeleteRole(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	if err != nil {
+		return err
+	}
+
+	if strings.Count(uidBasedID, "::") != 2 {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Cannot delete Role by versioned object ID. Please provide the object version ID.",
+			Status:  "bad_request",
+		})
+	}
+
+	versionedPartyID, err := uuid.Parse(strings.Split(uidBasedID, "::")[0])
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Invalid uid_based_id format",
+			Status:  "bad_request",
+		})
+	}
+
+	currentRoleID, err := h.OpenEHRService.GetCurrentRoleID(ctx, versionedPartyID)
+	if err != nil {
+		if err == ErrRoleNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Role not found for the given role ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Role by ID before deletion", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get Role by ID before deletion",
+			Status:  "error",
+		})
+	}
+	if currentRoleID.Value != uidBasedID {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusPreconditionFailed,
+			Message: "Role has been modified since the provided version",
+			Status:  "precondition_failed",
+		})
+	}
+	if ok, err := IfMatch(c, auditCtx, currentRoleID.Value, "Role"); !ok {
+		return err
+	}
+
+	if err := h.OpenEHRService.DeleteRole(ctx, versionedPartyID); err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete Role by ID", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to delete Role by ID",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	h.WebhookSink.Enqueue(webhook.EventTypeRoleDeleted, map[string]any{
+		"versioned_party_id": versionedPartyID,
+	})
+
+	c.Status(fiber.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) GetVersionedParty(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	versionedObjectID, err := uuid.Parse(c.Params("versioned_object_id"))
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Invalid versioned_object_id format",
+			Status:  "bad_request",
+		})
+	}
+	auditCtx.Event.Details["versioned_party_id"] = versionedObjectID
+
+	versionedPartyJSON, err := h.OpenEHRService.GetVersionedPartyRawJSON(ctx, versionedObjectID)
+	if err != nil {
+		if err == ErrVersionedPartyNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Versioned Party not found for the given ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Versioned Party by ID", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get Versioned Party by ID",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	return SendRawRepresentation[rm.VERSIONED_PARTY](c, fiber.StatusOK, versionedPartyJSON)
+}
+
+func (h *Handler) GetVersionedPartyRevisionHistory(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	versionedObjectID, err := uuid.Parse(c.Params("versioned_object_id"))
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Invalid versioned_object_id format",
+			Status:  "bad_request",
+		})
+	}
+	auditCtx.Event.Details["versioned_party_id"] = versionedObjectID
+
+	revisionHistoryJSON, err := h.OpenEHRService.GetVersionedPartyRevisionHistoryRawJSON(ctx, versionedObjectID)
+	if err != nil {
+		if err == ErrRevisionHistoryNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Versioned Party revision history not found for the given ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Versioned Party revision history", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get Versioned Party revision history",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	return SendRawRepresentation[rm.REVISION_HISTORY](c, fiber.StatusOK, revisionHistoryJSON)
+}
+
+func (h *Handler) GetVersionedPartyVersionAtTime(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	versionedObjectID, err := uuid.Parse(c.Params("versioned_object_id"))
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Invalid versioned_object_id format",
+			Status:  "bad_request",
+		})
+	}
+	auditCtx.Event.Details["versioned_party_id"] = versionedObjectID
+
+	var filterAtTime time.Time
+	if atTimeStr := c.Query("version_at_time"); atTimeStr != "" {
+		parsedTime, err := time.Parse(time.RFC3339, atTimeStr)
+		if err != nil {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Invalid version_at_time format. Use RFC3339 format.",
+				Status:  "bad_request",
+			})
+		}
+		filterAtTime = parsedTime
+	}
+
+	partyVersionJSON, err := h.OpenEHRService.GetVersionedPartyVersionAtTimeRawJSON(ctx, versionedObjectID, filterAtTime)
+	if err != nil {
+		if err == ErrVersionedPartyVersionNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Versioned Party version not found for the given ID at the specified time",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Versioned Party version at time", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get Versioned Party version at time",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	return SendRawRepresentation[rm.OriginalVersionDataUnion](c, fiber.StatusOK, partyVersionJSON)
+}
+
+func (h *Handler) GetVersionedPartyVersion(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	versionedObjectID, err := uuid.Parse(c.Params("versioned_object_id"))
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Invalid versioned_object_id format",
+			Status:  "bad_request",
+		})
+	}
+	auditCtx.Event.Details["versioned_party_id"] = versionedObjectID
+
+	versionID, err := StringFromPath(c, auditCtx, "version_id")
+	if err != nil {
+		return err
+	}
+
+	partyVersionJSON, err := h.OpenEHRService.GetVersionedPartyVersionByIDRawJSON(ctx, versionedObjectID, versionID)
+	if err != nil {
+		if err == ErrVersionedPartyVersionNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Versioned Party version not found for the given version ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Versioned Party version by ID", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get Versioned Party version by ID",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	return SendRawRepresentation[rm.OriginalVersionDataUnion](c, fiber.StatusOK, partyVersionJSON)
+}
+
+// Webhook events emitted for each party type changed by a demographic contribution
+var partyContributionEvents = map[string]struct {
+	Created webhook.EventType
+	Updated webhook.EventType
+	Deleted webhook.EventType
+}{
+	rm.PERSON_TYPE:       {webhook.EventTypePersonCreated, webhook.EventTypePersonUpdated, webhook.EventTypePersonDeleted},
+	rm.AGENT_TYPE:        {webhook.EventTypeAgentCreated, webhook.EventTypeAgentUpdated, webhook.EventTypeAgentDeleted},
+	rm.GROUP_TYPE:        {webhook.EventTypeGroupCreated, webhook.EventTypeGroupUpdated, webhook.EventTypeGroupDeleted},
+	rm.ORGANISATION_TYPE: {webhook.EventTypeOrganisationCreated, webhook.EventTypeOrganisationUpdated, webhook.EventTypeOrganisationDeleted},
+	rm.ROLE_TYPE:         {webhook.EventTypeRoleCreated, webhook.EventTypeRoleUpdated, webhook.EventTypeRoleDeleted},
+}
+
+func (h *Handler) CreateDemographicContribution(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
+	if err != nil {
+		return err
+	}
+
+	returnType, err := ReturnTypeFromHeader(c, auditCtx)
+	if err != nil {
+		return err
+	}
+
+	var request ContributionRequest
+	if err := ParseBody(c, auditCtx, &request); err != nil {
+		return err
+	}
+
+	contribution, changes, err := h.OpenEHRService.CommitDemographicContribution(ctx, request)
+	if err != nil {
+		if err == ErrContributionAlreadyExists {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "Contribution with the given UID already exists",
+				Status:  "conflict",
+			})
+		}
+		if errors.Is(err, ErrVersionedObjectNotFound) {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: err.Error(),
+				Status:  "not_found",
+			})
+		}
+		if errors.Is(err, ErrContributionVersionConflict) {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusPreconditionFailed,
+				Message: err.Error(),
+				Status:  "precondition_failed",
+			})
+		}
+		if errors.Is(err, ErrContributionInvalid) {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: err.Error(),
+				Status:  "bad_request",
+			})
+		}
+		if err == ErrVersionLowerOrEqualToCurrent {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Version in request body is lower or equal to the preceding version",
+				Status:  "bad_request",
+			})
+		}
+		if validationErrs, ok := err.(util.ValidateError); ok {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Validation error in request body",
+				Status:  "bad_request",
+				Details: validationErrs,
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to commit Demographic Contribution", "error", err)
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Status:  "error",
+		})
+	}
+	contributionID := contribution.UID.Value
+	auditCtx.Event.Details["contribution_uid"] = contributionID
+
+	auditCtx.Success()
+
+	for _, change := range changes {
+		events := partyContributionEvents[change.Type]
+		uidKey := strings.ToLower(change.Type) + "_uid"
+
+		switch change.ChangeType {
+		case terminology.AUDIT_CHANGE_TYPE_CODE_CREATION:
+			h.WebhookSink.Enqueue(events.Created, map[string]any{
+				uidKey: change.VersionUID.Value,
+			})
+		case terminology.AUDIT_CHANGE_TYPE_CODE_DELETED:
+			h.WebhookSink.Enqueue(events.Deleted, map[string]any{
+				"versioned_party_id": change.VersionedObjectID,
+			})
+		default:
+			h.WebhookSink.Enqueue(events.Updated, map[string]any{
+				"prev_" + uidKey: change.PrecedingVersionUID.V.Value,
+				"curr_" + uidKey: change.VersionUID.Value,
+			})
+		}
+	}
+
+	c.Set("ETag", "\""+contributionID+"\"")
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/demographic/contribution/"+contributionID)
+
+	switch returnType {
+	case ReturnTypeMinimal:
+		c.Status(fiber.StatusCreated)
+		return nil
+	case ReturnTypeRepresentation:
+		return SendRepresentation(c, fiber.StatusCreated, contribution)
+	case ReturnTypeIdentifier:
+		return c.Status(fiber.StatusCreated).JSON(`{"uid":"` + contributionID + `"}`)
+	default:
+		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
+		return SendRepresentation(c, fiber.StatusCreated, contribution)
+	}
+}
+
+func (h *Handler) GetDemographicContribution(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json", "application/xml")
+	if err != nil {
+		return err
+	}
+
+	contributionUID, err := StringFromPath(c, auditCtx, "contribution_uid")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["contribution_uid"] = contributionUID
+
+	contributionJSON, err := h.OpenEHRService.GetContributionRawJSON(ctx, contributionUID, utils.None[uuid.UUID]())
+	if err != nil {
+		if err == ErrContributionNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Contribution not found for the given Contribution UID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Demographic Contribution by ID", "error", err)
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get Contribution by ID",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	return SendRawRepresentation[rm.CONTRIBUTION](c, fiber.StatusOK, contributionJSON)
+}
+
+func (h *Handler) GetDemographicTags(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json")
+	if err != nil {
+		return err
+	}
+
+	key := c.Query("key")
+	value := c.Query("value")
+	auditCtx.Event.Details["key"] = key
+	auditCtx.Event.Details["value"] = value
+
+	tagsJSON, err := h.OpenEHRService.GetDemographicTagsRawJSON(ctx, key, value)
+	if err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Demographic Tags", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get Demographic Tags",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	c.Set("Content-Type", "application/json")
+	return c.Status(fiber.StatusOK).Send(tagsJSON)
+}
+
+func (h *Handler) GetAgentTags(c *fiber.Ctx) error {
+	return h.getPartyTags(c, rm.AGENT_TYPE)
+}
+
+func (h *Handler) UpdateAgentTags(c *fiber.Ctx) error {
+	return h.updatePartyTags(c, rm.AGENT_TYPE)
+}
+
+func (h *Handler) DeleteAgentTagByKey(c *fiber.Ctx) error {
+	return h.deletePartyTagByKey(c, rm.AGENT_TYPE)
+}
+
+func (h *Handler) GetGroupTags(c *fiber.Ctx) error {
+	return h.getPartyTags(c, rm.GROUP_TYPE)
+}
+
+func (h *Handler) UpdateGroupTags(c *fiber.Ctx) error {
+	return h.updatePartyTags(c, rm.GROUP_TYPE)
+}
+
+func (h *Handler) DeleteGroupTagByKey(c *fiber.Ctx) error {
+	return h.deletePartyTagByKey(c, rm.GROUP_TYPE)
+}
+
+func (h *Handler) GetPersonTags(c *fiber.Ctx) error {
+	return h.getPartyTags(c, rm.PERSON_TYPE)
+}
+
+func (h *Handler) UpdatePersonTags(c *fiber.Ctx) error {
+	return h.updatePartyTags(c, rm.PERSON_TYPE)
+}
+
+func (h *Handler) DeletePersonTagByKey(c *fiber.Ctx) error {
+	return h.deletePartyTagByKey(c, rm.PERSON_TYPE)
+}
+
+func (h *Handler) GetOrganisationTags(c *fiber.Ctx) error {
+	return h.getPartyTags(c, rm.ORGANISATION_TYPE)
+}
+
+func (h *Handler) UpdateOrganisationTags(c *fiber.Ctx) error {
+	return h.updatePartyTags(c, rm.ORGANISATION_TYPE)
+}
+
+func (h *Handler) DeleteOrganisationTagByKey(c *fiber.Ctx) error {
+	return h.deletePartyTagByKey(c, rm.ORGANISATION_TYPE)
+}
+
+func (h *Handler) GetRoleTags(c *fiber.Ctx) error {
+	return h.getPartyTags(c, rm.ROLE_TYPE)
+}
+
+func (h *Handler) UpdateRoleTags(c *fiber.Ctx) error {
+	return h.updatePartyTags(c, rm.ROLE_TYPE)
+}
+
+func (h *Handler) DeleteRoleTagByKey(c *fiber.Ctx) error {
+	return h.deletePartyTagByKey(c, rm.ROLE_TYPE)
+}
+
+// getPartyTags serves the tags of a party version, or of the versioned party when uid_based_id is a UUID
+func (h *Handler) getPartyTags(c *fiber.Ctx, partyType string) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json")
+	if err != nil {
+		return err
+	}
+
+	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["uid_based_id"] = uidBasedID
+
+	tagsJSON, err := h.OpenEHRService.GetPartyTagsRawJSON(ctx, partyType, uidBasedID)
+	if err != nil {
+		if err == ErrVersionedPartyNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Party not found for the given ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Party Tags", "type", partyType, "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get Party Tags",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	c.Set("Content-Type", "application/json")
+	return c.Status(fiber.StatusOK).Send(tagsJSON)
+}
+
+// updatePartyTags replaces the tags of a party version, or of the versioned party when uid_based_id is a UUID
+func (h *Handler) updatePartyTags(c *fiber.Ctx, partyType string) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json")
+	if err != nil {
+		return err
+	}
+
+	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["uid_based_id"] = uidBasedID
+
+	var tags []rm.ITEM_TAG
+	if err := c.BodyParser(&tags); err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Failed to parse request body",
+			Status:  "bad_request",
+		})
+	}
+
+	if ok, err := h.ifMatchPartyTags(c, auditCtx, partyType, uidBasedID); !ok {
+		return err
+	}
+
+	tags, err = h.OpenEHRService.ReplacePartyTags(ctx, partyType, uidBasedID, tags)
+	if err != nil {
+		if err == ErrVersionedPartyNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Party not found for the given ID",
+				Status:  "not_found",
+			})
+		}
+		if err, ok := err.(util.ValidateError); ok {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Validation error in request body",
+				Status:  "bad_request",
+				Details: err,
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to update Party Tags", "type", partyType, "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to update Party Tags",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+	return c.Status(fiber.StatusOK).JSON(tags)
+}
+
+func (h *Handler) deletePartyTagByKey(c *fiber.Ctx, partyType string) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["uid_based_id"] = uidBasedID
+
+	key, err := StringFromPath(c, auditCtx, "key")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["key"] = key
+
+	if ok, err := h.ifMatchPartyTags(c, auditCtx, partyType, uidBasedID); !ok {
+		return err
+	}
+
+	if err := h.OpenEHRService.DeletePartyTagByKey(ctx, partyType, uidBasedID, key); err != nil {
+		if err == ErrTagNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Tag not found for the given party and key",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete Party Tag", "type", partyType, "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to delete Party Tag",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	c.Status(fiber.StatusNoContent)
+	return nil
+}
+
+// ifMatchPartyTags checks If-Match against the latest version of a versioned party, or against the party version the tags go with
+func (h *Handler) ifMatchPartyTags(c *fiber.Ctx, auditCtx *audit.Context, partyType, uidBasedID string) (bool, error) {
+	currentPartyID := uidBasedID
+	if versionedPartyID, err := uuid.Parse(uidBasedID); err == nil {
+		latestPartyID, err := h.OpenEHRService.GetPartyID(c.Context(), partyType, versionedPartyID)
+		if err != nil {
+			if err == ErrVersionedPartyNotFound {
+				return false, SendErrorResponse(c, auditCtx, ErrorResponse{
+					Code:    fiber.StatusNotFound,
+					Message: "Party not found for the given ID",
+					Status:  "not_found",
+				})
+			}
+
+			h.Telemetry.Logger.ErrorContext(c.Context(), "Failed to get current Party", "type", partyType, "error", err)
+			return false, SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusInternalServerError,
+				Message: "Internal server error",
+				Status:  "error",
+			})
+		}
+		currentPartyID = latestPartyID.Value
+	}
+
+	return IfMatch(c, auditCtx, currentPartyID, "Party")
+}
+
+func (h *Handler) ExecuteAdHocAQL(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	query := c.Query("q")
+	if query == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "q query parameter is required",
+			Status:  "bad_request",
+		})
+	}
+
+	ehrID := c.Query("ehr_id")
+	if ehrID != "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotImplemented,
+			Message: "Execute Ad Hoc AQL with ehr_id not implemented yet",
+			Status:  "not_implemented",
+		})
+	}
+
+	fetch := c.Query("fetch")
+	if fetch != "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotImplemented,
+			Message: "Execute Ad Hoc AQL with fetch not implemented yet",
+			Status:  "not_implemented",
+		})
+	}
+
+	offset := c.Query("offset")
+	if offset != "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotImplemented,
+			Message: "Execute Ad Hoc AQL with offset not implemented yet",
+			Status:  "not_implemented",
+		})
+	}
+
+	queryParametersStr := c.Query("query_parameters")
+	queryParametersURLValues, err := url.ParseQuery(queryParametersStr)
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Invalid query_parameters format",
+			Status:  "bad_request",
+		})
+	}
+
+	queryParameters := make(map[string]any)
+	for key, values := range queryParametersURLValues {
+		if len(values) > 0 {
+			queryParameters[key] = values[0]
+		}
+	}
+
+	// Execute AQL query
+	if err := h.OpenEHRService.QueryWithStream(ctx, c.Response().BodyWriter(), query, queryParameters, AQLOptionsFrom(c)); err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to execute Ad Hoc AQL", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to execute Ad Hoc AQL",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	// Register event
+	h.WebhookSink.Enqueue(webhook.EventTypeQueryExecuted, map[string]any{
+		"query":      query,
+		"parameters": queryParameters,
+	})
+
+	c.Set("Content-Type", "application/json")
+	c.Status(fiber.StatusOK)
+	return nil
+}
+
+type AdHocAQLRequest struct {
+	Query           string         `json:"q"`
+	EHRID           string         `json:"ehr_id,omitempty"`
+	Fetch           string         `json:"fetch,omitempty"`
+	Offset          int            `json:"offset,omitempty"`
+	QueryParameters map[string]any `json:"query_parameters,omitempty"`
+}
+
+func (h *Handler) ExecuteAdHocAQLPost(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json")
+	if err != nil {
+		return err
+	}
+
+	var aqlRequest AdHocAQLRequest
+	if err := ParseBody(c, auditCtx, &aqlRequest); err != nil {
+		return err
+	}
+
+	if aqlRequest.Query == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "query field is required in the request body",
+			Status:  "bad_request",
+		})
+	}
+
+	if aqlRequest.EHRID != "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotImplemented,
+			Message: "Execute Ad Hoc AQL Post with ehr_id not implemented yet",
+			Status:  "not_implemented",
+		})
+	}
+
+	if aqlRequest.Fetch != "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotImplemented,
+			Message: "Execute Ad Hoc AQL Post with fetch not implemented yet",
+			Status:  "not_implemented",
+		})
+	}
+
+	if aqlRequest.Offset != 0 {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotImplemented,
+			Message: "Execute Ad Hoc AQL Post with offset not implemented yet",
+			Status:  "not_implemented",
+		})
+	}
+
+	// Execute AQL query
+	if err := h.OpenEHRService.QueryWithStream(ctx, c.Response().BodyWriter(), aqlRequest.Query, aqlRequest.QueryParameters, AQLOptionsFrom(c)); err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to execute Ad Hoc AQL Post", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to execute Ad Hoc AQL Post",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	// Register event
+	h.WebhookSink.Enqueue(webhook.EventTypeQueryExecuted, map[string]any{
+		"query":      aqlRequest.Query,
+		"parameters": aqlRequest.QueryParameters,
+	})
+
+	c.Set("Content-Type", "application/json")
+	c.Status(fiber.StatusOK)
+	return nil
+}
+
+func (h *Handler) ExecuteStoredAQL(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	name := c.Params("qualified_query_name")
+	if name == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "qualified_query_name path parameter is required",
+			Status:  "bad_request",
+		})
+	}
+
+	ehrID := c.Query("ehr_id")
+	if ehrID != "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotImplemented,
+			Message: "Execute Stored AQL with ehr_id not implemented yet",
+			Status:  "not_implemented",
+		})
+	}
+
+	fetch := c.Query("fetch")
+	if fetch != "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotImplemented,
+			Message: "Execute Stored AQL with fetch not implemented yet",
+			Status:  "not_implemented",
+		})
+	}
+
+	offset := c.Query("offset")
+	if offset != "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotImplemented,
+			Message: "Execute Stored AQL with offset not implemented yet",
+			Status:  "not_implemented",
+		})
+	}
+
+	queryParametersStr := c.Query("query_parameters")
+	queryParametersURLValues, err := url.ParseQuery(queryParametersStr)
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Invalid query_parameters format",
+			Status:  "bad_request",
+		})
+	}
+
+	queryParameters := make(map[string]any)
+	for key, values := range queryParametersURLValues {
+		if len(values) > 0 {
+			queryParameters[key] = values[0]
+		}
+	}
+
+	// Retrieve stored query by name
+	storedQuery, err := h.OpenEHRService.GetQueryByName(ctx, name, "")
+	if err != nil {
+		if err == ErrQueryNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Stored query not found for the given name",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get stored query by name", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get stored query by name",
+			Status:  "error",
+		})
+	}
+
+	// Type check the parameters against the ones declared by the stored query
+	queryParameters, err = h.OpenEHRService.CheckQueryParameters(storedQuery, queryParameters)
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: err.Error(),
+			Status:  "bad_request",
+		})
+	}
+
+	// Execute AQL query
+	if err := h.OpenEHRService.QueryWithStream(ctx, c.Response().BodyWriter(), storedQuery.Query, queryParameters, AQLOptionsFrom(c)); err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to execute Stored AQL", "error", err)
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to execute Stored AQL",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	// Register event
+	h.WebhookSink.Enqueue(webhook.EventTypeQueryExecuted, map[string]any{
+		"query_name": name,
+		"parameters": queryParameters,
+	})
+
+	c.Set("Content-Type", "application/json")
+	c.Status(fiber.StatusOK)
+	return nil
+}
+
+type StoredAQLRequest struct {
+	EHRID           string         `json:"ehr_id,omitempty"`
+	Fetch           string         `json:"fetch,omitempty"`
+	Offset          int            `json:"offset,omitempty"`
+	QueryParameters map[string]any `json:"query_parameters,omitempty"`
+}
+
+func (h *Handler) ExecuteStoredAQLPost(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json")
+	if err != nil {
+		return err
+	}
+
+	name := c.Params("qualified_query_name")
+	if name == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "qualified_query_name path parameter is required",
+			Status:  "bad_request",
+		})
+	}
+
+	var aqlRequest StoredAQLRequest
+	if err := ParseBody(c, auditCtx, &aqlRequest); err != nil {
+		return err
+	}
+
+	if aqlRequest.EHRID != "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotImplemented,
+			Message: "Execute Stored AQL Post with ehr_id not implemented yet",
+			Status:  "not_implemented",
+		})
+	}
+
+	if aqlRequest.Fetch != "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotImplemented,
+			Message: "Execute Stored AQL Post with fetch not implemented yet",
+			Status:  "not_implemented",
+		})
+	}
+
+	if aqlRequest.Offset != 0 {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotImplemented,
+			Message: "Execute Stored AQL Post with offset not implemented yet",
+			Status:  "not_implemented",
+		})
+	}
+
+	// Retrieve stored query by name
+	storedQuery, err := h.OpenEHRService.GetQueryByName(ctx, name, "")
+	if err != nil {
+		if err == ErrQueryNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Stored query not found for the given name",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get stored query by name", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get stored query by name",
+			Status:  "error",
+		})
+	}
+
+	// Type check the parameters against the ones declared by the stored query
+	aqlRequest.QueryParameters, err = h.OpenEHRService.CheckQueryParameters(storedQuery, aqlRequest.QueryParameters)
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: err.Error(),
+			Status:  "bad_request",
+		})
+	}
+
+	// Execute AQL query
+	if err := h.OpenEHRService.QueryWithStream(ctx, c.Response().BodyWriter(), storedQuery.Query, aqlRequest.QueryParameters, AQLOptionsFrom(c)); err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to execute Stored AQL Post", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to execute Stored AQL Post",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	// Register event
+	h.WebhookSink.Enqueue(webhook.EventTypeQueryExecuted, map[string]any{
+		"query_name": name,
+		"parameters": aqlRequest.QueryParameters,
+	})
+
+	c.Set("Content-Type", "application/json")
+	c.Status(fiber.StatusOK)
+	return nil
+}
+
+func (h *Handler) ExecuteStoredAQLVersion(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	name := c.Params("qualified_query_name")
+	if name == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "qualified_query_name path parameter is required",
+			Status:  "bad_request",
+		})
+	}
+
+	version := c.Params("version")
+	if version == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "version path parameter is required",
+			Status:  "bad_request",
+		})
+	}
+
+	ehrID := c.Query("ehr_id")
+	if ehrID != "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotImplemented,
+			Message: "Execute Stored AQL Version with ehr_id not implemented yet",
+			Status:  "not_implemented",
+		})
+	}
+
+	fetch := c.Query("fetch")
+	if fetch != "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotImplemented,
+			Message: "Execute Stored AQL Version with fetch not implemented yet",
+			Status:  "not_implemented",
+		})
+	}
+
+	offset := c.Query("offset")
+	if offset != "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotImplemented,
+			Message: "Execute Stored AQL Version with offset not implemented yet",
+			Status:  "not_implemented",
+		})
+	}
+
+	queryParametersStr := c.Query("query_parameters")
+	queryParametersURLValues, err := url.ParseQuery(queryParametersStr)
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Invalid query_parameters format",
+			Status:  "bad_request",
+		})
+	}
+
+	queryParameters := make(map[string]any)
+	for key, values := range queryParametersURLValues {
+		if len(values) > 0 {
+			queryParameters[key] = values[0]
+		}
+	}
+
+	// Retrieve stored query by name and version
+	storedQuery, err := h.OpenEHRService.GetQueryByName(ctx, name, version)
+	if err != nil {
+		if err == ErrQueryNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Stored query not found for the given name and version",
+				Status:  "not_found",
+			})
+		}
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get stored query by name and version", "error", err)
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get stored query by name and version",
+			Status:  "error",
+		})
+	}
+
+	// Type check the parameters against the ones declared by the stored query
+	queryParameters, err = h.OpenEHRService.CheckQueryParameters(storedQuery, queryParameters)
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: err.Error(),
+			Status:  "bad_request",
+		})
+	}
+
+	// Execute AQL query
+	if err := h.OpenEHRService.QueryWithStream(ctx, c.Response().BodyWriter(), storedQuery.Query, queryParameters, AQLOptionsFrom(c)); err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to execute Stored AQL Version", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to execute Stored AQL Version",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	// Register event
+	h.WebhookSink.Enqueue(webhook.EventTypeQueryExecuted, map[string]any{
+		"query_name": name,
+		"version":    version,
+		"parameters": queryParameters,
+	})
+
+	c.Set("Content-Type", "application/json")
+	c.Status(fiber.StatusOK)
+	return nil
+}
+
+type StoredAQLVersionRequest struct {
+	EHRID           string         `json:"ehr_id,omitempty"`
+	Fetch           string         `json:"fetch,omitempty"`
+	Offset          int            `json:"offset,omitempty"`
+	QueryParameters map[string]any `json:"query_parameters,omitempty"`
+}
+
+func (h *Handler) ExecuteStoredAQLVersionPost(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json")
+	if err != nil {
+		return err
+	}
+
+	name := c.Params("qualified_query_name")
+	if name == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "qualified_query_name path parameter is required",
+			Status:  "bad_request",
+		})
+	}
+
+	version := c.Params("version")
+	if version == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "version path parameter is required",
+			Status:  "bad_request",
+		})
+	}
+
+	var aqlRequest StoredAQLVersionRequest
+	if err := ParseBody(c, auditCtx, &aqlRequest); err != nil {
+		return err
+	}
+
+	if aqlRequest.EHRID != "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotImplemented,
+			Message: "Execute Stored AQL Version Post with ehr_id not implemented yet",
+			Status:  "not_implemented",
+		})
+	}
+
+	if aqlRequest.Fetch != "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotImplemented,
+			Message: "Execute Stored AQL Version Post with fetch not implemented yet",
+			Status:  "not_implemented",
+		})
+	}
+
+	if aqlRequest.Offset != 0 {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotImplemented,
+			Message: "Execute Stored AQL Version Post with offset not implemented yet",
+			Status:  "not_implemented",
+		})
+	}
+
+	// Retrieve stored query by name and version
+	storedQuery, err := h.OpenEHRService.GetQueryByName(ctx, name, version)
+	if err != nil {
+		if err == ErrQueryNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Stored query not found for the given name and version",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get stored query by name and version", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get stored query by name and version",
+			Status:  "error",
+		})
+	}
+
+	// Type check the parameters against the ones declared by the stored query
+	aqlRequest.QueryParameters, err = h.OpenEHRService.CheckQueryParameters(storedQuery, aqlRequest.QueryParameters)
+	if err != nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: err.Error(),
+			Status:  "bad_request",
+		})
+	}
+
+	// Execute AQL query
+	if err := h.OpenEHRService.QueryWithStream(ctx, c.Response().BodyWriter(), storedQuery.Query, aqlRequest.QueryParameters, AQLOptionsFrom(c)); err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to execute Stored AQL Version Post", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to execute Stored AQL Version Post",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	// Register event
+	h.WebhookSink.Enqueue(webhook.EventTypeQueryExecuted, map[string]any{
+		"query_name": name,
+		"version":    version,
+		"parameters": aqlRequest.QueryParameters,
+	})
+
+	c.Set("Content-Type", "application/json")
+	c.Status(fiber.StatusOK)
+	return nil
+}
+
+func (h *Handler) GetTemplatesADL14(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotImplemented).SendString("Get Templates ADL1.4 not implemented yet")
+}
+
+func (h *Handler) UploadTemplateADL14(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotImplemented).SendString("Upload Template ADL1.4 not implemented yet")
+}
+
+func (h *Handler) GetTemplateADL14ByID(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotImplemented).SendString("Get Template ADL1.4 By ID not implemented yet")
+}
+
+func (h *Handler) GetTemplatesADL2(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotImplemented).SendString("Get Templates ADL2 not implemented yet")
+}
+
+func (h *Handler) UploadTemplateADL2(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotImplemented).SendString("Upload Template ADL2 not implemented yet")
+}
+
+func (h *Handler) GetTemplateADL2ByID(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotImplemented).SendString("Get Template ADL2 By ID not implemented yet")
+}
+
+func (h *Handler) GetTemplateADL2AtVersion(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotImplemented).SendString("Get Template ADL2 At Version not implemented yet")
+}
+
+func (h *Handler) ListStoredQueries(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	name := c.Params("qualified_query_name")
+	if name == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "qualified_query_name path parameter is required",
+			Status:  "bad_request",
+		})
+	}
+
+	queries, err := h.OpenEHRService.ListStoredQueries(ctx, name)
+	if err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to list stored queries", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to list stored queries",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+	return c.Status(fiber.StatusOK).JSON(queries)
+}
+
+func (h *Handler) StoreQuery(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	if c.Accepts("text/plain") == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotAcceptable,
+			Message: "Accept header must include text/plain",
+			Status:  "not_acceptable",
+		})
+	}
+
+	name := c.Params("qualified_query_name")
+	if name == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "qualified_query_name path parameter is required",
+			Status:  "bad_request",
+		})
+	}
+
+	queryType := c.Query("query_type")
+	if queryType != "" && queryType != "AQL" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Unsupported query_type. Only 'AQL' is supported.",
+			Status:  "bad_request",
+		})
+	}
+
+	query := string(c.Body())
+	if query == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Query in request body is required",
+			Status:  "bad_request",
+		})
+	}
+
+	// Check if query with the same name already exists
+	_, err := h.OpenEHRService.GetQueryByName(ctx, name, "")
+	if err == nil {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusConflict,
+			Message: "Query with the given name already exists, system cannot update without knowing the target version, please use Store Query Version endpoint instead",
+			Status:  "conflict",
+		})
+	}
+	if err != ErrQueryNotFound {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to check existing query by name", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to store query",
+			Status:  "error",
+		})
+	}
+
+	// Store the query
+	err = h.OpenEHRService.StoreQuery(ctx, name, "1.0.0", query)
+	if err != nil {
+		if errors.Is(err, ErrQueryInvalid) {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: err.Error(),
+				Status:  "bad_request",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to store query", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to store query",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	// Register event
+	h.WebhookSink.Enqueue(webhook.EventTypeQueryStored, map[string]any{
+		"query_name": name,
+		"version":    "1.0.0",
+		"query":      query,
+	})
+
+	c.Status(fiber.StatusOK)
+	return nil
+}
+
+func (h *Handler) StoreQueryVersion(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	if c.Accepts("text/plain") == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotAcceptable,
+			Message: "Accept header must include text/plain",
+			Status:  "not_acceptable",
+		})
+	}
+
+	name := c.Params("qualified_query_name")
+	if name == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "qualified_query_name path parameter is required",
+			Status:  "bad_request",
+		})
+	}
+
+	version := c.Params("version")
+	if version == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "version path parameter is required",
+			Status:  "bad_request",
+		})
+	}
+
+	queryType := c.Query("query_type")
+	if queryType != "" && queryType != "AQL" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Unsupported query_type. Only 'AQL' is supported.",
+			Status:  "bad_request",
+		})
+	}
+
+	query := string(c.Body())
+	if query == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Query in request body is required",
+			Status:  "bad_request",
+		})
+	}
+
+	// Store the new version of the query
+	err := h.OpenEHRService.StoreQuery(ctx, name, version, query)
+	if err != nil {
+		if errors.Is(err, ErrQueryInvalid) {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: err.Error(),
+				Status:  "bad_request",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to store query version", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to store query version",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	// Register event
+	h.WebhookSink.Enqueue(webhook.EventTypeQueryStored, map[string]any{
+		"query_name": name,
+		"version":    version,
+		"query":      query,
+	})
+
+	c.Status(fiber.StatusOK)
+	return nil
+}
+
+func (h *Handler) GetStoredQueryAtVersion(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	name := c.Params("qualified_query_name")
+	if name == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "qualified_query_name path parameter is required",
+			Status:  "bad_request",
+		})
+	}
+
+	version := c.Params("version")
+	if version == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "version path parameter is required",
+			Status:  "bad_request",
+		})
+	}
+
+	storedQuery, err := h.OpenEHRService.GetQueryByName(ctx, name, version)
+	if err != nil {
+		if err == ErrQueryNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Stored query not found for the given name and version",
+				Status:  "error",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get stored query by name and version", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get stored query by name and version",
 			Status:  "error",
 		})
 	}
 
 	auditCtx.Success()
+	return c.Status(fiber.StatusOK).JSON(storedQuery)
+}
 
-	h.WebhookSink.Enqueue(webhook.EventTypeOrganisationDeleted, map[string]any{
-		"versioned_party_id": versionedPartyID,
-	})
+func (h *Handler) DeleteStoredQuery(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	name, err := StringFromPath(c, auditCtx, "qualified_query_name")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["query_name"] = name
+
+	err = h.OpenEHRService.DeleteStoredQuery(ctx, name, "")
+	if err != nil {
+		if err == ErrQueryNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Stored query not found for the given name",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete stored query", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to delete stored query",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
 
 	c.Status(fiber.StatusNoContent)
 	return nil
 }
 
-// func (h *Handler) CreateRole(c *fiber.Ctx) error {
-// 	ctx := c.Context()
-// 	auditCtx := middleware.AuditFrom(c)
-
-// 	err := Accepts(c, auditCtx, "application/json")
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	returnType, err := ReturnTypeFromHeader(c, auditCtx)
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	var role rm.ROLE
-// 	if err := ParseBody(c, auditCtx, &role); err != nil {
-// 		return err
-// 	}
-
-// 	createdRole, err := h.OpenEHRService.CreateRole(ctx, role)
-// 	if err != nil {
-// 		if err == ErrRoleAlreadyExists {
-// 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 				Code:    fiber.StatusConflict,
-// 				Message: "Role with the given UID already exists",
-// 				Status:  "conflict",
-// 			})
-// 		}
-// 		if err, ok := err.(util.ValidateError); ok {
-// 			return c.Status(fiber.StatusBadRequest).JSON(err)
-// 		}
-
-// 		h.Telemetry.Logger.ErrorContext(ctx, "Failed to create Role", "error", err)
-
-// 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 			Code:    fiber.StatusInternalServerError,
-// 			Message: "Failed to create Role",
-// 			Status:  "error",
-// 		})
-// 	}
-// 	createdRoleID := createdRole.UID.V.OBJECT_VERSION_ID().Value
-
-// 	auditCtx.Success()
-
-// 	h.WebhookSink.Enqueue(webhook.EventTypeRoleCreated, map[string]any{
-// 		"role_uid": createdRoleID,
-// 	})
-
-// 	c.Set("ETag", "\""+createdRoleID+"\"")
-// 	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/role/"+createdRoleID)
-
-// 	switch returnType {
-// 	case ReturnTypeMinimal:
-// 		c.Status(fiber.StatusCreated)
-// 		return nil
-// 	case ReturnTypeRepresentation:
-// 		return c.Status(fiber.StatusCreated).JSON(role)
-// 	case ReturnTypeIdentifier:
-// 		return c.Status(fiber.StatusCreated).JSON(`{"uid":"` + createdRoleID + `"}`)
-// 	default:
-// 		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
-// 		return c.Status(fiber.StatusCreated).JSON(role)
-// 	}
-// }
-
-// func (h *Handler) GetRole(c *fiber.Ctx) error {
-// 	ctx := c.Context()
-// 	auditCtx := middleware.AuditFrom(c)
-
-// 	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	if strings.Count(uidBasedID, "::") == 2 {
-// 		role, err := h.OpenEHRService.GetRoleAtVersion(ctx, uidBasedID)
-// 		if err != nil {
-// 			if err == ErrRoleNotFound {
-// 				return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 					Code:    fiber.StatusNotFound,
-// 					Message: "Role not found for the given role ID",
-// 					Status:  "not_found",
-// 				})
-// 			}
-
-// 			h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Role by ID", "error", err)
-
-// 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 				Code:    fiber.StatusInternalServerError,
-// 				Message: "Failed to get Role by ID",
-// 				Status:  "error",
-// 			})
-// 		}
-
-// 		auditCtx.Success()
-// 		return c.Status(fiber.StatusOK).JSON(role)
-// 	}
-
-// 	versionedPartyID, err := uuid.Parse(strings.Split(uidBasedID, "::")[0])
-// 	if err != nil {
-// 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 			Code:    fiber.StatusBadRequest,
-// 			Message: "Invalid uid_based_id format",
-// 			Status:  "bad_request",
-// 		})
-// 	}
-
-// 	role, err := h.OpenEHRService.GetCurrentRoleVersionByVersionedPartyID(ctx, versionedPartyID)
-// 	if err != nil {
-// 		if err == ErrRoleNotFound {
-// 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 				Code:    fiber.StatusNotFound,
-// 				Message: "Role not found for the given role ID",
-// 				Status:  "not_found",
-// 			})
-// 		}
-
-// 		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Role by ID", "error", err)
-
-// 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 			Code:    fiber.StatusInternalServerError,
-// 			Message: "Failed to get Role by ID",
-// 			Status:  "error",
-// 		})
-// 	}
-
-// 	auditCtx.Success()
-// 	return c.Status(fiber.StatusOK).JSON(role)
-// }
-
-// func (h *Handler) UpdateRole(c *fiber.Ctx) error {
-// 	ctx := c.Context()
-// 	auditCtx := middleware.AuditFrom(c)
-
-// 	err := Accepts(c, auditCtx, "application/json")
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	versionID, err := StringFromPath(c, auditCtx, "uid_based_id")
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	ifMatch, err := StringFromHeader(c, auditCtx, "If-Match")
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	returnType, err := ReturnTypeFromHeader(c, auditCtx)
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	versionedPartyID, err := uuid.Parse(strings.Split(versionID, "::")[0])
-// 	if err != nil {
-// 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 			Code:    fiber.StatusBadRequest,
-// 			Message: "Invalid uid_based_id format",
-// 			Status:  "bad_request",
-// 		})
-// 	}
-
-// 	var role rm.ROLE
-// 	if err := ParseBody(c, auditCtx, &role); err != nil {
-// 		return err
-// 	}
-
-// 	currentRole, err := h.OpenEHRService.GetCurrentRoleVersionByVersionedPartyID(ctx, versionedPartyID)
-// 	if err != nil {
-// 		if err == ErrRoleNotFound {
-// 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 				Code:    fiber.StatusNotFound,
-// 				Message: "Role not found for the given role ID",
-// 				Status:  "not_found",
-// 			})
-// 		}
-// 		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current Role by ID", "error", err)
-// 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 			Code:    fiber.StatusInternalServerError,
-// 			Message: "Failed to get current Role by ID",
-// 			Status:  "error",
-// 		})
-// 	}
-
-// 	if currentRole.UID.V.OBJECT_VERSION_ID().Value != ifMatch {
-// 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 			Code:    fiber.StatusPreconditionFailed,
-// 			Message: "Role has been modified since the provided version",
-// 			Status:  "precondition_failed",
-// 		})
-// 	}
-
-// 	updatedRole, err := h.OpenEHRService.UpdateRole(ctx, versionedPartyID, role)
-// 	if err != nil {
-// 		if err == ErrRoleNotFound {
-// 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 				Code:    fiber.StatusNotFound,
-// 				Message: "Role not found for the given role ID",
-// 				Status:  "not_found",
-// 			})
-// 		}
-// 		if err == ErrRoleVersionLowerOrEqualToCurrent {
-// 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 				Code:    fiber.StatusConflict,
-// 				Message: "Role version is lower than or equal to the current version",
-// 				Status:  "conflict",
-// 			})
-// 		}
-// 		if err == ErrInvalidRoleUIDMismatch {
-// 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 				Code:    fiber.StatusBadRequest,
-// 				Message: "Role UID HIER_OBJECT_ID in request body does not match current Role UID",
-// 				Status:  "bad_request",
-// 			})
-// 		}
-// 		if validationErrs, ok := err.(util.ValidateError); ok {
-// 			return c.Status(fiber.StatusBadRequest).JSON(validationErrs)
-// 		}
-
-// 		h.Telemetry.Logger.ErrorContext(ctx, "Failed to update Role", "error", err)
-
-// 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 			Code:    fiber.StatusInternalServerError,
-// 			Message: "Failed to update Role",
-// 			Status:  "error",
-// 		})
-// 	}
-// 	updatedRoleID := updatedRole.UID.V.OBJECT_VERSION_ID().Value
-
-// 	auditCtx.Success()
-
-// 	h.WebhookSink.Enqueue(webhook.EventTypeRoleUpdated, map[string]any{
-// 		"prev_role_uid": currentRole.UID.V.OBJECT_VERSION_ID().Value,
-// 		"curr_role_uid": updatedRoleID,
-// 	})
-
-// 	c.Set("ETag", "\""+updatedRoleID+"\"")
-// 	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/role/"+updatedRoleID)
-
-// 	switch returnType {
-// 	case ReturnTypeMinimal:
-// 		c.Status(fiber.StatusNoContent)
-// 		return nil
-// 	case ReturnTypeRepresentation:
-// 		return c.Status(fiber.StatusOK).JSON(updatedRole)
-// 	case ReturnTypeIdentifier:
-// 		return c.Status(fiber.StatusOK).JSON(`{"uid":"` + updatedRoleID + `"}`)
-// 	default:
-// 		h.Telemetry.Logger.WarnContext(ctx, "Unhandled Prefer header value", "value", returnType)
-// 		return c.Status(fiber.StatusOK).JSON(updatedRole)
-// 	}
-// }
-
-// func (h *Handler) DeleteRole(c *fiber.Ctx) error {
-// 	ctx := c.Context()
-
-// 	auditCtx := middleware.AuditFrom(c)
-
-// 	uidBasedID, err := StringFromPath(c, auditCtx, "uid_based_id")
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	if !strings.Contains(uidBasedID, "::") {
-// 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 			Code:    fiber.StatusBadRequest,
-// 			Message: "Cannot delete Role by versioned object ID. Please provide the object version ID.",
-// 			Status:  "bad_request",
-// 		})
-// 	}
-
-// 	versionedPartyID, err := uuid.Parse(strings.Split(uidBasedID, "::")[0])
-// 	if err != nil {
-// 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 			Code:    fiber.StatusBadRequest,
-// 			Message: "Invalid uid_based_id format",
-// 			Status:  "bad_request",
-// 		})
-// 	}
-
-// 	currentRole, err := h.OpenEHRService.GetCurrentRoleVersionByVersionedPartyID(ctx, versionedPartyID)
-// 	if err != nil {
-// 		if err == ErrRoleNotFound {
-// 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 				Code:    fiber.StatusNotFound,
-// 				Message: "Role not found for the given role ID",
-// 				Status:  "not_found",
-// 			})
-// 		}
-
-// 		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Role by ID before deletion", "error", err)
-
-// 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 			Code:    fiber.StatusInternalServerError,
-// 			Message: "Failed to get Role by ID before deletion",
-// 			Status:  "error",
-// 		})
-// 	}
-// 	if currentRole.UID.V.OBJECT_VERSION_ID().Value != uidBasedID {
-// 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 			Code:    fiber.StatusPreconditionFailed,
-// 			Message: "Role has been modified since the provided version",
-// 			Status:  "precondition_failed",
-// 		})
-// 	}
-
-// 	if err := h.OpenEHRService.DeleteRole(ctx, versionedPartyID); err != nil {
-// 		if err == ErrRoleNotFound {
-// 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 				Code:    fiber.StatusNotFound,
-// 				Message: "Role not found for the given role ID",
-// 				Status:  "not_found",
-// 			})
-// 		}
-
-// 		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete Role by ID", "error", err)
-
-// 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 			Code:    fiber.StatusInternalServerError,
-// 			Message: "Failed to delete Role by ID",
-// 			Status:  "error",
-// 		})
-// 	}
-
-// 	auditCtx.Success()
-
-// 	h.WebhookSink.Enqueue(webhook.EventTypeRoleDeleted, map[string]any{
-// 		"versioned_party_id": versionedPartyID,
-// 	})
-
-// 	c.Status(fiber.StatusNoContent)
-// 	return nil
-// }
-
-// func (h *Handler) GetVersionedParty(c *fiber.Ctx) error {
-// 	ctx := c.Context()
-// 	auditCtx := middleware.AuditFrom(c)
-
-// 	versionedObjectID, err := UUIDFromPath(c, auditCtx, "versioned_object_id")
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	party, err := h.OpenEHRService.GetVersionedParty(ctx, versionedObjectID)
-// 	if err != nil {
-// 		if err == ErrVersionedPartyNotFound {
-// 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 				Code:    fiber.StatusNotFound,
-// 				Message: "Versioned Party not found for the given ID",
-// 				Status:  "not_found",
-// 			})
-// 		}
-
-// 		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Versioned Party by ID", "error", err)
-
-// 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 			Code:    fiber.StatusInternalServerError,
-// 			Message: "Failed to get Versioned Party by ID",
-// 			Status:  "error",
-// 		})
-// 	}
-
-// 	auditCtx.Success()
-// 	return c.Status(fiber.StatusOK).JSON(party)
-// }
-
-// func (h *Handler) GetVersionedPartyRevisionHistory(c *fiber.Ctx) error {
-// 	ctx := c.Context()
-// 	auditCtx := middleware.AuditFrom(c)
-
-// 	versionedObjectID, err := UUIDFromPath(c, auditCtx, "versioned_object_id")
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	revisionHistory, err := h.OpenEHRService.GetVersionedPartyRevisionHistory(ctx, versionedObjectID)
-// 	if err != nil {
-// 		if err == ErrRevisionHistoryNotFound {
-// 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 				Code:    fiber.StatusNotFound,
-// 				Message: "Versioned Party not found for the given ID",
-// 				Status:  "not_found",
-// 			})
-// 		}
-
-// 		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Versioned Party Revision History by ID", "error", err)
-
-// 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 			Code:    fiber.StatusInternalServerError,
-// 			Message: "Failed to get Versioned Party Revision History by ID",
-// 			Status:  "error",
-// 		})
-// 	}
-
-// 	auditCtx.Success()
-// 	return c.Status(fiber.StatusOK).JSON(revisionHistory)
-// }
-
-// func (h *Handler) GetVersionedPartyVersionAtTime(c *fiber.Ctx) error {
-// 	ctx := c.Context()
-// 	auditCtx := middleware.AuditFrom(c)
-
-// 	versionedObjectID, err := UUIDFromPath(c, auditCtx, "versioned_object_id")
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	var filterAtTime time.Time
-// 	if c.Query("version_at_time") != "" {
-// 		versionAtTime, err := TimeFromQuery(c, auditCtx, "version_at_time")
-// 		if err != nil {
-// 			return err
-// 		}
-// 		filterAtTime = versionAtTime
-// 	}
-
-// 	partyVersionJSON, err := h.OpenEHRService.GetVersionedPartyVersionJSON(ctx, versionedObjectID, filterAtTime, "")
-// 	if err != nil {
-// 		if err == ErrVersionedPartyNotFound {
-// 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 				Code:    fiber.StatusNotFound,
-// 				Message: "Versioned Party not found for the given ID",
-// 				Status:  "not_found",
-// 			})
-// 		}
-// 		if err == ErrVersionedPartyVersionNotFound {
-// 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 				Code:    fiber.StatusNotFound,
-// 				Message: "Versioned Party version not found for the given time",
-// 				Status:  "not_found",
-// 			})
-// 		}
-
-// 		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Versioned Party Version at Time by ID", "error", err)
-
-// 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 			Code:    fiber.StatusInternalServerError,
-// 			Message: "Failed to get Versioned Party Version at Time by ID",
-// 			Status:  "error",
-// 		})
-// 	}
-
-// 	auditCtx.Success()
-
-// 	c.Set("Content-Type", "application/json")
-// 	return c.Status(fiber.StatusOK).Send(partyVersionJSON)
-// }
-
-// func (h *Handler) GetVersionedPartyVersion(c *fiber.Ctx) error {
-// 	ctx := c.Context()
-// 	auditCtx := middleware.AuditFrom(c)
-
-// 	versionedObjectID, err := UUIDFromPath(c, auditCtx, "versioned_object_id")
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	versionID, err := StringFromPath(c, auditCtx, "version_id")
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	partyVersionJSON, err := h.OpenEHRService.GetVersionedPartyVersionJSON(ctx, versionedObjectID, time.Time{}, versionID)
-// 	if err != nil {
-// 		if err == ErrVersionedPartyNotFound {
-// 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 				Code:    fiber.StatusNotFound,
-// 				Message: "Versioned Party not found for the given ID",
-// 				Status:  "not_found",
-// 			})
-// 		}
-// 		if err == ErrVersionedPartyVersionNotFound {
-// 			return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 				Code:    fiber.StatusNotFound,
-// 				Message: "Versioned Party version not found for the given version ID",
-// 				Status:  "not_found",
-// 			})
-// 		}
-// 		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get Versioned Party Version by ID", "error", err)
-// 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-// 			Code:    fiber.StatusInternalServerError,
-// 			Message: "Failed to get Versioned Party Version by ID",
-// 			Status:  "error",
-// 		})
-// 	}
-
-// 	auditCtx.Success()
-
-// 	c.Set("Content-Type", "application/json")
-// 	return c.Status(fiber.StatusOK).Send(partyVersionJSON)
-// }
+func (h *Handler) DeleteStoredQueryVersion(c *fiber.Ctx) error {
+	ctx := c.Context()
 
-func (h *Handler) CreateDemographicContribution(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Create Demographic Contribution not implemented yet")
-}
+	auditCtx := middleware.AuditFrom(c)
 
-func (h *Handler) GetDemographicContribution(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Get Demographic Contribution not implemented yet")
-}
+	name, err := StringFromPath(c, auditCtx, "qualified_query_name")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["query_name"] = name
 
-func (h *Handler) GetDemographicTags(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Get Demographic Tags not implemented yet")
-}
+	version, err := StringFromPath(c, auditCtx, "version")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["version"] = version
 
-func (h *Handler) GetAgentTags(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Get Agent Tags not implemented yet")
-}
+	err = h.OpenEHRService.DeleteStoredQuery(ctx, name, version)
+	if err != nil {
+		if err == ErrQueryNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Stored query not found for the given name and version",
+				Status:  "not_found",
+			})
+		}
 
-func (h *Handler) UpdateAgentTags(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Update Agent Tags not implemented yet")
-}
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete stored query version", "error", err)
 
-func (h *Handler) DeleteAgentTagByKey(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Delete Agent Tag By Key not implemented yet")
-}
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to delete stored query version",
+			Status:  "error",
+		})
+	}
 
-func (h *Handler) GetGroupTags(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Get Group Tags not implemented yet")
-}
+	auditCtx.Success()
 
-func (h *Handler) UpdateGroupTags(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Update Group Tags not implemented yet")
+	c.Status(fiber.StatusNoContent)
+	return nil
 }
 
-func (h *Handler) DeleteGroupTagByKey(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Delete Group Tag By Key not implemented yet")
+type StandingQueryRequest struct {
+	QueryName    string                 `json:"query_name"`
+	QueryVersion utils.Optional[string] `json:"query_version"`
+	Parameters   map[string]any         `json:"parameters,omitempty"`
 }
 
-func (h *Handler) GetPersonTags(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Get Person Tags not implemented yet")
-}
+func (h *Handler) ListStandingQueries(c *fiber.Ctx) error {
+	ctx := c.Context()
 
-func (h *Handler) UpdatePersonTags(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Update Person Tags not implemented yet")
+	auditCtx := middleware.AuditFrom(c)
+
+	standingQueries, err := h.OpenEHRService.ListStandingQueries(ctx, false)
+	if err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to list standing queries", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to list standing queries",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+	return c.Status(fiber.StatusOK).JSON(standingQueries)
 }
 
-func (h *Handler) DeletePersonTagByKey(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Delete Person Tag By Key not implemented yet")
+func (h *Handler) RegisterStandingQuery(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json")
+	if err != nil {
+		return err
+	}
+
+	var request StandingQueryRequest
+	if err := ParseBody(c, auditCtx, &request); err != nil {
+		return err
+	}
+
+	if request.QueryName == "" {
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "query_name field is required in the request body",
+			Status:  "bad_request",
+		})
+	}
+	auditCtx.Event.Details["query_name"] = request.QueryName
+
+	standingQuery, err := h.OpenEHRService.RegisterStandingQuery(ctx, request.QueryName, request.QueryVersion, request.Parameters, AQLOptionsFrom(c).EHRAccess)
+	if err != nil {
+		if err == ErrQueryNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Stored query not found for the given name and version",
+				Status:  "not_found",
+			})
+		}
+		if errors.Is(err, ErrStandingQueryInvalid) {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: err.Error(),
+				Status:  "bad_request",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to register standing query", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to register standing query",
+			Status:  "error",
+		})
+	}
+	auditCtx.Event.Details["standing_query_id"] = standingQuery.ID
+
+	auditCtx.Success()
+
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/standing_query/"+standingQuery.ID.String())
+	return c.Status(fiber.StatusCreated).JSON(standingQuery)
 }
 
-func (h *Handler) GetOrganisationTags(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Get Organisation Tags not implemented yet")
+func (h *Handler) GetStandingQuery(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	standingQueryID, err := UUIDFromPath(c, auditCtx, "standing_query_id")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["standing_query_id"] = standingQueryID
+
+	standingQuery, err := h.OpenEHRService.GetStandingQuery(ctx, standingQueryID)
+	if err != nil {
+		if err == ErrStandingQueryNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Standing query not found for the given ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get standing query", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get standing query",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+	return c.Status(fiber.StatusOK).JSON(standingQuery)
 }
 
-func (h *Handler) UpdateOrganisationTags(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Update Organisation Tags not implemented yet")
+func (h *Handler) DeleteStandingQuery(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	standingQueryID, err := UUIDFromPath(c, auditCtx, "standing_query_id")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["standing_query_id"] = standingQueryID
+
+	err = h.OpenEHRService.DeleteStandingQuery(ctx, standingQueryID)
+	if err != nil {
+		if err == ErrStandingQueryNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Standing query not found for the given ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete standing query", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to delete standing query",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	c.Status(fiber.StatusNoContent)
+	return nil
 }
 
-func (h *Handler) DeleteOrganisationTagByKey(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Delete Organisation Tag By Key not implemented yet")
+type CohortRequest struct {
+	Name                   string                `json:"name"`
+	Description            string                `json:"description"`
+	Query                  string                `json:"query"`
+	Parameters             map[string]any        `json:"parameters,omitempty"`
+	RefreshIntervalSeconds utils.Optional[int64] `json:"refresh_interval_seconds"`
 }
 
-func (h *Handler) GetRoleTags(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Get Role Tags not implemented yet")
+func (h *Handler) ListCohorts(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	cohorts, err := h.OpenEHRService.ListCohorts(ctx)
+	if err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to list cohorts", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to list cohorts",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+	return c.Status(fiber.StatusOK).JSON(cohorts)
 }
 
-func (h *Handler) UpdateRoleTags(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Update Role Tags not implemented yet")
+func (h *Handler) CreateCohort(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json")
+	if err != nil {
+		return err
+	}
+
+	var request CohortRequest
+	if err := ParseBody(c, auditCtx, &request); err != nil {
+		return err
+	}
+	auditCtx.Event.Details["cohort_name"] = request.Name
+
+	cohort, err := h.OpenEHRService.CreateCohort(ctx, Cohort{
+		Name:                   request.Name,
+		Description:            request.Description,
+		Query:                  request.Query,
+		Parameters:             request.Parameters,
+		RefreshIntervalSeconds: request.RefreshIntervalSeconds,
+		EHRAccess:              AQLOptionsFrom(c).EHRAccess,
+	})
+	if err != nil {
+		if errors.Is(err, ErrCohortInvalid) {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: err.Error(),
+				Status:  "bad_request",
+			})
+		}
+		if err == ErrCohortAlreadyExists {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "Cohort with the given name already exists",
+				Status:  "conflict",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to create cohort", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to create cohort",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/cohort/"+cohort.Name)
+	return c.Status(fiber.StatusCreated).JSON(cohort)
 }
 
-func (h *Handler) DeleteRoleTagByKey(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Delete Role Tag By Key not implemented yet")
+func (h *Handler) GetCohort(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	cohortName, err := StringFromPath(c, auditCtx, "cohort_name")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["cohort_name"] = cohortName
+
+	cohort, err := h.OpenEHRService.GetCohort(ctx, cohortName)
+	if err != nil {
+		if err == ErrCohortNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Cohort not found for the given name",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get cohort", "error", err)
+
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get cohort",
+			Status:  "error",
+		})
+	}
+
+	auditCtx.Success()
+	return c.Status(fiber.StatusOK).JSON(cohort)
 }
 
-func (h *Handler) ExecuteAdHocAQL(c *fiber.Ctx) error {
+func (h *Handler) DeleteCohort(c *fiber.Ctx) error {
 	ctx := c.Context()
 
 	auditCtx := middleware.AuditFrom(c)
 
-	query := c.Query("q")
-	if query == "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "q query parameter is required",
-			Status:  "bad_request",
-		})
+	cohortName, err := StringFromPath(c, auditCtx, "cohort_name")
+	if err != nil {
+		return err
 	}
+	auditCtx.Event.Details["cohort_name"] = cohortName
 
-	ehrID := c.Query("ehr_id")
-	if ehrID != "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotImplemented,
-			Message: "Execute Ad Hoc AQL with ehr_id not implemented yet",
-			Status:  "not_implemented",
-		})
-	}
+	err = h.OpenEHRService.DeleteCohort(ctx, cohortName)
+	if err != nil {
+		if err == ErrCohortNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Cohort not found for the given name",
+				Status:  "not_found",
+			})
+		}
 
-	fetch := c.Query("fetch")
-	if fetch != "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotImplemented,
-			Message: "Execute Ad Hoc AQL with fetch not implemented yet",
-			Status:  "not_implemented",
-		})
-	}
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete cohort", "error", err)
 
-	offset := c.Query("offset")
-	if offset != "" {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotImplemented,
-			Message: "Execute Ad Hoc AQL with offset not implemented yet",
-			Status:  "not_implemented",
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to delete cohort",
+			Status:  "error",
 		})
 	}
 
-	queryParametersStr := c.Query("query_parameters")
-	queryParametersURLValues, err := url.ParseQuery(queryParametersStr)
+	auditCtx.Success()
+
+	c.Status(fiber.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) RefreshCohort(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	cohortName, err := StringFromPath(c, auditCtx, "cohort_name")
 	if err != nil {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "Invalid query_parameters format",
-			Status:  "bad_request",
-		})
+		return err
 	}
+	auditCtx.Event.Details["cohort_name"] = cohortName
 
-	queryParameters := make(map[string]any)
-	for key, values := range queryParametersURLValues {
-		if len(values) > 0 {
-			queryParameters[key] = values[0]
+	version, err := h.OpenEHRService.RefreshCohort(ctx, cohortName)
+	if err != nil {
+		if err == ErrCohortNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Cohort not found for the given name",
+				Status:  "not_found",
+			})
+		}
+		if errors.Is(err, ErrCohortInvalid) {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: err.Error(),
+				Status:  "bad_request",
+			})
 		}
-	}
 
-	// Execute AQL query
-	if err := h.OpenEHRService.QueryWithStream(ctx, c.Response().BodyWriter(), query, queryParameters); err != nil {
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to execute Ad Hoc AQL", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to refresh cohort", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to execute Ad Hoc AQL",
+			Message: "Failed to refresh cohort",
 			Status:  "error",
 		})
 	}
+	auditCtx.Event.Details["version"] = version.Version
 
 	auditCtx.Success()
-
-	// Register event
-	h.WebhookSink.Enqueue(webhook.EventTypeQueryExecuted, map[string]any{
-		"query":      query,
-		"parameters": queryParameters,
-	})
-
-	c.Set("Content-Type", "application/json")
-	c.Status(fiber.StatusOK)
-	return nil
-}
-
-type AdHocAQLRequest struct {
-	Query           string         `json:"q"`
-	EHRID           string         `json:"ehr_id,omitempty"`
-	Fetch           string         `json:"fetch,omitempty"`
-	Offset          int            `json:"offset,omitempty"`
-	QueryParameters map[string]any `json:"query_parameters,omitempty"`
+	return c.Status(fiber.StatusCreated).JSON(version)
 }
 
-func (h *Handler) ExecuteAdHocAQLPost(c *fiber.Ctx) error {
+func (h *Handler) ListCohortVersions(c *fiber.Ctx) error {
 	ctx := c.Context()
 
 	auditCtx := middleware.AuditFrom(c)
 
-	err := Accepts(c, auditCtx, "application/json")
+	cohortName, err := StringFromPath(c, auditCtx, "cohort_name")
 	if err != nil {
 		return err
 	}
+	auditCtx.Event.Details["cohort_name"] = cohortName
 
-	var aqlRequest AdHocAQLRequest
-	if err := ParseBody(c, auditCtx, &aqlRequest); err != nil {
-		return err
-	}
+	versions, err := h.OpenEHRService.ListCohortVersions(ctx, cohortName)
+	if err != nil {
+		if err == ErrCohortNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Cohort not found for the given name",
+				Status:  "not_found",
+			})
+		}
 
-	if aqlRequest.Query == "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "query field is required in the request body",
-			Status:  "bad_request",
-		})
-	}
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to list cohort versions", "error", err)
 
-	if aqlRequest.EHRID != "" {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotImplemented,
-			Message: "Execute Ad Hoc AQL Post with ehr_id not implemented yet",
-			Status:  "not_implemented",
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to list cohort versions",
+			Status:  "error",
 		})
 	}
 
-	if aqlRequest.Fetch != "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotImplemented,
-			Message: "Execute Ad Hoc AQL Post with fetch not implemented yet",
-			Status:  "not_implemented",
-		})
+	auditCtx.Success()
+	return c.Status(fiber.StatusOK).JSON(versions)
+}
+
+func (h *Handler) GetCohortMembers(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	cohortName, err := StringFromPath(c, auditCtx, "cohort_name")
+	if err != nil {
+		return err
 	}
+	auditCtx.Event.Details["cohort_name"] = cohortName
 
-	if aqlRequest.Offset != 0 {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotImplemented,
-			Message: "Execute Ad Hoc AQL Post with offset not implemented yet",
-			Status:  "not_implemented",
-		})
+	version := utils.None[int]()
+	if versionStr := c.Query("version"); versionStr != "" {
+		number, err := strconv.Atoi(versionStr)
+		if err != nil || number < 1 {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Invalid version query parameter, must be a positive integer",
+				Status:  "bad_request",
+			})
+		}
+		version = utils.Some(number)
+		auditCtx.Event.Details["version"] = number
 	}
 
-	// Execute AQL query
-	if err := h.OpenEHRService.QueryWithStream(ctx, c.Response().BodyWriter(), aqlRequest.Query, aqlRequest.QueryParameters); err != nil {
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to execute Ad Hoc AQL Post", "error", err)
+	members, err := h.OpenEHRService.GetCohortMembers(ctx, cohortName, version)
+	if err != nil {
+		if err == ErrCohortNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Cohort version not found for the given name",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get cohort members", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to execute Ad Hoc AQL Post",
+			Message: "Failed to get cohort members",
 			Status:  "error",
 		})
 	}
 
 	auditCtx.Success()
+	return c.Status(fiber.StatusOK).JSON(members)
+}
 
-	// Register event
-	h.WebhookSink.Enqueue(webhook.EventTypeQueryExecuted, map[string]any{
-		"query":      aqlRequest.Query,
-		"parameters": aqlRequest.QueryParameters,
-	})
-
-	c.Set("Content-Type", "application/json")
-	c.Status(fiber.StatusOK)
-	return nil
+type ReportRequest struct {
+	Name                  string                    `json:"name"`
+	QueryName             string                    `json:"query_name"`
+	QueryVersion          utils.Optional[string]    `json:"query_version"`
+	Parameters            map[string]any            `json:"parameters,omitempty"`
+	Schedule              string                    `json:"schedule"`
+	Format                ReportFormat              `json:"format"`
+	Delivery              ReportDelivery            `json:"delivery"`
+	WebhookSubscriptionID utils.Optional[uuid.UUID] `json:"webhook_subscription_id"`
 }
 
-func (h *Handler) ExecuteStoredAQL(c *fiber.Ctx) error {
+func (h *Handler) ListReports(c *fiber.Ctx) error {
 	ctx := c.Context()
 
 	auditCtx := middleware.AuditFrom(c)
 
-	name := c.Params("qualified_query_name")
-	if name == "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "qualified_query_name path parameter is required",
-			Status:  "bad_request",
-		})
-	}
+	reports, err := h.OpenEHRService.ListReports(ctx)
+	if err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to list reports", "error", err)
 
-	ehrID := c.Query("ehr_id")
-	if ehrID != "" {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotImplemented,
-			Message: "Execute Stored AQL with ehr_id not implemented yet",
-			Status:  "not_implemented",
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to list reports",
+			Status:  "error",
 		})
 	}
 
-	fetch := c.Query("fetch")
-	if fetch != "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotImplemented,
-			Message: "Execute Stored AQL with fetch not implemented yet",
-			Status:  "not_implemented",
-		})
-	}
+	auditCtx.Success()
+	return c.Status(fiber.StatusOK).JSON(reports)
+}
 
-	offset := c.Query("offset")
-	if offset != "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotImplemented,
-			Message: "Execute Stored AQL with offset not implemented yet",
-			Status:  "not_implemented",
-		})
-	}
+func (h *Handler) CreateReport(c *fiber.Ctx) error {
+	ctx := c.Context()
 
-	queryParametersStr := c.Query("query_parameters")
-	queryParametersURLValues, err := url.ParseQuery(queryParametersStr)
+	auditCtx := middleware.AuditFrom(c)
+
+	err := Accepts(c, auditCtx, "application/json")
 	if err != nil {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "Invalid query_parameters format",
-			Status:  "bad_request",
-		})
+		return err
 	}
 
-	queryParameters := make(map[string]any)
-	for key, values := range queryParametersURLValues {
-		if len(values) > 0 {
-			queryParameters[key] = values[0]
+	var request ReportRequest
+	if err := ParseBody(c, auditCtx, &request); err != nil {
+		return err
+	}
+	auditCtx.Event.Details["report_name"] = request.Name
+
+	if request.WebhookSubscriptionID.E {
+		exists, err := h.WebhookService.ExistsSubscription(ctx, request.WebhookSubscriptionID.V)
+		if err != nil {
+			h.Telemetry.Logger.ErrorContext(ctx, "Failed to check webhook subscription existence", "error", err)
+
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusInternalServerError,
+				Message: "Failed to create report",
+				Status:  "error",
+			})
+		}
+		if !exists {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Webhook subscription not found for the given webhook_subscription_id",
+				Status:  "bad_request",
+			})
 		}
 	}
 
-	// Retrieve stored query by name
-	storedQuery, err := h.OpenEHRService.GetQueryByName(ctx, name, "")
+	report, err := h.OpenEHRService.CreateReport(ctx, Report{
+		Name:                  request.Name,
+		QueryName:             request.QueryName,
+		QueryVersion:          request.QueryVersion,
+		Parameters:            request.Parameters,
+		Schedule:              request.Schedule,
+		Format:                request.Format,
+		Delivery:              request.Delivery,
+		WebhookSubscriptionID: request.WebhookSubscriptionID,
+		EHRAccess:             AQLOptionsFrom(c).EHRAccess,
+	})
 	if err != nil {
+		if errors.Is(err, ErrReportInvalid) {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: err.Error(),
+				Status:  "bad_request",
+			})
+		}
 		if err == ErrQueryNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Stored query not found for the given name",
+				Message: "Stored query not found for the given name and version",
 				Status:  "not_found",
 			})
 		}
+		if err == ErrReportAlreadyExists {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "Report with the given name already exists",
+				Status:  "conflict",
+			})
+		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get stored query by name", "error", err)
-
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get stored query by name",
-			Status:  "error",
-		})
-	}
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to create report", "error", err)
 
-	// Execute AQL query
-	if err := h.OpenEHRService.QueryWithStream(ctx, c.Response().BodyWriter(), storedQuery.Query, nil); err != nil {
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to execute Stored AQL", "error", err)
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to execute Stored AQL",
+			Message: "Failed to create report",
 			Status:  "error",
 		})
 	}
+	auditCtx.Event.Details["report_id"] = report.ID
 
 	auditCtx.Success()
 
-	// Register event
-	h.WebhookSink.Enqueue(webhook.EventTypeQueryExecuted, map[string]any{
-		"query_name": name,
-		"parameters": queryParameters,
-	})
-
-	c.Set("Content-Type", "application/json")
-	c.Status(fiber.StatusOK)
-	return nil
-}
-
-type StoredAQLRequest struct {
-	EHRID           string         `json:"ehr_id,omitempty"`
-	Fetch           string         `json:"fetch,omitempty"`
-	Offset          int            `json:"offset,omitempty"`
-	QueryParameters map[string]any `json:"query_parameters,omitempty"`
+	c.Set("Location", c.Protocol()+"://"+c.Hostname()+"/openehr/v1/report/"+report.ID.String())
+	return c.Status(fiber.StatusCreated).JSON(report)
 }
 
-func (h *Handler) ExecuteStoredAQLPost(c *fiber.Ctx) error {
+func (h *Handler) GetReport(c *fiber.Ctx) error {
 	ctx := c.Context()
 
 	auditCtx := middleware.AuditFrom(c)
 
-	err := Accepts(c, auditCtx, "application/json")
+	reportID, err := UUIDFromPath(c, auditCtx, "report_id")
 	if err != nil {
 		return err
 	}
+	auditCtx.Event.Details["report_id"] = reportID
 
-	name := c.Params("qualified_query_name")
-	if name == "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "qualified_query_name path parameter is required",
-			Status:  "bad_request",
-		})
-	}
+	report, err := h.OpenEHRService.GetReport(ctx, reportID)
+	if err != nil {
+		if err == ErrReportNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Report not found for the given ID",
+				Status:  "not_found",
+			})
+		}
 
-	var aqlRequest StoredAQLRequest
-	if err := ParseBody(c, auditCtx, &aqlRequest); err != nil {
-		return err
-	}
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get report", "error", err)
 
-	if aqlRequest.EHRID != "" {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotImplemented,
-			Message: "Execute Stored AQL Post with ehr_id not implemented yet",
-			Status:  "not_implemented",
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get report",
+			Status:  "error",
 		})
 	}
 
-	if aqlRequest.Fetch != "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotImplemented,
-			Message: "Execute Stored AQL Post with fetch not implemented yet",
-			Status:  "not_implemented",
-		})
-	}
+	auditCtx.Success()
+	return c.Status(fiber.StatusOK).JSON(report)
+}
 
-	if aqlRequest.Offset != 0 {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotImplemented,
-			Message: "Execute Stored AQL Post with offset not implemented yet",
-			Status:  "not_implemented",
-		})
+func (h *Handler) DeleteReport(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	reportID, err := UUIDFromPath(c, auditCtx, "report_id")
+	if err != nil {
+		return err
 	}
+	auditCtx.Event.Details["report_id"] = reportID
 
-	// Retrieve stored query by name
-	storedQuery, err := h.OpenEHRService.GetQueryByName(ctx, name, "")
+	err = h.OpenEHRService.DeleteReport(ctx, reportID)
 	if err != nil {
-		if err == ErrQueryNotFound {
+		if err == ErrReportNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Stored query not found for the given name",
+				Message: "Report not found for the given ID",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get stored query by name", "error", err)
-
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get stored query by name",
-			Status:  "error",
-		})
-	}
-
-	// Execute AQL query
-	if err := h.OpenEHRService.QueryWithStream(ctx, c.Response().BodyWriter(), storedQuery.Query, aqlRequest.QueryParameters); err != nil {
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to execute Stored AQL Post", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete report", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to execute Stored AQL Post",
+			Message: "Failed to delete report",
 			Status:  "error",
 		})
 	}
 
 	auditCtx.Success()
 
-	// Register event
-	h.WebhookSink.Enqueue(webhook.EventTypeQueryExecuted, map[string]any{
-		"query_name": name,
-		"parameters": aqlRequest.QueryParameters,
-	})
-
-	c.Set("Content-Type", "application/json")
-	c.Status(fiber.StatusOK)
+	c.Status(fiber.StatusNoContent)
 	return nil
 }
 
-func (h *Handler) ExecuteStoredAQLVersion(c *fiber.Ctx) error {
+func (h *Handler) ListReportRuns(c *fiber.Ctx) error {
 	ctx := c.Context()
 
 	auditCtx := middleware.AuditFrom(c)
 
-	name := c.Params("qualified_query_name")
-	if name == "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "qualified_query_name path parameter is required",
-			Status:  "bad_request",
-		})
-	}
-
-	version := c.Params("version")
-	if version == "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "version path parameter is required",
-			Status:  "bad_request",
-		})
-	}
-
-	ehrID := c.Query("ehr_id")
-	if ehrID != "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotImplemented,
-			Message: "Execute Stored AQL Version with ehr_id not implemented yet",
-			Status:  "not_implemented",
-		})
-	}
-
-	fetch := c.Query("fetch")
-	if fetch != "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotImplemented,
-			Message: "Execute Stored AQL Version with fetch not implemented yet",
-			Status:  "not_implemented",
-		})
-	}
-
-	offset := c.Query("offset")
-	if offset != "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotImplemented,
-			Message: "Execute Stored AQL Version with offset not implemented yet",
-			Status:  "not_implemented",
-		})
-	}
-
-	queryParametersStr := c.Query("query_parameters")
-	queryParametersURLValues, err := url.ParseQuery(queryParametersStr)
+	reportID, err := UUIDFromPath(c, auditCtx, "report_id")
 	if err != nil {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "Invalid query_parameters format",
-			Status:  "bad_request",
-		})
-	}
-
-	queryParameters := make(map[string]any)
-	for key, values := range queryParametersURLValues {
-		if len(values) > 0 {
-			queryParameters[key] = values[0]
-		}
+		return err
 	}
+	auditCtx.Event.Details["report_id"] = reportID
 
-	// Retrieve stored query by name and version
-	storedQuery, err := h.OpenEHRService.GetQueryByName(ctx, name, version)
+	runs, err := h.OpenEHRService.ListReportRuns(ctx, reportID)
 	if err != nil {
-		if err == ErrQueryNotFound {
+		if err == ErrReportNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Stored query not found for the given name and version",
+				Message: "Report not found for the given ID",
 				Status:  "not_found",
 			})
 		}
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get stored query by name and version", "error", err)
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get stored query by name and version",
-			Status:  "error",
-		})
-	}
 
-	// Execute AQL query
-	if err := h.OpenEHRService.QueryWithStream(ctx, c.Response().BodyWriter(), storedQuery.Query, queryParameters); err != nil {
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to execute Stored AQL Version", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to list report runs", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to execute Stored AQL Version",
+			Message: "Failed to list report runs",
 			Status:  "error",
 		})
 	}
 
 	auditCtx.Success()
-
-	// Register event
-	h.WebhookSink.Enqueue(webhook.EventTypeQueryExecuted, map[string]any{
-		"query_name": name,
-		"version":    version,
-		"parameters": queryParameters,
-	})
-
-	c.Set("Content-Type", "application/json")
-	c.Status(fiber.StatusOK)
-	return nil
-}
-
-type StoredAQLVersionRequest struct {
-	EHRID           string         `json:"ehr_id,omitempty"`
-	Fetch           string         `json:"fetch,omitempty"`
-	Offset          int            `json:"offset,omitempty"`
-	QueryParameters map[string]any `json:"query_parameters,omitempty"`
+	return c.Status(fiber.StatusOK).JSON(runs)
 }
 
-func (h *Handler) ExecuteStoredAQLVersionPost(c *fiber.Ctx) error {
+func (h *Handler) GetReportRunOutput(c *fiber.Ctx) error {
 	ctx := c.Context()
 
 	auditCtx := middleware.AuditFrom(c)
 
-	err := Accepts(c, auditCtx, "application/json")
+	reportID, err := UUIDFromPath(c, auditCtx, "report_id")
 	if err != nil {
 		return err
 	}
+	auditCtx.Event.Details["report_id"] = reportID
 
-	name := c.Params("qualified_query_name")
-	if name == "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "qualified_query_name path parameter is required",
-			Status:  "bad_request",
-		})
+	runID, err := UUIDFromPath(c, auditCtx, "run_id")
+	if err != nil {
+		return err
 	}
+	auditCtx.Event.Details["run_id"] = runID
+
+	report, err := h.OpenEHRService.GetReport(ctx, reportID)
+	if err != nil {
+		if err == ErrReportNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Report not found for the given ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get report", "error", err)
 
-	version := c.Params("version")
-	if version == "" {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "version path parameter is required",
-			Status:  "bad_request",
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get report run output",
+			Status:  "error",
 		})
 	}
 
-	var aqlRequest StoredAQLVersionRequest
-	if err := ParseBody(c, auditCtx, &aqlRequest); err != nil {
-		return err
-	}
+	run, err := h.OpenEHRService.GetReportRun(ctx, reportID, runID)
+	if err != nil {
+		if err == ErrReportRunNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "Report run not found for the given ID",
+				Status:  "not_found",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get report run", "error", err)
 
-	if aqlRequest.EHRID != "" {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotImplemented,
-			Message: "Execute Stored AQL Version Post with ehr_id not implemented yet",
-			Status:  "not_implemented",
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get report run output",
+			Status:  "error",
 		})
 	}
 
-	if aqlRequest.Fetch != "" {
+	if !run.OutputPath.E {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotImplemented,
-			Message: "Execute Stored AQL Version Post with fetch not implemented yet",
-			Status:  "not_implemented",
+			Code:    fiber.StatusNotFound,
+			Message: "Report run has no output",
+			Status:  "not_found",
 		})
 	}
 
-	if aqlRequest.Offset != 0 {
+	if err := c.SendFile(run.OutputPath.V); err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to send report run output", "error", err)
+
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotImplemented,
-			Message: "Execute Stored AQL Version Post with offset not implemented yet",
-			Status:  "not_implemented",
+			Code:    fiber.StatusInternalServerError,
+			Message: "Failed to get report run output",
+			Status:  "error",
 		})
 	}
+	c.Set("Content-Type", report.Format.ContentType())
 
-	// Retrieve stored query by name and version
-	storedQuery, err := h.OpenEHRService.GetQueryByName(ctx, name, version)
+	auditCtx.Success()
+	return nil
+}
+
+func (h *Handler) DeleteEHRByID(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	auditCtx := middleware.AuditFrom(c)
+
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
 	if err != nil {
-		if err == ErrQueryNotFound {
+		return err
+	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
+
+	// The EHR Status holds the modifiable state of the EHR, so its version is the EHR's entity tag
+	currentEHRStatusID, err := h.OpenEHRService.GetEHRStatusID(ctx, ehrID)
+	if err != nil {
+		if err == ErrEHRStatusNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Stored query not found for the given name and version",
+				Message: "EHR with the given ID not found",
 				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get stored query by name and version", "error", err)
-
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current EHR Status", "error", err)
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get stored query by name and version",
+			Message: "Internal server error",
 			Status:  "error",
 		})
 	}
+	if ok, err := IfMatch(c, auditCtx, currentEHRStatusID.Value, "EHR"); !ok {
+		return err
+	}
 
-	// Execute AQL query
-	if err := h.OpenEHRService.QueryWithStream(ctx, c.Response().BodyWriter(), storedQuery.Query, aqlRequest.QueryParameters); err != nil {
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to execute Stored AQL Version Post", "error", err)
+	err = h.OpenEHRService.DeleteEHR(ctx, ehrID)
+	if err != nil {
+		if err == ErrEHRNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "EHR with the given ID not found",
+				Status:  "error",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete EHR by ID", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to execute Stored AQL Version Post",
+			Message: "Failed to delete EHR by ID",
 			Status:  "error",
 		})
 	}
 
+	purgeAfter := time.Now().Add(h.Settings.EHRDeletionGracePeriod).UTC()
+	auditCtx.Event.Details["purge_after"] = purgeAfter
 	auditCtx.Success()
 
 	// Register event
-	h.WebhookSink.Enqueue(webhook.EventTypeQueryExecuted, map[string]any{
-		"query_name": name,
-		"version":    version,
-		"parameters": aqlRequest.QueryParameters,
+	h.WebhookSink.Enqueue(webhook.EventTypeEHRDeleted, map[string]any{
+		"ehr_id":      ehrID,
+		"purge_after": purgeAfter,
 	})
 
-	c.Set("Content-Type", "application/json")
-	c.Status(fiber.StatusOK)
+	c.Status(fiber.StatusNoContent)
 	return nil
 }
 
-func (h *Handler) GetTemplatesADL14(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Get Templates ADL1.4 not implemented yet")
-}
-
-func (h *Handler) UploadTemplateADL14(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Upload Template ADL1.4 not implemented yet")
-}
-
-func (h *Handler) GetTemplateADL14ByID(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Get Template ADL1.4 By ID not implemented yet")
-}
-
-func (h *Handler) GetTemplatesADL2(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Get Templates ADL2 not implemented yet")
-}
-
-func (h *Handler) UploadTemplateADL2(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Upload Template ADL2 not implemented yet")
-}
-
-func (h *Handler) GetTemplateADL2ByID(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Get Template ADL2 By ID not implemented yet")
-}
-
-func (h *Handler) GetTemplateADL2AtVersion(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).SendString("Get Template ADL2 At Version not implemented yet")
-}
-
-func (h *Handler) ListStoredQueries(c *fiber.Ctx) error {
+func (h *Handler) DeleteMultipleEHRs(c *fiber.Ctx) error {
 	ctx := c.Context()
 
 	auditCtx := middleware.AuditFrom(c)
 
-	name := c.Params("qualified_query_name")
-	if name == "" {
+	// Parse multiple ehr_id query parameters
+	// Example: ?ehr_id=7d44b88c-4199-4bad-97dc-d78268e01398&ehr_id=297c3e91-7c17-4497-85dd-01e05aaae44e
+	var ehrIDList []string
+	for key, value := range c.Context().QueryArgs().All() {
+		if string(key) == "ehr_id" {
+			ehrIDList = append(ehrIDList, string(value))
+		}
+	}
+
+	if len(ehrIDList) == 0 {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusBadRequest,
-			Message: "qualified_query_name path parameter is required",
+			Message: "At least one ehr_id query parameter is required",
 			Status:  "bad_request",
 		})
 	}
 
-	queries, err := h.OpenEHRService.ListStoredQueries(ctx, name)
+	err := h.OpenEHRService.DeleteEHRBulk(ctx, ehrIDList)
 	if err != nil {
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to list stored queries", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete multiple EHRs", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to list stored queries",
+			Message: "Failed to delete multiple EHRs",
 			Status:  "error",
 		})
 	}
 
+	purgeAfter := time.Now().Add(h.Settings.EHRDeletionGracePeriod).UTC()
+	auditCtx.Event.Details["purge_after"] = purgeAfter
 	auditCtx.Success()
-	return c.Status(fiber.StatusOK).JSON(queries)
+
+	// Register event
+	h.WebhookSink.Enqueue(webhook.EventTypeEHRDeleted, map[string]any{
+		"ehr_id_list": ehrIDList,
+		"purge_after": purgeAfter,
+	})
+
+	c.Status(fiber.StatusNoContent)
+	return nil
 }
 
-func (h *Handler) StoreQuery(c *fiber.Ctx) error {
+// RestoreEHR undoes the deletion of an EHR that has not been purged yet
+func (h *Handler) RestoreEHR(c *fiber.Ctx) error {
 	ctx := c.Context()
 
 	auditCtx := middleware.AuditFrom(c)
 
-	if c.Accepts("text/plain") == "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotAcceptable,
-			Message: "Accept header must include text/plain",
-			Status:  "not_acceptable",
-		})
-	}
-
-	name := c.Params("qualified_query_name")
-	if name == "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "qualified_query_name path parameter is required",
-			Status:  "bad_request",
-		})
-	}
-
-	queryType := c.Query("query_type")
-	if queryType != "" && queryType != "AQL" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "Unsupported query_type. Only 'AQL' is supported.",
-			Status:  "bad_request",
-		})
-	}
-
-	query := string(c.Body())
-	if query == "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "Query in request body is required",
-			Status:  "bad_request",
-		})
-	}
-
-	// Check if query with the same name already exists
-	_, err := h.OpenEHRService.GetQueryByName(ctx, name, "")
-	if err == nil {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusConflict,
-			Message: "Query with the given name already exists, system cannot update without knowing the target version, please use Store Query Version endpoint instead",
-			Status:  "conflict",
-		})
-	}
-	if err != ErrQueryNotFound {
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to check existing query by name", "error", err)
-
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to store query",
-			Status:  "error",
-		})
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
+	if err != nil {
+		return err
 	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	// Store the query
-	err = h.OpenEHRService.StoreQuery(ctx, name, "1.0.0", query)
+	err = h.OpenEHRService.RestoreEHR(ctx, ehrID, h.Settings.EHRDeletionGracePeriod)
 	if err != nil {
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to store query", "error", err)
+		if err == ErrEHRNotFound {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "EHR with the given ID not found",
+				Status:  "not_found",
+			})
+		}
+		if err == ErrEHRNotDeleted {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "EHR with the given ID is not deleted",
+				Status:  "conflict",
+			})
+		}
+		if err == ErrEHRRestorePeriodExpired {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusGone,
+				Message: "Grace period to restore the EHR has expired, it is about to be purged",
+				Status:  "gone",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to restore EHR", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to store query",
+			Message: "Failed to restore EHR",
 			Status:  "error",
 		})
 	}
@@ -4602,226 +7764,411 @@ func (h *Handler) StoreQuery(c *fiber.Ctx) error {
 	auditCtx.Success()
 
 	// Register event
-	h.WebhookSink.Enqueue(webhook.EventTypeQueryStored, map[string]any{
-		"query_name": name,
-		"version":    "1.0.0",
-		"query":      query,
+	h.WebhookSink.Enqueue(webhook.EventTypeEHRRestored, map[string]any{
+		"ehr_id": ehrID,
 	})
 
-	c.Status(fiber.StatusOK)
+	c.Status(fiber.StatusNoContent)
 	return nil
 }
 
-func (h *Handler) StoreQueryVersion(c *fiber.Ctx) error {
+// ExportEHR streams everything held for the EHR as a JSON export document, or as zip archive of canonical JSON files with ?format=zip
+func (h *Handler) ExportEHR(c *fiber.Ctx) error {
 	ctx := c.Context()
 
 	auditCtx := middleware.AuditFrom(c)
 
-	if c.Accepts("text/plain") == "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusNotAcceptable,
-			Message: "Accept header must include text/plain",
-			Status:  "not_acceptable",
-		})
+	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
+	if err != nil {
+		return err
 	}
+	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	name := c.Params("qualified_query_name")
-	if name == "" {
+	format := c.Query("format", EHRExtractFormatJSON)
+	if format != EHRExtractFormatJSON && format != EHRExtractFormatZip {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusBadRequest,
-			Message: "qualified_query_name path parameter is required",
+			Message: "Query parameter format must be json or zip",
 			Status:  "bad_request",
 		})
 	}
+	auditCtx.Event.Details["format"] = format
 
-	version := c.Params("version")
-	if version == "" {
+	exists, err := h.OpenEHRService.ExistsEHR(ctx, ehrID)
+	if err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to check if EHR exists", "error", err)
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "version path parameter is required",
-			Status:  "bad_request",
+			Code:    fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Status:  "error",
 		})
 	}
-
-	queryType := c.Query("query_type")
-	if queryType != "" && queryType != "AQL" {
+	if !exists {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "Unsupported query_type. Only 'AQL' is supported.",
-			Status:  "bad_request",
+			Code:    fiber.StatusNotFound,
+			Message: "EHR not found for the given EHR ID",
+			Status:  "not_found",
 		})
 	}
 
-	query := string(c.Body())
-	if query == "" {
+	filename := fmt.Sprintf("ehr_extract_%s.%s", ehrID, format)
+	if format == EHRExtractFormatZip {
+		c.Set(fiber.HeaderContentType, "application/zip")
+	} else {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	// The extract is written while it is read, a failure halfway can only be logged as the status is already sent
+	logger := h.Telemetry.Logger
+	service := h.OpenEHRService
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		var writer EHRExtractWriter = NewEHRExtractJSONWriter(w)
+		if format == EHRExtractFormatZip {
+			writer = NewEHRExtractZipWriter(w)
+		}
+
+		if err := service.ExportEHR(context.Background(), ehrID, writer); err != nil {
+			logger.Error("Failed to export EHR", "ehr_id", ehrID, "error", err)
+		}
+		if err := w.Flush(); err != nil {
+			logger.Error("Failed to flush EHR extract", "ehr_id", ehrID, "error", err)
+		}
+	})
+
+	auditCtx.Success()
+	return nil
+}
+
+// ImportEHR recreates the EHR of an extract made by ExportEHR, sent as JSON export document or as zip archive with Content-Type application/zip
+func (h *Handler) ImportEHR(c *fiber.Ctx) error {
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+	tenantID := middleware.TenantFrom(c)
+
+	format := EHRExtractFormatJSON
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), "application/zip") {
+		format = EHRExtractFormatZip
+	}
+	auditCtx.Event.Details["format"] = format
+
+	items, err := ReadEHRExtract(c.Body(), format)
+	if err != nil {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusBadRequest,
-			Message: "Query in request body is required",
+			Message: err.Error(),
 			Status:  "bad_request",
 		})
 	}
 
-	// Store the new version of the query
-	err := h.OpenEHRService.StoreQuery(ctx, name, version, query)
-	if err != nil {
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to store query version", "error", err)
+	result, err := h.OpenEHRService.ImportEHR(ctx, tenantID, items)
+	if err != nil {
+		if validationErrs, ok := err.(util.ValidateError); ok {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Validation error",
+				Status:  "bad_request",
+				Details: validationErrs,
+			})
+		}
+		if errors.Is(err, ErrEHRExtractInvalid) {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: err.Error(),
+				Status:  "bad_request",
+			})
+		}
+		if errors.Is(err, ErrEHRExtractConflict) {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: err.Error(),
+				Status:  "conflict",
+			})
+		}
+		if err == ErrEHRLimitReached {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusForbidden,
+				Message: "EHR limit reached for tenant",
+				Status:  "forbidden",
+			})
+		}
+
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to import EHR", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to store query version",
+			Message: "Failed to import EHR",
 			Status:  "error",
 		})
 	}
 
+	auditCtx.Event.Details["ehr_id"] = result.EHRID
 	auditCtx.Success()
 
 	// Register event
-	h.WebhookSink.Enqueue(webhook.EventTypeQueryStored, map[string]any{
-		"query_name": name,
-		"version":    version,
-		"query":      query,
+	h.WebhookSink.Enqueue(webhook.EventTypeEHRImported, map[string]any{
+		"ehr_id":                 result.EHRID,
+		"versioned_object_count": result.VersionedObjectCount,
+		"version_count":          result.VersionCount,
+		"contribution_count":     result.ContributionCount,
 	})
 
-	c.Status(fiber.StatusOK)
-	return nil
+	return c.Status(fiber.StatusCreated).JSON(result)
 }
 
-func (h *Handler) GetStoredQueryAtVersion(c *fiber.Ctx) error {
-	ctx := c.Context()
+type MergeEHRRequest struct {
+	TargetEHRID uuid.UUID `json:"target_ehr_id"`
+	Description string    `json:"description"`
+}
 
+// MergeEHR moves the data of the EHR in the path into the target EHR, or only reports what would move on a dry run
+func (h *Handler) MergeEHR(c *fiber.Ctx) error {
+	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
-	name := c.Params("qualified_query_name")
-	if name == "" {
-		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "qualified_query_name path parameter is required",
-			Status:  "bad_request",
-		})
+	err := Accepts(c, auditCtx, "application/json")
+	if err != nil {
+		return err
 	}
 
-	version := c.Params("version")
-	if version == "" {
+	sourceEHRID, err := UUIDFromPath(c, auditCtx, "ehr_id")
+	if err != nil {
+		return err
+	}
+	auditCtx.Event.Details["ehr_id"] = sourceEHRID
+
+	var request MergeEHRRequest
+	if err := ParseBody(c, auditCtx, &request); err != nil {
+		return err
+	}
+	if request.TargetEHRID == uuid.Nil {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusBadRequest,
-			Message: "version path parameter is required",
+			Message: "target_ehr_id is required",
 			Status:  "bad_request",
 		})
 	}
+	auditCtx.Event.Details["target_ehr_id"] = request.TargetEHRID
 
-	storedQuery, err := h.OpenEHRService.GetQueryByName(ctx, name, version)
+	dryRun := c.QueryBool("dry_run")
+	auditCtx.Event.Details["dry_run"] = dryRun
+
+	// The EHR Status holds the modifiable state of the EHR, so its version is the EHR's entity tag
+	currentEHRStatusID, err := h.OpenEHRService.GetEHRStatusID(ctx, sourceEHRID)
 	if err != nil {
-		if err == ErrQueryNotFound {
+		if err == ErrEHRStatusNotFound {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "Stored query not found for the given name and version",
-				Status:  "error",
+				Message: "EHR with the given ID not found",
+				Status:  "not_found",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get stored query by name and version", "error", err)
-
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to get current EHR Status", "error", err)
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to get stored query by name and version",
+			Message: "Internal server error",
 			Status:  "error",
 		})
 	}
-
-	auditCtx.Success()
-	return c.Status(fiber.StatusOK).JSON(storedQuery)
-}
-
-func (h *Handler) DeleteEHRByID(c *fiber.Ctx) error {
-	ctx := c.Context()
-
-	auditCtx := middleware.AuditFrom(c)
-
-	ehrID, err := UUIDFromPath(c, auditCtx, "ehr_id")
-	if err != nil {
+	if ok, err := IfMatch(c, auditCtx, currentEHRStatusID.Value, "EHR"); !ok {
 		return err
 	}
-	auditCtx.Event.Details["ehr_id"] = ehrID
 
-	err = h.OpenEHRService.DeleteEHR(ctx, ehrID)
+	result, err := h.OpenEHRService.MergeEHRs(ctx, sourceEHRID, request.TargetEHRID, request.Description, dryRun)
 	if err != nil {
-		if err == ErrEHRNotFound {
+		if errors.Is(err, ErrEHRMergeSameEHR) {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusBadRequest,
+				Message: "Source and target EHR of a merge must differ",
+				Status:  "bad_request",
+			})
+		}
+		if errors.Is(err, ErrEHRNotFound) {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
 				Code:    fiber.StatusNotFound,
-				Message: "EHR with the given ID not found",
-				Status:  "error",
+				Message: "Source or target EHR not found",
+				Status:  "not_found",
+			})
+		}
+		if errors.Is(err, ErrEHRStatusNotFound) {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusNotFound,
+				Message: "EHR Status of the source EHR not found",
+				Status:  "not_found",
+			})
+		}
+		if errors.Is(err, ErrEHRAlreadyMerged) {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: err.Error(),
+				Status:  "conflict",
+			})
+		}
+		if errors.Is(err, ErrEHRNotModifiable) {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "Source or target EHR is not modifiable, its EHR Status has is_modifiable set to false",
+				Status:  "conflict",
+			})
+		}
+		if errors.Is(err, ErrEHRMergeDirectoryConflict) {
+			return SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusConflict,
+				Message: "Source and target EHR both have a directory, combine the folders into the target directory first",
+				Status:  "conflict",
 			})
 		}
 
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete EHR by ID", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to merge EHRs", "error", err)
 
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to delete EHR by ID",
+			Message: "Failed to merge EHRs",
 			Status:  "error",
 		})
 	}
 
 	auditCtx.Success()
 
-	// Register event
-	h.WebhookSink.Enqueue(webhook.EventTypeEHRDeleted, map[string]any{
-		"ehr_id": ehrID,
-	})
+	if !dryRun {
+		h.WebhookSink.Enqueue(webhook.EventTypeEHRMerged, map[string]any{
+			"source_ehr_id":             result.SourceEHRID,
+			"target_ehr_id":             result.TargetEHRID,
+			"contribution_uid":          result.ContributionUID.V,
+			"versioned_composition_ids": result.VersionedCompositionIDs,
+			"versioned_folder_ids":      result.VersionedFolderIDs,
+		})
+	}
 
-	c.Status(fiber.StatusNoContent)
-	return nil
+	return c.Status(fiber.StatusOK).JSON(result)
 }
 
-func (h *Handler) DeleteMultipleEHRs(c *fiber.Ctx) error {
-	ctx := c.Context()
+// EHRAccessProtected enforces the EHR_ACCESS settings of the EHR in the path, for callers identified by a token.
+// Deleted EHRs waiting to be purged are reported as not found.
+func (h *Handler) EHRAccessProtected(c *fiber.Ctx) error {
+	ehrID, err := uuid.Parse(c.Params("ehr_id"))
+	if err != nil {
+		// Let the handler report the malformed path parameter
+		return c.Next()
+	}
 
+	ctx := c.Context()
 	auditCtx := middleware.AuditFrom(c)
 
-	// Parse multiple ehr_id query parameters
-	// Example: ?ehr_id=7d44b88c-4199-4bad-97dc-d78268e01398&ehr_id=297c3e91-7c17-4497-85dd-01e05aaae44e
-	var ehrIDList []string
-	for key, value := range c.Context().QueryArgs().All() {
-		if string(key) == "ehr_id" {
-			ehrIDList = append(ehrIDList, string(value))
-		}
+	deleted, err := h.OpenEHRService.IsEHRDeleted(ctx, ehrID)
+	if err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to check if EHR is deleted", "error", err)
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Status:  "error",
+		})
 	}
-
-	if len(ehrIDList) == 0 {
+	if deleted {
+		auditCtx.Event.Details["ehr_id"] = ehrID
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
-			Code:    fiber.StatusBadRequest,
-			Message: "At least one ehr_id query parameter is required",
-			Status:  "bad_request",
+			Code:    fiber.StatusNotFound,
+			Message: "EHR not found for the given EHR ID",
+			Status:  "not_found",
 		})
 	}
 
-	err := h.OpenEHRService.DeleteEHRBulk(ctx, ehrIDList)
+	allowed, err := h.EHRAccessAllowed(c, auditCtx, ehrID)
+	if !allowed {
+		return err
+	}
+
+	return c.Next()
+}
+
+// EHRAccessAllowed checks the caller against the EHR_ACCESS settings of the EHR, it sends the error response when access is refused.
+// Without OAuth there is no caller identity, the settings are then not enforced.
+func (h *Handler) EHRAccessAllowed(c *fiber.Ctx, auditCtx *audit.Context, ehrID uuid.UUID) (bool, error) {
+	principal, ok := middleware.PrincipalFrom(c)
+	if !ok {
+		return true, nil
+	}
+
+	ctx := c.Context()
+	allowed, err := h.OpenEHRService.IsEHRAccessAllowed(ctx, ehrID, principal.Subject, principal.Roles)
 	if err != nil {
-		h.Telemetry.Logger.ErrorContext(ctx, "Failed to delete multiple EHRs", "error", err)
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to check EHR Access", "error", err)
+		return false, SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Status:  "error",
+		})
+	}
+	if !allowed {
+		auditCtx.Event.Details["ehr_id"] = ehrID
+		return false, SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusForbidden,
+			Message: "Access to this EHR is restricted by its EHR Access settings",
+			Status:  "forbidden",
+		})
+	}
+
+	return true, nil
+}
+
+// EHRQueryableProtected hides EHRs whose EHR_STATUS has is_queryable set to false, unless the caller has the admin scope
+func (h *Handler) EHRQueryableProtected(c *fiber.Ctx) error {
+	ehrID, err := uuid.Parse(c.Params("ehr_id"))
+	if err != nil || IncludeNonQueryable(c) {
+		return c.Next()
+	}
 
+	ctx := c.Context()
+	auditCtx := middleware.AuditFrom(c)
+
+	queryable, err := h.OpenEHRService.IsEHRQueryable(ctx, ehrID)
+	if err != nil {
+		h.Telemetry.Logger.ErrorContext(ctx, "Failed to check if EHR is queryable", "error", err)
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusInternalServerError,
-			Message: "Failed to delete multiple EHRs",
+			Message: "Internal server error",
 			Status:  "error",
 		})
 	}
+	if !queryable {
+		auditCtx.Event.Details["ehr_id"] = ehrID
+		return SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusNotFound,
+			Message: "EHR not found, or excluded from queries because its EHR Status has is_queryable set to false",
+			Status:  "not_found",
+		})
+	}
 
-	auditCtx.Success()
+	return c.Next()
+}
 
-	// Register event
-	h.WebhookSink.Enqueue(webhook.EventTypeEHRDeleted, map[string]any{
-		"ehr_id_list": ehrIDList,
-	})
+// IncludeNonQueryable reports if the caller may see EHRs whose EHR_STATUS has is_queryable set to false
+func IncludeNonQueryable(c *fiber.Ctx) bool {
+	principal, ok := middleware.PrincipalFrom(c)
+	return ok && principal.HasScope(oauth.ScopeEHRAdmin.String())
+}
 
-	c.Status(fiber.StatusNoContent)
-	return nil
+// AQLOptionsFrom restricts queries to the EHRs the caller is allowed to read, incomplete compositions are only included when asked for
+func AQLOptionsFrom(c *fiber.Ctx) aql.Options {
+	options := aql.Options{
+		IncludeNonQueryable: IncludeNonQueryable(c),
+		IncludeIncomplete:   c.QueryBool("include_incomplete"),
+	}
+	if principal, ok := middleware.PrincipalFrom(c); ok {
+		options.EHRAccess = utils.Some(aql.EHRAccessSubject{
+			Subject: principal.Subject,
+			Roles:   principal.Roles,
+		})
+	}
+	return options
 }
 
-func Accepts(c *fiber.Ctx, auditCtx *audit.Context, contentType string) error {
-	if c.Accepts(contentType) == "" {
+func Accepts(c *fiber.Ctx, auditCtx *audit.Context, contentTypes ...string) error {
+	if c.Accepts(contentTypes...) == "" {
 		return SendErrorResponse(c, auditCtx, ErrorResponse{
 			Code:    fiber.StatusNotAcceptable,
-			Message: "Accept header must include " + contentType,
+			Message: "Accept header must include " + strings.Join(contentTypes, " or "),
 			Status:  "not_acceptable",
 		})
 	}
@@ -4829,6 +8176,40 @@ func Accepts(c *fiber.Ctx, auditCtx *audit.Context, contentType string) error {
 	return nil
 }
 
+// AcceptsXML reports if the RM object in the response should be represented as canonical XML instead of JSON
+func AcceptsXML(c *fiber.Ctx) bool {
+	return c.Accepts("application/json", "application/xml") == "application/xml"
+}
+
+// SendRepresentation sends an RM object as JSON or canonical XML, depending on the Accept header
+func SendRepresentation(c *fiber.Ctx, status int, v any) error {
+	if !AcceptsXML(c) {
+		return c.Status(status).JSON(v)
+	}
+
+	data, err := rm.MarshalXML(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode XML representation: %w", err)
+	}
+
+	c.Set("Content-Type", "application/xml")
+	return c.Status(status).Send(data)
+}
+
+// SendRawRepresentation sends an RM object stored as JSON, it is only decoded into T when XML is requested
+func SendRawRepresentation[T any](c *fiber.Ctx, status int, data []byte) error {
+	if !AcceptsXML(c) {
+		c.Set("Content-Type", "application/json")
+		return c.Status(status).Send(data)
+	}
+
+	var v T
+	if err := sonic.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("failed to decode stored %T: %w", v, err)
+	}
+	return SendRepresentation(c, status, &v)
+}
+
 func StringFromHeader(c *fiber.Ctx, auditCtx *audit.Context, name string) (string, error) {
 	value := c.Get(name)
 	if value == "" {
@@ -4841,6 +8222,107 @@ func StringFromHeader(c *fiber.Ctx, auditCtx *audit.Context, name string) (strin
 	return value, nil
 }
 
+// entityTag returns the opaque value of an entity tag, without weak indicator and quotes
+func entityTag(tag string) string {
+	return strings.Trim(strings.TrimPrefix(strings.TrimSpace(tag), "W/"), `"`)
+}
+
+// ETagMatches reports if an If-Match or If-None-Match header matches the entity tag, the header holds * or a list of (weak) entity tags
+func ETagMatches(header, etag string) bool {
+	for tag := range strings.SplitSeq(header, ",") {
+		if strings.TrimSpace(tag) == "*" || entityTag(tag) == entityTag(etag) {
+			return true
+		}
+	}
+	return false
+}
+
+// IfMatch checks the optional If-Match header against the current version and answers 412 Precondition Failed when it does not match
+func IfMatch(c *fiber.Ctx, auditCtx *audit.Context, currentVersionID, resource string) (bool, error) {
+	ifMatch := c.Get(fiber.HeaderIfMatch)
+	if ifMatch == "" || ETagMatches(ifMatch, currentVersionID) {
+		return true, nil
+	}
+
+	return false, SendErrorResponse(c, auditCtx, ErrorResponse{
+		Code:    fiber.StatusPreconditionFailed,
+		Message: resource + " has been modified since the version in the If-Match header",
+		Status:  "precondition_failed",
+	})
+}
+
+var xmlVersionUIDPattern = regexp.MustCompile(`<uid[^>]*>\s*<value>([^<]+)</value>`)
+
+// versionIDFromBody returns the OBJECT_VERSION_ID of the RM object in a response body, if it holds a single version
+func versionIDFromBody(contentType string, body []byte) string {
+	var versionID string
+	if strings.Contains(contentType, "xml") {
+		if match := xmlVersionUIDPattern.FindSubmatch(body); match != nil {
+			versionID = string(match[1])
+		}
+	} else if node, err := sonic.Get(body, "uid", "value"); err == nil {
+		versionID, _ = node.String()
+	}
+
+	if strings.Count(versionID, "::") != 2 {
+		return ""
+	}
+	return versionID
+}
+
+// ConditionalGET sets ETag and Last-Modified on versioned GET responses and answers 304 Not Modified when If-None-Match or If-Modified-Since show the client already holds it.
+// The ETag is the weak version UID when the response holds a single version, other responses are tagged with a hash of their body.
+func (h *Handler) ConditionalGET(c *fiber.Ctx) error {
+	if err := c.Next(); err != nil {
+		return err
+	}
+	if c.Response().StatusCode() != fiber.StatusOK {
+		return nil
+	}
+
+	body := c.Response().Body()
+	etag := string(c.Response().Header.Peek(fiber.HeaderETag))
+	versionID := entityTag(etag)
+	if etag == "" {
+		versionID = versionIDFromBody(string(c.Response().Header.ContentType()), body)
+		if versionID == "" {
+			sum := sha256.Sum256(body)
+			versionID = hex.EncodeToString(sum[:16])
+		}
+	}
+	// The JSON and XML representations of a version share its UID, so the tag is weak
+	etag = `W/"` + versionID + `"`
+	c.Set(fiber.HeaderETag, etag)
+
+	var lastModified time.Time
+	if strings.Count(versionID, "::") == 2 {
+		committedAt, err := h.OpenEHRService.GetVersionCommitTime(c.Context(), versionID)
+		if err != nil && err != ErrVersionNotFound {
+			h.Telemetry.Logger.WarnContext(c.Context(), "Failed to get version commit time", "error", err)
+		}
+		if err == nil {
+			lastModified = committedAt.UTC().Truncate(time.Second)
+			c.Set(fiber.HeaderLastModified, lastModified.Format(http.TimeFormat))
+		}
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since
+	notModified := false
+	if ifNoneMatch := c.Get(fiber.HeaderIfNoneMatch); ifNoneMatch != "" {
+		notModified = ETagMatches(ifNoneMatch, etag)
+	} else if ifModifiedSince := c.Get(fiber.HeaderIfModifiedSince); ifModifiedSince != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ifModifiedSince)
+		notModified = err == nil && !lastModified.After(since)
+	}
+
+	if notModified {
+		c.Response().ResetBody()
+		c.Status(fiber.StatusNotModified)
+	}
+
+	return nil
+}
+
 func ReturnTypeFromHeader(c *fiber.Ctx, auditCtx *audit.Context) (ReturnType, error) {
 	returnType := ReturnType(c.Get("Prefer", string(ReturnTypeMinimal)))
 	if !returnType.IsValid() {
@@ -4913,7 +8395,15 @@ func ParseBody(c *fiber.Ctx, auditCtx *audit.Context, out any) error {
 		})
 	}
 
-	if err := c.BodyParser(&out); err != nil {
+	var err error
+	contentType, _, _ := strings.Cut(c.Get(fiber.HeaderContentType), ";")
+	switch strings.TrimSpace(contentType) {
+	case fiber.MIMEApplicationXML, fiber.MIMETextXML:
+		err = rm.UnmarshalXML(c.Body(), out)
+	default:
+		err = c.BodyParser(&out)
+	}
+	if err != nil {
 		verr, ok := err.(util.ValidateError)
 		if ok {
 			return SendErrorResponse(c, auditCtx, ErrorResponse{
@@ -4933,6 +8423,70 @@ func ParseBody(c *fiber.Ctx, auditCtx *audit.Context, out any) error {
 	return nil
 }
 
+// PatchBody applies the JSON Patch (RFC 6902) or JSON Merge Patch (RFC 7396) in the request body to the current
+// JSON representation of a resource and decodes the result into out
+func PatchBody(c *fiber.Ctx, auditCtx *audit.Context, current []byte, out any) (bool, error) {
+	if len(c.Body()) == 0 {
+		return false, SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusBadRequest,
+			Message: "Request body is required",
+			Status:  "bad_request",
+		})
+	}
+
+	var patched []byte
+	var err error
+	contentType, _, _ := strings.Cut(c.Get(fiber.HeaderContentType), ";")
+	switch strings.TrimSpace(contentType) {
+	case jsonpatch.MIMEApplicationJSONPatch:
+		patched, err = jsonpatch.Apply(current, c.Body())
+	case jsonpatch.MIMEApplicationMergePatch:
+		patched, err = jsonpatch.MergePatch(current, c.Body())
+	default:
+		return false, SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusUnsupportedMediaType,
+			Message: "Content-Type must be " + jsonpatch.MIMEApplicationJSONPatch + " or " + jsonpatch.MIMEApplicationMergePatch,
+			Status:  "unsupported_media_type",
+		})
+	}
+	if err != nil {
+		return false, SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusUnprocessableEntity,
+			Message: "Patch could not be applied: " + err.Error(),
+			Status:  "unprocessable_entity",
+		})
+	}
+
+	if err := sonic.Unmarshal(patched, out); err != nil {
+		if verr, ok := err.(util.ValidateError); ok {
+			return false, SendErrorResponse(c, auditCtx, ErrorResponse{
+				Code:    fiber.StatusUnprocessableEntity,
+				Message: "Validation error in patched resource",
+				Status:  "unprocessable_entity",
+				Details: verr,
+			})
+		}
+
+		return false, SendErrorResponse(c, auditCtx, ErrorResponse{
+			Code:    fiber.StatusUnprocessableEntity,
+			Message: "Patched resource is not a valid RM object",
+			Status:  "unprocessable_entity",
+		})
+	}
+	return true, nil
+}
+
+// PatchedUID replaces a UID that still refers to the current version after patching with its HIER_OBJECT_ID,
+// so the update commits the next version instead of rejecting the unchanged one
+func PatchedUID(uid utils.Optional[rm.UIDBasedIDUnion], current rm.OBJECT_VERSION_ID) utils.Optional[rm.UIDBasedIDUnion] {
+	if uid.E && uid.V.Kind == rm.UID_BASED_ID_kind_OBJECT_VERSION_ID && uid.V.OBJECT_VERSION_ID().Value == current.Value {
+		return utils.Some(rm.UID_BASED_ID_from_HIER_OBJECT_ID(&rm.HIER_OBJECT_ID{
+			Value: current.UID(),
+		}))
+	}
+	return uid
+}
+
 type ReturnType string
 
 const (
@@ -0,0 +1,548 @@
+package openehr
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/freekieb7/gopenehr/internal/database"
+	"github.com/freekieb7/gopenehr/internal/openehr/aql"
+	"github.com/freekieb7/gopenehr/internal/telemetry"
+	"github.com/freekieb7/gopenehr/internal/webhook"
+	"github.com/freekieb7/gopenehr/pkg/cron"
+	"github.com/freekieb7/gopenehr/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const ReportPollInterval = 30 * time.Second
+
+type ReportFormat string
+
+const (
+	ReportFormatCSV    ReportFormat = "csv"
+	ReportFormatNDJSON ReportFormat = "ndjson"
+)
+
+func (f ReportFormat) ContentType() string {
+	switch f {
+	case ReportFormatCSV:
+		return "text/csv"
+	default:
+		return "application/x-ndjson"
+	}
+}
+
+type ReportDelivery string
+
+const (
+	ReportDeliveryFile    ReportDelivery = "file"
+	ReportDeliveryWebhook ReportDelivery = "webhook"
+)
+
+type ReportRunStatus string
+
+const (
+	ReportRunStatusRunning   ReportRunStatus = "running"
+	ReportRunStatusSucceeded ReportRunStatus = "succeeded"
+	ReportRunStatusFailed    ReportRunStatus = "failed"
+)
+
+// Report runs a stored query on a cron schedule and delivers the result as a file or to a webhook subscription
+type Report struct {
+	ID                    uuid.UUID                 `json:"id"`
+	Name                  string                    `json:"name"`
+	QueryName             string                    `json:"query_name"`
+	QueryVersion          utils.Optional[string]    `json:"query_version"`
+	Parameters            map[string]any            `json:"parameters"`
+	Schedule              string                    `json:"schedule"`
+	Format                ReportFormat              `json:"format"`
+	Delivery              ReportDelivery            `json:"delivery"`
+	WebhookSubscriptionID utils.Optional[uuid.UUID] `json:"webhook_subscription_id"`
+	IsActive              bool                      `json:"is_active"`
+	NextRunAt             utils.Optional[time.Time] `json:"next_run_at"`
+	CreatedAt             time.Time                 `json:"created_at"`
+	// EHRAccess is the caller that created the report, runs only include EHRs they may read
+	EHRAccess utils.Optional[aql.EHRAccessSubject] `json:"-"`
+}
+
+type ReportRun struct {
+	ID         uuid.UUID                 `json:"id"`
+	ReportID   uuid.UUID                 `json:"report_id"`
+	Status     ReportRunStatus           `json:"status"`
+	RowCount   int                       `json:"row_count"`
+	Error      utils.Optional[string]    `json:"error"`
+	OutputPath utils.Optional[string]    `json:"-"`
+	StartedAt  time.Time                 `json:"started_at"`
+	FinishedAt utils.Optional[time.Time] `json:"finished_at"`
+}
+
+const reportColumns = `id, name, query_name, query_version, parameters, schedule, format, delivery, webhook_subscription_id, is_active, next_run_at, created_at, access_subject, access_roles`
+
+func scanReport(row pgx.Row, report *Report) error {
+	var accessSubject utils.Optional[string]
+	var accessRoles []string
+	if err := row.Scan(&report.ID, &report.Name, &report.QueryName, &report.QueryVersion, &report.Parameters, &report.Schedule, &report.Format, &report.Delivery, &report.WebhookSubscriptionID, &report.IsActive, &report.NextRunAt, &report.CreatedAt, &accessSubject, &accessRoles); err != nil {
+		return err
+	}
+	report.EHRAccess = ehrAccessFromColumns(accessSubject, accessRoles)
+	return nil
+}
+
+func (s *Service) CreateReport(ctx context.Context, report Report) (Report, error) {
+	if report.Parameters == nil {
+		report.Parameters = make(map[string]any)
+	}
+
+	if report.Name == "" {
+		return Report{}, fmt.Errorf("%w: name is required", ErrReportInvalid)
+	}
+
+	schedule, err := cron.Parse(report.Schedule)
+	if err != nil {
+		return Report{}, fmt.Errorf("%w: %w", ErrReportInvalid, err)
+	}
+	nextRunAt := schedule.Next(time.Now())
+	if nextRunAt.IsZero() {
+		return Report{}, fmt.Errorf("%w: schedule does not match any time within five years", ErrReportInvalid)
+	}
+
+	switch report.Format {
+	case ReportFormatCSV, ReportFormatNDJSON:
+	default:
+		return Report{}, fmt.Errorf("%w: format must be one of csv, ndjson", ErrReportInvalid)
+	}
+
+	switch report.Delivery {
+	case ReportDeliveryFile:
+		if report.WebhookSubscriptionID.E {
+			return Report{}, fmt.Errorf("%w: webhook_subscription_id is only allowed for webhook delivery", ErrReportInvalid)
+		}
+	case ReportDeliveryWebhook:
+		if !report.WebhookSubscriptionID.E {
+			return Report{}, fmt.Errorf("%w: webhook_subscription_id is required for webhook delivery", ErrReportInvalid)
+		}
+	default:
+		return Report{}, fmt.Errorf("%w: delivery must be one of file, webhook", ErrReportInvalid)
+	}
+
+	storedQuery, err := s.GetQueryByName(ctx, report.QueryName, report.QueryVersion.V)
+	if err != nil {
+		return Report{}, err
+	}
+	report.Parameters, err = s.CheckQueryParameters(storedQuery, report.Parameters)
+	if err != nil {
+		return Report{}, fmt.Errorf("%w: %w", ErrReportInvalid, err)
+	}
+	if _, _, err := aql.ToSQL(storedQuery.Query, report.Parameters); err != nil {
+		return Report{}, fmt.Errorf("%w: %w", ErrReportInvalid, err)
+	}
+
+	report.IsActive = true
+	report.NextRunAt = utils.Some(nextRunAt)
+
+	accessSubject, accessRoles := ehrAccessColumns(report.EHRAccess)
+	row := s.DB.QueryRow(ctx, `
+		INSERT INTO openehr.tbl_report (name, query_name, query_version, parameters, schedule, format, delivery, webhook_subscription_id, is_active, next_run_at, access_subject, access_roles)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING id, created_at
+	`, report.Name, report.QueryName, report.QueryVersion, report.Parameters, report.Schedule, report.Format, report.Delivery, report.WebhookSubscriptionID, report.IsActive, report.NextRunAt, accessSubject, accessRoles)
+	if err := row.Scan(&report.ID, &report.CreatedAt); err != nil {
+		if err == database.ErrNoRows {
+			return Report{}, ErrReportAlreadyExists
+		}
+		return Report{}, fmt.Errorf("failed to insert report: %w", err)
+	}
+
+	return report, nil
+}
+
+func (s *Service) ListReports(ctx context.Context) ([]Report, error) {
+	rows, err := s.DB.Query(ctx, `SELECT `+reportColumns+` FROM openehr.tbl_report ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reports: %w", err)
+	}
+	defer rows.Close()
+
+	reports := make([]Report, 0)
+	for rows.Next() {
+		var report Report
+		if err := scanReport(rows, &report); err != nil {
+			return nil, fmt.Errorf("failed to scan report: %w", err)
+		}
+		reports = append(reports, report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reports: %w", err)
+	}
+
+	return reports, nil
+}
+
+func (s *Service) GetReport(ctx context.Context, id uuid.UUID) (Report, error) {
+	var report Report
+	err := scanReport(s.DB.QueryRow(ctx, `SELECT `+reportColumns+` FROM openehr.tbl_report WHERE id = $1`, id), &report)
+	if err != nil {
+		if err == database.ErrNoRows {
+			return Report{}, ErrReportNotFound
+		}
+		return Report{}, fmt.Errorf("failed to get report: %w", err)
+	}
+
+	return report, nil
+}
+
+func (s *Service) DeleteReport(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.DB.Exec(ctx, `DELETE FROM openehr.tbl_report WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete report: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrReportNotFound
+	}
+
+	return nil
+}
+
+// ListDueReports returns the active reports whose next run time has passed
+func (s *Service) ListDueReports(ctx context.Context) ([]Report, error) {
+	rows, err := s.DB.Query(ctx, `SELECT `+reportColumns+` FROM openehr.tbl_report WHERE is_active AND next_run_at <= NOW() ORDER BY next_run_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due reports: %w", err)
+	}
+	defer rows.Close()
+
+	reports := make([]Report, 0)
+	for rows.Next() {
+		var report Report
+		if err := scanReport(rows, &report); err != nil {
+			return nil, fmt.Errorf("failed to scan report: %w", err)
+		}
+		reports = append(reports, report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating due reports: %w", err)
+	}
+
+	return reports, nil
+}
+
+// ClaimReportRun moves the next run time of a due report forward. It reports false when another
+// instance claimed the run first, so every scheduled run is executed only once.
+func (s *Service) ClaimReportRun(ctx context.Context, report Report, nextRunAt time.Time) (bool, error) {
+	tag, err := s.DB.Exec(ctx, `UPDATE openehr.tbl_report SET next_run_at = $2 WHERE id = $1 AND next_run_at = $3`, report.ID, nextRunAt, report.NextRunAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim report run: %w", err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// DeactivateReport stops the scheduling of a report, for schedules that no longer match any time
+func (s *Service) DeactivateReport(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.DB.Exec(ctx, `UPDATE openehr.tbl_report SET is_active = false WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to deactivate report: %w", err)
+	}
+
+	return nil
+}
+
+const reportRunColumns = `id, report_id, status, row_count, error, output_path, started_at, finished_at`
+
+func scanReportRun(row pgx.Row, run *ReportRun) error {
+	return row.Scan(&run.ID, &run.ReportID, &run.Status, &run.RowCount, &run.Error, &run.OutputPath, &run.StartedAt, &run.FinishedAt)
+}
+
+func (s *Service) ListReportRuns(ctx context.Context, reportID uuid.UUID) ([]ReportRun, error) {
+	if _, err := s.GetReport(ctx, reportID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.DB.Query(ctx, `SELECT `+reportRunColumns+` FROM openehr.tbl_report_run WHERE report_id = $1 ORDER BY started_at DESC`, reportID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query report runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := make([]ReportRun, 0)
+	for rows.Next() {
+		var run ReportRun
+		if err := scanReportRun(rows, &run); err != nil {
+			return nil, fmt.Errorf("failed to scan report run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating report runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+func (s *Service) GetReportRun(ctx context.Context, reportID, runID uuid.UUID) (ReportRun, error) {
+	var run ReportRun
+	err := scanReportRun(s.DB.QueryRow(ctx, `SELECT `+reportRunColumns+` FROM openehr.tbl_report_run WHERE report_id = $1 AND id = $2`, reportID, runID), &run)
+	if err != nil {
+		if err == database.ErrNoRows {
+			return ReportRun{}, ErrReportRunNotFound
+		}
+		return ReportRun{}, fmt.Errorf("failed to get report run: %w", err)
+	}
+
+	return run, nil
+}
+
+func (s *Service) StartReportRun(ctx context.Context, reportID uuid.UUID) (ReportRun, error) {
+	run := ReportRun{
+		ReportID: reportID,
+		Status:   ReportRunStatusRunning,
+	}
+
+	err := s.DB.QueryRow(ctx, `INSERT INTO openehr.tbl_report_run (report_id, status) VALUES ($1, $2) RETURNING id, started_at`, run.ReportID, run.Status).Scan(&run.ID, &run.StartedAt)
+	if err != nil {
+		return ReportRun{}, fmt.Errorf("failed to insert report run: %w", err)
+	}
+
+	return run, nil
+}
+
+func (s *Service) FinishReportRun(ctx context.Context, run ReportRun) error {
+	_, err := s.DB.Exec(ctx, `UPDATE openehr.tbl_report_run SET status = $2, row_count = $3, error = $4, output_path = $5, finished_at = NOW() WHERE id = $1`,
+		run.ID, run.Status, run.RowCount, run.Error, run.OutputPath)
+	if err != nil {
+		return fmt.Errorf("failed to update report run: %w", err)
+	}
+
+	return nil
+}
+
+// WriteReportOutput executes the stored query of the report and writes the result rows to w in the report format
+func (s *Service) WriteReportOutput(ctx context.Context, report Report, w *bufio.Writer) (int, error) {
+	storedQuery, err := s.GetQueryByName(ctx, report.QueryName, report.QueryVersion.V)
+	if err != nil {
+		return 0, err
+	}
+
+	sqlQuery, columns, err := aql.ToSQLWithOptions(storedQuery.Query, report.Parameters, aql.Options{EHRAccess: report.EHRAccess})
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrReportInvalid, err)
+	}
+
+	rows, err := s.DB.Query(ctx, sqlQuery)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute report query: %w", err)
+	}
+	defer rows.Close()
+
+	csvWriter := csv.NewWriter(w)
+	if report.Format == ReportFormatCSV {
+		if err := csvWriter.Write(columns); err != nil {
+			return 0, fmt.Errorf("failed to write report header: %w", err)
+		}
+	}
+
+	rowCount := 0
+	var data json.RawMessage
+	for rows.Next() {
+		if err := rows.Scan(&data); err != nil {
+			return rowCount, fmt.Errorf("failed to scan report row: %w", err)
+		}
+
+		var values []json.RawMessage
+		if err := json.Unmarshal(data, &values); err != nil {
+			return rowCount, fmt.Errorf("failed to decode report row: %w", err)
+		}
+
+		switch report.Format {
+		case ReportFormatCSV:
+			record := make([]string, len(values))
+			for i, value := range values {
+				// Strings are written as is, other values as their JSON representation
+				var text string
+				if err := json.Unmarshal(value, &text); err == nil {
+					record[i] = text
+				} else if string(value) != "null" {
+					record[i] = string(value)
+				}
+			}
+			if err := csvWriter.Write(record); err != nil {
+				return rowCount, fmt.Errorf("failed to write report row: %w", err)
+			}
+		case ReportFormatNDJSON:
+			object := make(map[string]json.RawMessage, len(columns))
+			for i, column := range columns {
+				if i < len(values) {
+					object[column] = values[i]
+				}
+			}
+			line, err := json.Marshal(object)
+			if err != nil {
+				return rowCount, fmt.Errorf("failed to encode report row: %w", err)
+			}
+			if _, err := w.Write(append(line, '\n')); err != nil {
+				return rowCount, fmt.Errorf("failed to write report row: %w", err)
+			}
+		}
+		rowCount++
+	}
+	if err := rows.Err(); err != nil {
+		return rowCount, fmt.Errorf("error iterating report rows: %w", err)
+	}
+
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return rowCount, fmt.Errorf("failed to write report: %w", err)
+	}
+
+	return rowCount, w.Flush()
+}
+
+// ReportScheduler runs due reports and keeps their run history
+type ReportScheduler struct {
+	Logger         *telemetry.Logger
+	Service        *Service
+	WebhookService *webhook.Service
+	OutputDir      string
+}
+
+func NewReportScheduler(logger *telemetry.Logger, service *Service, webhookService *webhook.Service, outputDir string) *ReportScheduler {
+	return &ReportScheduler{
+		Logger:         logger,
+		Service:        service,
+		WebhookService: webhookService,
+		OutputDir:      outputDir,
+	}
+}
+
+func (r *ReportScheduler) Start(ctx context.Context) error {
+	ticker := time.NewTicker(ReportPollInterval)
+	defer ticker.Stop()
+
+	r.Logger.Info("Report scheduler started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.Logger.Info("Report scheduler shutting down")
+			return nil
+
+		case <-ticker.C:
+			reports, err := r.Service.ListDueReports(ctx)
+			if err != nil {
+				r.Logger.ErrorContext(ctx, "Failed to list due reports", "error", err)
+				continue
+			}
+
+			for _, report := range reports {
+				schedule, err := cron.Parse(report.Schedule)
+				if err != nil {
+					r.Logger.ErrorContext(ctx, "Invalid report schedule", "report_id", report.ID, "error", err)
+					continue
+				}
+
+				// Claiming the zero time would leave the report due on every poll
+				nextRunAt := schedule.Next(time.Now())
+				if nextRunAt.IsZero() {
+					r.Logger.WarnContext(ctx, "Report schedule has no next run, deactivating report", "report_id", report.ID, "schedule", report.Schedule)
+					if err := r.Service.DeactivateReport(ctx, report.ID); err != nil {
+						r.Logger.ErrorContext(ctx, "Failed to deactivate report", "report_id", report.ID, "error", err)
+					}
+					continue
+				}
+
+				claimed, err := r.Service.ClaimReportRun(ctx, report, nextRunAt)
+				if err != nil {
+					r.Logger.ErrorContext(ctx, "Failed to claim report run", "report_id", report.ID, "error", err)
+					continue
+				}
+				if !claimed {
+					continue
+				}
+
+				run, err := r.RunReport(ctx, report)
+				if err != nil {
+					r.Logger.ErrorContext(ctx, "Failed to run report", "report_id", report.ID, "error", err)
+					continue
+				}
+				r.Logger.InfoContext(ctx, "Report run finished", "report_id", report.ID, "run_id", run.ID, "status", run.Status, "rows", run.RowCount)
+			}
+		}
+	}
+}
+
+// RunReport executes the report, writes the output to the output directory and delivers it.
+// Failures of the report itself are recorded on the run, only bookkeeping failures are returned.
+func (r *ReportScheduler) RunReport(ctx context.Context, report Report) (ReportRun, error) {
+	run, err := r.Service.StartReportRun(ctx, report.ID)
+	if err != nil {
+		return ReportRun{}, err
+	}
+
+	path := filepath.Join(r.OutputDir, report.ID.String(), run.ID.String()+"."+string(report.Format))
+	run.RowCount, err = r.writeOutput(ctx, report, path)
+	if err == nil {
+		run.OutputPath = utils.Some(path)
+		if report.Delivery == ReportDeliveryWebhook {
+			err = r.deliverWebhook(ctx, report, run, path)
+		}
+	}
+
+	run.Status = ReportRunStatusSucceeded
+	if err != nil {
+		run.Status = ReportRunStatusFailed
+		run.Error = utils.Some(err.Error())
+	}
+
+	if err := r.Service.FinishReportRun(ctx, run); err != nil {
+		return run, err
+	}
+
+	return run, nil
+}
+
+func (r *ReportScheduler) writeOutput(ctx context.Context, report Report, path string) (int, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return 0, fmt.Errorf("failed to create report output directory: %w", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create report output file: %w", err)
+	}
+	defer file.Close()
+
+	rowCount, err := r.Service.WriteReportOutput(ctx, report, bufio.NewWriterSize(file, 64*1024))
+	if err != nil {
+		return rowCount, err
+	}
+
+	return rowCount, file.Close()
+}
+
+func (r *ReportScheduler) deliverWebhook(ctx context.Context, report Report, run ReportRun, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read report output: %w", err)
+	}
+
+	err = r.WebhookService.Deliver(ctx, report.WebhookSubscriptionID.V, webhook.EventTypeReportCompleted, map[string]any{
+		"report_id":    report.ID,
+		"report_name":  report.Name,
+		"run_id":       run.ID,
+		"format":       report.Format,
+		"content_type": report.Format.ContentType(),
+		"row_count":    run.RowCount,
+		"content":      string(content),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to deliver report to webhook: %w", err)
+	}
+
+	return nil
+}
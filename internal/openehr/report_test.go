@@ -0,0 +1,16 @@
+package openehr
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCreateReportScheduleWithoutNextRun(t *testing.T) {
+	service := &Service{}
+
+	_, err := service.CreateReport(context.Background(), Report{Name: "leap", Schedule: "0 0 30 2 *"})
+	if !errors.Is(err, ErrReportInvalid) {
+		t.Fatalf("Expected ErrReportInvalid for a schedule that never matches, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package rm
+
+import (
+	"fmt"
+
+	"github.com/freekieb7/gopenehr/internal/openehr/util"
+	"github.com/freekieb7/gopenehr/pkg/utils"
+)
+
+const ACCESS_CONTROL_SETTINGS_TYPE string = "ACCESS_CONTROL_SETTINGS"
+
+// ACCESS_CONTROL_SETTINGS is abstract in the RM, this is the concrete form the CDR enforces.
+// An EHR without allowed subjects and roles is unrestricted, otherwise the caller must match one of them.
+type ACCESS_CONTROL_SETTINGS struct {
+	Type_           utils.Optional[string]   `json:"_type,omitzero"`
+	AllowedSubjects utils.Optional[[]string] `json:"allowed_subjects,omitzero"`
+	AllowedRoles    utils.Optional[[]string] `json:"allowed_roles,omitzero"`
+}
+
+func (a *ACCESS_CONTROL_SETTINGS) SetModelName() {
+	a.Type_ = utils.Some(ACCESS_CONTROL_SETTINGS_TYPE)
+}
+
+func (a *ACCESS_CONTROL_SETTINGS) Validate(path string) util.ValidateError {
+	var validateErr util.ValidateError
+	var attrPath string
+
+	// Validate _type
+	if a.Type_.E && a.Type_.V != ACCESS_CONTROL_SETTINGS_TYPE {
+		attrPath = path + "._type"
+		validateErr.Errs = append(validateErr.Errs, util.ValidationError{
+			Model:          ACCESS_CONTROL_SETTINGS_TYPE,
+			Path:           attrPath,
+			Message:        fmt.Sprintf("invalid %s _type field: %s", ACCESS_CONTROL_SETTINGS_TYPE, a.Type_.V),
+			Recommendation: fmt.Sprintf("Ensure _type field is set to '%s'", ACCESS_CONTROL_SETTINGS_TYPE),
+		})
+	}
+
+	// Validate allowed_subjects
+	if a.AllowedSubjects.E {
+		for i, subject := range a.AllowedSubjects.V {
+			if subject == "" {
+				attrPath = fmt.Sprintf("%s.allowed_subjects[%d]", path, i)
+				validateErr.Errs = append(validateErr.Errs, util.ValidationError{
+					Model:          ACCESS_CONTROL_SETTINGS_TYPE,
+					Path:           attrPath,
+					Message:        "allowed subject cannot be empty",
+					Recommendation: "Ensure every allowed subject is the subject identifier of a caller",
+				})
+			}
+		}
+	}
+
+	// Validate allowed_roles
+	if a.AllowedRoles.E {
+		for i, role := range a.AllowedRoles.V {
+			if role == "" {
+				attrPath = fmt.Sprintf("%s.allowed_roles[%d]", path, i)
+				validateErr.Errs = append(validateErr.Errs, util.ValidationError{
+					Model:          ACCESS_CONTROL_SETTINGS_TYPE,
+					Path:           attrPath,
+					Message:        "allowed role cannot be empty",
+					Recommendation: "Ensure every allowed role is a role granted to callers",
+				})
+			}
+		}
+	}
+
+	return validateErr
+}
@@ -10,14 +10,14 @@ import (
 const EHR_ACCESS_TYPE string = "EHR_ACCESS"
 
 type EHR_ACCESS struct {
-	Type_            utils.Optional[string]          `json:"_type,omitzero"`
-	Name             DvTextUnion                     `json:"name"`
-	ArchetypeNodeID  string                          `json:"archetype_node_id"`
-	UID              utils.Optional[UIDBasedIDUnion] `json:"uid,omitzero"`
-	Links            utils.Optional[[]LINK]          `json:"links,omitzero"`
-	ArchetypeDetails utils.Optional[ARCHETYPED]      `json:"archetype_details,omitzero"`
-	FeederAudit      utils.Optional[FEEDER_AUDIT]    `json:"feeder_audit,omitzero"`
-	// Settings         utils.Optional[ACCESS_CONTROL_SETTINGS] `json:"settings,omitzero"`
+	Type_            utils.Optional[string]                  `json:"_type,omitzero"`
+	Name             DvTextUnion                             `json:"name"`
+	ArchetypeNodeID  string                                  `json:"archetype_node_id"`
+	UID              utils.Optional[UIDBasedIDUnion]         `json:"uid,omitzero"`
+	Links            utils.Optional[[]LINK]                  `json:"links,omitzero"`
+	ArchetypeDetails utils.Optional[ARCHETYPED]              `json:"archetype_details,omitzero"`
+	FeederAudit      utils.Optional[FEEDER_AUDIT]            `json:"feeder_audit,omitzero"`
+	Settings         utils.Optional[ACCESS_CONTROL_SETTINGS] `json:"settings,omitzero"`
 }
 
 func (e *EHR_ACCESS) SetModelName() {
@@ -37,6 +37,9 @@ func (e *EHR_ACCESS) SetModelName() {
 	if e.FeederAudit.E {
 		e.FeederAudit.V.SetModelName()
 	}
+	if e.Settings.E {
+		e.Settings.V.SetModelName()
+	}
 }
 
 func (e *EHR_ACCESS) Validate(path string) util.ValidateError {
@@ -84,5 +87,11 @@ func (e *EHR_ACCESS) Validate(path string) util.ValidateError {
 		validateErr.Errs = append(validateErr.Errs, e.FeederAudit.V.Validate(attrPath).Errs...)
 	}
 
+	// Validate settings
+	if e.Settings.E {
+		attrPath = path + ".settings"
+		validateErr.Errs = append(validateErr.Errs, e.Settings.V.Validate(attrPath).Errs...)
+	}
+
 	return validateErr
 }
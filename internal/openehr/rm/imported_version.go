@@ -0,0 +1,53 @@
+package rm
+
+import (
+	"github.com/freekieb7/gopenehr/internal/openehr/util"
+	"github.com/freekieb7/gopenehr/pkg/utils"
+)
+
+const IMPORTED_VERSION_TYPE = "IMPORTED_VERSION"
+
+type IMPORTED_VERSION struct {
+	Type_        utils.Optional[string] `json:"_type,omitzero"`
+	Contribution OBJECT_REF             `json:"contribution"`
+	CommitAudit  AUDIT_DETAILS          `json:"commit_audit"`
+	Signature    utils.Optional[string] `json:"signature,omitzero"`
+	Item         ORIGINAL_VERSION       `json:"item"`
+}
+
+func (iv *IMPORTED_VERSION) SetModelName() {
+	iv.Type_ = utils.Some(IMPORTED_VERSION_TYPE)
+	iv.Contribution.SetModelName()
+	iv.CommitAudit.SetModelName()
+	iv.Item.SetModelName()
+}
+
+func (iv *IMPORTED_VERSION) Validate(path string) util.ValidateError {
+	var validateErr util.ValidateError
+	var attrPath string
+
+	// Validate _type
+	if iv.Type_.E && iv.Type_.V != IMPORTED_VERSION_TYPE {
+		attrPath = path + "._type"
+		validateErr.Errs = append(validateErr.Errs, util.ValidationError{
+			Model:          IMPORTED_VERSION_TYPE,
+			Path:           attrPath,
+			Message:        "_type must be " + IMPORTED_VERSION_TYPE,
+			Recommendation: "Set _type to " + IMPORTED_VERSION_TYPE,
+		})
+	}
+
+	// Validate contribution
+	attrPath = path + ".contribution"
+	validateErr.Errs = append(validateErr.Errs, iv.Contribution.Validate(attrPath).Errs...)
+
+	// Validate commit_audit
+	attrPath = path + ".commit_audit"
+	validateErr.Errs = append(validateErr.Errs, iv.CommitAudit.Validate(attrPath).Errs...)
+
+	// Validate item
+	attrPath = path + ".item"
+	validateErr.Errs = append(validateErr.Errs, iv.Item.Validate(attrPath).Errs...)
+
+	return validateErr
+}
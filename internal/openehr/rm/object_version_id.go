@@ -131,6 +131,21 @@ func (o OBJECT_VERSION_ID) VersionTreeID() VersionTreeID {
 	}
 }
 
+// VersionTreeIDFits reports if the trunk version, branch number and branch version each fit in the 8 bits VersionTreeID gives them
+func (o OBJECT_VERSION_ID) VersionTreeIDFits() bool {
+	parts := strings.Split(o.Value, "::")
+	if len(parts) != 3 {
+		return false
+	}
+
+	for part := range strings.SplitSeq(parts[2], ".") {
+		if _, err := strconv.ParseUint(part, 10, 8); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
 type VersionTreeID struct {
 	Major uint8
 	Minor uint8
@@ -145,6 +160,25 @@ func (v VersionTreeID) String() string {
 	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
 }
 
+// IsTrunk reports if the version is on the trunk of the version tree, branch versions have a branch number and branch version
+func (v VersionTreeID) IsTrunk() bool {
+	return v.Minor == 0 && v.Patch == 0
+}
+
+// Follows reports if the version can be committed directly after the preceding version.
+// A trunk version follows a lower trunk version, the first version of a branch follows the trunk version it branches from
+// and every next version of a branch follows the version before it on the same branch.
+func (v VersionTreeID) Follows(preceding VersionTreeID) bool {
+	switch {
+	case v.IsTrunk():
+		return preceding.IsTrunk() && v.Major > preceding.Major
+	case preceding.IsTrunk():
+		return v.Major == preceding.Major && v.Minor > 0 && v.Patch == 1
+	default:
+		return v.Major == preceding.Major && v.Minor == preceding.Minor && int(v.Patch) == int(preceding.Patch)+1
+	}
+}
+
 func (v VersionTreeID) Int() int32 {
 	return int32(v.Major)<<16 | int32(v.Minor)<<8 | int32(v.Patch)
 }
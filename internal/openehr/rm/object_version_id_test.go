@@ -0,0 +1,64 @@
+package rm
+
+import "testing"
+
+func TestVersionTreeIDFollows(t *testing.T) {
+	tests := []struct {
+		version   string
+		preceding string
+		follows   bool
+	}{
+		{"2", "1", true},
+		{"3", "1", true},
+		{"1", "1", false},
+		{"1", "2", false},
+		{"1.1.1", "1", true},
+		{"1.2.1", "1", true},
+		{"1.1.2", "1", false},
+		{"2.1.1", "1", false},
+		{"1.1.2", "1.1.1", true},
+		{"1.1.3", "1.1.1", false},
+		{"1.2.2", "1.1.1", false},
+		{"2", "1.1.1", false},
+		{"1.1.0", "1.1.255", false},
+	}
+
+	for _, test := range tests {
+		follows := VersionTreeIDFromString(test.version).Follows(VersionTreeIDFromString(test.preceding))
+		if follows != test.follows {
+			t.Errorf("Expected %s follows %s to be %t, got %t", test.version, test.preceding, test.follows, follows)
+		}
+	}
+}
+
+func TestVersionTreeIDFits(t *testing.T) {
+	tests := map[string]bool{
+		"8849182c-82ad-4088-a07f-48ead4180515::local::1":           true,
+		"8849182c-82ad-4088-a07f-48ead4180515::local::255.255.255": true,
+		"8849182c-82ad-4088-a07f-48ead4180515::local::256":         false,
+		"8849182c-82ad-4088-a07f-48ead4180515::local::1.256.1":     false,
+		"8849182c-82ad-4088-a07f-48ead4180515::local::1.1.256":     false,
+	}
+
+	for value, fits := range tests {
+		if actual := (OBJECT_VERSION_ID{Value: value}).VersionTreeIDFits(); actual != fits {
+			t.Errorf("Expected %s to fit is %t, got %t", value, fits, actual)
+		}
+	}
+}
+
+func TestVersionTreeIDOrder(t *testing.T) {
+	// Ordered by Int, branch versions come right after the trunk version they branch from
+	versions := []string{"1", "1.1.1", "1.1.2", "1.2.1", "2"}
+	for i := 1; i < len(versions); i++ {
+		previous := VersionTreeIDFromString(versions[i-1])
+		current := VersionTreeIDFromString(versions[i])
+		if previous.Int() >= current.Int() {
+			t.Errorf("Expected %s to be ordered before %s", versions[i-1], versions[i])
+		}
+	}
+
+	if !VersionTreeIDFromString("3").IsTrunk() || VersionTreeIDFromString("3.1.1").IsTrunk() {
+		t.Error("Trunk versions are not recognised")
+	}
+}
@@ -12,6 +12,9 @@ const ORIGINAL_VERSION_TYPE = "ORIGINAL_VERSION"
 
 type ORIGINAL_VERSION struct {
 	Type_                 utils.Optional[string]              `json:"_type,omitzero"`
+	Contribution          utils.Optional[OBJECT_REF]          `json:"contribution,omitzero"`
+	CommitAudit           utils.Optional[AUDIT_DETAILS]       `json:"commit_audit,omitzero"`
+	Signature             utils.Optional[string]              `json:"signature,omitzero"`
 	UID                   OBJECT_VERSION_ID                   `json:"uid"`
 	PrecedingVersionUID   utils.Optional[OBJECT_VERSION_ID]   `json:"preceding_version_uid,omitzero"`
 	OtherInputVersionUIDs utils.Optional[[]OBJECT_VERSION_ID] `json:"other_input_version_uids,omitzero"`
@@ -22,6 +25,12 @@ type ORIGINAL_VERSION struct {
 
 func (ov *ORIGINAL_VERSION) SetModelName() {
 	ov.Type_ = utils.Some(ORIGINAL_VERSION_TYPE)
+	if ov.Contribution.E {
+		ov.Contribution.V.SetModelName()
+	}
+	if ov.CommitAudit.E {
+		ov.CommitAudit.V.SetModelName()
+	}
 	ov.UID.SetModelName()
 	if ov.PrecedingVersionUID.E {
 		ov.PrecedingVersionUID.V.SetModelName()
@@ -55,6 +64,18 @@ func (ov *ORIGINAL_VERSION) Validate(path string) util.ValidateError {
 		})
 	}
 
+	// Validate contribution
+	if ov.Contribution.E {
+		attrPath = path + ".contribution"
+		validateErr.Errs = append(validateErr.Errs, ov.Contribution.V.Validate(attrPath).Errs...)
+	}
+
+	// Validate commit_audit
+	if ov.CommitAudit.E {
+		attrPath = path + ".commit_audit"
+		validateErr.Errs = append(validateErr.Errs, ov.CommitAudit.V.Validate(attrPath).Errs...)
+	}
+
 	// Validate uid
 	attrPath = path + ".uid"
 	validateErr.Errs = append(validateErr.Errs, ov.UID.Validate(attrPath).Errs...)
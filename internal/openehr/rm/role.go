@@ -18,9 +18,8 @@ type ROLE struct {
 	ArchetypeDetails utils.Optional[ARCHETYPED]           `json:"archetype_details,omitzero"`
 	FeederAudit      utils.Optional[FEEDER_AUDIT]         `json:"feeder_audit,omitzero"`
 	Details          utils.Optional[ItemStructureUnion]   `json:"details,omitzero"`
-	Target           PARTY_REF                            `json:"target"`
 	TimeValidity     utils.Optional[DV_INTERVAL[DV_DATE]] `json:"time_validity,omitzero"`
-	Source           PARTY_REF                            `json:"source"`
+	Performer        PARTY_REF                            `json:"performer"`
 }
 
 func (a *ROLE) SetModelName() {
@@ -43,11 +42,10 @@ func (a *ROLE) SetModelName() {
 	if a.Details.E {
 		a.Details.V.SetModelName()
 	}
-	a.Target.SetModelName()
 	if a.TimeValidity.E {
 		a.TimeValidity.V.SetModelName()
 	}
-	a.Source.SetModelName()
+	a.Performer.SetModelName()
 }
 
 func (a *ROLE) Validate(path string) util.ValidateError {
@@ -102,19 +100,15 @@ func (a *ROLE) Validate(path string) util.ValidateError {
 		validateErr.Errs = append(validateErr.Errs, a.Details.V.Validate(attrPath).Errs...)
 	}
 
-	// Validate Target
-	attrPath = path + ".target"
-	validateErr.Errs = append(validateErr.Errs, a.Target.Validate(attrPath).Errs...)
-
 	// Validate TimeValidity
 	attrPath = path + ".time_validity"
 	if a.TimeValidity.E {
 		validateErr.Errs = append(validateErr.Errs, a.TimeValidity.V.Validate(attrPath).Errs...)
 	}
 
-	// Validate Source
-	attrPath = path + ".source"
-	validateErr.Errs = append(validateErr.Errs, a.Source.Validate(attrPath).Errs...)
+	// Validate Performer
+	attrPath = path + ".performer"
+	validateErr.Errs = append(validateErr.Errs, a.Performer.Validate(attrPath).Errs...)
 
 	return validateErr
 }
@@ -0,0 +1,487 @@
+package rm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+const (
+	XML_NAMESPACE     = "http://schemas.openehr.org/v1"
+	XSI_NAMESPACE     = "http://www.w3.org/2001/XMLSchema-instance"
+	XML_ATTR_XSI_TYPE = "xsi:type"
+)
+
+// xmlAttributes are the RM attributes the openEHR XSDs define as XML attributes instead of elements
+var xmlAttributes = map[string]bool{
+	"archetype_node_id": true,
+}
+
+// xmlRootElements are the global elements of the openEHR XSDs that are not named after their type
+var xmlRootElements = map[string]string{
+	ORIGINAL_VERSION_TYPE: "version",
+	IMPORTED_VERSION_TYPE: "version",
+}
+
+// MarshalXML encodes an RM object as canonical openEHR XML.
+// Elements follow the attribute order of the RM structs and xsi:type is written wherever the XSD type is polymorphic.
+func MarshalXML(v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface || isUnionType(rv.Type()) {
+		if isUnionType(rv.Type()) {
+			rv = rv.FieldByName("Value")
+			continue
+		}
+		if rv.IsNil() {
+			return nil, fmt.Errorf("cannot encode nil as XML")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot encode %s as XML, expected an RM object", rv.Type())
+	}
+
+	typeName := xmlTypeName(rv.Type())
+	name, ok := xmlRootElements[typeName]
+	if !ok {
+		name = strings.ToLower(typeName)
+	}
+
+	attrs := []xml.Attr{
+		{Name: xml.Name{Local: "xmlns"}, Value: XML_NAMESPACE},
+		{Name: xml.Name{Local: "xmlns:xsi"}, Value: XSI_NAMESPACE},
+	}
+	if name != strings.ToLower(typeName) {
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: XML_ATTR_XSI_TYPE}, Value: typeName})
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	enc := xml.NewEncoder(&buf)
+	if err := encodeXMLStruct(enc, name, rv, attrs); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to flush XML encoder: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func encodeXMLValue(enc *xml.Encoder, name string, rv reflect.Value, polymorphic bool) error {
+	switch {
+	case isOptionalType(rv.Type()):
+		if !rv.FieldByName("E").Bool() {
+			return nil
+		}
+		return encodeXMLValue(enc, name, rv.FieldByName("V"), polymorphic)
+	case isUnionType(rv.Type()):
+		return encodeXMLValue(enc, name, rv.FieldByName("Value"), true)
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		if rv.Kind() == reflect.Interface && rv.Elem().Kind() == reflect.Map {
+			// Polymorphic attributes without a union, such as the limits of DV_INTERVAL, are decoded as plain JSON data values
+			data, err := sonic.Marshal(rv.Interface())
+			if err != nil {
+				return fmt.Errorf("failed to encode %s: %w", name, err)
+			}
+			var value DataValueUnion
+			if err := sonic.Unmarshal(data, &value); err != nil {
+				return fmt.Errorf("failed to encode %s: %w", name, err)
+			}
+			return encodeXMLValue(enc, name, reflect.ValueOf(value), true)
+		}
+		return encodeXMLValue(enc, name, rv.Elem(), polymorphic || rv.Kind() == reflect.Interface)
+	case reflect.Struct:
+		var attrs []xml.Attr
+		if polymorphic {
+			attrs = append(attrs, xml.Attr{Name: xml.Name{Local: XML_ATTR_XSI_TYPE}, Value: xmlTypeName(rv.Type())})
+		}
+		return encodeXMLStruct(enc, name, rv, attrs)
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return encodeXMLText(enc, name, base64.StdEncoding.EncodeToString(rv.Bytes()))
+		}
+		for i := range rv.Len() {
+			if err := encodeXMLValue(enc, name, rv.Index(i), polymorphic); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		text, err := xmlScalarText(rv)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", name, err)
+		}
+		return encodeXMLText(enc, name, text)
+	}
+}
+
+func encodeXMLStruct(enc *xml.Encoder, name string, rv reflect.Value, attrs []xml.Attr) error {
+	type field struct {
+		name  string
+		value reflect.Value
+	}
+
+	fields := make([]field, 0, rv.NumField())
+	for i := range rv.NumField() {
+		fieldName := xmlFieldName(rv.Type().Field(i))
+		if fieldName == "" || fieldName == "_type" {
+			continue
+		}
+
+		value := rv.Field(i)
+		if xmlAttributes[fieldName] {
+			if isOptionalType(value.Type()) {
+				if !value.FieldByName("E").Bool() {
+					continue
+				}
+				value = value.FieldByName("V")
+			}
+
+			text, err := xmlScalarText(value)
+			if err != nil {
+				return fmt.Errorf("failed to encode attribute %s: %w", fieldName, err)
+			}
+			attrs = append(attrs, xml.Attr{Name: xml.Name{Local: fieldName}, Value: text})
+			continue
+		}
+
+		fields = append(fields, field{name: fieldName, value: value})
+	}
+
+	start := xml.StartElement{Name: xml.Name{Local: name}, Attr: attrs}
+	if err := enc.EncodeToken(start); err != nil {
+		return fmt.Errorf("failed to encode element %s: %w", name, err)
+	}
+	for _, f := range fields {
+		if err := encodeXMLValue(enc, f.name, f.value, false); err != nil {
+			return err
+		}
+	}
+	if err := enc.EncodeToken(start.End()); err != nil {
+		return fmt.Errorf("failed to encode element %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func encodeXMLText(enc *xml.Encoder, name, text string) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := enc.EncodeToken(start); err != nil {
+		return fmt.Errorf("failed to encode element %s: %w", name, err)
+	}
+	if err := enc.EncodeToken(xml.CharData(text)); err != nil {
+		return fmt.Errorf("failed to encode element %s: %w", name, err)
+	}
+	if err := enc.EncodeToken(start.End()); err != nil {
+		return fmt.Errorf("failed to encode element %s: %w", name, err)
+	}
+	return nil
+}
+
+func xmlScalarText(rv reflect.Value) (string, error) {
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("unsupported value of type %s", rv.Type())
+	}
+}
+
+// xmlNode is a parsed XML element, decoded into JSON once the RM type it holds is known
+type xmlNode struct {
+	name     string
+	attrs    map[string]string
+	children []*xmlNode
+	text     strings.Builder
+}
+
+// UnmarshalXML decodes canonical openEHR XML into an RM object.
+// The XML is converted into canonical JSON using the RM structs as schema, xsi:type selects the type of polymorphic attributes.
+func UnmarshalXML(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("cannot decode XML into %T, expected a pointer", v)
+	}
+
+	root, err := parseXMLNode(data)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := root.attrs[XML_ATTR_XSI_TYPE]; !ok && isUnionType(rv.Type().Elem()) {
+		// Global elements are named after their type, which makes xsi:type optional at the root
+		root.attrs[XML_ATTR_XSI_TYPE] = strings.ToUpper(root.name)
+	}
+
+	value, err := xmlNodeToJSON(root, rv.Type().Elem(), "/"+root.name)
+	if err != nil {
+		return err
+	}
+
+	jsonData, err := sonic.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to convert XML into JSON: %w", err)
+	}
+
+	return sonic.Unmarshal(jsonData, v)
+}
+
+func parseXMLNode(data []byte) (*xmlNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+
+	var root *xmlNode
+	var stack []*xmlNode
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid XML: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: t.Name.Local, attrs: make(map[string]string, len(t.Attr))}
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+					continue
+				}
+				name := attr.Name.Local
+				if attr.Name.Space == XSI_NAMESPACE {
+					name = "xsi:" + name
+				}
+				node.attrs[name] = attr.Value
+			}
+
+			if len(stack) == 0 {
+				if root != nil {
+					return nil, fmt.Errorf("invalid XML: multiple root elements")
+				}
+				root = node
+			} else {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, node)
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("invalid XML: missing root element")
+	}
+	return root, nil
+}
+
+func xmlNodeToJSON(node *xmlNode, t reflect.Type, path string) (any, error) {
+	switch {
+	case isOptionalType(t):
+		return xmlNodeToJSON(node, optionalValueType(t), path)
+	case isUnionType(t):
+		concrete, err := xmlUnionType(t, node, path)
+		if err != nil {
+			return nil, err
+		}
+		return xmlNodeToJSON(node, concrete, path)
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return xmlNodeToJSON(node, t.Elem(), path)
+	case reflect.Interface:
+		// Polymorphic attributes without a union, such as the limits of DV_INTERVAL, hold data values
+		concrete, err := xmlUnionType(reflect.TypeFor[DataValueUnion](), node, path)
+		if err != nil {
+			return nil, err
+		}
+		return xmlNodeToJSON(node, concrete, path)
+	case reflect.Struct:
+		return xmlStructToJSON(node, t, path)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return strings.TrimSpace(node.text.String()), nil
+		}
+		return nil, fmt.Errorf("unexpected list at %s", path)
+	default:
+		return xmlScalarToJSON(node.text.String(), t, path)
+	}
+}
+
+func xmlStructToJSON(node *xmlNode, t reflect.Type, path string) (any, error) {
+	obj := make(map[string]any)
+	if xsiType, ok := node.attrs[XML_ATTR_XSI_TYPE]; ok {
+		obj["_type"] = xsiType
+	}
+
+	fields := make(map[string]reflect.StructField, t.NumField())
+	for i := range t.NumField() {
+		if name := xmlFieldName(t.Field(i)); name != "" {
+			fields[name] = t.Field(i)
+		}
+	}
+
+	for name, value := range node.attrs {
+		if name == XML_ATTR_XSI_TYPE || strings.HasPrefix(name, "xsi:") {
+			continue
+		}
+
+		field, ok := fields[name]
+		if !ok || !xmlAttributes[name] {
+			return nil, fmt.Errorf("unexpected attribute %s at %s", name, path)
+		}
+
+		fieldType := field.Type
+		if isOptionalType(fieldType) {
+			fieldType = optionalValueType(fieldType)
+		}
+		scalar, err := xmlScalarToJSON(value, fieldType, path+"/@"+name)
+		if err != nil {
+			return nil, err
+		}
+		obj[name] = scalar
+	}
+
+	for i, child := range node.children {
+		childPath := fmt.Sprintf("%s/%s[%d]", path, child.name, i+1)
+
+		field, ok := fields[child.name]
+		if !ok || child.name == "_type" || xmlAttributes[child.name] {
+			return nil, fmt.Errorf("unexpected element %s at %s", child.name, path)
+		}
+
+		fieldType := field.Type
+		if isOptionalType(fieldType) {
+			fieldType = optionalValueType(fieldType)
+		}
+
+		if fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() != reflect.Uint8 {
+			item, err := xmlNodeToJSON(child, fieldType.Elem(), childPath)
+			if err != nil {
+				return nil, err
+			}
+			items, _ := obj[child.name].([]any)
+			obj[child.name] = append(items, item)
+			continue
+		}
+
+		if _, exists := obj[child.name]; exists {
+			return nil, fmt.Errorf("element %s occurs more than once at %s", child.name, path)
+		}
+		value, err := xmlNodeToJSON(child, fieldType, childPath)
+		if err != nil {
+			return nil, err
+		}
+		obj[child.name] = value
+	}
+
+	return obj, nil
+}
+
+func xmlScalarToJSON(text string, t reflect.Type, path string) (any, error) {
+	switch t.Kind() {
+	case reflect.String:
+		return text, nil
+	case reflect.Bool:
+		value, err := strconv.ParseBool(strings.TrimSpace(text))
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean %q at %s", text, path)
+		}
+		return value, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		number := json.Number(strings.TrimSpace(text))
+		if _, err := number.Float64(); err != nil {
+			return nil, fmt.Errorf("invalid number %q at %s", text, path)
+		}
+		return number, nil
+	default:
+		return nil, fmt.Errorf("unsupported value of type %s at %s", t, path)
+	}
+}
+
+// xmlUnionType resolves the RM type selected by xsi:type, by letting the union decode an object of that type
+func xmlUnionType(t reflect.Type, node *xmlNode, path string) (reflect.Type, error) {
+	xsiType, ok := node.attrs[XML_ATTR_XSI_TYPE]
+	if !ok {
+		return nil, fmt.Errorf("missing xsi:type at %s, the element is polymorphic", path)
+	}
+
+	union := reflect.New(t)
+	unmarshaler, ok := union.Interface().(json.Unmarshaler)
+	if !ok {
+		return nil, fmt.Errorf("unsupported polymorphic type %s at %s", t, path)
+	}
+	if err := unmarshaler.UnmarshalJSON([]byte(`{"_type":` + strconv.Quote(xsiType) + `}`)); err != nil {
+		return nil, fmt.Errorf("invalid xsi:type %s at %s: %w", xsiType, path, err)
+	}
+
+	value := union.Elem().FieldByName("Value")
+	if value.IsNil() {
+		return nil, fmt.Errorf("unknown xsi:type %s at %s", xsiType, path)
+	}
+	return value.Elem().Type(), nil
+}
+
+// xmlTypeName is the RM type name of a struct, type parameters of generic types are not part of it
+func xmlTypeName(t reflect.Type) string {
+	name, _, _ := strings.Cut(t.Name(), "[")
+	return name
+}
+
+func xmlFieldName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// isOptionalType reports if the type is a utils.Optional
+func isOptionalType(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && strings.HasPrefix(t.Name(), "Optional[") && t.NumField() == 2 &&
+		t.Field(0).Name == "V" && t.Field(1).Name == "E"
+}
+
+func optionalValueType(t reflect.Type) reflect.Type {
+	return t.Field(0).Type
+}
+
+// isUnionType reports if the type is one of the RM unions holding a polymorphic value
+func isUnionType(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.NumField() == 2 &&
+		t.Field(0).Name == "Kind" && t.Field(1).Name == "Value" && t.Field(1).Type.Kind() == reflect.Interface
+}
@@ -0,0 +1,62 @@
+package rm
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestCompositionXMLRoundTrip(t *testing.T) {
+	content, err := os.ReadFile("../../../tests/fixture/composition.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var composition COMPOSITION
+	err = json.Unmarshal(content, &composition)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := MarshalXML(&composition)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(data, []byte(`<composition xmlns="http://schemas.openehr.org/v1"`)) {
+		t.Error("COMPOSITION root element is not encoded properly")
+	}
+
+	if !bytes.Contains(data, []byte(`<content xsi:type="OBSERVATION" archetype_node_id="`)) {
+		t.Error("OBSERVATION content item is not encoded with xsi:type and archetype_node_id attribute")
+	}
+
+	var decoded COMPOSITION
+	err = UnmarshalXML(data, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := MarshalXML(&decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, actual) {
+		t.Error("COMPOSITION does not survive an XML round trip")
+	}
+
+	if !bytes.Contains(data, []byte(`<lower xsi:type="DV_COUNT"><magnitude>8</magnitude></lower>`)) {
+		t.Error("DV_INTERVAL lower is not encoded with xsi:type")
+	}
+}
+
+func TestUnmarshalXMLMissingXSIType(t *testing.T) {
+	data := []byte(`<composition xmlns="http://schemas.openehr.org/v1" archetype_node_id="openEHR-EHR-COMPOSITION.test.v1"><name><value>Test</value></name></composition>`)
+
+	var composition COMPOSITION
+	if err := UnmarshalXML(data, &composition); err == nil {
+		t.Error("Expected an error for a polymorphic element without xsi:type")
+	}
+}
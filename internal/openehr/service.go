@@ -37,11 +37,23 @@ var (
 	ErrDirectoryNotFound                       = fmt.Errorf("directory not found")
 	ErrContributionAlreadyExists               = fmt.Errorf("contribution already exists")
 	ErrContributionNotFound                    = fmt.Errorf("contribution not found")
+	ErrContributionInvalid                     = fmt.Errorf("invalid contribution")
+	ErrContributionVersionConflict             = fmt.Errorf("preceding version does not match the latest version")
 	ErrEHRStatusVersionLowerOrEqualToCurrent   = fmt.Errorf("EHR Status version must be incremented")
 	ErrInvalidEHRStatusUIDMismatch             = fmt.Errorf("EHR Status UID HIER_OBJECT_ID does not match current EHR Status UID")
+	ErrEHRAccessNotFound                       = fmt.Errorf("EHR Access not found")
+	ErrEHRAccessVersionConflict                = fmt.Errorf("EHR Access has been modified since the provided version")
+	ErrInvalidEHRAccessUIDMismatch             = fmt.Errorf("EHR Access UID HIER_OBJECT_ID does not match current EHR Access UID")
+	ErrEHRAccessDenied                         = fmt.Errorf("access to EHR denied by its EHR Access settings")
+	ErrEHRNotModifiable                        = fmt.Errorf("EHR is not modifiable, its EHR Status has is_modifiable set to false")
 	ErrFolderNotFoundInDirectory               = fmt.Errorf("folder not found in directory")
 	ErrDirectoryVersionLowerOrEqualToCurrent   = fmt.Errorf("directory version must be incremented")
 	ErrInvalidDirectoryUIDMismatch             = fmt.Errorf("directory UID HIER_OBJECT_ID does not match current directory UID")
+	ErrDirectoryVersionConflict                = fmt.Errorf("directory has been modified since the given version")
+	ErrDirectoryFolderAlreadyExists            = fmt.Errorf("folder with the given name already exists in the parent folder")
+	ErrDirectoryFolderMoveInvalid              = fmt.Errorf("folder cannot be moved into itself or one of its sub-folders")
+	ErrDirectoryItemAlreadyExists              = fmt.Errorf("item with the given id already exists in the folder")
+	ErrDirectoryItemNotFound                   = fmt.Errorf("item with the given id not found in the folder")
 	ErrCompositionUIDNotProvided               = fmt.Errorf("composition UID must be provided")
 
 	ErrAgentAlreadyExists                       = fmt.Errorf("agent with the given UID already exists")
@@ -65,24 +77,57 @@ var (
 	ErrRoleVersionLowerOrEqualToCurrent         = fmt.Errorf("role version is lower than or equal to the current version")
 	ErrInvalidRoleUIDMismatch                   = fmt.Errorf("role UID does not match current role UID")
 	ErrVersionedPartyVersionNotFound            = fmt.Errorf("versioned party version not found")
+	ErrTagNotFound                              = fmt.Errorf("tag not found")
+	ErrInvalidTagJSONPath                       = fmt.Errorf("invalid tag JSONPath condition")
 	ErrInvalidAgentUIDMismatch                  = fmt.Errorf("agent UID does not match current agent UID")
 	ErrInvalidPersonUIDMismatch                 = fmt.Errorf("person UID does not match current person UID")
 	ErrVersionedObjectNotFound                  = fmt.Errorf("versioned object not found")
+	ErrVersionNotFound                          = fmt.Errorf("version not found")
+	ErrInvalidPageToken                         = fmt.Errorf("invalid page token")
 
 	ErrVersionLowerOrEqualToCurrent = fmt.Errorf("object version must be incremented")
 
-	ErrQueryNotFound      = fmt.Errorf("AQL query not found")
-	ErrQueryAlreadyExists = fmt.Errorf("AQL query with the given name already exists")
+	ErrQueryNotFound         = fmt.Errorf("AQL query not found")
+	ErrQueryAlreadyExists    = fmt.Errorf("AQL query with the given name already exists")
+	ErrQueryInvalid          = fmt.Errorf("invalid stored query")
+	ErrQueryParameterInvalid = aql.ErrInvalidParameter
+
+	ErrStandingQueryNotFound = fmt.Errorf("standing query not found")
+	ErrStandingQueryInvalid  = fmt.Errorf("stored query cannot be used as standing query")
+
+	ErrCohortNotFound      = fmt.Errorf("cohort not found")
+	ErrCohortAlreadyExists = fmt.Errorf("cohort with the given name already exists")
+	ErrCohortInvalid       = fmt.Errorf("invalid cohort definition")
+
+	ErrReportNotFound      = fmt.Errorf("report not found")
+	ErrReportAlreadyExists = fmt.Errorf("report with the given name already exists")
+	ErrReportInvalid       = fmt.Errorf("invalid report definition")
+	ErrReportRunNotFound   = fmt.Errorf("report run not found")
 
 	ErrEHRLimitReached = fmt.Errorf("EHR limit reached for tenant")
+
+	ErrEHRMergeSameEHR           = fmt.Errorf("source and target EHR of a merge must differ")
+	ErrEHRAlreadyMerged          = fmt.Errorf("EHR has already been merged into another EHR")
+	ErrEHRMergeDirectoryConflict = fmt.Errorf("source and target EHR both have a directory")
+
+	ErrEHRNotDeleted           = fmt.Errorf("EHR is not deleted")
+	ErrEHRRestorePeriodExpired = fmt.Errorf("grace period to restore the EHR has expired")
+
+	ErrEHRExtractInvalid  = fmt.Errorf("invalid EHR extract")
+	ErrEHRExtractConflict = fmt.Errorf("EHR extract conflicts with existing data")
+
+	ErrAttachmentNotFound = fmt.Errorf("attachment not found")
+	ErrAttachmentEmpty    = fmt.Errorf("attachment is empty")
+	ErrAttachmentTooLarge = fmt.Errorf("attachment exceeds the maximum size")
 )
 
 type StoredQuery struct {
-	Name    string    `json:"name"`
-	Version string    `json:"version"`
-	Query   string    `json:"q"`
-	Type    string    `json:"type"`
-	Saved   time.Time `json:"saved"`
+	Name       string          `json:"name"`
+	Version    string          `json:"version"`
+	Query      string          `json:"q"`
+	Type       string          `json:"type"`
+	Saved      time.Time       `json:"saved"`
+	Parameters []aql.Parameter `json:"parameters"`
 }
 
 type Service struct {
@@ -208,16 +253,8 @@ func (s *Service) CreateEHR(ctx context.Context, tenantID, ehrID uuid.UUID, ehrS
 	}()
 
 	// Increment user's registered EHR count
-	if tenantID != uuid.Nil {
-		var ehrCount int
-		row := tx.QueryRow(ctx, `UPDATE tenant.tbl_subscription SET ehr_count = ehr_count + 1, updated_at = NOW() WHERE tenant_id = $1 AND ehr_count < ehr_limit RETURNING ehr_count;`, tenantID)
-		err = row.Scan(&ehrCount)
-		if err != nil {
-			return rm.EHR{}, fmt.Errorf("failed to increment tenant's registered EHR count: %w", err)
-		}
-		if ehrCount == 0 {
-			return rm.EHR{}, ErrEHRLimitReached
-		}
+	if err := incrementTenantEHRCount(ctx, tx, tenantID); err != nil {
+		return rm.EHR{}, err
 	}
 
 	batch := &pgx.Batch{}
@@ -265,8 +302,26 @@ func (s *Service) CreateEHR(ctx context.Context, tenantID, ehrID uuid.UUID, ehrS
 	return ehr, nil
 }
 
+// incrementTenantEHRCount registers another EHR for the tenant, no tenant is counted for callers without one
+func incrementTenantEHRCount(ctx context.Context, tx pgx.Tx, tenantID uuid.UUID) error {
+	if tenantID == uuid.Nil {
+		return nil
+	}
+
+	var ehrCount int
+	row := tx.QueryRow(ctx, `UPDATE tenant.tbl_subscription SET ehr_count = ehr_count + 1, updated_at = NOW() WHERE tenant_id = $1 AND ehr_count < ehr_limit RETURNING ehr_count;`, tenantID)
+	err := row.Scan(&ehrCount)
+	if err != nil {
+		return fmt.Errorf("failed to increment tenant's registered EHR count: %w", err)
+	}
+	if ehrCount == 0 {
+		return ErrEHRLimitReached
+	}
+	return nil
+}
+
 func (s *Service) ExistsEHR(ctx context.Context, id uuid.UUID) (bool, error) {
-	query := `SELECT 1 FROM openehr.tbl_ehr WHERE id = $1 LIMIT 1`
+	query := `SELECT 1 FROM openehr.tbl_ehr WHERE id = $1 AND deleted_at IS NULL LIMIT 1`
 	args := []any{id}
 
 	row := s.DB.QueryRow(ctx, query, args...)
@@ -284,7 +339,7 @@ func (s *Service) ExistsEHR(ctx context.Context, id uuid.UUID) (bool, error) {
 }
 
 func (s *Service) GetEHRRawJSON(ctx context.Context, id uuid.UUID) ([]byte, error) {
-	query := `SELECT ed.data FROM openehr.tbl_ehr e JOIN tbl_ehr_data ed ON e.id = ed.id WHERE e.id = $1 LIMIT 1`
+	query := `SELECT ed.data FROM openehr.tbl_ehr e JOIN tbl_ehr_data ed ON e.id = ed.id WHERE e.id = $1 AND e.deleted_at IS NULL LIMIT 1`
 	args := []any{id}
 
 	row := s.DB.QueryRow(ctx, query, args...)
@@ -309,6 +364,7 @@ func (s *Service) GetEHRBySubjectRawJSON(ctx context.Context, subjectID, subject
 		JOIN openehr.tbl_ehr_status es ON e.id = es.ehr_id
         WHERE es.data->'subject'->'external_ref'->>'namespace' = $1
 		  AND es.data->'subject'->'external_ref'->'id'->>'value' = $2
+		  AND e.deleted_at IS NULL
         LIMIT 1
     `
 	args := []any{subjectNamespace, subjectID}
@@ -327,8 +383,9 @@ func (s *Service) GetEHRBySubjectRawJSON(ctx context.Context, subjectID, subject
 	return data, nil
 }
 
+// DeleteEHR marks the EHR as deleted, it is hidden right away and only removed by the EHRPurger once the grace period expires
 func (s *Service) DeleteEHR(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM openehr.tbl_ehr WHERE id = $1 RETURNING 1`
+	query := `UPDATE openehr.tbl_ehr SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL RETURNING 1`
 	args := []any{id}
 
 	row := s.DB.QueryRow(ctx, query, args...)
@@ -340,7 +397,7 @@ func (s *Service) DeleteEHR(ctx context.Context, id uuid.UUID) error {
 			return ErrEHRNotFound
 		}
 
-		return fmt.Errorf("failed to delete EHR from database: %w", err)
+		return fmt.Errorf("failed to mark EHR as deleted in database: %w", err)
 	}
 	return nil
 }
@@ -356,11 +413,11 @@ func (s *Service) DeleteEHRBulk(ctx context.Context, ids []string) error {
 		placeholders[i] = fmt.Sprintf("$%d", i+1)
 		args[i] = id
 	}
-	query := fmt.Sprintf(`DELETE FROM openehr.tbl_ehr WHERE id IN (%s)`, strings.Join(placeholders, ", "))
+	query := fmt.Sprintf(`UPDATE openehr.tbl_ehr SET deleted_at = NOW() WHERE id IN (%s) AND deleted_at IS NULL`, strings.Join(placeholders, ", "))
 
 	_, err := s.DB.Exec(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("failed to delete multiple EHRs from database: %w", err)
+		return fmt.Errorf("failed to mark multiple EHRs as deleted in database: %w", err)
 	}
 
 	return nil
@@ -438,8 +495,32 @@ func (s *Service) ValidateEHRStatus(ctx context.Context, ehrStatus rm.EHR_STATUS
 	return nil
 }
 
+// checkEHRModifiable refuses writes to an EHR whose latest EHR_STATUS has is_modifiable set to false, a deleted EHR is reported as not found
+func checkEHRModifiable(ctx context.Context, tx pgx.Tx, ehrID uuid.UUID) error {
+	var deleted, modifiable bool
+	if err := tx.QueryRow(ctx, `SELECT openehr.ehr_is_deleted($1), openehr.ehr_is_modifiable($1)`, ehrID).Scan(&deleted, &modifiable); err != nil {
+		return fmt.Errorf("failed to check if EHR is modifiable: %w", err)
+	}
+	if deleted {
+		return ErrEHRNotFound
+	}
+	if !modifiable {
+		return ErrEHRNotModifiable
+	}
+	return nil
+}
+
+// IsEHRQueryable reports if the latest EHR_STATUS of the EHR has is_queryable set, an EHR without EHR_STATUS counts as queryable
+func (s *Service) IsEHRQueryable(ctx context.Context, ehrID uuid.UUID) (bool, error) {
+	var queryable bool
+	if err := s.DB.QueryRow(ctx, `SELECT openehr.ehr_is_queryable($1)`, ehrID).Scan(&queryable); err != nil {
+		return false, fmt.Errorf("failed to check if EHR is queryable: %w", err)
+	}
+	return queryable, nil
+}
+
 func (s *Service) GetEHRStatusID(ctx context.Context, ehrID uuid.UUID) (rm.OBJECT_VERSION_ID, error) {
-	query := `SELECT id FROM openehr.tbl_ehr_status WHERE ehr_id = $1 ORDER BY version_int DESC LIMIT 1`
+	query := `SELECT id FROM openehr.tbl_ehr_status WHERE ehr_id = $1 AND NOT openehr.ehr_is_deleted(ehr_id) ORDER BY version_int DESC LIMIT 1`
 	args := []any{ehrID}
 
 	row := s.DB.QueryRow(ctx, query, args...)
@@ -682,16 +763,17 @@ func (s *Service) GetVersionedEHRStatusRevisionHistoryRawJSON(ctx context.Contex
 					),
 					'audits', audits
 				)
-				ORDER BY version_id
+				ORDER BY string_to_array(split_part(version_id, '::', 3), '.')::int[]
 			)
 		)
 		FROM (
 			SELECT 
 				version->'id'->>'value' as version_id,
-				jsonb_agg(cd.data->'audit' ORDER BY cd.data->'audit'->'time_committed'->>'value') as audits
+				jsonb_agg(COALESCE(vd.version_data->'commit_audit', cd.data->'audit') ORDER BY cd.data->'audit'->'time_committed'->>'value') as audits
 			FROM openehr.tbl_contribution c
 			JOIN openehr.tbl_contribution_data cd ON c.id = cd.id,
 				jsonb_array_elements(cd.data->'versions') as version
+				LEFT JOIN openehr.tbl_ehr_status_data vd ON vd.id = version->'id'->>'value'
 			WHERE c.ehr_id = $1
 				AND version->>'type' = 'EHR_STATUS'
 			GROUP BY version->'id'->>'value'
@@ -748,6 +830,10 @@ func (s *Service) CreateComposition(ctx context.Context, ehrID uuid.UUID, compos
 		return rm.COMPOSITION{}, err
 	}
 
+	if err := verifyAttachmentURIs(ctx, s.DB, ehrID, "$", composition); err != nil {
+		return rm.COMPOSITION{}, err
+	}
+
 	// Check if versioned object ID is already used
 	if composition.UID.E {
 		var versionedCompositionIDstr string
@@ -802,6 +888,10 @@ func (s *Service) CreateComposition(ctx context.Context, ehrID uuid.UUID, compos
 		}
 	}()
 
+	if err := checkEHRModifiable(ctx, tx, ehrID); err != nil {
+		return rm.COMPOSITION{}, err
+	}
+
 	batch := &pgx.Batch{}
 
 	contributionData, err := sonic.Marshal(contribution)
@@ -867,7 +957,8 @@ func (s *Service) CreateComposition(ctx context.Context, ehrID uuid.UUID, compos
 }
 
 func (s *Service) GetCompositionID(ctx context.Context, ehrID uuid.UUID, versionedCompositionID uuid.UUID) (rm.OBJECT_VERSION_ID, error) {
-	query := `SELECT id FROM openehr.tbl_composition WHERE ehr_id = $1 AND versioned_composition_id = $2 ORDER BY version_int DESC LIMIT 1`
+	// Branch versions never become the latest version, only the trunk of the version tree does
+	query := `SELECT id FROM openehr.tbl_composition WHERE ehr_id = $1 AND versioned_composition_id = $2 AND version_int & 65535 = 0 ORDER BY version_int DESC LIMIT 1`
 	args := []any{ehrID, versionedCompositionID}
 
 	var id string
@@ -881,6 +972,40 @@ func (s *Service) GetCompositionID(ctx context.Context, ehrID uuid.UUID, version
 	return rm.OBJECT_VERSION_ID{Value: id}, nil
 }
 
+// GetVersionCommitTime returns when the version with the given OBJECT_VERSION_ID was committed, whichever type of object it holds
+func (s *Service) GetVersionCommitTime(ctx context.Context, versionID string) (time.Time, error) {
+	query := `
+		SELECT created_at FROM openehr.tbl_ehr_status WHERE id = $1
+		UNION ALL
+		SELECT created_at FROM openehr.tbl_ehr_access WHERE id = $1
+		UNION ALL
+		SELECT created_at FROM openehr.tbl_composition WHERE id = $1
+		UNION ALL
+		SELECT created_at FROM openehr.tbl_folder WHERE id = $1
+		UNION ALL
+		SELECT created_at FROM openehr.tbl_person WHERE id = $1
+		UNION ALL
+		SELECT created_at FROM openehr.tbl_agent WHERE id = $1
+		UNION ALL
+		SELECT created_at FROM openehr.tbl_group WHERE id = $1
+		UNION ALL
+		SELECT created_at FROM openehr.tbl_organisation WHERE id = $1
+		UNION ALL
+		SELECT created_at FROM openehr.tbl_role WHERE id = $1
+		LIMIT 1
+	`
+
+	var committedAt time.Time
+	if err := s.DB.QueryRow(ctx, query, versionID).Scan(&committedAt); err != nil {
+		if err == database.ErrNoRows {
+			return time.Time{}, ErrVersionNotFound
+		}
+		return time.Time{}, fmt.Errorf("failed to fetch version commit time from database: %w", err)
+	}
+
+	return committedAt, nil
+}
+
 func (s *Service) GetCompositionRawJSON(ctx context.Context, ehrID uuid.UUID, objectVersionID string) ([]byte, error) {
 	query := `
 		SELECT cd.data 
@@ -906,7 +1031,7 @@ func (s *Service) GetCompositionByVersionedCompositionIDRawJSON(ctx context.Cont
 		SELECT cd.data
 		FROM openehr.tbl_composition c 
 		JOIN openehr.tbl_composition_data cd ON cd.id = c.id 
-		WHERE c.ehr_id = $1 AND c.versioned_composition_id = $2
+		WHERE c.ehr_id = $1 AND c.versioned_composition_id = $2 AND c.version_int & 65535 = 0
 		ORDER BY c.version_int DESC
 		LIMIT 1
 	`
@@ -929,6 +1054,10 @@ func (s *Service) UpdateComposition(ctx context.Context, ehrID uuid.UUID, curren
 		return rm.COMPOSITION{}, err
 	}
 
+	if err := verifyAttachmentURIs(ctx, s.DB, ehrID, "$", nextComposition); err != nil {
+		return rm.COMPOSITION{}, err
+	}
+
 	// Ensure Composition contains a UID to check/upgrade
 	if !nextComposition.UID.E {
 		nextComposition.UID = utils.Some(rm.UID_BASED_ID_from_HIER_OBJECT_ID(&rm.HIER_OBJECT_ID{
@@ -972,6 +1101,10 @@ func (s *Service) UpdateComposition(ctx context.Context, ehrID uuid.UUID, curren
 		return rm.COMPOSITION{}, fmt.Errorf("failed to marshal composition version data: %w", err)
 	}
 
+	if err := checkEHRModifiable(ctx, tx, ehrID); err != nil {
+		return rm.COMPOSITION{}, err
+	}
+
 	batch := &pgx.Batch{}
 
 	// Insert CONTRIBUTION
@@ -1036,6 +1169,10 @@ func (s *Service) DeleteVersionedComposition(ctx context.Context, ehrID uuid.UUI
 		}
 	}()
 
+	if err := checkEHRModifiable(ctx, tx, ehrID); err != nil {
+		return err
+	}
+
 	batch := &pgx.Batch{}
 
 	// Insert CONTRIBUTION
@@ -1105,6 +1242,7 @@ func (s *Service) GetVersionedCompositionRawJSON(ctx context.Context, ehrID uuid
 	return data, nil
 }
 
+// GetVersionedCompositionRevisionHistoryRawJSON lists the versions in version tree order, so the versions of a branch follow the trunk version they branch from
 func (s *Service) GetVersionedCompositionRevisionHistoryRawJSON(ctx context.Context, ehrID uuid.UUID, versionedCompositionID string) ([]byte, error) {
 	query := `
 		SELECT jsonb_build_object(
@@ -1115,16 +1253,17 @@ func (s *Service) GetVersionedCompositionRevisionHistoryRawJSON(ctx context.Cont
 					),
 					'audits', audits
 				)
-				ORDER BY version_id
+				ORDER BY string_to_array(split_part(version_id, '::', 3), '.')::int[]
 			)
 		)
 		FROM (
 			SELECT 
 				version->'id'->>'value' as version_id,
-				jsonb_agg(cd.data->'audit' ORDER BY cd.data->'audit'->'time_committed'->>'value') as audits
+				jsonb_agg(COALESCE(vd.version_data->'commit_audit', cd.data->'audit') ORDER BY cd.data->'audit'->'time_committed'->>'value') as audits
 			FROM openehr.tbl_contribution c
 			JOIN openehr.tbl_contribution_data cd ON c.id = cd.id,
 				jsonb_array_elements(cd.data->'versions') as version
+				LEFT JOIN openehr.tbl_composition_data vd ON vd.id = version->'id'->>'value'
 			WHERE c.ehr_id = $1
 				AND version->>'type' = 'COMPOSITION'
 				AND version->'id'->>'value' LIKE $2 || '%'
@@ -1152,7 +1291,7 @@ func (s *Service) GetCompositionAtTimeRawJSON(ctx context.Context, ehrID uuid.UU
 		SELECT cd.data 
 		FROM openehr.tbl_composition c
 		JOIN openehr.tbl_composition_data cd ON cd.id = c.id
-		WHERE c.ehr_id = $1 AND c.versioned_composition_id = $2
+		WHERE c.ehr_id = $1 AND c.versioned_composition_id = $2 AND c.version_int & 65535 = 0
 	`
 	args := []any{ehrID, versionedCompositionID}
 
@@ -1204,92 +1343,7 @@ func (s *Service) ValidateDirectory(ctx context.Context, ehrID uuid.UUID, direct
 		return validateErr
 	}
 
-	// Additional Directory validation can be added here
-	folderQueue := make([]rm.FOLDER, 0)
-	folderQueue = append(folderQueue, directory)
-	pathQueue := make([]string, 0)
-	pathQueue = append(pathQueue, "$")
-
-	for len(folderQueue) > 0 {
-		currentFolder := folderQueue[0]
-		folderQueue = folderQueue[1:]
-		currentPath := pathQueue[0]
-		pathQueue = pathQueue[1:]
-
-		// Add folders
-		if currentFolder.Folders.E {
-			folderQueue = append(folderQueue, currentFolder.Folders.V...)
-			for i := range currentFolder.Folders.V {
-				pathQueue = append(pathQueue, fmt.Sprintf("%s.folders[%d]", currentPath, i))
-			}
-		}
-
-		// Process object references
-		for i, currentRef := range currentFolder.Items.V {
-			if currentRef.Namespace != config.NAMESPACE_LOCAL {
-				// Skip external references
-				continue
-			}
-
-			itemPath := fmt.Sprintf("%s.items[%d]", currentPath, i)
-
-			// Handle different reference types
-			switch currentRef.Type {
-			case rm.COMPOSITION_TYPE:
-				id, ok := currentRef.ID.Value.(*rm.OBJECT_VERSION_ID)
-				if !ok {
-					validateErr.Errs = append(validateErr.Errs, outil.ValidationError{
-						Model:          rm.COMPOSITION_TYPE,
-						Path:           itemPath,
-						Message:        "Mismatch between type and id provided",
-						Recommendation: "Ensure the ID is of type OBJECT_VERSION_ID",
-					})
-				}
-
-				exists, err := s.ExistsComposition(ctx, ehrID, id.Value)
-				if err != nil {
-					return fmt.Errorf("failed to validate existence of Composition: %w", err)
-				}
-				if !exists {
-					validateErr.Errs = append(validateErr.Errs, outil.ValidationError{
-						Model:          rm.COMPOSITION_TYPE,
-						Path:           itemPath,
-						Message:        "COMPOSITION does not exist for this EHR in the system",
-						Recommendation: "Ensure the composition exists for this EHR",
-					})
-				}
-			case rm.VERSIONED_COMPOSITION_TYPE:
-				id, ok := currentRef.ID.Value.(*rm.HIER_OBJECT_ID)
-				if !ok {
-					validateErr.Errs = append(validateErr.Errs, outil.ValidationError{
-						Model:          rm.VERSIONED_COMPOSITION_TYPE,
-						Path:           itemPath,
-						Message:        "Mismatch between type and id provided",
-						Recommendation: "Ensure the ID is of type HIER_OBJECT_ID",
-					})
-				}
-
-				exists, err := s.ExistsComposition(ctx, ehrID, id.Value)
-				if err != nil {
-					return fmt.Errorf("failed to validate existence of Composition: %w", err)
-				}
-				if !exists {
-					validateErr.Errs = append(validateErr.Errs, outil.ValidationError{
-						Model:          rm.COMPOSITION_TYPE,
-						Path:           itemPath,
-						Message:        "COMPOSITION does not exist for this EHR in the system",
-						Recommendation: "Ensure the composition exists for this EHR",
-					})
-				}
-			}
-		}
-	}
-
-	if len(validateErr.Errs) > 0 {
-		return validateErr
-	}
-
-	return nil
+	return validateDirectoryItems(ctx, s.DB, ehrID, directory)
 }
 
 func (s *Service) CreateDirectory(ctx context.Context, ehrID uuid.UUID, directory rm.FOLDER) (rm.FOLDER, error) {
@@ -1362,6 +1416,10 @@ func (s *Service) CreateDirectory(ctx context.Context, ehrID uuid.UUID, director
 		}
 	}()
 
+	if err := checkEHRModifiable(ctx, tx, ehrID); err != nil {
+		return rm.FOLDER{}, err
+	}
+
 	batch := &pgx.Batch{}
 
 	// Insert CONTRIBUTION
@@ -1375,12 +1433,12 @@ func (s *Service) CreateDirectory(ctx context.Context, ehrID uuid.UUID, director
 	batch.Queue(`INSERT INTO openehr.tbl_versioned_folder_data (id, data) VALUES ($1, $2)`, versionedFolder.UID.Value, versionedFolder)
 
 	// Insert FOLDER
-	batch.Queue(`INSERT INTO openehr.tbl_folder (id, version_int, version_object_id, ehr_id, contribution_id) VALUES ($1, $2, $3, $4, $5)`, folderVersion.UID.Value, directory.UID.V.OBJECT_VERSION_ID().VersionTreeID().Int(), directory.UID.V.OBJECT_VERSION_ID().UID(), ehrID, contribution.UID.Value)
+	batch.Queue(`INSERT INTO openehr.tbl_folder (id, version_int, versioned_folder_id, ehr_id, contribution_id) VALUES ($1, $2, $3, $4, $5)`, folderVersion.UID.Value, directory.UID.V.OBJECT_VERSION_ID().VersionTreeID().Int(), directory.UID.V.OBJECT_VERSION_ID().UID(), ehrID, contribution.UID.Value)
 	folderVersion.SetModelName()
 	batch.Queue(`INSERT INTO openehr.tbl_folder_data (id, data, version_data) VALUES ($1, ($2::jsonb)->'data', jsonb_set($2::jsonb, '{data}', 'null', true))`, folderVersion.UID.Value, folderVersion)
 
 	// Update EHR, add contribution ref to list
-	batch.Queue(`UPDATE openehr.tbl_ehr
+	batch.Queue(`UPDATE openehr.tbl_ehr_data
 		SET data = jsonb_insert(
 			jsonb_insert(
 				jsonb_set(data, '{directory}', $2::jsonb)
@@ -1449,11 +1507,11 @@ func (s *Service) GetDirectoryRawJSON(ctx context.Context, ehrID uuid.UUID) ([]b
 		SELECT fd.data
         FROM openehr.tbl_folder f
         JOIN openehr.tbl_folder_data fd ON fd.id = f.id
-        WHERE f.ehr_id = $2
-        ORDER BY f.created_at DESC
+        WHERE f.ehr_id = $1
+        ORDER BY f.version_int DESC
         LIMIT 1
 	`
-	args := []any{rm.FOLDER_TYPE, ehrID}
+	args := []any{ehrID}
 
 	row := s.DB.QueryRow(ctx, query, args...)
 
@@ -1486,17 +1544,6 @@ func (s *Service) UpdateDirectory(ctx context.Context, ehrID uuid.UUID, currentD
 	}
 	nextDirectory.UID = utils.Some(rm.UID_BASED_ID_from_OBJECT_VERSION_ID(&updatedID))
 
-	folderVersion := NewOriginalVersion(nextDirectory.UID.V.OBJECT_VERSION_ID(), rm.ORIGINAL_VERSION_DATA_from_FOLDER(nextDirectory), utils.Some(currentDirectoryID))
-	contribution := NewContribution("Directory updated", terminology.AUDIT_CHANGE_TYPE_CODE_MODIFICATION,
-		[]rm.OBJECT_REF{
-			{
-				Type:      rm.VERSIONED_FOLDER_TYPE,
-				Namespace: rm.Namespace_local,
-				ID:        rm.OBJECT_ID_from_OBJECT_VERSION_ID(nextDirectory.UID.V.OBJECT_VERSION_ID()),
-			},
-		},
-	)
-
 	tx, err := s.DB.Begin(ctx)
 	if err != nil {
 		return rm.FOLDER{}, fmt.Errorf("failed to begin transaction: %w", err)
@@ -1507,27 +1554,12 @@ func (s *Service) UpdateDirectory(ctx context.Context, ehrID uuid.UUID, currentD
 		}
 	}()
 
-	batch := &pgx.Batch{}
-
-	// Insert CONTRIBUTION
-	batch.Queue(`INSERT INTO openehr.tbl_contribution (id, ehr_id) VALUES ($1, $2)`, contribution.UID.Value, ehrID)
-	contribution.SetModelName()
-	batch.Queue(`INSERT INTO openehr.tbl_contribution_data (id, data) VALUES ($1, $2)`, contribution.UID.Value, contribution)
-
-	// Insert FOLDER
-	batch.Queue(`INSERT INTO openehr.tbl_folder (id, version_int, version_object_id, ehr_id, contribution_id) VALUES ($1, $2, $3, $4, $5)`, folderVersion.UID.Value, nextDirectory.UID.V.OBJECT_VERSION_ID().VersionTreeID().Int(), nextDirectory.UID.V.OBJECT_VERSION_ID().UID(), ehrID, contribution.UID.Value)
-	folderVersion.SetModelName()
-	batch.Queue(`INSERT INTO openehr.tbl_folder_data (id, data, version_data) VALUES ($1, ($2::jsonb)->'data', jsonb_set($2::jsonb, '{data}', 'null', true))`, folderVersion.UID.Value, folderVersion)
+	if err := checkEHRModifiable(ctx, tx, ehrID); err != nil {
+		return rm.FOLDER{}, err
+	}
 
-	// Update EHR with contribution ref
-	batch.Queue(`UPDATE openehr.tbl_ehr
-		SET data = jsonb_insert(data, '{contributions, -1}', $1::jsonb, true)
-		WHERE id = $2
-	`, rm.OBJECT_REF{
-		Type:      rm.CONTRIBUTION_TYPE,
-		Namespace: rm.Namespace_local,
-		ID:        rm.OBJECT_ID_from_HIER_OBJECT_ID(contribution.UID),
-	}, ehrID)
+	batch := &pgx.Batch{}
+	queueDirectoryVersion(batch, ehrID, currentDirectoryID, nextDirectory, "Directory updated")
 
 	br := tx.SendBatch(ctx, batch)
 	_, err = br.Exec()
@@ -1569,6 +1601,10 @@ func (s *Service) DeleteDirectory(ctx context.Context, ehrID uuid.UUID, versione
 		}
 	}()
 
+	if err := checkEHRModifiable(ctx, tx, ehrID); err != nil {
+		return err
+	}
+
 	batch := &pgx.Batch{}
 
 	// Insert CONTRIBUTION
@@ -1582,7 +1618,7 @@ func (s *Service) DeleteDirectory(ctx context.Context, ehrID uuid.UUID, versione
 	// Update EHR, add contribution ref to list and remove directory reference
 	// Folder reference is deleted as just the first entry, like the openehr docs specify
 	batch.Queue(`
-		UPDATE openehr.tbl_ehr
+		UPDATE openehr.tbl_ehr_data
 		SET data = jsonb_insert(data, '{contributions, -1}', $1::jsonb, true) #- '{directory}' #- '{folders, 0}'
 		WHERE id = $2
 	`, rm.OBJECT_REF{
@@ -1624,11 +1660,11 @@ func (s *Service) GetFolderAtTimeRawJSON(ctx context.Context, ehrID uuid.UUID, f
 	args := []any{ehrID, jsonPath}
 
 	if !filterAtTime.IsZero() {
-		query += `AND ov.created_at <= $3 `
+		query += `AND f.created_at <= $3 `
 		args = append(args, filterAtTime)
 	}
 
-	query += `ORDER BY ov.version_int DESC LIMIT 1`
+	query += `ORDER BY f.version_int DESC LIMIT 1`
 
 	row := s.DB.QueryRow(ctx, query, args...)
 
@@ -3172,11 +3208,63 @@ func (s *Service) ValidateRole(ctx context.Context, role rm.ROLE) error {
 		return validateErr
 	}
 
-	// Additional Organisation validation can be added here
+	// A role is always played by a party, a local performer must exist in this CDR
+	exists, err := s.ExistsRolePerformer(ctx, role.Performer)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		validateErr.Errs = append(validateErr.Errs, outil.ValidationError{
+			Model:          rm.ROLE_TYPE,
+			Path:           "$.performer",
+			Message:        "performer does not refer to an existing party",
+			Recommendation: "Ensure performer refers to the versioned party ID of an existing Person, Agent, Group or Organisation",
+		})
+		return validateErr
+	}
 
 	return nil
 }
 
+// ExistsRolePerformer checks if the performer of a role refers to an existing party, references outside the local namespace cannot be checked
+func (s *Service) ExistsRolePerformer(ctx context.Context, performer rm.PARTY_REF) (bool, error) {
+	if performer.Namespace != rm.Namespace_local {
+		return true, nil
+	}
+
+	var partyIDStr string
+	switch id := performer.ID.Value.(type) {
+	case *rm.HIER_OBJECT_ID:
+		partyIDStr = id.Value
+	case *rm.OBJECT_VERSION_ID:
+		partyIDStr = id.UID()
+	default:
+		return true, nil
+	}
+
+	partyID, err := uuid.Parse(partyIDStr)
+	if err != nil {
+		return false, nil
+	}
+
+	// Roles cannot perform other roles
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM openehr.tbl_versioned_object vo
+			WHERE vo.id = $1 AND vo.type = $2
+			  AND NOT EXISTS (SELECT 1 FROM openehr.tbl_role r WHERE r.versioned_party_id = vo.id)
+		)
+	`
+
+	var exists bool
+	err = s.DB.QueryRow(ctx, query, partyID, rm.VERSIONED_PARTY_TYPE).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if role performer exists: %w", err)
+	}
+
+	return exists, nil
+}
+
 func (s *Service) CreateRole(ctx context.Context, role rm.ROLE) (rm.ROLE, error) {
 	err := s.ValidateRole(ctx, role)
 	if err != nil {
@@ -3199,6 +3287,7 @@ func (s *Service) CreateRole(ctx context.Context, role rm.ROLE) (rm.ROLE, error)
 		if err != nil {
 			return rm.ROLE{}, fmt.Errorf("invalid role UID: %w", err)
 		}
+
 		exists, err := s.ExistsVersionedObject(ctx, versionedPartyID)
 		if err != nil {
 			return rm.ROLE{}, fmt.Errorf("failed to check existing role: %w", err)
@@ -3206,6 +3295,12 @@ func (s *Service) CreateRole(ctx context.Context, role rm.ROLE) (rm.ROLE, error)
 		if exists {
 			return rm.ROLE{}, ErrRoleAlreadyExists
 		}
+
+		if role.UID.V.Kind == rm.UID_BASED_ID_kind_HIER_OBJECT_ID {
+			role.UID = utils.Some(rm.UID_BASED_ID_from_OBJECT_VERSION_ID(&rm.OBJECT_VERSION_ID{
+				Value: fmt.Sprintf("%s::%s::%d", versionedPartyID.String(), config.NAMESPACE_LOCAL, 1),
+			}))
+		}
 	} else {
 		// Assign a new UID if not provided
 		role.UID = utils.Some(rm.UID_BASED_ID_from_OBJECT_VERSION_ID(&rm.OBJECT_VERSION_ID{
@@ -3269,77 +3364,100 @@ func (s *Service) CreateRole(ctx context.Context, role rm.ROLE) (rm.ROLE, error)
 	return role, nil
 }
 
-func (s *Service) GetCurrentRoleVersionByVersionedPartyID(ctx context.Context, versionedPartyID uuid.UUID) (rm.ROLE, error) {
+func (s *Service) GetCurrentRoleID(ctx context.Context, versionedPartyID uuid.UUID) (rm.OBJECT_VERSION_ID, error) {
 	query := `
-		SELECT ovd.object_data
-		FROM openehr.tbl_object_version ov
-		JOIN openehr.tbl_object_version_data ovd ON ov.id = ovd.id 
-		WHERE ov.versioned_object_id = $1 AND ov.type = $2
-		ORDER BY ov.created_at DESC
+		SELECT r.id 
+		FROM openehr.tbl_role r
+		WHERE r.versioned_party_id = $1
+		ORDER BY r.version_int DESC
 		LIMIT 1
 	`
+	row := s.DB.QueryRow(ctx, query, versionedPartyID)
 
-	var role rm.ROLE
-	err := s.DB.QueryRow(ctx, query, versionedPartyID.String(), rm.ROLE_TYPE).Scan(&role)
+	var roleID string
+	err := row.Scan(&roleID)
 	if err != nil {
 		if errors.Is(err, database.ErrNoRows) {
-			return rm.ROLE{}, ErrRoleNotFound
+			return rm.OBJECT_VERSION_ID{}, ErrRoleNotFound
 		}
-		return rm.ROLE{}, fmt.Errorf("failed to get latest role by versioned party ID: %w", err)
+		return rm.OBJECT_VERSION_ID{}, fmt.Errorf("failed to get latest role by versioned party ID: %w", err)
 	}
 
-	return role, nil
+	return rm.OBJECT_VERSION_ID{Value: roleID}, nil
 }
 
-func (s *Service) GetRoleRawJSON(ctx context.Context, versionID string) (rm.ROLE, error) {
-	query := `SELECT data FROM openehr.tbl_role_data WHERE id = $1 LIMIT 1`
+func (s *Service) GetRoleByVersionedPartyIDRawJSON(ctx context.Context, versionedPartyID uuid.UUID) ([]byte, error) {
+	query := `
+		SELECT rd.data 
+		FROM openehr.tbl_role r
+		JOIN openehr.tbl_role_data rd ON rd.id = r.id 
+		WHERE r.versioned_party_id = $1
+		ORDER BY r.version_int DESC
+		LIMIT 1
+	`
+
+	row := s.DB.QueryRow(ctx, query, versionedPartyID)
 
-	var role rm.ROLE
-	err := s.DB.QueryRow(ctx, query, versionID, rm.ROLE_TYPE).Scan(&role)
+	var data []byte
+	err := row.Scan(&data)
 	if err != nil {
 		if errors.Is(err, database.ErrNoRows) {
-			return rm.ROLE{}, ErrRoleNotFound
+			return nil, ErrRoleNotFound
 		}
-		return rm.ROLE{}, fmt.Errorf("failed to get role at version: %w", err)
+		return nil, fmt.Errorf("failed to get latest role by versioned party ID: %w", err)
 	}
 
-	return role, nil
+	return data, nil
 }
 
-func (s *Service) UpdateRole(ctx context.Context, versionedPartyID uuid.UUID, role rm.ROLE) (rm.ROLE, error) {
-	err := s.ValidateRole(ctx, role)
+func (s *Service) GetRoleByIDRawJSON(ctx context.Context, roleID string) ([]byte, error) {
+	query := `SELECT data FROM openehr.tbl_role_data WHERE id = $1 LIMIT 1`
+
+	row := s.DB.QueryRow(ctx, query, roleID)
+
+	var data []byte
+	err := row.Scan(&data)
 	if err != nil {
-		return rm.ROLE{}, err
+		if errors.Is(err, database.ErrNoRows) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, fmt.Errorf("failed to get role: %w", err)
 	}
 
-	currentRole, err := s.GetCurrentRoleVersionByVersionedPartyID(ctx, versionedPartyID)
+	return data, nil
+}
+
+func (s *Service) UpdateRole(ctx context.Context, currentRoleID rm.OBJECT_VERSION_ID, nextRole rm.ROLE) (rm.ROLE, error) {
+	err := s.ValidateRole(ctx, nextRole)
 	if err != nil {
-		if errors.Is(err, ErrRoleNotFound) {
-			return rm.ROLE{}, ErrRoleNotFound
-		}
-		return rm.ROLE{}, fmt.Errorf("failed to get current Role: %w", err)
+		return rm.ROLE{}, err
 	}
-	currentRoleID := currentRole.UID.V.Value.(*rm.OBJECT_VERSION_ID)
 
-	if !role.UID.E {
-		role.UID = utils.Some(rm.UID_BASED_ID_from_HIER_OBJECT_ID(&rm.HIER_OBJECT_ID{
+	if !nextRole.UID.E {
+		nextRole.UID = utils.Some(rm.UID_BASED_ID_from_HIER_OBJECT_ID(&rm.HIER_OBJECT_ID{
 			Value: currentRoleID.UID(),
 		}))
 	}
 
-	updatedID, err := UpgradeObjectVersionID(currentRole.UID.V, *currentRoleID)
+	updatedID, err := UpgradeObjectVersionID(nextRole.UID.V, currentRoleID)
 	if err != nil {
+		if errors.Is(err, ErrVersionLowerOrEqualToCurrent) {
+			return rm.ROLE{}, ErrRoleVersionLowerOrEqualToCurrent
+		}
 		return rm.ROLE{}, fmt.Errorf("failed to upgrade current Role UID: %w", err)
 	}
-	role.UID = utils.Some(rm.UID_BASED_ID_from_OBJECT_VERSION_ID(&updatedID))
+	if updatedID.UID() != currentRoleID.UID() {
+		return rm.ROLE{}, ErrInvalidRoleUIDMismatch
+	}
+	nextRole.UID = utils.Some(rm.UID_BASED_ID_from_OBJECT_VERSION_ID(&updatedID))
 
-	roleVersion := NewOriginalVersion(role.UID.V.OBJECT_VERSION_ID(), rm.ORIGINAL_VERSION_DATA_from_ROLE(role), utils.Some(*currentRoleID))
+	roleVersion := NewOriginalVersion(updatedID, rm.ORIGINAL_VERSION_DATA_from_ROLE(nextRole), utils.Some(currentRoleID))
 	contribution := NewContribution("Role updated", terminology.AUDIT_CHANGE_TYPE_CODE_MODIFICATION,
 		[]rm.OBJECT_REF{
 			{
 				Type:      rm.VERSIONED_PARTY_TYPE,
 				Namespace: rm.Namespace_local,
-				ID:        rm.OBJECT_ID_from_OBJECT_VERSION_ID(role.UID.V.OBJECT_VERSION_ID()),
+				ID:        rm.OBJECT_ID_from_OBJECT_VERSION_ID(updatedID),
 			},
 		},
 	)
@@ -3362,7 +3480,7 @@ func (s *Service) UpdateRole(ctx context.Context, versionedPartyID uuid.UUID, ro
 	batch.Queue(`INSERT INTO openehr.tbl_contribution_data (id, data) VALUES ($1, $2)`, contribution.UID.Value, contribution)
 
 	// Insert ROLE
-	batch.Queue(`INSERT INTO openehr.tbl_role (id, version_int, versioned_party_id, type, contribution_id) VALUES ($1, $2, $3, $4, $5)`, roleVersion.UID.Value, roleVersion.UID.VersionTreeID().Int(), versionedPartyID, rm.ROLE_TYPE, contribution.UID.Value)
+	batch.Queue(`INSERT INTO openehr.tbl_role (id, version_int, versioned_party_id, contribution_id) VALUES ($1, $2, $3, $4)`, roleVersion.UID.Value, roleVersion.UID.VersionTreeID().Int(), uuid.MustParse(updatedID.UID()), contribution.UID.Value)
 	roleVersion.SetModelName()
 	batch.Queue(`INSERT INTO openehr.tbl_role_data (id, data, version_data) VALUES ($1, ($2::jsonb)->'data', jsonb_set($2::jsonb, '{data}', 'null', true))`, roleVersion.UID.Value, roleVersion)
 
@@ -3380,7 +3498,7 @@ func (s *Service) UpdateRole(ctx context.Context, versionedPartyID uuid.UUID, ro
 		return rm.ROLE{}, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return role, nil
+	return nextRole, nil
 }
 
 func (s *Service) DeleteRole(ctx context.Context, versionedObjectID uuid.UUID) error {
@@ -3434,13 +3552,22 @@ func (s *Service) DeleteRole(ctx context.Context, versionedObjectID uuid.UUID) e
 }
 
 func (s *Service) GetVersionedPartyRawJSON(ctx context.Context, versionedPartyID uuid.UUID) ([]byte, error) {
-	query := `SELECT data FROM openehr.tbl_versioned_object WHERE id = $1 AND type = $2 LIMIT 1`
+	query := `
+		SELECT vpd.data
+		FROM openehr.tbl_versioned_object vo
+		JOIN openehr.tbl_versioned_party_data vpd ON vpd.id = vo.id
+		WHERE vo.id = $1 AND vo.type = $2
+		LIMIT 1
+	`
 
 	row := s.DB.QueryRow(ctx, query, versionedPartyID, rm.VERSIONED_PARTY_TYPE)
 
 	var data []byte
 	err := row.Scan(&data)
 	if err != nil {
+		if errors.Is(err, database.ErrNoRows) {
+			return nil, ErrVersionedPartyNotFound
+		}
 		return nil, fmt.Errorf("failed to get versioned party by ID: %w", err)
 	}
 	return data, nil
@@ -3456,23 +3583,36 @@ func (s *Service) GetVersionedPartyRevisionHistoryRawJSON(ctx context.Context, v
 					),
 					'audits', audits
 				)
-				ORDER BY version_id
+				ORDER BY string_to_array(split_part(version_id, '::', 3), '.')::int[]
 			)
 		)
 		FROM (
 			SELECT 
 				version->'id'->>'value' as version_id,
-				jsonb_agg(cd.data->'audit' ORDER BY cd.data->'audit'->'time_committed'->>'value') as audits
+				jsonb_agg(COALESCE(vd.version_data->'commit_audit', cd.data->'audit') ORDER BY cd.data->'audit'->'time_committed'->>'value') as audits
 			FROM openehr.tbl_contribution c
 			JOIN openehr.tbl_contribution_data cd ON c.id = cd.id,
 				jsonb_array_elements(cd.data->'versions') as version
+				LEFT JOIN LATERAL (
+					SELECT version_data FROM openehr.tbl_person_data WHERE id = version->'id'->>'value'
+					UNION ALL
+					SELECT version_data FROM openehr.tbl_agent_data WHERE id = version->'id'->>'value'
+					UNION ALL
+					SELECT version_data FROM openehr.tbl_group_data WHERE id = version->'id'->>'value'
+					UNION ALL
+					SELECT version_data FROM openehr.tbl_organisation_data WHERE id = version->'id'->>'value'
+					UNION ALL
+					SELECT version_data FROM openehr.tbl_role_data WHERE id = version->'id'->>'value'
+					LIMIT 1
+				) vd ON true
 			WHERE c.ehr_id IS NULL
 				AND version->>'type' = ANY($1::text[])
 				AND version->'id'->>'value' LIKE $2 || '%'
 			GROUP BY version->'id'->>'value'
 		) grouped
+		HAVING COUNT(*) > 0
 	`
-	args := []any{[]string{rm.AGENT_TYPE, rm.PERSON_TYPE, rm.GROUP_TYPE, rm.ORGANISATION_TYPE, rm.ROLE_TYPE}, versionedObjectID}
+	args := []any{[]string{rm.VERSIONED_PARTY_TYPE, rm.AGENT_TYPE, rm.PERSON_TYPE, rm.GROUP_TYPE, rm.ORGANISATION_TYPE, rm.ROLE_TYPE}, versionedObjectID.String()}
 
 	row := s.DB.QueryRow(ctx, query, args...)
 
@@ -3487,43 +3627,28 @@ func (s *Service) GetVersionedPartyRevisionHistoryRawJSON(ctx context.Context, v
 	return data, nil
 }
 
+// partyVersionsQuery selects the versions of every party type, a versioned party only ever holds one of them
+const partyVersionsQuery = `
+	SELECT a.id, ad.data, a.created_at FROM openehr.tbl_agent a JOIN openehr.tbl_agent_data ad ON ad.id = a.id WHERE a.versioned_party_id = $1
+	UNION ALL
+	SELECT p.id, pd.data, p.created_at FROM openehr.tbl_person p JOIN openehr.tbl_person_data pd ON pd.id = p.id WHERE p.versioned_party_id = $1
+	UNION ALL
+	SELECT g.id, gd.data, g.created_at FROM openehr.tbl_group g JOIN openehr.tbl_group_data gd ON gd.id = g.id WHERE g.versioned_party_id = $1
+	UNION ALL
+	SELECT o.id, od.data, o.created_at FROM openehr.tbl_organisation o JOIN openehr.tbl_organisation_data od ON od.id = o.id WHERE o.versioned_party_id = $1
+	UNION ALL
+	SELECT r.id, rd.data, r.created_at FROM openehr.tbl_role r JOIN openehr.tbl_role_data rd ON rd.id = r.id WHERE r.versioned_party_id = $1
+`
+
 func (s *Service) GetVersionedPartyVersionAtTimeRawJSON(ctx context.Context, versionedPartyID uuid.UUID, filterAtTime time.Time) ([]byte, error) {
-	query := `
-		SELECT data 
-		FROM (
-			SELECT data, created_at
-			FROM openehr.tbl_agent a
-			JOIN openehr.tbl_agent_data ad ON ad.id = a.id
-			WHERE a.versioned_party_id = $1
-			UNION ALL
-			SELECT data, created_at
-			FROM openehr.tbl_person p
-			JOIN openehr.tbl_person_data pd ON pd.id = p.id
-			WHERE p.versioned_party_id = $1
-			UNION ALL
-			SELECT data, created_at
-			FROM openehr.tbl_group g
-			JOIN openehr.tbl_group_data gd ON gd.id = g.id
-			WHERE g.versioned_party_id = $1
-			UNION ALL
-			SELECT data, created_at
-			FROM openehr.tbl_organisation o
-			JOIN openehr.tbl_organisation_data od ON od.id = o.id
-			WHERE o.versioned_party_id = $1
-			UNION ALL
-			SELECT data, created_at
-			FROM openehr.tbl_role r
-			JOIN openehr.tbl_role_data rd ON rd.id = r.id
-			WHERE r.versioned_party_id = $1
-		)
-	`
+	query := `SELECT party.data FROM (` + partyVersionsQuery + `) party `
 	args := []any{versionedPartyID}
 
 	if !filterAtTime.IsZero() {
-		query += `AND ov.created_at <= $2 `
+		query += `WHERE party.created_at <= $2 `
 		args = append(args, filterAtTime)
 	}
-	query += `ORDER BY ov.created_at DESC LIMIT 1`
+	query += `ORDER BY party.created_at DESC LIMIT 1`
 
 	row := s.DB.QueryRow(ctx, query, args...)
 
@@ -3538,14 +3663,32 @@ func (s *Service) GetVersionedPartyVersionAtTimeRawJSON(ctx context.Context, ver
 	return data, nil
 }
 
+func (s *Service) GetVersionedPartyVersionByIDRawJSON(ctx context.Context, versionedPartyID uuid.UUID, versionID string) ([]byte, error) {
+	query := `SELECT party.data FROM (` + partyVersionsQuery + `) party WHERE party.id = $2 LIMIT 1`
+	args := []any{versionedPartyID, versionID}
+
+	row := s.DB.QueryRow(ctx, query, args...)
+
+	var data []byte
+	if err := row.Scan(&data); err != nil {
+		if err == database.ErrNoRows {
+			return nil, ErrVersionedPartyVersionNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch Party version by ID from database: %w", err)
+	}
+
+	return data, nil
+}
+
 func (s *Service) GetContributionRawJSON(ctx context.Context, contributionID string, ehrID utils.Optional[uuid.UUID]) ([]byte, error) {
 	query := `
 		SELECT cd.data
 		FROM openehr.tbl_contribution c
 		JOIN openehr.tbl_contribution_data cd ON c.id = cd.id
-		WHERE c.ehr_id = $1 AND c.id = $2
+		WHERE c.ehr_id IS NOT DISTINCT FROM $1 AND c.id = $2
 		LIMIT 1
 	`
+	// Demographic contributions are not part of an EHR
 	args := []any{ehrID, contributionID}
 
 	row := s.DB.QueryRow(ctx, query, args...)
@@ -3718,12 +3861,12 @@ func (s *Service) ExistsVersionedObject(ctx context.Context, versionedObjectID u
 // 	return nil
 // }
 
-func (s *Service) QueryWithStream(ctx context.Context, w io.Writer, aqlQuery string, aqlParams map[string]any) error {
+func (s *Service) QueryWithStream(ctx context.Context, w io.Writer, aqlQuery string, aqlParams map[string]any, options aql.Options) error {
 	if aqlParams == nil {
 		aqlParams = make(map[string]any)
 	}
 
-	sqlQuery, _, err := aql.ToSQL(aqlQuery, aqlParams)
+	sqlQuery, _, err := aql.ToSQLWithOptions(aqlQuery, aqlParams, options)
 	if err != nil {
 		s.Logger.Error("internal error", "error", err)
 		return err
@@ -3819,7 +3962,8 @@ func (s *Service) ListStoredQueries(ctx context.Context, filterName string) ([]S
 				'type', 'AQL', 
 				'version', version,
 				'saved', to_char(created_at, 'YYYY-MM-DD"T"HH24:MI:SS.MSTZH:TZM'),
-				'q', query
+				'q', query,
+				'parameters', parameters
 			)), '[]'::jsonb) as queries
 		FROM openehr.tbl_query
 	`)
@@ -3842,39 +3986,88 @@ func (s *Service) ListStoredQueries(ctx context.Context, filterName string) ([]S
 	return queries, nil
 }
 
+// GetQueryByName returns the stored query with the given version. The version may be partial, e.g. '1.2'
+// resolves to the latest 1.2.x version, and when no version is given the latest version is returned.
 func (s *Service) GetQueryByName(ctx context.Context, name string, filterVersion string) (StoredQuery, error) {
-	var query strings.Builder
-	var args []any
+	rows, err := s.DB.Query(ctx, `SELECT name, version, query, parameters, created_at FROM openehr.tbl_query WHERE name = $1`, name)
+	if err != nil {
+		return StoredQuery{}, fmt.Errorf("error retrieving AQL query by name: %w", err)
+	}
+	defer rows.Close()
 
-	query.WriteString(`SELECT name, version, query, created_at FROM openehr.tbl_query WHERE name = $1 `)
-	args = append(args, name)
+	var latest StoredQuery
+	var latestVersion outil.SemVer
+	found := false
+	for rows.Next() {
+		var storedQuery StoredQuery
+		if err := rows.Scan(&storedQuery.Name, &storedQuery.Version, &storedQuery.Query, &storedQuery.Parameters, &storedQuery.Saved); err != nil {
+			return StoredQuery{}, fmt.Errorf("error scanning AQL query: %w", err)
+		}
+		storedQuery.Type = "AQL"
 
-	if filterVersion != "" {
-		query.WriteString(`AND version = $2 `)
-		args = append(args, filterVersion)
-	}
+		if storedQuery.Version == filterVersion {
+			return storedQuery, nil
+		}
 
-	query.WriteString(`ORDER BY created_at DESC LIMIT 1`)
+		version, ok := outil.ParseSemVer(storedQuery.Version)
+		if !ok {
+			continue
+		}
+		if filterVersion != "" && !version.MatchesPartial(filterVersion) {
+			continue
+		}
+		// Pre-releases are only returned when asked for explicitly
+		if version.PreRelease != "" {
+			continue
+		}
 
-	var storedQuery StoredQuery
-	if err := s.DB.QueryRow(ctx, query.String(), args...).Scan(&storedQuery.Name, &storedQuery.Version, &storedQuery.Query, &storedQuery.Saved); err != nil {
-		if err == database.ErrNoRows {
-			return StoredQuery{}, ErrQueryNotFound
+		if !found || version.Compare(latestVersion) > 0 {
+			latest = storedQuery
+			latestVersion = version
+			found = true
 		}
-		return StoredQuery{}, fmt.Errorf("error retrieving AQL query by name: %w", err)
+	}
+	if err := rows.Err(); err != nil {
+		return StoredQuery{}, fmt.Errorf("error iterating AQL queries: %w", err)
+	}
+
+	if !found {
+		return StoredQuery{}, ErrQueryNotFound
+	}
+
+	return latest, nil
+}
+
+// ValidateStoredQuery checks the qualified name, the SemVer version and the AQL, returning the parameters the AQL declares
+func ValidateStoredQuery(name, version, aqlQuery string) ([]aql.Parameter, error) {
+	if !outil.ValidateQualifiedQueryName(name) {
+		return nil, fmt.Errorf("%w: name must be of the form namespace::query_name", ErrQueryInvalid)
+	}
+
+	if !outil.ValidateSemVer(version) {
+		return nil, fmt.Errorf("%w: version must be a SemVer version, e.g. 1.0.0", ErrQueryInvalid)
 	}
 
-	storedQuery.Type = "AQL"
-	return storedQuery, nil
+	parameters, err := aql.ExtractParameters(aqlQuery)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrQueryInvalid, err)
+	}
+
+	return parameters, nil
 }
 
 func (s *Service) StoreQuery(ctx context.Context, name, version, aqlQuery string) error {
+	parameters, err := ValidateStoredQuery(name, version, aqlQuery)
+	if err != nil {
+		return err
+	}
+
 	// Store the new query
-	_, err := s.DB.Exec(ctx, `INSERT INTO openehr.tbl_query (id, name, version, query) VALUES ($1, $2, $3, $4) ON CONFLICT (name, version) DO UPDATE SET query = EXCLUDED.query`,
-		uuid.New(),
+	_, err = s.DB.Exec(ctx, `INSERT INTO openehr.tbl_query (name, version, query, parameters) VALUES ($1, $2, $3, $4) ON CONFLICT (name, version) DO UPDATE SET query = EXCLUDED.query, parameters = EXCLUDED.parameters`,
 		name,
 		version,
 		aqlQuery,
+		parameters,
 	)
 	if err != nil {
 		return fmt.Errorf("error storing AQL query: %w", err)
@@ -3883,6 +4076,24 @@ func (s *Service) StoreQuery(ctx context.Context, name, version, aqlQuery string
 	return nil
 }
 
+// DeleteStoredQuery deletes a single version of a stored query, or all of its versions when no version is given
+func (s *Service) DeleteStoredQuery(ctx context.Context, name, version string) error {
+	tag, err := s.DB.Exec(ctx, `DELETE FROM openehr.tbl_query WHERE name = $1 AND ($2 = '' OR version = $2)`, name, version)
+	if err != nil {
+		return fmt.Errorf("error deleting AQL query: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrQueryNotFound
+	}
+
+	return nil
+}
+
+// CheckQueryParameters type checks the values against the parameters declared by the stored query
+func (s *Service) CheckQueryParameters(storedQuery StoredQuery, values map[string]any) (map[string]any, error) {
+	return aql.CheckParameters(storedQuery.Parameters, values)
+}
+
 func NewVersionedEHRAccess(id, ehrID uuid.UUID) rm.VERSIONED_EHR_ACCESS {
 	return rm.VERSIONED_EHR_ACCESS{
 		UID: rm.HIER_OBJECT_ID{
@@ -4005,8 +4216,8 @@ func NewVersionedParty(uid uuid.UUID) rm.VERSIONED_PARTY {
 func UpgradeObjectVersionID(current rm.UIDBasedIDUnion, previous rm.OBJECT_VERSION_ID) (rm.OBJECT_VERSION_ID, error) {
 	switch current.Kind {
 	case rm.UID_BASED_ID_kind_OBJECT_VERSION_ID:
-		// Check version is incremented
-		if current.OBJECT_VERSION_ID().VersionTreeID().CompareTo(previous.VersionTreeID()) <= 0 {
+		// Check version is incremented on the trunk, branch versions can only be committed through a contribution
+		if !current.OBJECT_VERSION_ID().VersionTreeID().IsTrunk() || current.OBJECT_VERSION_ID().VersionTreeID().CompareTo(previous.VersionTreeID()) <= 0 {
 			return rm.OBJECT_VERSION_ID{}, ErrVersionLowerOrEqualToCurrent
 		}
 		return current.OBJECT_VERSION_ID(), nil
@@ -4031,7 +4242,7 @@ func NewContribution(description string, auditChangeType terminology.AuditChange
 		UID: rm.HIER_OBJECT_ID{
 			Value: uuid.NewString(),
 		},
-		Versions: make([]rm.OBJECT_REF, 0),
+		Versions: versions,
 		Audit: rm.AUDIT_DETAILS{
 			SystemID:      config.SYSTEM_ID_GOPENEHR,
 			TimeCommitted: rm.DV_DATE_TIME{Value: time.Now().UTC().Format(time.RFC3339)},
@@ -4053,6 +4264,16 @@ func NewContribution(description string, auditChangeType terminology.AuditChange
 				},
 			},
 		}
+	case terminology.AUDIT_CHANGE_TYPE_CODE_AMENDMENT:
+		contribution.Audit.ChangeType = rm.DV_CODED_TEXT{
+			Value: terminology.GetAuditChangeTypeName(terminology.AUDIT_CHANGE_TYPE_CODE_AMENDMENT),
+			DefiningCode: rm.CODE_PHRASE{
+				CodeString: string(terminology.AUDIT_CHANGE_TYPE_CODE_AMENDMENT),
+				TerminologyID: rm.TERMINOLOGY_ID{
+					Value: string(terminology.AUDIT_CHANGE_TYPE_TERMINOLOGY_ID_OPENEHR),
+				},
+			},
+		}
 	case terminology.AUDIT_CHANGE_TYPE_CODE_MODIFICATION:
 		contribution.Audit.ChangeType = rm.DV_CODED_TEXT{
 			Value: terminology.GetAuditChangeTypeName(terminology.AUDIT_CHANGE_TYPE_CODE_MODIFICATION),
@@ -4073,6 +4294,16 @@ func NewContribution(description string, auditChangeType terminology.AuditChange
 				},
 			},
 		}
+	case terminology.AUDIT_CHANGE_TYPE_CODE_IMPORT:
+		contribution.Audit.ChangeType = rm.DV_CODED_TEXT{
+			Value: "import",
+			DefiningCode: rm.CODE_PHRASE{
+				CodeString: string(terminology.AUDIT_CHANGE_TYPE_CODE_IMPORT),
+				TerminologyID: rm.TERMINOLOGY_ID{
+					Value: string(terminology.AUDIT_CHANGE_TYPE_TERMINOLOGY_ID_LOCAL),
+				},
+			},
+		}
 	default:
 		panic("unsupported audit change type")
 	}
@@ -4080,19 +4311,36 @@ func NewContribution(description string, auditChangeType terminology.AuditChange
 	return contribution
 }
 
+// NewImportedVersion wraps an ORIGINAL_VERSION of another system, the contribution importing it becomes the contribution of the version
+func NewImportedVersion(contribution rm.CONTRIBUTION, original rm.ORIGINAL_VERSION) rm.IMPORTED_VERSION {
+	return rm.IMPORTED_VERSION{
+		Contribution: rm.OBJECT_REF{
+			Type:      rm.CONTRIBUTION_TYPE,
+			Namespace: rm.Namespace_local,
+			ID:        rm.OBJECT_ID_from_HIER_OBJECT_ID(contribution.UID),
+		},
+		CommitAudit: contribution.Audit,
+		Item:        original,
+	}
+}
+
 func NewOriginalVersion(id rm.OBJECT_VERSION_ID, data rm.OriginalVersionDataUnion, precedingVersion utils.Optional[rm.OBJECT_VERSION_ID]) rm.ORIGINAL_VERSION {
 	return rm.ORIGINAL_VERSION{
 		UID:                 id,
 		PrecedingVersionUID: precedingVersion,
-		LifecycleState: rm.DV_CODED_TEXT{
-			Value: terminology.VersionLifecycleStateNames[terminology.VERSION_LIFECYCLE_STATE_CODE_COMPLETE],
-			DefiningCode: rm.CODE_PHRASE{
-				CodeString: terminology.VERSION_LIFECYCLE_STATE_CODE_COMPLETE,
-				TerminologyID: rm.TERMINOLOGY_ID{
-					Value: terminology.VERSION_LIFECYCLE_STATE_TERMINOLOGY_ID_OPENEHR,
-				},
+		LifecycleState:      NewVersionLifecycleState(terminology.VERSION_LIFECYCLE_STATE_CODE_COMPLETE),
+		Data:                data,
+	}
+}
+
+func NewVersionLifecycleState(code string) rm.DV_CODED_TEXT {
+	return rm.DV_CODED_TEXT{
+		Value: terminology.GetVersionLifecycleStateName(code),
+		DefiningCode: rm.CODE_PHRASE{
+			CodeString: code,
+			TerminologyID: rm.TERMINOLOGY_ID{
+				Value: terminology.VERSION_LIFECYCLE_STATE_TERMINOLOGY_ID_OPENEHR,
 			},
 		},
-		Data: data,
 	}
 }
@@ -0,0 +1,286 @@
+package openehr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/freekieb7/gopenehr/internal/database"
+	"github.com/freekieb7/gopenehr/internal/openehr/aql"
+	"github.com/freekieb7/gopenehr/internal/telemetry"
+	"github.com/freekieb7/gopenehr/internal/webhook"
+	"github.com/freekieb7/gopenehr/pkg/async"
+	"github.com/freekieb7/gopenehr/pkg/utils"
+	"github.com/google/uuid"
+)
+
+// StandingQuery is a stored AQL query that is evaluated against every newly committed composition
+type StandingQuery struct {
+	ID           uuid.UUID              `json:"id"`
+	QueryName    string                 `json:"query_name"`
+	QueryVersion utils.Optional[string] `json:"query_version"`
+	Parameters   map[string]any         `json:"parameters"`
+	IsActive     bool                   `json:"is_active"`
+	CreatedAt    time.Time              `json:"created_at"`
+	// EHRAccess is the caller that registered the standing query, it only matches compositions of EHRs they may read
+	EHRAccess utils.Optional[aql.EHRAccessSubject] `json:"-"`
+}
+
+// StandingQueryMatch holds the rows a standing query produced for a single composition
+type StandingQueryMatch struct {
+	Columns []string
+	Rows    []json.RawMessage
+}
+
+// ehrAccessColumns splits the caller a background query runs for into the access_subject and access_roles columns
+func ehrAccessColumns(ehrAccess utils.Optional[aql.EHRAccessSubject]) (utils.Optional[string], []string) {
+	if !ehrAccess.E {
+		return utils.None[string](), nil
+	}
+	return utils.Some(ehrAccess.V.Subject), ehrAccess.V.Roles
+}
+
+// ehrAccessFromColumns is the reverse of ehrAccessColumns
+func ehrAccessFromColumns(subject utils.Optional[string], roles []string) utils.Optional[aql.EHRAccessSubject] {
+	if !subject.E {
+		return utils.None[aql.EHRAccessSubject]()
+	}
+	return utils.Some(aql.EHRAccessSubject{Subject: subject.V, Roles: roles})
+}
+
+func (s *Service) RegisterStandingQuery(ctx context.Context, queryName string, queryVersion utils.Optional[string], parameters map[string]any, ehrAccess utils.Optional[aql.EHRAccessSubject]) (StandingQuery, error) {
+	if parameters == nil {
+		parameters = make(map[string]any)
+	}
+
+	storedQuery, err := s.GetQueryByName(ctx, queryName, queryVersion.V)
+	if err != nil {
+		return StandingQuery{}, err
+	}
+
+	parameters, err = s.CheckQueryParameters(storedQuery, parameters)
+	if err != nil {
+		return StandingQuery{}, fmt.Errorf("%w: %w", ErrStandingQueryInvalid, err)
+	}
+
+	// The query must be restrictable to a single composition, otherwise it cannot be evaluated on commit
+	if _, _, err := aql.ToSQLWithOptions(storedQuery.Query, parameters, aql.Options{CompositionID: utils.Some("")}); err != nil {
+		return StandingQuery{}, fmt.Errorf("%w: %w", ErrStandingQueryInvalid, err)
+	}
+
+	standingQuery := StandingQuery{
+		QueryName:    queryName,
+		QueryVersion: queryVersion,
+		Parameters:   parameters,
+		IsActive:     true,
+		EHRAccess:    ehrAccess,
+	}
+
+	accessSubject, accessRoles := ehrAccessColumns(standingQuery.EHRAccess)
+	row := s.DB.QueryRow(ctx, `INSERT INTO openehr.tbl_standing_query (query_name, query_version, parameters, is_active, access_subject, access_roles) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		standingQuery.QueryName, standingQuery.QueryVersion, standingQuery.Parameters, standingQuery.IsActive, accessSubject, accessRoles)
+	if err := row.Scan(&standingQuery.ID, &standingQuery.CreatedAt); err != nil {
+		return StandingQuery{}, fmt.Errorf("failed to insert standing query: %w", err)
+	}
+
+	return standingQuery, nil
+}
+
+func (s *Service) ListStandingQueries(ctx context.Context, onlyActive bool) ([]StandingQuery, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT id, query_name, query_version, parameters, is_active, created_at, access_subject, access_roles
+		FROM openehr.tbl_standing_query
+		WHERE NOT $1 OR is_active
+		ORDER BY created_at
+	`, onlyActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query standing queries: %w", err)
+	}
+	defer rows.Close()
+
+	standingQueries := make([]StandingQuery, 0)
+	for rows.Next() {
+		var standingQuery StandingQuery
+		var accessSubject utils.Optional[string]
+		var accessRoles []string
+		if err := rows.Scan(&standingQuery.ID, &standingQuery.QueryName, &standingQuery.QueryVersion, &standingQuery.Parameters, &standingQuery.IsActive, &standingQuery.CreatedAt, &accessSubject, &accessRoles); err != nil {
+			return nil, fmt.Errorf("failed to scan standing query: %w", err)
+		}
+		standingQuery.EHRAccess = ehrAccessFromColumns(accessSubject, accessRoles)
+		standingQueries = append(standingQueries, standingQuery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating standing queries: %w", err)
+	}
+
+	return standingQueries, nil
+}
+
+func (s *Service) GetStandingQuery(ctx context.Context, id uuid.UUID) (StandingQuery, error) {
+	var standingQuery StandingQuery
+	var accessSubject utils.Optional[string]
+	var accessRoles []string
+	err := s.DB.QueryRow(ctx, `
+		SELECT id, query_name, query_version, parameters, is_active, created_at, access_subject, access_roles
+		FROM openehr.tbl_standing_query
+		WHERE id = $1
+	`, id).Scan(&standingQuery.ID, &standingQuery.QueryName, &standingQuery.QueryVersion, &standingQuery.Parameters, &standingQuery.IsActive, &standingQuery.CreatedAt, &accessSubject, &accessRoles)
+	if err != nil {
+		if err == database.ErrNoRows {
+			return StandingQuery{}, ErrStandingQueryNotFound
+		}
+		return StandingQuery{}, fmt.Errorf("failed to get standing query: %w", err)
+	}
+	standingQuery.EHRAccess = ehrAccessFromColumns(accessSubject, accessRoles)
+
+	return standingQuery, nil
+}
+
+func (s *Service) DeleteStandingQuery(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.DB.Exec(ctx, `DELETE FROM openehr.tbl_standing_query WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete standing query: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrStandingQueryNotFound
+	}
+
+	return nil
+}
+
+// EvaluateStandingQuery executes the standing query restricted to the given composition version
+func (s *Service) EvaluateStandingQuery(ctx context.Context, standingQuery StandingQuery, compositionID string) (StandingQueryMatch, error) {
+	storedQuery, err := s.GetQueryByName(ctx, standingQuery.QueryName, standingQuery.QueryVersion.V)
+	if err != nil {
+		return StandingQueryMatch{}, err
+	}
+
+	sqlQuery, columns, err := aql.ToSQLWithOptions(storedQuery.Query, standingQuery.Parameters, aql.Options{CompositionID: utils.Some(compositionID), EHRAccess: standingQuery.EHRAccess})
+	if err != nil {
+		return StandingQueryMatch{}, fmt.Errorf("%w: %w", ErrStandingQueryInvalid, err)
+	}
+
+	rows, err := s.DB.Query(ctx, sqlQuery)
+	if err != nil {
+		return StandingQueryMatch{}, fmt.Errorf("failed to execute standing query: %w", err)
+	}
+	defer rows.Close()
+
+	match := StandingQueryMatch{
+		Columns: columns,
+		Rows:    make([]json.RawMessage, 0),
+	}
+	for rows.Next() {
+		var row json.RawMessage
+		if err := rows.Scan(&row); err != nil {
+			return StandingQueryMatch{}, fmt.Errorf("failed to scan standing query row: %w", err)
+		}
+		match.Rows = append(match.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return StandingQueryMatch{}, fmt.Errorf("error iterating standing query rows: %w", err)
+	}
+
+	return match, nil
+}
+
+// CommittedComposition identifies a composition version that standing queries should be evaluated against
+type CommittedComposition struct {
+	EHRID         uuid.UUID
+	CompositionID string
+}
+
+type StandingQueryEvaluator struct {
+	Logger      *telemetry.Logger
+	Service     *Service
+	WebhookSink webhook.Sink
+
+	worker *async.Worker[CommittedComposition]
+}
+
+// NewStandingQueryEvaluator creates an evaluator backed by async.Worker, so commits are not delayed by evaluation
+func NewStandingQueryEvaluator(tel *telemetry.Telemetry, service *Service, webhookSink webhook.Sink) *StandingQueryEvaluator {
+	// Create worker metrics
+	queueDepth, _ := tel.Metrics.Meter.Int64UpDownCounter("standing_query_queue_depth")
+	droppedEvents, _ := tel.Metrics.Meter.Int64Counter("standing_query_dropped_total")
+	flushFailures, _ := tel.Metrics.Meter.Int64Counter("standing_query_flush_failures")
+	batchSize, _ := tel.Metrics.Meter.Int64Histogram("standing_query_flush_batch_size")
+
+	metrics := &async.WorkerMetrics{
+		QueueDepth:    queueDepth,
+		DroppedEvents: droppedEvents,
+		FlushFailures: flushFailures,
+		BatchSize:     batchSize,
+	}
+
+	cfg := async.WorkerConfig{
+		Logger:     tel.Logger,
+		QueueSize:  1000,
+		BatchSize:  100,
+		FlushEvery: 1 * time.Second,
+		Metrics:    metrics,
+		Tracer:     tel.Tracing.Tracer,
+	}
+
+	evaluator := &StandingQueryEvaluator{
+		Logger:      tel.Logger,
+		Service:     service,
+		WebhookSink: webhookSink,
+	}
+
+	// Worker flush function calls StandingQueryEvaluator.evaluate
+	evaluator.worker = async.NewWorker(cfg, func(ctx context.Context, batch []CommittedComposition) error {
+		return evaluator.evaluate(ctx, batch)
+	})
+
+	return evaluator
+}
+
+// Start launches the worker
+func (e *StandingQueryEvaluator) Start(ctx context.Context) {
+	e.worker.Start(ctx)
+}
+
+// Enqueue schedules a newly committed composition for evaluation
+func (e *StandingQueryEvaluator) Enqueue(ehrID uuid.UUID, compositionID string) {
+	e.worker.Enqueue(CommittedComposition{
+		EHRID:         ehrID,
+		CompositionID: compositionID,
+	})
+}
+
+// evaluate runs every active standing query against a batch of compositions (called by async.Worker)
+func (e *StandingQueryEvaluator) evaluate(ctx context.Context, batch []CommittedComposition) error {
+	standingQueries, err := e.Service.ListStandingQueries(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	for _, standingQuery := range standingQueries {
+		for _, committed := range batch {
+			match, err := e.Service.EvaluateStandingQuery(ctx, standingQuery, committed.CompositionID)
+			if err != nil {
+				// A single broken query should not block the others
+				e.Logger.ErrorContext(ctx, "Failed to evaluate standing query", "standing_query_id", standingQuery.ID, "composition_uid", committed.CompositionID, "error", err)
+				continue
+			}
+
+			if len(match.Rows) == 0 {
+				continue
+			}
+
+			e.WebhookSink.Enqueue(webhook.EventTypeStandingQueryMatched, map[string]any{
+				"standing_query_id": standingQuery.ID,
+				"query_name":        standingQuery.QueryName,
+				"query_version":     standingQuery.QueryVersion,
+				"ehr_id":            committed.EHRID,
+				"composition_uid":   committed.CompositionID,
+				"columns":           match.Columns,
+				"rows":              match.Rows,
+			})
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,408 @@
+package openehr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/freekieb7/gopenehr/internal/database"
+	"github.com/freekieb7/gopenehr/internal/openehr/aql"
+	"github.com/freekieb7/gopenehr/internal/openehr/rm"
+	"github.com/freekieb7/gopenehr/internal/openehr/util"
+	"github.com/freekieb7/gopenehr/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Tables holding the tags of each party type, keyed by the column referring to the tagged version
+var partyTagTables = map[string]struct {
+	versionTable string
+	tagTable     string
+	idColumn     string
+}{
+	rm.PERSON_TYPE:       {"openehr.tbl_person", "openehr.tbl_person_tag", "person_id"},
+	rm.AGENT_TYPE:        {"openehr.tbl_agent", "openehr.tbl_agent_tag", "agent_id"},
+	rm.GROUP_TYPE:        {"openehr.tbl_group", "openehr.tbl_group_tag", "group_id"},
+	rm.ORGANISATION_TYPE: {"openehr.tbl_organisation", "openehr.tbl_organisation_tag", "organisation_id"},
+	rm.ROLE_TYPE:         {"openehr.tbl_role", "openehr.tbl_role_tag", "role_id"},
+}
+
+// validateTagTargets checks the tags are valid, unique by key and all point to the same target and owner
+func validateTagTargets(tags []rm.ITEM_TAG, target, owner string) error {
+	var validateErr util.ValidateError
+
+	keys := make(map[string]bool, len(tags))
+	for i, tag := range tags {
+		path := fmt.Sprintf("$[%d]", i)
+		validateErr.Errs = append(validateErr.Errs, tag.Validate(path).Errs...)
+
+		if keys[tag.Key] {
+			validateErr.Errs = append(validateErr.Errs, util.ValidationError{
+				Model:          rm.ITEM_TAG_TYPE,
+				Path:           path + ".key",
+				Message:        fmt.Sprintf("duplicate tag key %q", tag.Key),
+				Recommendation: "Ensure every tag key is used only once",
+			})
+		}
+		keys[tag.Key] = true
+
+		var targetID string
+		switch tag.Target.Kind {
+		case rm.UID_BASED_ID_kind_HIER_OBJECT_ID:
+			targetID = tag.Target.HIER_OBJECT_ID().Value
+		case rm.UID_BASED_ID_kind_OBJECT_VERSION_ID:
+			targetID = tag.Target.OBJECT_VERSION_ID().Value
+		}
+		if targetID != target {
+			validateErr.Errs = append(validateErr.Errs, util.ValidationError{
+				Model:          rm.ITEM_TAG_TYPE,
+				Path:           path + ".target",
+				Message:        fmt.Sprintf("tag target %q does not match the tagged object %q", targetID, target),
+				Recommendation: "Ensure target is the ID used in the request path",
+			})
+		}
+
+		var ownerID string
+		if tag.OwnerID.ID.Kind == rm.OBJECT_ID_kind_HIER_OBJECT_ID {
+			ownerID = tag.OwnerID.ID.HIER_OBJECT_ID().Value
+		}
+		if ownerID != owner {
+			validateErr.Errs = append(validateErr.Errs, util.ValidationError{
+				Model:          rm.ITEM_TAG_TYPE,
+				Path:           path + ".owner_id",
+				Message:        fmt.Sprintf("tag owner %q does not match the owner %q", ownerID, owner),
+				Recommendation: "Ensure owner_id is a HIER_OBJECT_ID referring to the owner of the tagged object",
+			})
+		}
+	}
+
+	if len(validateErr.Errs) > 0 {
+		return validateErr
+	}
+	return nil
+}
+
+func (s *Service) GetEHRStatusTagsRawJSON(ctx context.Context, ehrID uuid.UUID) ([]byte, error) {
+	query := `
+		SELECT COALESCE(jsonb_agg(data ORDER BY ehr_status_id, key), '[]'::jsonb)
+		FROM openehr.tbl_ehr_status_tag
+		WHERE ehr_id = $1
+	`
+
+	var data []byte
+	if err := s.DB.QueryRow(ctx, query, ehrID).Scan(&data); err != nil {
+		return nil, fmt.Errorf("failed to fetch EHR Status tags from database: %w", err)
+	}
+
+	return data, nil
+}
+
+func (s *Service) GetEHRStatusVersionTagsRawJSON(ctx context.Context, ehrID uuid.UUID, ehrStatusID string) ([]byte, error) {
+	query := `
+		SELECT (SELECT COALESCE(jsonb_agg(t.data ORDER BY t.key), '[]'::jsonb) FROM openehr.tbl_ehr_status_tag t WHERE t.ehr_status_id = es.id)
+		FROM openehr.tbl_ehr_status es
+		WHERE es.ehr_id = $1 AND es.id = $2
+	`
+
+	var data []byte
+	if err := s.DB.QueryRow(ctx, query, ehrID, ehrStatusID).Scan(&data); err != nil {
+		if errors.Is(err, database.ErrNoRows) {
+			return nil, ErrEHRStatusNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch EHR Status version tags from database: %w", err)
+	}
+
+	return data, nil
+}
+
+// ReplaceEHRStatusVersionTags replaces all tags of an EHR_STATUS version, tags are not versioned so no contribution is made
+func (s *Service) ReplaceEHRStatusVersionTags(ctx context.Context, ehrID uuid.UUID, ehrStatusID string, tags []rm.ITEM_TAG) ([]rm.ITEM_TAG, error) {
+	if err := validateTagTargets(tags, ehrStatusID, ehrID.String()); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && rbErr != database.ErrTxClosed {
+			s.Logger.ErrorContext(ctx, "failed to rollback transaction", "error", rbErr)
+		}
+	}()
+
+	err = tx.QueryRow(ctx, `SELECT 1 FROM openehr.tbl_ehr_status WHERE ehr_id = $1 AND id = $2`, ehrID, ehrStatusID).Scan(new(int))
+	if err != nil {
+		if errors.Is(err, database.ErrNoRows) {
+			return nil, ErrEHRStatusNotFound
+		}
+		return nil, fmt.Errorf("failed to check if EHR Status version exists: %w", err)
+	}
+
+	batch := &pgx.Batch{}
+	batch.Queue(`DELETE FROM openehr.tbl_ehr_status_tag WHERE ehr_status_id = $1`, ehrStatusID)
+	for i := range tags {
+		tags[i].SetModelName()
+		batch.Queue(`INSERT INTO openehr.tbl_ehr_status_tag (ehr_status_id, key, data, ehr_id) VALUES ($1, $2, $3, $4)`, ehrStatusID, tags[i].Key, tags[i], ehrID)
+	}
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return nil, fmt.Errorf("failed to replace EHR Status version tags: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return tags, nil
+}
+
+func (s *Service) DeleteEHRStatusVersionTagByKey(ctx context.Context, ehrID uuid.UUID, ehrStatusID string, key string) error {
+	tag, err := s.DB.Exec(ctx, `DELETE FROM openehr.tbl_ehr_status_tag WHERE ehr_id = $1 AND ehr_status_id = $2 AND key = $3`, ehrID, ehrStatusID, key)
+	if err != nil {
+		return fmt.Errorf("failed to delete EHR Status version tag: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTagNotFound
+	}
+
+	return nil
+}
+
+// GetPartyTagsRawJSON returns the tags of a party version, or of the versioned party when a UUID is given
+func (s *Service) GetPartyTagsRawJSON(ctx context.Context, partyType string, uidBasedID string) ([]byte, error) {
+	tables, ok := partyTagTables[partyType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported party type: %s", partyType)
+	}
+
+	var query string
+	var args []any
+	if versionedPartyID, err := uuid.Parse(uidBasedID); err == nil {
+		query = `
+			SELECT (SELECT COALESCE(jsonb_agg(t.data ORDER BY t.key), '[]'::jsonb) FROM openehr.tbl_versioned_party_tag t WHERE t.versioned_party_id = $1)
+			FROM ` + tables.versionTable + `
+			WHERE versioned_party_id = $1
+			LIMIT 1
+		`
+		args = []any{versionedPartyID}
+	} else {
+		query = `
+			SELECT (SELECT COALESCE(jsonb_agg(t.data ORDER BY t.key), '[]'::jsonb) FROM ` + tables.tagTable + ` t WHERE t.` + tables.idColumn + ` = p.id)
+			FROM ` + tables.versionTable + ` p
+			WHERE p.id = $1
+		`
+		args = []any{uidBasedID}
+	}
+
+	var data []byte
+	if err := s.DB.QueryRow(ctx, query, args...).Scan(&data); err != nil {
+		if errors.Is(err, database.ErrNoRows) {
+			return nil, ErrVersionedPartyNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch party tags from database: %w", err)
+	}
+
+	return data, nil
+}
+
+// GetPartyID returns the latest version of a versioned party of the given type
+func (s *Service) GetPartyID(ctx context.Context, partyType string, versionedPartyID uuid.UUID) (rm.OBJECT_VERSION_ID, error) {
+	tables, ok := partyTagTables[partyType]
+	if !ok {
+		return rm.OBJECT_VERSION_ID{}, fmt.Errorf("unsupported party type: %s", partyType)
+	}
+
+	var id string
+	err := s.DB.QueryRow(ctx, `SELECT id FROM `+tables.versionTable+` WHERE versioned_party_id = $1 ORDER BY version_int DESC LIMIT 1`, versionedPartyID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, database.ErrNoRows) {
+			return rm.OBJECT_VERSION_ID{}, ErrVersionedPartyNotFound
+		}
+		return rm.OBJECT_VERSION_ID{}, fmt.Errorf("failed to fetch party ID from database: %w", err)
+	}
+
+	return rm.OBJECT_VERSION_ID{Value: id}, nil
+}
+
+// ReplacePartyTags replaces all tags of a party version, or of the versioned party when a UUID is given.
+// Tags are not versioned so no contribution is made.
+func (s *Service) ReplacePartyTags(ctx context.Context, partyType string, uidBasedID string, tags []rm.ITEM_TAG) ([]rm.ITEM_TAG, error) {
+	tables, ok := partyTagTables[partyType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported party type: %s", partyType)
+	}
+
+	versionedPartyID, err := uuid.Parse(strings.Split(uidBasedID, "::")[0])
+	if err != nil {
+		return nil, ErrVersionedPartyNotFound
+	}
+	isVersioned := !strings.Contains(uidBasedID, "::")
+
+	if err := validateTagTargets(tags, uidBasedID, versionedPartyID.String()); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && rbErr != database.ErrTxClosed {
+			s.Logger.ErrorContext(ctx, "failed to rollback transaction", "error", rbErr)
+		}
+	}()
+
+	batch := &pgx.Batch{}
+	if isVersioned {
+		err = tx.QueryRow(ctx, `SELECT 1 FROM `+tables.versionTable+` WHERE versioned_party_id = $1 LIMIT 1`, versionedPartyID).Scan(new(int))
+		batch.Queue(`DELETE FROM openehr.tbl_versioned_party_tag WHERE versioned_party_id = $1`, versionedPartyID)
+		for i := range tags {
+			tags[i].SetModelName()
+			batch.Queue(`INSERT INTO openehr.tbl_versioned_party_tag (versioned_party_id, key, data) VALUES ($1, $2, $3)`, versionedPartyID, tags[i].Key, tags[i])
+		}
+	} else {
+		err = tx.QueryRow(ctx, `SELECT 1 FROM `+tables.versionTable+` WHERE id = $1`, uidBasedID).Scan(new(int))
+		batch.Queue(`DELETE FROM `+tables.tagTable+` WHERE `+tables.idColumn+` = $1`, uidBasedID)
+		for i := range tags {
+			tags[i].SetModelName()
+			batch.Queue(`INSERT INTO `+tables.tagTable+` (`+tables.idColumn+`, key, data) VALUES ($1, $2, $3)`, uidBasedID, tags[i].Key, tags[i])
+		}
+	}
+	if err != nil {
+		if errors.Is(err, database.ErrNoRows) {
+			return nil, ErrVersionedPartyNotFound
+		}
+		return nil, fmt.Errorf("failed to check if party exists: %w", err)
+	}
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return nil, fmt.Errorf("failed to replace party tags: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return tags, nil
+}
+
+func (s *Service) DeletePartyTagByKey(ctx context.Context, partyType string, uidBasedID string, key string) error {
+	tables, ok := partyTagTables[partyType]
+	if !ok {
+		return fmt.Errorf("unsupported party type: %s", partyType)
+	}
+
+	var query string
+	var args []any
+	if versionedPartyID, err := uuid.Parse(uidBasedID); err == nil {
+		// Only delete tags of versioned parties of the requested type
+		query = `
+			DELETE FROM openehr.tbl_versioned_party_tag
+			WHERE versioned_party_id = $1 AND key = $2
+			  AND EXISTS (SELECT 1 FROM ` + tables.versionTable + ` WHERE versioned_party_id = $1)
+		`
+		args = []any{versionedPartyID, key}
+	} else {
+		query = `DELETE FROM ` + tables.tagTable + ` WHERE ` + tables.idColumn + ` = $1 AND key = $2`
+		args = []any{uidBasedID, key}
+	}
+
+	tag, err := s.DB.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete party tag: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTagNotFound
+	}
+
+	return nil
+}
+
+// GetDemographicTagsRawJSON lists the tags of all parties, optionally filtered on key and value
+func (s *Service) GetDemographicTagsRawJSON(ctx context.Context, key, value string) ([]byte, error) {
+	parts := []string{`SELECT data FROM openehr.tbl_versioned_party_tag`}
+	for _, partyType := range []string{rm.PERSON_TYPE, rm.AGENT_TYPE, rm.GROUP_TYPE, rm.ORGANISATION_TYPE, rm.ROLE_TYPE} {
+		parts = append(parts, `SELECT data FROM `+partyTagTables[partyType].tagTable)
+	}
+
+	query := `
+		SELECT COALESCE(jsonb_agg(tag.data ORDER BY tag.data->>'key', tag.data->'target'->>'value'), '[]'::jsonb)
+		FROM (` + strings.Join(parts, " UNION ALL ") + `) tag
+		WHERE ($1 = '' OR tag.data->>'key' = $1)
+		  AND ($2 = '' OR tag.data->>'value' = $2)
+	`
+
+	var data []byte
+	if err := s.DB.QueryRow(ctx, query, key, value).Scan(&data); err != nil {
+		return nil, fmt.Errorf("failed to fetch demographic tags from database: %w", err)
+	}
+
+	return data, nil
+}
+
+// TagSearch selects tagged versioned objects by tag key, optionally narrowed down on the tag value or a JSONPath condition on the tag
+type TagSearch struct {
+	// EHRID restricts the search to a single EHR, otherwise all EHRs are searched
+	EHRID    utils.Optional[uuid.UUID]
+	Key      string
+	Value    utils.Optional[string]
+	JSONPath utils.Optional[string]
+}
+
+// SearchTagsRawJSON finds the compositions and EHR statuses carrying a matching tag.
+// EHRs hidden from queries or refused by their EHR_ACCESS settings are left out, following the AQL options.
+func (s *Service) SearchTagsRawJSON(ctx context.Context, search TagSearch, options aql.Options) ([]byte, error) {
+	if search.JSONPath.E {
+		if err := s.DB.QueryRow(ctx, `SELECT $1::jsonpath`, search.JSONPath.V).Scan(new(string)); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidTagJSONPath, err)
+		}
+	}
+
+	var subject utils.Optional[string]
+	roles := []string{}
+	if options.EHRAccess.E {
+		subject = utils.Some(options.EHRAccess.V.Subject)
+		roles = options.EHRAccess.V.Roles
+	}
+
+	query := `
+		SELECT COALESCE(jsonb_agg(jsonb_build_object(
+			'ehr_id', t.ehr_id,
+			'type', t.type,
+			'versioned_object_id', t.versioned_object_id,
+			'version_uid', t.version_uid,
+			'tag', t.data
+		) ORDER BY t.ehr_id, t.versioned_object_id, t.version_uid), '[]'::jsonb)
+		FROM (
+			SELECT vct.ehr_id, 'VERSIONED_COMPOSITION' AS type, vct.versioned_composition_id AS versioned_object_id, NULL::TEXT AS version_uid, vct.data
+			FROM openehr.tbl_versioned_composition_tag vct
+			WHERE vct.key = $2
+			UNION ALL
+			SELECT ct.ehr_id, 'COMPOSITION', c.versioned_composition_id, ct.composition_id, ct.data
+			FROM openehr.tbl_composition_tag ct
+			JOIN openehr.tbl_composition c ON c.id = ct.composition_id
+			WHERE ct.key = $2
+			UNION ALL
+			SELECT est.ehr_id, 'EHR_STATUS', es.versioned_ehr_status_id, est.ehr_status_id, est.data
+			FROM openehr.tbl_ehr_status_tag est
+			JOIN openehr.tbl_ehr_status es ON es.id = est.ehr_status_id
+			WHERE est.key = $2
+		) t
+		WHERE ($1::UUID IS NULL OR t.ehr_id = $1)
+		  AND ($3::TEXT IS NULL OR t.data->>'value' = $3)
+		  AND ($4::JSONPATH IS NULL OR jsonb_path_exists(t.data, $4::JSONPATH))
+		  AND NOT openehr.ehr_is_deleted(t.ehr_id)
+		  AND ($5 OR openehr.ehr_is_queryable(t.ehr_id))
+		  AND ($6::TEXT IS NULL OR openehr.ehr_access_allowed(t.ehr_id, $6, $7::TEXT[]))
+	`
+
+	var data []byte
+	err := s.DB.QueryRow(ctx, query, search.EHRID, search.Key, search.Value, search.JSONPath, options.IncludeNonQueryable, subject, roles).Scan(&data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search tags in database: %w", err)
+	}
+
+	return data, nil
+}
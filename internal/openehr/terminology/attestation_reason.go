@@ -5,6 +5,10 @@ package terminology
 // External reference: HL7 ParticipationSignature domain
 // Used in: ATTESTATION.reason
 
+const (
+	ATTESTATION_REASON_TERMINOLOGY_ID_OPENEHR string = "openehr"
+)
+
 // Attestation reason constants
 const (
 	ATTESTATION_CODE_REASON_SIGNED    string = "240"
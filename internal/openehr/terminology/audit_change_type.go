@@ -31,6 +31,12 @@ const (
 	AUDIT_CHANGE_TYPE_CODE_UNKNOWN           AuditChangeType = "253"
 )
 
+// Local audit change types, these are not part of the openEHR terminology
+const (
+	AUDIT_CHANGE_TYPE_TERMINOLOGY_ID_LOCAL AuditChangeType = "local"
+	AUDIT_CHANGE_TYPE_CODE_IMPORT          AuditChangeType = "import"
+)
+
 // AuditChangeTypeNames maps audit change type codes to their human-readable names
 var AuditChangeTypeNames = map[AuditChangeType]string{
 	AUDIT_CHANGE_TYPE_CODE_CREATION:          "creation",
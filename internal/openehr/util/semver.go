@@ -0,0 +1,121 @@
+package util
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Semantic version, see https://semver.org
+	SemVerRegex = regexp.MustCompile(`^(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(?:-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?(?:\+[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?$`)
+	// Partial semantic version, only major or major and minor, e.g. '1' or '1.2'
+	PartialSemVerRegex = regexp.MustCompile(`^(0|[1-9][0-9]*)(?:\.(0|[1-9][0-9]*))?$`)
+)
+
+type SemVer struct {
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease string
+}
+
+func ParseSemVer(version string) (SemVer, bool) {
+	matches := SemVerRegex.FindStringSubmatch(version)
+	if matches == nil {
+		return SemVer{}, false
+	}
+
+	major, _ := strconv.Atoi(matches[1])
+	minor, _ := strconv.Atoi(matches[2])
+	patch, _ := strconv.Atoi(matches[3])
+
+	return SemVer{
+		Major:      major,
+		Minor:      minor,
+		Patch:      patch,
+		PreRelease: matches[4],
+	}, true
+}
+
+func ValidateSemVer(version string) bool {
+	return SemVerRegex.MatchString(version)
+}
+
+// MatchesPartial reports whether the version falls under a partial version, e.g. 1.2.3 matches '1' and '1.2'
+func (v SemVer) MatchesPartial(partial string) bool {
+	matches := PartialSemVerRegex.FindStringSubmatch(partial)
+	if matches == nil {
+		return false
+	}
+
+	major, _ := strconv.Atoi(matches[1])
+	if v.Major != major {
+		return false
+	}
+
+	if matches[2] != "" {
+		minor, _ := strconv.Atoi(matches[2])
+		return v.Minor == minor
+	}
+
+	return true
+}
+
+// Compare returns -1, 0 or 1 following semantic versioning precedence
+func (v SemVer) Compare(other SemVer) int {
+	for _, diff := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		if diff < 0 {
+			return -1
+		}
+		if diff > 0 {
+			return 1
+		}
+	}
+
+	// A pre-release has lower precedence than the release itself
+	switch {
+	case v.PreRelease == other.PreRelease:
+		return 0
+	case v.PreRelease == "":
+		return 1
+	case other.PreRelease == "":
+		return -1
+	}
+
+	left := strings.Split(v.PreRelease, ".")
+	right := strings.Split(other.PreRelease, ".")
+	for i := 0; i < len(left) && i < len(right); i++ {
+		if left[i] == right[i] {
+			continue
+		}
+
+		leftNumber, leftErr := strconv.Atoi(left[i])
+		rightNumber, rightErr := strconv.Atoi(right[i])
+		switch {
+		case leftErr == nil && rightErr == nil:
+			if leftNumber < rightNumber {
+				return -1
+			}
+			return 1
+		case leftErr == nil:
+			// Numeric identifiers have lower precedence than alphanumeric ones
+			return -1
+		case rightErr == nil:
+			return 1
+		case left[i] < right[i]:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	switch {
+	case len(left) < len(right):
+		return -1
+	case len(left) > len(right):
+		return 1
+	default:
+		return 0
+	}
+}
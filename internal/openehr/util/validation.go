@@ -20,6 +20,8 @@ var (
 	VersionTreeIDRegex = regexp.MustCompile(`^([0-9]+)(\.([0-9]+)\.([0-9]+))?$`)
 	// Lexical form: rm_originator '-' rm_name '-' rm_entity '.' concept_name { '-' specialisation }* '.v' number.
 	ArchetypeIDRegex = regexp.MustCompile(`^([a-zA-Z0-9_]+)-([a-zA-Z0-9_]+)-([a-zA-Z0-9_]+)\.([a-zA-Z0-9_]+)(-[a-zA-Z0-9_]+)*\.v([0-9]+)$`)
+	// Lexical form: namespace '::' query_name, e.g. 'org.openehr::compositions'
+	QualifiedQueryNameRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_.-]*::[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
 )
 
 // User friendly validation error structure
@@ -66,6 +68,10 @@ func ValidateUUID(uuid string) bool {
 	return UUIDRegex.MatchString(uuid)
 }
 
+func ValidateQualifiedQueryName(name string) bool {
+	return QualifiedQueryNameRegex.MatchString(name)
+}
+
 func ValidateISOOID(oid string) bool {
 	return ISOOIDRegex.MatchString(oid)
 }
@@ -12,19 +12,28 @@ type Event struct {
 type EventType string
 
 const (
-	EventTypeEHRCreated EventType = "ehr.created"
-	EventTypeEHRDeleted EventType = "ehr.deleted"
+	EventTypeEHRCreated  EventType = "ehr.created"
+	EventTypeEHRDeleted  EventType = "ehr.deleted"
+	EventTypeEHRMerged   EventType = "ehr.merged"
+	EventTypeEHRRestored EventType = "ehr.restored"
+	EventTypeEHRPurged   EventType = "ehr.purged"
+	EventTypeEHRImported EventType = "ehr.imported"
 
 	EventTypeEHRStatusUpdated EventType = "ehr_status.updated"
 
-	EventTypeCompositionCreated EventType = "composition.created"
-	EventTypeCompositionUpdated EventType = "composition.updated"
-	EventTypeCompositionDeleted EventType = "composition.deleted"
+	EventTypeEHRAccessUpdated EventType = "ehr_access.updated"
+
+	EventTypeCompositionCreated  EventType = "composition.created"
+	EventTypeCompositionUpdated  EventType = "composition.updated"
+	EventTypeCompositionDeleted  EventType = "composition.deleted"
+	EventTypeCompositionAttested EventType = "composition.attested"
 
 	EventTypeDirectoryCreated EventType = "directory.created"
 	EventTypeDirectoryUpdated EventType = "directory.updated"
 	EventTypeDirectoryDeleted EventType = "directory.deleted"
 
+	EventTypeAttachmentCreated EventType = "attachment.created"
+
 	EventTypePersonCreated EventType = "person.created"
 	EventTypePersonUpdated EventType = "person.updated"
 	EventTypePersonDeleted EventType = "person.deleted"
@@ -47,14 +56,24 @@ const (
 
 	EventTypeQueryExecuted EventType = "query.executed"
 	EventTypeQueryStored   EventType = "query.stored"
+
+	EventTypeStandingQueryMatched EventType = "standing_query.matched"
+
+	EventTypeReportCompleted EventType = "report.completed"
 )
 
 var EventTypes = map[EventType]string{
 	EventTypeEHRCreated:          "EHR Created",
 	EventTypeEHRDeleted:          "EHR Deleted",
+	EventTypeEHRMerged:           "EHR Merged",
+	EventTypeEHRRestored:         "EHR Restored",
+	EventTypeEHRPurged:           "EHR Purged",
+	EventTypeEHRImported:         "EHR Imported",
 	EventTypeEHRStatusUpdated:    "EHR Status Updated",
+	EventTypeEHRAccessUpdated:    "EHR Access Updated",
 	EventTypeCompositionCreated:  "Composition Created",
 	EventTypeCompositionDeleted:  "Composition Deleted",
+	EventTypeCompositionAttested: "Composition Attested",
 	EventTypePersonCreated:       "Person Created",
 	EventTypePersonUpdated:       "Person Updated",
 	EventTypePersonDeleted:       "Person Deleted",
@@ -72,6 +91,9 @@ var EventTypes = map[EventType]string{
 	EventTypeRoleDeleted:         "Role Deleted",
 	EventTypeQueryExecuted:       "Query Executed",
 	EventTypeQueryStored:         "Query Stored",
+
+	EventTypeStandingQueryMatched: "Standing Query Matched",
+	EventTypeReportCompleted:      "Report Completed",
 }
 
 func IsValidEventType(event EventType) bool {
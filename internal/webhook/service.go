@@ -2,10 +2,12 @@ package webhook
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
+	"github.com/freekieb7/gopenehr/internal/config"
 	"github.com/freekieb7/gopenehr/internal/database"
 	"github.com/freekieb7/gopenehr/internal/telemetry"
 	"github.com/freekieb7/gopenehr/pkg/utils"
@@ -13,7 +15,8 @@ import (
 )
 
 var (
-	ErrInvalidEventType = errors.New("invalid event type")
+	ErrInvalidEventType     = errors.New("invalid event type")
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
 )
 
 type Service struct {
@@ -143,3 +146,50 @@ func (s *Service) Unsubscribe(ctx context.Context, subscriptionID uuid.UUID) err
 	}
 	return nil
 }
+
+// Deliver queues an event for a single subscription, regardless of the event types it subscribed to
+func (s *Service) Deliver(ctx context.Context, subscriptionID uuid.UUID, eventType EventType, payload map[string]any) error {
+	event := Event{
+		Version: "1.0",
+		Source:  "urn:uuid:" + config.SYSTEM_ID_GOPENEHR,
+		Type:    string(eventType),
+		ID:      uuid.NewString(),
+		Time:    time.Now().Format(time.RFC3339),
+		Data:    payload,
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook event: %w", err)
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && err != database.ErrTxClosed {
+			s.Logger.ErrorContext(ctx, "Failed to rollback transaction", "error", err)
+		}
+	}()
+
+	var eventID uuid.UUID
+	if err := tx.QueryRow(ctx, `INSERT INTO webhook.tbl_event (type, payload) VALUES ($1, $2) RETURNING id`, event.Type, data).Scan(&eventID); err != nil {
+		return fmt.Errorf("failed to insert webhook event: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO webhook.tbl_delivery (event_id, subscription_id, attempt_count, status, created_at)
+		SELECT $1, s.id, 0, 'pending', NOW()
+		FROM webhook.tbl_subscription s
+		WHERE s.id = $2 AND s.is_active = TRUE
+	`, eventID, subscriptionID)
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook delivery: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSubscriptionNotFound
+	}
+
+	return tx.Commit(ctx)
+}
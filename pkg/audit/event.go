@@ -27,9 +27,11 @@ const (
 	ResourceEHRStatus                   Resource = "ehr_status"
 	ResourceVersionedEHRStatus          Resource = "versioned_ehr_status"
 	ResourceVersionedEHRStatusVersion   Resource = "versioned_ehr_status_version"
+	ResourceEHRAccess                   Resource = "ehr_access"
 	ResourceComposition                 Resource = "composition"
 	ResourceVersionedComposition        Resource = "versioned_composition"
 	ResourceVersionedCompositionVersion Resource = "versioned_composition_version"
+	ResourceAttestation                 Resource = "attestation"
 	ResourceDirectory                   Resource = "directory"
 	ResourceFolder                      Resource = "folder"
 	ResourceContribution                Resource = "contribution"
@@ -42,10 +44,14 @@ const (
 	ResourceVersionedPartyVersion       Resource = "versioned_party_version"
 	ResourceTemplate                    Resource = "template"
 	ResourceQuery                       Resource = "query"
+	ResourceStandingQuery               Resource = "standing_query"
+	ResourceCohort                      Resource = "cohort"
+	ResourceReport                      Resource = "report"
 	ResourceWebhook                     Resource = "webhook"
 	ResourceItemTag                     Resource = "item_tag"
 	ResourceAudit                       Resource = "audit"
 	ResourceTenant                      Resource = "tenant"
+	ResourceAttachment                  Resource = "attachment"
 )
 
 type Action string
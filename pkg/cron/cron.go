@@ -0,0 +1,185 @@
+package cron
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed five field cron expression (minute hour day-of-month month day-of-week).
+// Every field is a bitset of the values it matches.
+type Schedule struct {
+	Minute     uint64
+	Hour       uint64
+	DayOfMonth uint64
+	Month      uint64
+	DayOfWeek  uint64
+
+	// Like cron, when both day fields are restricted a day matches if either of them does
+	dayOfMonthAny bool
+	dayOfWeekAny  bool
+}
+
+type field struct {
+	name string
+	min  int
+	max  int
+}
+
+var (
+	minuteField     = field{"minute", 0, 59}
+	hourField       = field{"hour", 0, 23}
+	dayOfMonthField = field{"day of month", 1, 31}
+	monthField      = field{"month", 1, 12}
+	dayOfWeekField  = field{"day of week", 0, 7}
+)
+
+var shorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a cron expression such as "*/15 6-18 * * 1-5" or a shorthand such as "@daily"
+func Parse(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if shorthand, ok := shorthands[strings.ToLower(expr)]; ok {
+		expr = shorthand
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return Schedule{}, fmt.Errorf("cron expression must have 5 fields, got %d", len(fields))
+	}
+
+	var schedule Schedule
+	var err error
+	if schedule.Minute, err = parseField(fields[0], minuteField); err != nil {
+		return Schedule{}, err
+	}
+	if schedule.Hour, err = parseField(fields[1], hourField); err != nil {
+		return Schedule{}, err
+	}
+	if schedule.DayOfMonth, err = parseField(fields[2], dayOfMonthField); err != nil {
+		return Schedule{}, err
+	}
+	if schedule.Month, err = parseField(fields[3], monthField); err != nil {
+		return Schedule{}, err
+	}
+	if schedule.DayOfWeek, err = parseField(fields[4], dayOfWeekField); err != nil {
+		return Schedule{}, err
+	}
+
+	// Sunday can be written as both 0 and 7
+	if schedule.DayOfWeek&(1<<7) != 0 {
+		schedule.DayOfWeek |= 1
+	}
+
+	schedule.dayOfMonthAny = fields[2] == "*"
+	schedule.dayOfWeekAny = fields[4] == "*"
+
+	return schedule, nil
+}
+
+func parseField(value string, f field) (uint64, error) {
+	var set uint64
+	for part := range strings.SplitSeq(value, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepPart, f.name)
+			}
+		}
+
+		start, end := f.min, f.max
+		if rangePart != "*" {
+			low, high, isRange := strings.Cut(rangePart, "-")
+
+			var err error
+			start, err = strconv.Atoi(low)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q in %s field", low, f.name)
+			}
+			end = start
+			if isRange {
+				end, err = strconv.Atoi(high)
+				if err != nil {
+					return 0, fmt.Errorf("invalid value %q in %s field", high, f.name)
+				}
+			} else if hasStep {
+				end = f.max
+			}
+		}
+
+		if start < f.min || end > f.max || start > end {
+			return 0, fmt.Errorf("value out of range in %s field, must be between %d and %d", f.name, f.min, f.max)
+		}
+
+		for i := start; i <= end; i += step {
+			set |= 1 << i
+		}
+	}
+
+	return set, nil
+}
+
+func (s Schedule) matchesDay(t time.Time) bool {
+	dayOfMonth := s.DayOfMonth&(1<<t.Day()) != 0
+	dayOfWeek := s.DayOfWeek&(1<<int(t.Weekday())) != 0
+
+	switch {
+	case s.dayOfMonthAny && s.dayOfWeekAny:
+		return true
+	case s.dayOfMonthAny:
+		return dayOfWeek
+	case s.dayOfWeekAny:
+		return dayOfMonth
+	default:
+		return dayOfMonth || dayOfWeek
+	}
+}
+
+// Next returns the first time after t that matches the schedule, or the zero time if there is none within five years
+func (s Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.Month&(1<<int(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.Hour&(1<<t.Hour()) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.Minute&(1<<t.Minute()) == 0 {
+			// Jump straight to the next matching minute within this hour, if any
+			next := s.Minute >> t.Minute()
+			if next == 0 {
+				t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			} else {
+				t = t.Add(time.Duration(bits.TrailingZeros64(next)) * time.Minute)
+			}
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,41 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	from := time.Date(2025, time.January, 31, 10, 7, 30, 0, time.UTC)
+
+	tests := map[string]time.Time{
+		"* * * * *":         time.Date(2025, time.January, 31, 10, 8, 0, 0, time.UTC),
+		"*/15 * * * *":      time.Date(2025, time.January, 31, 10, 15, 0, 0, time.UTC),
+		"0 6 * * *":         time.Date(2025, time.February, 1, 6, 0, 0, 0, time.UTC),
+		"@monthly":          time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC),
+		"0 9 * * 1-5":       time.Date(2025, time.February, 3, 9, 0, 0, 0, time.UTC),
+		"30 2 29 2 *":       time.Date(2028, time.February, 29, 2, 30, 0, 0, time.UTC),
+		"0 0 1 * 7":         time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC),
+		"5,10 10-11 31 1 *": time.Date(2025, time.January, 31, 10, 10, 0, 0, time.UTC),
+	}
+
+	for expr, expected := range tests {
+		schedule, err := Parse(expr)
+		if err != nil {
+			t.Errorf("Parse(%q) returned an error: %v", expr, err)
+			continue
+		}
+
+		if actual := schedule.Next(from); !actual.Equal(expected) {
+			t.Errorf("Parse(%q).Next() = %s, expected %s", expr, actual, expected)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) expected an error", expr)
+		}
+	}
+}
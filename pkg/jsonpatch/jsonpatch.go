@@ -0,0 +1,363 @@
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	MIMEApplicationJSONPatch  = "application/json-patch+json"
+	MIMEApplicationMergePatch = "application/merge-patch+json"
+)
+
+// Operation is a single RFC 6902 JSON Patch operation.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Apply applies an RFC 6902 JSON Patch document to doc. The operations are applied in order
+// and the patch fails as a whole when one of them fails.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var operations []Operation
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return nil, fmt.Errorf("invalid patch document: %w", err)
+	}
+
+	root, err := decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target document: %w", err)
+	}
+
+	for i, op := range operations {
+		if op.Path == nil {
+			return nil, fmt.Errorf("operation %d: missing path", i)
+		}
+		path, err := parsePointer(*op.Path)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("operation %d: missing value", i)
+			}
+			value, err := decode(op.Value)
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: invalid value: %w", i, err)
+			}
+
+			switch op.Op {
+			case "add":
+				root, err = add(root, path, value)
+			case "replace":
+				root, err = replace(root, path, value)
+			case "test":
+				var current any
+				current, err = get(root, path)
+				if err == nil && !reflect.DeepEqual(current, value) {
+					err = fmt.Errorf("test failed at %q", *op.Path)
+				}
+			}
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+		case "remove":
+			if root, _, err = remove(root, path); err != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+		case "move", "copy":
+			if op.From == nil {
+				return nil, fmt.Errorf("operation %d: missing from", i)
+			}
+			from, err := parsePointer(*op.From)
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+
+			var value any
+			if op.Op == "move" {
+				if isPrefix(from, path) && len(from) < len(path) {
+					return nil, fmt.Errorf("operation %d: cannot move %q into one of its children", i, *op.From)
+				}
+				root, value, err = remove(root, from)
+			} else {
+				value, err = get(root, from)
+				if err == nil {
+					// Copies must not share nested maps or slices with the source
+					value, err = clone(value)
+				}
+			}
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+
+			if root, err = add(root, path, value); err != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+		default:
+			return nil, fmt.Errorf("operation %d: unsupported op %q", i, op.Op)
+		}
+	}
+
+	return json.Marshal(root)
+}
+
+// MergePatch applies an RFC 7396 JSON Merge Patch document to doc.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	root, err := decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target document: %w", err)
+	}
+
+	mergePatch, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("invalid patch document: %w", err)
+	}
+
+	return json.Marshal(merge(root, mergePatch))
+}
+
+func merge(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = merge(targetObject[key], value)
+	}
+
+	return targetObject
+}
+
+func decode(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after top-level value")
+	}
+	return v, nil
+}
+
+func clone(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return decode(data)
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return length, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+
+	limit := length - 1
+	if allowEnd {
+		limit = length
+	}
+	if index > limit {
+		return 0, fmt.Errorf("array index %d out of bounds", index)
+	}
+	return index, nil
+}
+
+func get(node any, path []string) (any, error) {
+	for _, token := range path {
+		switch v := node.(type) {
+		case map[string]any:
+			child, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("path member %q not found", token)
+			}
+			node = child
+		case []any:
+			index, err := arrayIndex(token, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			node = v[index]
+		default:
+			return nil, fmt.Errorf("path member %q not found", token)
+		}
+	}
+	return node, nil
+}
+
+// update replaces the value at path with the result of fn, rebuilding the slices on the way up
+// since inserting into or removing from an array changes its header.
+func update(node any, path []string, fn func(parent any, token string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+
+	token := path[0]
+	switch v := node.(type) {
+	case map[string]any:
+		child, ok := v[token]
+		if !ok {
+			return nil, fmt.Errorf("path member %q not found", token)
+		}
+		updated, err := update(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		v[token] = updated
+		return v, nil
+	case []any:
+		index, err := arrayIndex(token, len(v), false)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := update(v[index], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		v[index] = updated
+		return v, nil
+	default:
+		return nil, fmt.Errorf("path member %q not found", token)
+	}
+}
+
+func add(root any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return update(root, path, func(parent any, token string) (any, error) {
+		switch v := parent.(type) {
+		case map[string]any:
+			v[token] = value
+			return v, nil
+		case []any:
+			index, err := arrayIndex(token, len(v), true)
+			if err != nil {
+				return nil, err
+			}
+			v = append(v, nil)
+			copy(v[index+1:], v[index:])
+			v[index] = value
+			return v, nil
+		default:
+			return nil, fmt.Errorf("cannot add member %q to a scalar value", token)
+		}
+	})
+}
+
+func replace(root any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return update(root, path, func(parent any, token string) (any, error) {
+		switch v := parent.(type) {
+		case map[string]any:
+			if _, ok := v[token]; !ok {
+				return nil, fmt.Errorf("path member %q not found", token)
+			}
+			v[token] = value
+			return v, nil
+		case []any:
+			index, err := arrayIndex(token, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			v[index] = value
+			return v, nil
+		default:
+			return nil, fmt.Errorf("path member %q not found", token)
+		}
+	})
+}
+
+func remove(root any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("cannot remove the root of the document")
+	}
+
+	var removed any
+	root, err := update(root, path, func(parent any, token string) (any, error) {
+		switch v := parent.(type) {
+		case map[string]any:
+			value, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("path member %q not found", token)
+			}
+			removed = value
+			delete(v, token)
+			return v, nil
+		case []any:
+			index, err := arrayIndex(token, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = v[index]
+			return append(v[:index], v[index+1:]...), nil
+		default:
+			return nil, fmt.Errorf("path member %q not found", token)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return root, removed, nil
+}
@@ -0,0 +1,109 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func equalJSON(t *testing.T, actual []byte, expected string) {
+	t.Helper()
+
+	var a, e any
+	if err := json.Unmarshal(actual, &a); err != nil {
+		t.Fatalf("Invalid JSON %s: %v", actual, err)
+	}
+	if err := json.Unmarshal([]byte(expected), &e); err != nil {
+		t.Fatalf("Invalid JSON %s: %v", expected, err)
+	}
+	if !reflect.DeepEqual(a, e) {
+		t.Errorf("Expected %s, got %s", expected, actual)
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		doc      string
+		patch    string
+		expected string
+	}{
+		{`{"a":1}`, `[{"op":"add","path":"/b","value":2}]`, `{"a":1,"b":2}`},
+		{`{"a":[1,3]}`, `[{"op":"add","path":"/a/1","value":2}]`, `{"a":[1,2,3]}`},
+		{`{"a":[1]}`, `[{"op":"add","path":"/a/-","value":2}]`, `{"a":[1,2]}`},
+		{`{"a":1,"b":2}`, `[{"op":"remove","path":"/b"}]`, `{"a":1}`},
+		{`{"a":[1,2,3]}`, `[{"op":"remove","path":"/a/0"}]`, `{"a":[2,3]}`},
+		{`{"a":{"b":1}}`, `[{"op":"replace","path":"/a/b","value":"x"}]`, `{"a":{"b":"x"}}`},
+		{`{"a":{"b":1},"c":{}}`, `[{"op":"move","from":"/a/b","path":"/c/d"}]`, `{"a":{},"c":{"d":1}}`},
+		{`{"a":[1,2]}`, `[{"op":"copy","from":"/a","path":"/b"},{"op":"add","path":"/b/-","value":3}]`, `{"a":[1,2],"b":[1,2,3]}`},
+		{`{"a/b":1,"m~n":2}`, `[{"op":"replace","path":"/a~1b","value":3},{"op":"remove","path":"/m~0n"}]`, `{"a/b":3}`},
+		{`{"a":12345678901234567890}`, `[{"op":"test","path":"/a","value":12345678901234567890}]`, `{"a":12345678901234567890}`},
+		{`{"a":1}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`},
+	}
+
+	for _, test := range tests {
+		actual, err := Apply([]byte(test.doc), []byte(test.patch))
+		if err != nil {
+			t.Errorf("Apply(%s, %s) returned an error: %v", test.doc, test.patch, err)
+			continue
+		}
+		equalJSON(t, actual, test.expected)
+	}
+}
+
+func TestApplyInvalid(t *testing.T) {
+	tests := []struct {
+		doc   string
+		patch string
+	}{
+		{`{"a":1}`, `{"op":"add"}`},
+		{`{"a":1}`, `[{"op":"unknown","path":"/a"}]`},
+		{`{"a":1}`, `[{"op":"add","path":"a","value":1}]`},
+		{`{"a":1}`, `[{"op":"add","path":"/b"}]`},
+		{`{"a":1}`, `[{"op":"remove","path":"/b"}]`},
+		{`{"a":1}`, `[{"op":"replace","path":"/b","value":1}]`},
+		{`{"a":[1]}`, `[{"op":"add","path":"/a/2","value":1}]`},
+		{`{"a":[1]}`, `[{"op":"remove","path":"/a/01"}]`},
+		{`{"a":1}`, `[{"op":"test","path":"/a","value":2}]`},
+		{`{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/c"}]`},
+		{`{"a":1}`, `[{"op":"add","path":"/a/b","value":1}]`},
+	}
+
+	for _, test := range tests {
+		if _, err := Apply([]byte(test.doc), []byte(test.patch)); err == nil {
+			t.Errorf("Apply(%s, %s) expected an error", test.doc, test.patch)
+		}
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// Examples from RFC 7396 appendix A
+	tests := []struct {
+		doc      string
+		patch    string
+		expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, test := range tests {
+		actual, err := MergePatch([]byte(test.doc), []byte(test.patch))
+		if err != nil {
+			t.Errorf("MergePatch(%s, %s) returned an error: %v", test.doc, test.patch, err)
+			continue
+		}
+		equalJSON(t, actual, test.expected)
+	}
+}
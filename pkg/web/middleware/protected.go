@@ -2,14 +2,59 @@ package middleware
 
 import (
 	"context"
+	"slices"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
+const PrincipalContextKey string = "principal"
+
 type ValidateTokenFunc func(ctx context.Context, token string) (map[string]any, error)
 
+// Principal is the caller identified by the token, used by handlers that restrict access per record
+type Principal struct {
+	Subject string
+	Roles   []string
+	Scopes  []string
+}
+
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// PrincipalFrom returns the caller of the request, it is absent when the route is not protected by a token
+func PrincipalFrom(c *fiber.Ctx) (Principal, bool) {
+	principal, ok := c.Locals(PrincipalContextKey).(Principal)
+	return principal, ok
+}
+
+// rolesFromClaims reads the roles from the "roles" claim, or from "realm_access.roles" as issued by Keycloak
+func rolesFromClaims(claims map[string]any) []string {
+	raw, ok := claims["roles"]
+	if !ok {
+		if realmAccess, ok := claims["realm_access"].(map[string]any); ok {
+			raw = realmAccess["roles"]
+		}
+	}
+
+	switch v := raw.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		roles := make([]string, 0, len(v))
+		for _, role := range v {
+			if roleStr, ok := role.(string); ok {
+				roles = append(roles, roleStr)
+			}
+		}
+		return roles
+	default:
+		return nil
+	}
+}
+
 func JWTProtected(scopes []string, validate ValidateTokenFunc) func(c *fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
 		if validate == nil {
@@ -57,6 +102,14 @@ func JWTProtected(scopes []string, validate ValidateTokenFunc) func(c *fiber.Ctx
 			c.Locals("tenant_id", tenantID)
 		}
 
+		subject, _ := claims["sub"].(string)
+		scope, _ := claims["scope"].(string)
+		c.Locals(PrincipalContextKey, Principal{
+			Subject: subject,
+			Roles:   rolesFromClaims(claims),
+			Scopes:  strings.Fields(scope),
+		})
+
 		if len(scopes) > 0 {
 			tokenScopesRaw, ok := claims["scope"]
 			if !ok {